@@ -6,10 +6,13 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aquasecurity/tracee/pkg/config"
 	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/events"
 	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
 )
 
 func captureHelp() string {
@@ -30,7 +33,8 @@ clear-dir                                     clear the captured artifacts outpu
 
 Network:
 
-pcap:[single,process,container,command]       capture separate pcap files organized by single file, files per processes, containers and/or commands
+pcap:[single,process,container,command,user]  capture separate pcap files organized by single file, files per processes, containers, commands and/or users (UIDs)
+pcap:connection                               capture a pcap file per TCP connection (named by 5-tuple), from its SYN until FIN, RST or idle (tracks flows)
 pcap-options:[none,filtered]                  network capturing options:
                                               - none (default): pcap files containing all packets (traced/filtered or not)
                                               - filtered: pcap files containing only traced/filtered packets
@@ -41,6 +45,101 @@ pcap-snaplen:[default, headers, max or SIZE]  sets captured payload from each pa
                                               - sizes ended in 'b' or 'kb' (for ipv4, ipv6, tcp, udp):
                                                 256b, 512b, 1kb, 2kb, 4kb, ... (up to requested size)
                                               - max (entire packet)
+                                              - PROTO=SNAPLEN[,...] per protocol: dns, http, tls, tcp, udp, icmp, sctp or default
+                                                (e.g. dns=512b,http=128b,default=96b)
+pcap-no-loopback                              do not capture loopback (127.0.0.0/8, ::1) packets
+pcap-max-payload:SIZE                         absolute max payload captured from each packet (e.g. 64kb), even if snaplen is bigger
+pcap-payload-window:START-END                 keep only the [START, END) byte range of each tcp/udp payload (e.g. 512b-1kb)
+pcap-ring:SIZE                                fixed size pcap files (e.g. 10mb) overwriting their oldest packets when full
+pcap-rotate-size:SIZE                         rotate each pcap file (to a new, timestamp suffixed, file) once bigger than SIZE (e.g. 100mb)
+pcap-rotate-interval:DURATION                 rotate each pcap file (to a new, timestamp suffixed, file) once DURATION (e.g. 1h) elapsed
+pcap-compress:TYPE                            compress pcap files once finalized (rotated or at the end of capture): none (default) or gzip (to FILE.pcap.gz)
+pcap-min-lifetime:DURATION                    remove the pcap file of targets whose packets span less than DURATION (e.g. 5s) once finalized
+pcap-open-hook:COMMAND                        run COMMAND (by the shell, in the background) when a pcap file is opened, given its path and capture target ($1 and $2)
+pcap-close-hook:COMMAND                       run COMMAND (by the shell, in the background) when a pcap file is finalized (rotated or at the end of capture)
+pcap-hook-timeout:DURATION                    kill pcap hook commands running longer than DURATION (default: 30s)
+pcap-rate-packets:N                           max packets per second written to each pcap file (excess is dropped)
+pcap-rate-bytes:SIZE                          max bytes per second written to each pcap file (e.g. 1mb, excess is dropped)
+pcap-container-packets:N                      max packets written to the pcap files of each container (next ones are dropped)
+pcap-container-bytes:SIZE                     max bytes written to the pcap files of each container (e.g. 1gb, next ones are dropped)
+pcap-uid:UID[,UID...]                         only capture packets from processes owned by the given UIDs
+pcap-port:PORT|PRESET[,...]                   only capture packets from or to the given ports or port presets (k8s-control-plane: 6443,2379,2380,10250)
+pcap-filter:EXPRESSION                        only capture packets matching the libpcap style filter expression (e.g. "udp port 53 or tcp port 443")
+pcap-always-port:PORT|PRESET[,...]            always capture packets from or to the given ports or port presets, bypassing packet filters (e.g. 22,3389,445)
+pcap-min-entropy:BITS                         only capture packets whose payload entropy is above BITS per byte (0-8, e.g. 7.5)
+pcap-entropy-port:PORT|PRESET[,...]           only apply pcap-min-entropy to packets from or to the given ports or port presets
+pcap-sample-rate:RATE                         only capture a sample of flows (all of their packets): 1/N (one in N flows) or a fraction (e.g. 0.1)
+pcap-icmp-anomalous[:SIZE]                    only capture ICMP echoes whose payload is bigger than SIZE bytes (default: 56) or not filled like a ping
+pcap-tcp-urgent[:only]                        tag TCP segments with the URG flag set (urgent pointer in packet metadata), or only capture those
+pcap-bad-checksum[:only]                      tag packets with an invalid (IP, TCP, UDP or ICMP) checksum in packet metadata, or only capture those
+pcap-fix-checksums                            recompute IPv4 header, TCP and UDP checksums of captured packets (after length mangling)
+pcap-source:SOURCE[,SOURCE...]                only capture packets from the given sources (eBPF hooks): cgroup_skb_ingress, cgroup_skb_egress or unknown
+pcap-syscall:SYSCALL[,SYSCALL...]             only capture packets originated by the given syscalls (names or ids, e.g. sendto), egress packets only
+pcap-family:FAMILY                            only capture packets of the given address family: both (default), ipv4 or ipv6
+pcap-decap                                    capture the inner packets (IPv4 or IPv6) of GRE and VXLAN tunnels instead of the outer ones
+pcap-vxlan-port:PORT[,...]                    UDP ports of VXLAN tunnels, for pcap-decap (default: 4789)
+pcap-defrag                                   reassemble fragmented IPv4 datagrams, capturing the datagrams instead of their fragments
+pcap-l2-mode:MODE                             how the fake layer 2 header is written before packets: auto (default, per source or detected), prepend or overwrite
+pcap-link-type:TYPE                           link type of pcap files: null (default, BSD loopback header) or ethernet (synthetic Ethernet header)
+pcap-name:TEMPLATE                            name per process and per command pcap files after a Go template (e.g. {{.ContainerName}}_{{.HostProcessID}}_{{.ProcessName}}_{{.Timestamp}}.pcap)
+pcap-asn-db:PATH                              resolve destination ASNs (recorded as packet metadata) using a GeoLite2-ASN CSV file (repeatable)
+pcap-asn-allow:ASN[,ASN...]                   only capture packets to the given destination ASNs (e.g. AS13335)
+pcap-asn-deny:ASN[,ASN...]                    do not capture packets to the given destination ASNs
+pcap-geo-db:DIR                               geolocate external destinations (recorded as packet metadata) using a GeoLite2-City CSV database directory
+pcap-country-allow:CC[,CC...]                 only capture packets to the given destination countries (ISO codes, e.g. US)
+pcap-country-deny:CC[,CC...]                  do not capture packets to the given destination countries (ISO codes)
+pcap-tls-keylog                               embed TLS key log secrets, when available, into pcap files (pcapng decryption secrets blocks)
+pcap-packet-context                           record the process (pid, name and container) of every packet in its metadata
+pcap-journal                                  embed systemd journal entries, when available, into pcap files (pcapng systemd journal export blocks)
+pcap-index                                    maintain an index (pcap/index.jsonl) locating every captured packet by time, 5-tuple and target
+pcap-files-index                              maintain an index (pcap/files.json) of every created pcap file with its container, image and command
+pcap-flows                                    track flows, recording a summary (with TCP RTT estimates) in pcap files when each flow is over
+pcap-max-flows:N                              max flows tracked at once (default: 65536)
+pcap-flow-eviction:[idle,lru]                 flow evicted when too many are tracked: least recently active if idle (default) or anyway (lru)
+pcap-flow-reorder:DURATION                    track flows, taking TCP segments filling sequence gaps within DURATION (e.g. 10ms) as reordered, not retransmitted
+pcap-flow-threshold:SIZE[:N]                  track flows, only writing their packets once they exceed SIZE bytes (e.g. 10mb), backfilling the last N packets withheld
+pcap-flow-handshakes                          track flows, exporting their TCP handshake times as a metric histogram (network_capture_tcp_handshake_seconds)
+pcap-flow-http                                track flows, recording HTTP request and response metadata (method, host, path, status...) in flow summaries
+pcap-flow-id                                  track flows, recording a flow ID (from the 5-tuple and start time, stable across restarts) with packets and flow summaries
+pcap-flow-log[:only]                          track flows, logging each flow (as a CSV row) to pcap/flows.csv when it is over, in addition to (or only, instead of) pcap files
+pcap-flow-zeek                                track flows, logging each flow to pcap/conn.log (Zeek conn.log format) when it is over
+pcap-flow-timeline                            track flows, drawing those of each capture session as an SVG timeline (pcap/timeline-TIMESTAMP.svg)
+pcap-flow-events                              track flows, emitting a summary of each flow to the events stream (net_flow_summary) when it is over
+pcap-ja3                                      record JA3 (ClientHello) and JA3S (ServerHello) fingerprints of TLS hellos in their packet metadata
+pcap-ja3-allow:HASH[,HASH...]                 track flows, only capturing TLS flows with one of the given JA3 or JA3S fingerprints
+pcap-ja3-deny:HASH[,HASH...]                  track flows, not capturing TLS flows from a hello with one of the given JA3 or JA3S fingerprints on
+pcap-events[:only]                            emit each captured packet to the events stream (net_packet_captured), in addition to (or only, instead of) pcap files
+pcap-sink:unix:PATH|tcp:HOST:PORT             stream captured packets (pcapng) to a collector listening at the given unix socket or tcp address, instead of pcap files
+pcap-dry-run[:DURATION]                       count packets and bytes per capture target, logged every DURATION (default: 1m), instead of writing pcap files
+pcap-event-payload:[max or SIZE]              max packet bytes (base64 encoded) carried by each emitted event (default: 256b)
+pcap-dns-events                               emit DNS queries and responses parsed from captured packets to the events stream (net_packet_captured_dns)
+pcap-dns-port:PORT|PRESET[,...]               ports DNS messages are parsed from, for DNS events (default: 53)
+pcap-tls-events                               emit TLS hellos (server name and version) parsed from captured packets to the events stream (net_packet_tls)
+pcap-tls-port:PORT|PRESET[,...]               ports TLS hellos are parsed from, for TLS events (default: 443)
+pcap-http-events                              emit HTTP requests (method, host and path) parsed from captured packets to the events stream (net_packet_captured_http)
+pcap-http-port:PORT|PRESET[,...]              ports HTTP requests are parsed from, for HTTP events (default: 80)
+pcap-dns-dedup:DURATION                       write only the first of identical DNS queries (same name and type) within DURATION (e.g. 10s)
+pcap-protocol-sample                          write only the first packet of each distinct L7 protocol (dns, dhcp, http, tls, ssh...) of each capture target
+pcap-detection-window:DURATION                only capture targets (processes, containers...) from a detection made for them until DURATION (e.g. 5m)
+                                              after the last one (requires signatures)
+pcap-short-lived:DURATION                     only capture packets of processes living less than DURATION (e.g. 5s, buffered until they exit)
+pcap-ring-buffer:SIZE[:DURATION]              hold the last SIZE (e.g. 64mb) of packets in memory, only written when a detection (or the trigger
+                                              control command) dumps them, and for DURATION (e.g. 30s) after it
+pcap-stats:INTERVAL                           write per target stats (packets, bytes, protocol mix, flows) to FILE.pcap.stats.json every INTERVAL (e.g. 30s)
+pcap-sidecar                                  write a compact binary sidecar (FILE.pcap.idx) with the 5-tuple and offset of each packet
+pcap-chain                                    hash chain every block written to each pcap file (checkpoints in FILE.pcap.chain) for tamper-evidence
+pcap-split-direction[:DEFAULT]                write inbound and outbound packets to separate files (FILE.inbound.pcap, FILE.outbound.pcap),
+                                              packets of unknown direction go to FILE.unknown.pcap (or to DEFAULT: inbound or outbound)
+pcap-proto-dir:PROTO=DIR                      write pcap files of the given protocol (dns, dhcp, tcp, udp, icmp or sctp) to DIR instead of the output dir (repeatable)
+pcap-noise-file[:SIZE]                        write broadcast-heavy protocols (netbios, ssdp, mdns, llmnr) to pcap/noise.pcap only,
+                                              a ring file of SIZE (e.g. 1mb) if given, instead of the regular pcap files
+pcap-extract:SIZE                             reassemble TCP streams (up to SIZE each, e.g. 16mb) and extract transferred files (HTTP bodies, FTP transfers)
+pcap-extract-proto:PROTO[,PROTO...]           only reassemble TCP streams of the given protocols (http, ftp), requires pcap-extract
+pcap-control:PATH                             create a FIFO at PATH reading capture control commands (pause, resume, start-session, end-session, trigger)
+pcap-memory-limit:SIZE                        disable memory hungry capture features (one at a time) when heap usage goes above SIZE (e.g. 512mb)
+pcap-degrade-order:feature[,feature...]       order in which capture features are disabled under memory pressure (default: extract,flows,index,dns-dedup)
+pcap-latency-sample:N                         measure processing latency of 1 in N captured packets (default: 0, disabled)
+pcap-lost-log:DURATION                        log the network capture events lost once per DURATION, as a single warning (default: 10s)
 
 File Capture Filters
 Files capture upon read/write can be filtered to catch only specific IO operations.
@@ -68,6 +167,7 @@ Network Examples:
   --capture net --capture pcap-snaplen:headers             | capture network traffic, single pcap file (default), capture headers only
   --capture net --capture pcap-snaplen:default             | capture network traffic, single pcap file (default), capture headers + up to 96 bytes of payload
   --capture network --capture pcap:container,command       | capture network traffic, save pcap files for containers and commands
+  --capture network --capture pcap:user --capture pcap-uid:1000 | capture network traffic of processes owned by UID 1000, save pcap files per user
 
 Network notes worth mentioning:
 
@@ -84,6 +184,7 @@ Network notes worth mentioning:
   - If you specify "max" as snaplen, you will get full packets contents (pcap files will be large).
   - If you specify "headers" as snaplen, you will only get L2/L3 headers in captured packets.
   - If you specify "headers" but trace for net_packet_dns events, L4 DNS header will be captured.
+  - DHCP (v4 and v6) packets are always captured in full, whatever the snaplen is.
   - If you specify "headers" but trace for net_packet_http events, only L2/L3 headers will be captured.
 `
 }
@@ -93,6 +194,7 @@ func PrepareCapture(captureSlice []string, newBinary bool) (config.CaptureConfig
 
 	outDir := "/tmp/tracee"
 	clearDir := false
+	eventPayloadSet := false
 
 	for i := range captureSlice {
 		c := captureSlice[i]
@@ -141,6 +243,13 @@ func PrepareCapture(captureSlice []string, newBinary bool) (config.CaptureConfig
 				if field == "command" {
 					capture.Net.CaptureCommand = true
 				}
+				if field == "user" {
+					capture.Net.CaptureUser = true
+				}
+				if field == "connection" {
+					capture.Net.PerConnection = true
+					capture.Net.Flows = true
+				}
 			}
 			capture.Net.CaptureLength = 96 // default payload
 		} else if strings.HasPrefix(c, "pcap-options:") {
@@ -153,34 +262,563 @@ func PrepareCapture(captureSlice []string, newBinary bool) (config.CaptureConfig
 			}
 		} else if strings.HasPrefix(c, "pcap-snaplen:") {
 			context := strings.TrimPrefix(c, "pcap-snaplen:")
-			var amount uint64
-			var err error
-			context = strings.ToLower(context) // normalize
-			if context == "default" {
-				amount = 96 // default payload
-			} else if context == "max" {
-				amount = (1 << 16) - 1 // max length for IP packets
-			} else if context == "headers" {
-				amount = 0 // sets headers only length for capturing (default)
-			} else if strings.HasSuffix(context, "kb") ||
-				strings.HasSuffix(context, "k") {
-				context = strings.TrimSuffix(context, "kb")
-				context = strings.TrimSuffix(context, "k")
-				amount, err = strconv.ParseUint(context, 10, 64)
-				amount *= 1024 // result in bytes
-			} else if strings.HasSuffix(context, "b") {
-				context = strings.TrimSuffix(context, "b")
-				amount, err = strconv.ParseUint(context, 10, 64)
-			} else {
-				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap snaplen: missing b or kb ?")
-			}
-			if err != nil {
-				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap snaplen: %v", err)
+			if !strings.Contains(context, "=") {
+				context = "default=" + context // of packet length to be captured in bytes
+			}
+			// per protocol: PROTO=SNAPLEN[,PROTO=SNAPLEN...]
+			for _, field := range strings.Split(context, ",") {
+				protocol, snaplen, found := strings.Cut(field, "=")
+				if !found {
+					return config.CaptureConfig{}, errfmt.Errorf("invalid pcap snaplen, expected PROTO=SNAPLEN: %s", field)
+				}
+				amount, err := parseSnaplen(snaplen)
+				if err != nil {
+					return config.CaptureConfig{}, errfmt.WrapError(err)
+				}
+				if strings.ToLower(protocol) == "default" {
+					capture.Net.CaptureLength = amount
+					continue
+				}
+				protocol, err = pcaps.ParseSnaplenProtocol(protocol)
+				if err != nil {
+					return config.CaptureConfig{}, errfmt.WrapError(err)
+				}
+				if capture.Net.CaptureLengths == nil {
+					capture.Net.CaptureLengths = make(map[string]uint32)
+				}
+				capture.Net.CaptureLengths[protocol] = amount
+			}
+		} else if strings.HasPrefix(c, "pcap-max-payload:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-max-payload:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap max payload: %v", err)
 			}
 			if amount >= (1 << 16) {
 				amount = (1 << 16) - 1
 			}
-			capture.Net.CaptureLength = uint32(amount) // of packet length to be captured in bytes
+			capture.Net.PayloadCeiling = uint32(amount)
+		} else if strings.HasPrefix(c, "pcap-payload-window:") {
+			context := strings.TrimPrefix(c, "pcap-payload-window:")
+			bounds := strings.SplitN(context, "-", 2)
+			if len(bounds) != 2 {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap payload window: missing START-END range")
+			}
+			start, err := parseCaptureSize(bounds[0])
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap payload window start: %v", err)
+			}
+			end, err := parseCaptureSize(bounds[1])
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap payload window end: %v", err)
+			}
+			if end >= (1 << 16) {
+				end = (1 << 16) - 1
+			}
+			if start >= end {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap payload window start must be smaller than its end")
+			}
+			capture.Net.PayloadWindowStart = uint32(start)
+			capture.Net.PayloadWindowEnd = uint32(end)
+		} else if c == "pcap-no-loopback" {
+			capture.Net.ExcludeLoopback = true
+		} else if strings.HasPrefix(c, "pcap-ring:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-ring:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap ring size: %v", err)
+			}
+			capture.Net.RingFileSize = amount
+		} else if strings.HasPrefix(c, "pcap-rotate-size:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-rotate-size:"))
+			if err != nil || amount == 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("invalid pcap rotation size: %s", c)
+			}
+			capture.Net.RotateSize = amount
+		} else if strings.HasPrefix(c, "pcap-rotate-interval:") {
+			interval, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-rotate-interval:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap rotation interval: %v", err)
+			}
+			if interval <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap rotation interval must be positive")
+			}
+			capture.Net.RotateInterval = interval
+		} else if strings.HasPrefix(c, "pcap-compress:") {
+			compression, err := pcaps.ParseCompression(strings.TrimPrefix(c, "pcap-compress:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.Compress = compression
+		} else if strings.HasPrefix(c, "pcap-min-lifetime:") {
+			lifetime, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-min-lifetime:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap min lifetime: %v", err)
+			}
+			if lifetime <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap min lifetime must be positive")
+			}
+			capture.Net.MinLifetime = lifetime
+		} else if strings.HasPrefix(c, "pcap-open-hook:") {
+			capture.Net.OpenHook = strings.TrimPrefix(c, "pcap-open-hook:")
+			if capture.Net.OpenHook == "" {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap open hook command cannot be empty")
+			}
+		} else if strings.HasPrefix(c, "pcap-close-hook:") {
+			capture.Net.CloseHook = strings.TrimPrefix(c, "pcap-close-hook:")
+			if capture.Net.CloseHook == "" {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap close hook command cannot be empty")
+			}
+		} else if strings.HasPrefix(c, "pcap-hook-timeout:") {
+			timeout, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-hook-timeout:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap hook timeout: %v", err)
+			}
+			if timeout <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap hook timeout must be positive")
+			}
+			capture.Net.HookTimeout = timeout
+		} else if strings.HasPrefix(c, "pcap-rate-packets:") {
+			amount, err := strconv.ParseUint(strings.TrimPrefix(c, "pcap-rate-packets:"), 10, 64)
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap packets rate: %v", err)
+			}
+			capture.Net.RateLimitPackets = amount
+		} else if strings.HasPrefix(c, "pcap-rate-bytes:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-rate-bytes:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap bytes rate: %v", err)
+			}
+			capture.Net.RateLimitBytes = amount
+		} else if strings.HasPrefix(c, "pcap-container-packets:") {
+			amount, err := strconv.ParseUint(strings.TrimPrefix(c, "pcap-container-packets:"), 10, 64)
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap container packets: %v", err)
+			}
+			capture.Net.PerContainerPacketLimit = amount
+		} else if strings.HasPrefix(c, "pcap-container-bytes:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-container-bytes:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap container bytes: %v", err)
+			}
+			capture.Net.PerContainerByteLimit = amount
+		} else if strings.HasPrefix(c, "pcap-uid:") {
+			context := strings.TrimPrefix(c, "pcap-uid:")
+			for _, field := range strings.Split(context, ",") {
+				uid, err := strconv.ParseUint(field, 10, 32)
+				if err != nil {
+					return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap uid: %v", err)
+				}
+				capture.Net.UidFilter = append(capture.Net.UidFilter, uint32(uid))
+			}
+		} else if strings.HasPrefix(c, "pcap-port:") {
+			ports, err := pcaps.ParsePorts(strings.TrimPrefix(c, "pcap-port:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap port: %v", err)
+			}
+			capture.Net.PortFilter = append(capture.Net.PortFilter, ports...)
+		} else if strings.HasPrefix(c, "pcap-filter:") {
+			filter := strings.TrimPrefix(c, "pcap-filter:")
+			if _, err := pcaps.CompileFilter(filter); err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.Filter = filter
+		} else if strings.HasPrefix(c, "pcap-always-port:") {
+			ports, err := pcaps.ParsePorts(strings.TrimPrefix(c, "pcap-always-port:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap always port: %v", err)
+			}
+			capture.Net.AlwaysPorts = append(capture.Net.AlwaysPorts, ports...)
+		} else if strings.HasPrefix(c, "pcap-min-entropy:") {
+			bits, err := strconv.ParseFloat(strings.TrimPrefix(c, "pcap-min-entropy:"), 64)
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap min entropy: %v", err)
+			}
+			if bits < 0 || bits > 8 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap min entropy must be between 0 and 8 bits per byte: %v", bits)
+			}
+			capture.Net.MinEntropy = bits
+		} else if strings.HasPrefix(c, "pcap-sample-rate:") {
+			rate, err := parseSampleRate(strings.TrimPrefix(c, "pcap-sample-rate:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap sample rate: %v", err)
+			}
+			if rate <= 0 || rate > 1 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap sample rate must be above 0 and up to 1: %v", rate)
+			}
+			capture.Net.SampleRate = rate
+		} else if strings.HasPrefix(c, "pcap-entropy-port:") {
+			ports, err := pcaps.ParsePorts(strings.TrimPrefix(c, "pcap-entropy-port:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap entropy port: %v", err)
+			}
+			capture.Net.EntropyPorts = append(capture.Net.EntropyPorts, ports...)
+		} else if c == "pcap-icmp-anomalous" {
+			capture.Net.ICMPAnomalous = true
+		} else if strings.HasPrefix(c, "pcap-icmp-anomalous:") {
+			size, err := strconv.ParseUint(strings.TrimPrefix(c, "pcap-icmp-anomalous:"), 10, 16)
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap icmp payload size: %v", err)
+			}
+			capture.Net.ICMPAnomalous = true
+			capture.Net.ICMPMaxPayload = uint32(size)
+		} else if c == "pcap-tcp-urgent" {
+			capture.Net.TCPUrgent = true
+		} else if c == "pcap-tcp-urgent:only" {
+			capture.Net.TCPUrgent = true
+			capture.Net.TCPUrgentOnly = true
+		} else if c == "pcap-bad-checksum" {
+			capture.Net.BadChecksum = true
+		} else if c == "pcap-bad-checksum:only" {
+			capture.Net.BadChecksum = true
+			capture.Net.BadChecksumOnly = true
+		} else if c == "pcap-fix-checksums" {
+			capture.Net.FixChecksums = true
+		} else if strings.HasPrefix(c, "pcap-family:") {
+			family, err := pcaps.ParseFamily(strings.TrimPrefix(c, "pcap-family:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.Family = family
+		} else if c == "pcap-decap" {
+			capture.Net.Decap = true
+		} else if strings.HasPrefix(c, "pcap-vxlan-port:") {
+			ports, err := pcaps.ParsePorts(strings.TrimPrefix(c, "pcap-vxlan-port:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap vxlan port: %v", err)
+			}
+			capture.Net.VXLANPorts = append(capture.Net.VXLANPorts, ports...)
+		} else if c == "pcap-defrag" {
+			capture.Net.Defrag = true
+		} else if strings.HasPrefix(c, "pcap-source:") {
+			for _, s := range strings.Split(strings.TrimPrefix(c, "pcap-source:"), ",") {
+				source, err := pcaps.ParseSource(s)
+				if err != nil {
+					return config.CaptureConfig{}, errfmt.WrapError(err)
+				}
+				capture.Net.Sources = append(capture.Net.Sources, source)
+			}
+		} else if strings.HasPrefix(c, "pcap-syscall:") {
+			syscalls, err := parseCaptureSyscalls(strings.TrimPrefix(c, "pcap-syscall:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.Syscalls = append(capture.Net.Syscalls, syscalls...)
+		} else if strings.HasPrefix(c, "pcap-l2-mode:") {
+			mode, err := pcaps.ParseL2Mode(strings.TrimPrefix(c, "pcap-l2-mode:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.L2Mode = mode
+		} else if strings.HasPrefix(c, "pcap-link-type:") {
+			linkType, err := pcaps.ParseLinkType(strings.TrimPrefix(c, "pcap-link-type:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.LinkType = linkType
+		} else if strings.HasPrefix(c, "pcap-name:") {
+			name := strings.TrimPrefix(c, "pcap-name:")
+			if _, err := pcaps.ParseFileNameTemplate(name); err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.FileNameTemplate = name
+		} else if strings.HasPrefix(c, "pcap-asn-db:") {
+			capture.Net.ASNDatabases = append(capture.Net.ASNDatabases, strings.TrimPrefix(c, "pcap-asn-db:"))
+		} else if strings.HasPrefix(c, "pcap-asn-allow:") {
+			asns, err := parseCaptureASNs(strings.TrimPrefix(c, "pcap-asn-allow:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.ASNAllow = append(capture.Net.ASNAllow, asns...)
+		} else if strings.HasPrefix(c, "pcap-asn-deny:") {
+			asns, err := parseCaptureASNs(strings.TrimPrefix(c, "pcap-asn-deny:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.ASNDeny = append(capture.Net.ASNDeny, asns...)
+		} else if strings.HasPrefix(c, "pcap-geo-db:") {
+			capture.Net.GeoDatabase = strings.TrimPrefix(c, "pcap-geo-db:")
+		} else if strings.HasPrefix(c, "pcap-country-allow:") {
+			context := strings.ToUpper(strings.TrimPrefix(c, "pcap-country-allow:"))
+			capture.Net.CountryAllow = append(capture.Net.CountryAllow, strings.Split(context, ",")...)
+		} else if strings.HasPrefix(c, "pcap-country-deny:") {
+			context := strings.ToUpper(strings.TrimPrefix(c, "pcap-country-deny:"))
+			capture.Net.CountryDeny = append(capture.Net.CountryDeny, strings.Split(context, ",")...)
+		} else if c == "pcap-tls-keylog" {
+			capture.Net.TLSKeyLog = true
+		} else if c == "pcap-journal" {
+			capture.Net.Journal = true
+		} else if c == "pcap-packet-context" {
+			capture.Net.PacketContext = true
+		} else if c == "pcap-index" {
+			capture.Net.Index = true
+		} else if c == "pcap-files-index" {
+			capture.Net.FilesIndex = true
+		} else if c == "pcap-flows" {
+			capture.Net.Flows = true
+		} else if strings.HasPrefix(c, "pcap-max-flows:") {
+			amount, err := strconv.ParseUint(strings.TrimPrefix(c, "pcap-max-flows:"), 10, 32)
+			if err != nil || amount == 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("invalid pcap max flows: %s", c)
+			}
+			capture.Net.MaxFlows = uint32(amount)
+		} else if strings.HasPrefix(c, "pcap-flow-eviction:") {
+			policy, err := pcaps.ParseFlowEviction(strings.TrimPrefix(c, "pcap-flow-eviction:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.FlowEviction = policy
+		} else if strings.HasPrefix(c, "pcap-flow-reorder:") {
+			window, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-flow-reorder:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap flow reordering window: %v", err)
+			}
+			if window <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap flow reordering window must be positive")
+			}
+			capture.Net.Flows = true
+			capture.Net.FlowReorderWindow = window
+		} else if c == "pcap-flow-handshakes" {
+			capture.Net.Flows = true
+			capture.Net.FlowHandshakes = true
+		} else if strings.HasPrefix(c, "pcap-flow-threshold:") {
+			threshold, backfill, _ := strings.Cut(strings.TrimPrefix(c, "pcap-flow-threshold:"), ":")
+			amount, err := parseCaptureSize(threshold)
+			if err != nil || amount == 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("invalid pcap flow byte threshold: %s", c)
+			}
+			if backfill != "" {
+				packets, err := strconv.ParseUint(backfill, 10, 32)
+				if err != nil {
+					return config.CaptureConfig{}, errfmt.Errorf("invalid pcap flow backfill: %s", c)
+				}
+				capture.Net.FlowBackfill = uint32(packets)
+			}
+			capture.Net.Flows = true
+			capture.Net.FlowByteThreshold = amount
+		} else if c == "pcap-flow-http" {
+			capture.Net.Flows = true
+			capture.Net.FlowHTTP = true
+		} else if c == "pcap-flow-id" {
+			capture.Net.Flows = true
+			capture.Net.FlowIDs = true
+		} else if c == "pcap-flow-log" {
+			capture.Net.Flows = true
+			capture.Net.FlowLog = true
+		} else if c == "pcap-flow-log:only" {
+			capture.Net.Flows = true
+			capture.Net.FlowLog = true
+			capture.Net.FlowLogOnly = true
+		} else if c == "pcap-flow-zeek" {
+			capture.Net.Flows = true
+			capture.Net.FlowZeekLog = true
+		} else if c == "pcap-flow-timeline" {
+			capture.Net.Flows = true
+			capture.Net.FlowTimeline = true
+		} else if c == "pcap-flow-events" {
+			capture.Net.Flows = true
+			capture.Net.FlowEvents = true
+		} else if c == "pcap-ja3" {
+			capture.Net.JA3 = true
+		} else if strings.HasPrefix(c, "pcap-ja3-allow:") {
+			fingerprints, err := pcaps.ParseFingerprints(strings.TrimPrefix(c, "pcap-ja3-allow:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.Flows = true
+			capture.Net.JA3Allow = append(capture.Net.JA3Allow, fingerprints...)
+		} else if strings.HasPrefix(c, "pcap-ja3-deny:") {
+			fingerprints, err := pcaps.ParseFingerprints(strings.TrimPrefix(c, "pcap-ja3-deny:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.Flows = true
+			capture.Net.JA3Deny = append(capture.Net.JA3Deny, fingerprints...)
+		} else if c == "pcap-events" {
+			capture.Net.Events = true
+		} else if c == "pcap-events:only" {
+			capture.Net.Events = true
+			capture.Net.EventsOnly = true
+		} else if strings.HasPrefix(c, "pcap-sink:") {
+			network, address, err := pcaps.ParseSink(strings.TrimPrefix(c, "pcap-sink:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.SinkNetwork = network
+			capture.Net.SinkAddress = address
+		} else if c == "pcap-dry-run" {
+			capture.Net.DryRun = true
+		} else if strings.HasPrefix(c, "pcap-dry-run:") {
+			interval, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-dry-run:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap dry run interval: %v", err)
+			}
+			if interval <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap dry run interval must be positive")
+			}
+			capture.Net.DryRun = true
+			capture.Net.DryRunInterval = interval
+		} else if c == "pcap-dns-events" {
+			capture.Net.DNSEvents = true
+		} else if strings.HasPrefix(c, "pcap-dns-port:") {
+			ports, err := pcaps.ParsePorts(strings.TrimPrefix(c, "pcap-dns-port:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap dns port: %v", err)
+			}
+			capture.Net.DNSPorts = append(capture.Net.DNSPorts, ports...)
+		} else if c == "pcap-tls-events" {
+			capture.Net.TLSEvents = true
+		} else if strings.HasPrefix(c, "pcap-tls-port:") {
+			ports, err := pcaps.ParsePorts(strings.TrimPrefix(c, "pcap-tls-port:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap tls port: %v", err)
+			}
+			capture.Net.TLSPorts = append(capture.Net.TLSPorts, ports...)
+		} else if c == "pcap-http-events" {
+			capture.Net.HTTPEvents = true
+		} else if strings.HasPrefix(c, "pcap-http-port:") {
+			ports, err := pcaps.ParsePorts(strings.TrimPrefix(c, "pcap-http-port:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap http port: %v", err)
+			}
+			capture.Net.HTTPPorts = append(capture.Net.HTTPPorts, ports...)
+		} else if strings.HasPrefix(c, "pcap-event-payload:") {
+			context := strings.TrimPrefix(c, "pcap-event-payload:")
+			amount := uint64(0) // max: unlimited
+			if context != "max" {
+				var err error
+				amount, err = parseCaptureSize(context)
+				if err != nil {
+					return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap event payload: %v", err)
+				}
+				if amount >= (1 << 16) {
+					amount = (1 << 16) - 1
+				}
+			}
+			capture.Net.EventPayloadSize = uint32(amount)
+			eventPayloadSet = true
+		} else if c == "pcap-sidecar" {
+			capture.Net.Sidecar = true
+		} else if c == "pcap-chain" {
+			capture.Net.Chain = true
+		} else if c == "pcap-split-direction" {
+			capture.Net.SplitDirection = true
+		} else if strings.HasPrefix(c, "pcap-split-direction:") {
+			direction, err := pcaps.ParseDirection(strings.TrimPrefix(c, "pcap-split-direction:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.WrapError(err)
+			}
+			capture.Net.SplitDirection = true
+			capture.Net.UnknownDirection = direction
+		} else if strings.HasPrefix(c, "pcap-proto-dir:") {
+			protocol, dir, found := strings.Cut(strings.TrimPrefix(c, "pcap-proto-dir:"), "=")
+			if !found || protocol == "" || dir == "" {
+				return config.CaptureConfig{}, errfmt.Errorf("invalid pcap protocol dir, expected PROTO=DIR: %s", c)
+			}
+			if capture.Net.ProtocolDirs == nil {
+				capture.Net.ProtocolDirs = make(map[string]string)
+			}
+			capture.Net.ProtocolDirs[strings.ToLower(protocol)] = dir
+		} else if c == "pcap-noise-file" {
+			capture.Net.NoiseFile = true
+		} else if strings.HasPrefix(c, "pcap-noise-file:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-noise-file:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap noise file size: %v", err)
+			}
+			capture.Net.NoiseFile = true
+			capture.Net.NoiseFileSize = amount
+		} else if strings.HasPrefix(c, "pcap-extract:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-extract:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap extract stream size: %v", err)
+			}
+			capture.Net.ExtractMaxStream = amount
+		} else if strings.HasPrefix(c, "pcap-extract-proto:") {
+			for _, p := range strings.Split(strings.TrimPrefix(c, "pcap-extract-proto:"), ",") {
+				protocol, err := pcaps.ParseExtractProtocol(p)
+				if err != nil {
+					return config.CaptureConfig{}, errfmt.WrapError(err)
+				}
+				capture.Net.ExtractProtocols = append(capture.Net.ExtractProtocols, protocol)
+			}
+		} else if strings.HasPrefix(c, "pcap-control:") {
+			capture.Net.ControlFIFO = strings.TrimPrefix(c, "pcap-control:")
+			if capture.Net.ControlFIFO == "" {
+				return config.CaptureConfig{}, errfmt.Errorf("capture control fifo path cannot be empty")
+			}
+		} else if strings.HasPrefix(c, "pcap-dns-dedup:") {
+			window, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-dns-dedup:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap dns dedup window: %v", err)
+			}
+			capture.Net.DNSDedupWindow = window
+		} else if c == "pcap-protocol-sample" {
+			capture.Net.ProtocolSample = true
+		} else if strings.HasPrefix(c, "pcap-stats:") {
+			interval, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-stats:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap stats interval: %v", err)
+			}
+			if interval <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap stats interval must be positive")
+			}
+			capture.Net.StatsInterval = interval
+		} else if strings.HasPrefix(c, "pcap-detection-window:") {
+			window, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-detection-window:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap detection window: %v", err)
+			}
+			if window <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap detection window must be positive")
+			}
+			capture.Net.DetectionWindow = window
+		} else if strings.HasPrefix(c, "pcap-short-lived:") {
+			threshold, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-short-lived:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap short-lived threshold: %v", err)
+			}
+			if threshold <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap short-lived threshold must be positive")
+			}
+			capture.Net.ShortLived = threshold
+		} else if strings.HasPrefix(c, "pcap-ring-buffer:") {
+			size, after, _ := strings.Cut(strings.TrimPrefix(c, "pcap-ring-buffer:"), ":")
+			amount, err := parseCaptureSize(size)
+			if err != nil || amount == 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("invalid pcap ring buffer size: %s", c)
+			}
+			if after != "" {
+				duration, err := time.ParseDuration(after)
+				if err != nil || duration <= 0 {
+					return config.CaptureConfig{}, errfmt.Errorf("invalid pcap ring buffer duration: %s", c)
+				}
+				capture.Net.RingBufferAfter = duration
+			}
+			capture.Net.RingBuffer = amount
+		} else if strings.HasPrefix(c, "pcap-memory-limit:") {
+			amount, err := parseCaptureSize(strings.TrimPrefix(c, "pcap-memory-limit:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap memory limit: %v", err)
+			}
+			capture.Net.MemoryThreshold = amount
+		} else if strings.HasPrefix(c, "pcap-degrade-order:") {
+			context := strings.TrimPrefix(c, "pcap-degrade-order:")
+			capture.Net.DegradeOrder = strings.Split(context, ",")
+		} else if strings.HasPrefix(c, "pcap-latency-sample:") {
+			context := strings.TrimPrefix(c, "pcap-latency-sample:")
+			amount, err := strconv.ParseUint(context, 10, 32)
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap latency sample: %v", err)
+			}
+			capture.Net.LatencySampling = uint32(amount)
+		} else if strings.HasPrefix(c, "pcap-lost-log:") {
+			interval, err := time.ParseDuration(strings.TrimPrefix(c, "pcap-lost-log:"))
+			if err != nil {
+				return config.CaptureConfig{}, errfmt.Errorf("could not parse pcap lost log interval: %v", err)
+			}
+			if interval <= 0 {
+				return config.CaptureConfig{}, errfmt.Errorf("pcap lost log interval must be positive")
+			}
+			capture.Net.LostLogInterval = interval
 		} else if c == "clear-dir" {
 			clearDir = true
 		} else if strings.HasPrefix(c, "dir:") {
@@ -197,6 +835,16 @@ func PrepareCapture(captureSlice []string, newBinary bool) (config.CaptureConfig
 		}
 	}
 
+	// emitted events carry the first 256 bytes of each packet by default
+	if capture.Net.Events && !eventPayloadSet {
+		capture.Net.EventPayloadSize = 256
+	}
+
+	// the payload window is cut from the captured payload: capture up to its end
+	if capture.Net.PayloadWindowEnd > capture.Net.CaptureLength {
+		capture.Net.CaptureLength = capture.Net.PayloadWindowEnd
+	}
+
 	capture.OutputPath = filepath.Join(outDir, "out")
 	if !clearDir {
 		return capture, nil
@@ -209,6 +857,91 @@ func PrepareCapture(captureSlice []string, newBinary bool) (config.CaptureConfig
 	return capture, nil
 }
 
+// parseSnaplen parses a snaplen (length captured after the last known header):
+// default, max, headers or a size in bytes (b) or kilobytes (kb).
+func parseSnaplen(snaplen string) (uint32, error) {
+	var amount uint64
+	var err error
+
+	snaplen = strings.ToLower(snaplen) // normalize
+	if snaplen == "default" {
+		amount = 96 // default payload
+	} else if snaplen == "max" {
+		amount = (1 << 16) - 1 // max length for IP packets
+	} else if snaplen == "headers" {
+		amount = 0 // sets headers only length for capturing (default)
+	} else if strings.HasSuffix(snaplen, "kb") ||
+		strings.HasSuffix(snaplen, "k") {
+		snaplen = strings.TrimSuffix(snaplen, "kb")
+		snaplen = strings.TrimSuffix(snaplen, "k")
+		amount, err = strconv.ParseUint(snaplen, 10, 64)
+		amount *= 1024 // result in bytes
+	} else if strings.HasSuffix(snaplen, "b") {
+		snaplen = strings.TrimSuffix(snaplen, "b")
+		amount, err = strconv.ParseUint(snaplen, 10, 64)
+	} else {
+		return 0, errfmt.Errorf("could not parse pcap snaplen: missing b or kb ?")
+	}
+	if err != nil {
+		return 0, errfmt.Errorf("could not parse pcap snaplen: %v", err)
+	}
+	if amount >= (1 << 16) {
+		amount = (1 << 16) - 1
+	}
+
+	return uint32(amount), nil
+}
+
+// parseCaptureSize parses a size given in bytes (b), kilobytes (kb), megabytes
+// (mb) or gigabytes (gb) and returns it in bytes.
+func parseCaptureSize(size string) (uint64, error) {
+	size = strings.ToLower(size) // normalize
+
+	multiplier := uint64(1)
+	switch {
+	case strings.HasSuffix(size, "gb"):
+		multiplier = 1 << 30
+	case strings.HasSuffix(size, "mb"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(size, "kb"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(size, "b"):
+	default:
+		return 0, errfmt.Errorf("missing b, kb, mb or gb suffix")
+	}
+	size = strings.TrimRight(size, "gmkb")
+
+	amount, err := strconv.ParseUint(size, 10, 64)
+	if err != nil {
+		return 0, errfmt.WrapError(err)
+	}
+
+	return amount * multiplier, nil
+}
+
+// parseSampleRate parses a sample rate given as one in N (1/N) or as a fraction
+// (e.g. 0.1) and returns it as a fraction.
+func parseSampleRate(rate string) (float64, error) {
+	if n, ok := strings.CutPrefix(rate, "1/"); ok {
+		amount, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return 0, errfmt.WrapError(err)
+		}
+		if amount == 0 {
+			return 0, errfmt.Errorf("one in zero flows")
+		}
+
+		return 1 / float64(amount), nil
+	}
+
+	fraction, err := strconv.ParseFloat(rate, 64)
+	if err != nil {
+		return 0, errfmt.WrapError(err)
+	}
+
+	return fraction, nil
+}
+
 // parseFileCaptureOption parse file capture cmdline argument option of all supported formats.
 func parseFileCaptureOption(arg string, cap string, captureConfig *config.FileCaptureConfig) error {
 	captureConfig.Capture = true
@@ -296,3 +1029,38 @@ func parseFileCaptureFDs(filter string) (config.FileCaptureType, error) {
 	}
 	return 0, fmt.Errorf("unsupported file FD filter value for capture - %s", filter)
 }
+
+// parseCaptureSyscalls parses a comma separated list of syscalls, given by name
+// or id (e.g. sendto,connect or 44), and returns their names.
+func parseCaptureSyscalls(list string) ([]string, error) {
+	var syscalls []string
+
+	for _, field := range strings.Split(list, ",") {
+		id, ok := events.Core.GetDefinitionIDByName(field)
+		if number, err := strconv.Atoi(field); err == nil {
+			id, ok = events.ID(number), events.Core.IsDefined(events.ID(number))
+		}
+		if !ok || !events.Core.GetDefinitionByID(id).IsSyscall() {
+			return nil, errfmt.Errorf("invalid pcap syscall: %s", field)
+		}
+		syscalls = append(syscalls, events.Core.GetDefinitionByID(id).GetName())
+	}
+
+	return syscalls, nil
+}
+
+// parseCaptureASNs parses a comma separated list of ASNs (e.g. AS13335,15169).
+func parseCaptureASNs(list string) ([]uint32, error) {
+	var asns []uint32
+
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimPrefix(strings.ToUpper(field), "AS")
+		asn, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, errfmt.Errorf("could not parse pcap asn: %v", err)
+		}
+		asns = append(asns, uint32(asn))
+	}
+
+	return asns, nil
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/events"
 )
 
 func TestPrepareCapture(t *testing.T) {
@@ -150,6 +152,1250 @@ func TestPrepareCapture(t *testing.T) {
 					},
 				},
 			},
+			{
+				testName:     "capture network with latency sampling",
+				captureSlice: []string{"network", "pcap-latency-sample:100"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:   true,
+						CaptureLength:   96,
+						LatencySampling: 100,
+					},
+				},
+			},
+			{
+				testName:     "capture network per user with uid filter",
+				captureSlice: []string{"network", "pcap:user", "pcap-uid:1000,1001"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureUser:   true,
+						CaptureLength: 96,
+						UidFilter:     []uint32{1000, 1001},
+					},
+				},
+			},
+			{
+				testName:     "capture network per connection",
+				captureSlice: []string{"network", "pcap:single,connection"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						PerConnection: true,
+						Flows:         true,
+						CaptureLength: 96,
+					},
+				},
+			},
+			{
+				testName:     "capture network with packet index",
+				captureSlice: []string{"network", "pcap-index"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Index:         true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with memory limit",
+				captureSlice: []string{"network", "pcap-index", "pcap-memory-limit:512mb", "pcap-degrade-order:index"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:   true,
+						CaptureLength:   96,
+						Index:           true,
+						MemoryThreshold: 512 * 1024 * 1024,
+						DegradeOrder:    []string{"index"},
+					},
+				},
+			},
+			{
+				testName:     "capture network with max payload",
+				captureSlice: []string{"network", "pcap-snaplen:max", "pcap-max-payload:1kb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  (1 << 16) - 1,
+						PayloadCeiling: 1024,
+					},
+				},
+			},
+			{
+				testName:     "capture network with tls key log",
+				captureSlice: []string{"network", "pcap-tls-keylog"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						TLSKeyLog:     true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with rate limits",
+				captureSlice: []string{"network", "pcap-rate-packets:100", "pcap-rate-bytes:1mb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    96,
+						RateLimitPackets: 100,
+						RateLimitBytes:   1 << 20,
+					},
+				},
+			},
+			{
+				testName:     "capture network with dns dedup",
+				captureSlice: []string{"network", "pcap-dns-dedup:10s"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  96,
+						DNSDedupWindow: 10 * time.Second,
+					},
+				},
+			},
+			{
+				testName:     "capture network with ring files",
+				captureSlice: []string{"network", "pcap-ring:10mb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						RingFileSize:  10 << 20,
+					},
+				},
+			},
+			{
+				testName:     "capture network with asn filters",
+				captureSlice: []string{"network", "pcap-asn-db:/tmp/asn.csv", "pcap-asn-allow:AS13335,15169", "pcap-asn-deny:as64496"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						ASNDatabases:  []string{"/tmp/asn.csv"},
+						ASNAllow:      []uint32{13335, 15169},
+						ASNDeny:       []uint32{64496},
+					},
+				},
+			},
+			{
+				testName:     "capture network with country filters",
+				captureSlice: []string{"network", "pcap-geo-db:/tmp/geo", "pcap-country-allow:us,DE", "pcap-country-deny:cn"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						GeoDatabase:   "/tmp/geo",
+						CountryAllow:  []string{"US", "DE"},
+						CountryDeny:   []string{"CN"},
+					},
+				},
+			},
+			{
+				testName:     "capture network without loopback",
+				captureSlice: []string{"network", "pcap-no-loopback"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:   true,
+						CaptureLength:   96,
+						ExcludeLoopback: true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with sidecar",
+				captureSlice: []string{"network", "pcap-sidecar"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Sidecar:       true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with control fifo",
+				captureSlice: []string{"network", "pcap-control:/tmp/tracee/capture.ctl"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						ControlFIFO:   "/tmp/tracee/capture.ctl",
+					},
+				},
+			},
+			{
+				testName:     "capture network with protocol dirs",
+				captureSlice: []string{"network", "pcap-proto-dir:DNS=/data/dns", "pcap-proto-dir:tcp=/scratch/tcp"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						ProtocolDirs: map[string]string{
+							"dns": "/data/dns",
+							"tcp": "/scratch/tcp",
+						},
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid protocol dir",
+				captureSlice:    []string{"network", "pcap-proto-dir:dns"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap protocol dir, expected PROTO=DIR: pcap-proto-dir:dns"),
+			},
+			{
+				testName:     "capture network with file extraction",
+				captureSlice: []string{"network", "pcap-snaplen:max", "pcap-extract:16mb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    (1 << 16) - 1,
+						ExtractMaxStream: 16 * 1024 * 1024,
+					},
+				},
+			},
+			{
+				testName:     "capture network with port preset",
+				captureSlice: []string{"network", "pcap-port:k8s-control-plane,53"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						PortFilter:    []uint16{6443, 2379, 2380, 10250, 53},
+					},
+				},
+			},
+			{
+				testName:        "capture network with unknown port preset",
+				captureSlice:    []string{"network", "pcap-port:k8s"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("could not parse pcap port"),
+			},
+			{
+				testName:     "capture network with flows",
+				captureSlice: []string{"network", "pcap-flows"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with min entropy scoped to ports",
+				captureSlice: []string{"network", "pcap-min-entropy:7.5", "pcap-entropy-port:443,8443"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						MinEntropy:    7.5,
+						EntropyPorts:  []uint16{443, 8443},
+					},
+				},
+			},
+			{
+				testName:        "capture network with out of range min entropy",
+				captureSlice:    []string{"network", "pcap-min-entropy:9"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("pcap min entropy must be between 0 and 8 bits per byte: 9"),
+			},
+			{
+				testName:     "capture network with hash chain",
+				captureSlice: []string{"network", "pcap-chain"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Chain:         true,
+					},
+				},
+			},
+			{
+				testName:     "capture network split by direction",
+				captureSlice: []string{"network", "pcap-split-direction"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  96,
+						SplitDirection: true,
+					},
+				},
+			},
+			{
+				testName:     "capture network split by direction with unknown default",
+				captureSlice: []string{"network", "pcap-split-direction:Outbound"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    96,
+						SplitDirection:   true,
+						UnknownDirection: "outbound",
+					},
+				},
+			},
+			{
+				testName:        "capture network split by invalid direction",
+				captureSlice:    []string{"network", "pcap-split-direction:sideways"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap direction (inbound, outbound or unknown): sideways"),
+			},
+			{
+				testName:     "capture network anomalous icmp",
+				captureSlice: []string{"network", "pcap-icmp-anomalous"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						ICMPAnomalous: true,
+					},
+				},
+			},
+			{
+				testName:     "capture network anomalous icmp with size",
+				captureSlice: []string{"network", "pcap-icmp-anomalous:64"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  96,
+						ICMPAnomalous:  true,
+						ICMPMaxPayload: 64,
+					},
+				},
+			},
+			{
+				testName:     "capture network noise file",
+				captureSlice: []string{"network", "pcap-noise-file"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						NoiseFile:     true,
+					},
+				},
+			},
+			{
+				testName:     "capture network noise file with size",
+				captureSlice: []string{"network", "pcap-noise-file:1mb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						NoiseFile:     true,
+						NoiseFileSize: 1024 * 1024,
+					},
+				},
+			},
+			{
+				testName:     "capture network sources",
+				captureSlice: []string{"network", "pcap-source:cgroup_skb_egress,Unknown"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Sources:       []string{"cgroup_skb_egress", "unknown"},
+					},
+				},
+			},
+			{
+				testName:        "capture network invalid source",
+				captureSlice:    []string{"network", "pcap-source:kprobe"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap source (cgroup_skb_ingress, cgroup_skb_egress or unknown): kprobe"),
+			},
+			{
+				testName:     "capture network tcp urgent",
+				captureSlice: []string{"network", "pcap-tcp-urgent"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						TCPUrgent:     true,
+					},
+				},
+			},
+			{
+				testName:     "capture network tcp urgent only",
+				captureSlice: []string{"network", "pcap-tcp-urgent:only"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						TCPUrgent:     true,
+						TCPUrgentOnly: true,
+					},
+				},
+			},
+			{
+				testName:     "capture network flow log",
+				captureSlice: []string{"network", "pcap-flow-log"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						FlowLog:       true,
+					},
+				},
+			},
+			{
+				testName:     "capture network flow log only",
+				captureSlice: []string{"network", "pcap-flow-log:only"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						FlowLog:       true,
+						FlowLogOnly:   true,
+					},
+				},
+			},
+			{
+				testName:     "capture network max flows",
+				captureSlice: []string{"network", "pcap-flows", "pcap-max-flows:1024", "pcap-flow-eviction:LRU"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						MaxFlows:      1024,
+						FlowEviction:  "lru",
+					},
+				},
+			},
+			{
+				testName:        "capture network invalid flow eviction",
+				captureSlice:    []string{"network", "pcap-flow-eviction:random"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap flow eviction policy (idle or lru): random"),
+			},
+			{
+				testName:     "capture network flow http",
+				captureSlice: []string{"network", "pcap-flow-http"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						FlowHTTP:      true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with payload window",
+				captureSlice: []string{"network", "pcap-payload-window:512b-1kb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:      true,
+						CaptureLength:      1024,
+						PayloadWindowStart: 512,
+						PayloadWindowEnd:   1024,
+					},
+				},
+			},
+			{
+				testName:     "capture network with payload window inside snaplen",
+				captureSlice: []string{"network", "pcap-snaplen:max", "pcap-payload-window:0b-64b"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    (1 << 16) - 1,
+						PayloadWindowEnd: 64,
+					},
+				},
+			},
+			{
+				testName:        "capture network with empty payload window",
+				captureSlice:    []string{"network", "pcap-payload-window:1kb-512b"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("pcap payload window start must be smaller than its end"),
+			},
+			{
+				testName:     "capture network with events",
+				captureSlice: []string{"network", "pcap-events"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    96,
+						Events:           true,
+						EventPayloadSize: 256,
+					},
+				},
+			},
+			{
+				testName:     "capture network with events only and unlimited payload",
+				captureSlice: []string{"network", "pcap-events:only", "pcap-event-payload:max"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Events:        true,
+						EventsOnly:    true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with events payload size",
+				captureSlice: []string{"network", "pcap-event-payload:1kb", "pcap-events"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    96,
+						Events:           true,
+						EventPayloadSize: 1024,
+					},
+				},
+			},
+			{
+				testName:     "capture network with l2 mode",
+				captureSlice: []string{"network", "pcap-l2-mode:Prepend"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						L2Mode:        "prepend",
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid l2 mode",
+				captureSlice:    []string{"network", "pcap-l2-mode:swap"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap l2 mode (auto, prepend or overwrite): swap"),
+			},
+			{
+				testName:     "capture network with detection window",
+				captureSlice: []string{"network", "pcap-detection-window:5m"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:   true,
+						CaptureLength:   96,
+						DetectionWindow: 5 * time.Minute,
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid detection window",
+				captureSlice:    []string{"network", "pcap-detection-window:0s"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("pcap detection window must be positive"),
+			},
+			{
+				testName:     "capture network with target stats",
+				captureSlice: []string{"network", "pcap-stats:30s"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						StatsInterval: 30 * time.Second,
+					},
+				},
+			},
+			{
+				testName:     "capture network flow reordering window",
+				captureSlice: []string{"network", "pcap-flow-reorder:10ms"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:     true,
+						CaptureLength:     96,
+						Flows:             true,
+						FlowReorderWindow: 10 * time.Millisecond,
+					},
+				},
+			},
+			{
+				testName:        "capture network invalid flow reordering window",
+				captureSlice:    []string{"network", "pcap-flow-reorder:0s"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("pcap flow reordering window must be positive"),
+			},
+			{
+				testName:     "capture network with journal",
+				captureSlice: []string{"network", "pcap-journal"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Journal:       true,
+					},
+				},
+			},
+			{
+				testName:     "capture network flow byte threshold",
+				captureSlice: []string{"network", "pcap-flow-threshold:10mb:16"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:     true,
+						CaptureLength:     96,
+						Flows:             true,
+						FlowByteThreshold: 10 * 1024 * 1024,
+						FlowBackfill:      16,
+					},
+				},
+			},
+			{
+				testName:        "capture network invalid flow byte threshold",
+				captureSlice:    []string{"network", "pcap-flow-threshold:0"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap flow byte threshold: pcap-flow-threshold:0"),
+			},
+			{
+				testName:     "capture network bad checksum only",
+				captureSlice: []string{"network", "pcap-bad-checksum:only"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:   true,
+						CaptureLength:   96,
+						BadChecksum:     true,
+						BadChecksumOnly: true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with packet context",
+				captureSlice: []string{"network", "pcap-packet-context"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						PacketContext: true,
+					},
+				},
+			},
+			{
+				testName:     "capture network fix checksums",
+				captureSlice: []string{"network", "pcap-fix-checksums"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						FixChecksums:  true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with flow handshakes metric",
+				captureSlice: []string{"network", "pcap-flow-handshakes"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  96,
+						Flows:          true,
+						FlowHandshakes: true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with always ports",
+				captureSlice: []string{"network", "pcap-always-port:22,3389", "pcap-always-port:445"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						AlwaysPorts:   []uint16{22, 3389, 445},
+					},
+				},
+			},
+			{
+				testName:     "capture network with filter",
+				captureSlice: []string{"network", "pcap-filter:udp port 53 or tcp port 443"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Filter:        "udp port 53 or tcp port 443",
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid filter",
+				captureSlice:    []string{"network", "pcap-filter:tcp host 53"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("tcp host is not valid"),
+			},
+			{
+				testName:     "capture network with extract protocols",
+				captureSlice: []string{"network", "pcap-extract:16mb", "pcap-extract-proto:HTTP,ftp"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    96,
+						ExtractMaxStream: 16 * 1024 * 1024,
+						ExtractProtocols: []string{"http", "ftp"},
+					},
+				},
+			},
+			{
+				testName:     "capture pcap flow zeek",
+				captureSlice: []string{"network", "pcap-flow-zeek"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						FlowZeekLog:   true,
+					},
+				},
+			},
+			{
+				testName:     "capture pcap rotation",
+				captureSlice: []string{"network", "pcap-rotate-size:100mb", "pcap-rotate-interval:1h"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  96,
+						RotateSize:     100 * 1024 * 1024,
+						RotateInterval: time.Hour,
+					},
+				},
+			},
+			{
+				testName:      "capture pcap rotation invalid interval",
+				captureSlice:  []string{"network", "pcap-rotate-interval:0s"},
+				expectedError: errors.New("pcap rotation interval must be positive"),
+			},
+			{
+				testName:     "capture pcap short-lived processes",
+				captureSlice: []string{"network", "pcap-short-lived:5s"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						ShortLived:    5 * time.Second,
+					},
+				},
+			},
+			{
+				testName:     "capture pcap flow timeline",
+				captureSlice: []string{"network", "pcap-flow-timeline"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						FlowTimeline:  true,
+					},
+				},
+			},
+			{
+				testName:     "capture pcap flow events",
+				captureSlice: []string{"network", "pcap-flow-events"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						FlowEvents:    true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with link type",
+				captureSlice: []string{"network", "pcap-link-type:Ethernet"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						LinkType:      "ethernet",
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid link type",
+				captureSlice:    []string{"network", "pcap-link-type:wifi"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap link type (null or ethernet): wifi"),
+			},
+			{
+				testName:     "capture network with compression",
+				captureSlice: []string{"network", "pcap-compress:gzip"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Compress:      "gzip",
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid compression",
+				captureSlice:    []string{"network", "pcap-compress:zstd"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap compression (gzip or none): zstd"),
+			},
+			{
+				testName:     "capture network with ja3 filters",
+				captureSlice: []string{"network", "pcap-ja3", "pcap-ja3-allow:E7D705A3286E19EA42F587B344EE6865", "pcap-ja3-deny:6734f37431670b3ab4292b8f60f29984,b32309a26951912be7dba376398abc3b"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						JA3:           true,
+						JA3Allow:      []string{"e7d705a3286e19ea42f587b344ee6865"},
+						JA3Deny:       []string{"6734f37431670b3ab4292b8f60f29984", "b32309a26951912be7dba376398abc3b"},
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid ja3 fingerprint",
+				captureSlice:    []string{"network", "pcap-ja3-allow:abc"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid ja3 fingerprint (md5 hash): abc"),
+			},
+			{
+				testName:     "capture network with dns events",
+				captureSlice: []string{"network", "pcap-dns-events"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						DNSEvents:     true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with dns ports",
+				captureSlice: []string{"network", "pcap-dns-events", "pcap-dns-port:53,8053"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						DNSEvents:     true,
+						DNSPorts:      []uint16{53, 8053},
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid dns port",
+				captureSlice:    []string{"network", "pcap-dns-port:dns"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("could not parse pcap dns port: pcaps.ParsePorts: invalid port or unknown port preset: dns"),
+			},
+			{
+				testName:     "capture network with hooks",
+				captureSlice: []string{"network", "pcap-open-hook:echo \"$1\"", "pcap-close-hook:gzip -t \"$1\"", "pcap-hook-timeout:5s"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						OpenHook:      "echo \"$1\"",
+						CloseHook:     "gzip -t \"$1\"",
+						HookTimeout:   5 * time.Second,
+					},
+				},
+			},
+			{
+				testName:        "capture network with empty open hook",
+				captureSlice:    []string{"network", "pcap-open-hook:"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("pcap open hook command cannot be empty"),
+			},
+			{
+				testName:     "capture network ipv6 only",
+				captureSlice: []string{"network", "pcap-family:IPv6"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Family:        "ipv6",
+					},
+				},
+			},
+			{
+				testName:        "capture network invalid family",
+				captureSlice:    []string{"network", "pcap-family:inet"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap family (both, ipv4 or ipv6): inet"),
+			},
+			{
+				testName:     "capture network with min lifetime",
+				captureSlice: []string{"network", "pcap-min-lifetime:5s"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						MinLifetime:   5 * time.Second,
+					},
+				},
+			},
+			{
+				testName:     "capture network with per protocol snaplen",
+				captureSlice: []string{"network", "pcap-snaplen:DNS=512b,http=128b,default=64b"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  64,
+						CaptureLengths: map[string]uint32{"dns": 512, "http": 128},
+					},
+				},
+			},
+			{
+				testName:        "capture network with invalid snaplen protocol",
+				captureSlice:    []string{"network", "pcap-snaplen:smtp=1kb"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap snaplen protocol (dns, http, tls, tcp, udp, icmp or sctp): smtp"),
+			},
+			{
+				testName:     "capture network with decapsulation",
+				captureSlice: []string{"network", "pcap-decap"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Decap:         true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with protocol sampling",
+				captureSlice: []string{"network", "pcap:container", "pcap-protocol-sample"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureContainer: true,
+						CaptureLength:    96,
+						ProtocolSample:   true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with files index",
+				captureSlice: []string{"network", "pcap-files-index"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						FilesIndex:    true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with one in N flows sampled",
+				captureSlice: []string{"network", "pcap-sample-rate:1/4"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						SampleRate:    0.25,
+					},
+				},
+			},
+			{
+				testName:     "capture network with a fraction of flows sampled",
+				captureSlice: []string{"network", "pcap-sample-rate:0.1"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						SampleRate:    0.1,
+					},
+				},
+			},
+			{
+				testName:        "capture network with out of range sample rate",
+				captureSlice:    []string{"network", "pcap-sample-rate:1.5"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("pcap sample rate must be above 0 and up to 1: 1.5"),
+			},
+			{
+				testName:        "capture network with one in zero flows sampled",
+				captureSlice:    []string{"network", "pcap-sample-rate:1/0"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("could not parse pcap sample rate: flags.parseSampleRate: one in zero flows"),
+			},
+			{
+				testName:     "capture network with syscall filter",
+				captureSlice: []string{"network", "pcap-syscall:sendto,sendmsg", "pcap-syscall:write"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Syscalls:      []string{"sendto", "sendmsg", "write"},
+					},
+				},
+			},
+			{
+				testName:        "capture network with non syscall filter",
+				captureSlice:    []string{"network", "pcap-syscall:sched_process_exec"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("flags.parseCaptureSyscalls: invalid pcap syscall: sched_process_exec"),
+			},
+			{
+				testName:     "capture network with fragment reassembly",
+				captureSlice: []string{"network", "pcap-defrag"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Defrag:        true,
+					},
+				},
+			},
+			{
+				testName:     "capture network flow ids",
+				captureSlice: []string{"network", "pcap-flow-id"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Flows:         true,
+						FlowIDs:       true,
+					},
+				},
+			},
+			{
+				testName:     "capture network with tcp sink",
+				captureSlice: []string{"network", "pcap-sink:tcp:127.0.0.1:9000"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						SinkNetwork:   "tcp",
+						SinkAddress:   "127.0.0.1:9000",
+					},
+				},
+			},
+			{
+				testName:      "capture network with invalid sink",
+				captureSlice:  []string{"network", "pcap-sink:udp:127.0.0.1:9000"},
+				expectedError: errors.New("invalid pcap sink"),
+			},
+			{
+				testName:     "capture network tls events",
+				captureSlice: []string{"network", "pcap-tls-events", "pcap-tls-port:443,8443"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						TLSEvents:     true,
+						TLSPorts:      []uint16{443, 8443},
+					},
+				},
+			},
+			{
+				testName:     "capture network http events",
+				captureSlice: []string{"network", "pcap-http-events", "pcap-http-port:80,8080"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						HTTPEvents:    true,
+						HTTPPorts:     []uint16{80, 8080},
+					},
+				},
+			},
+			{
+				testName:     "capture network with file name template",
+				captureSlice: []string{"network", "pcap-name:{{.ProcessName}}_{{.Timestamp}}"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:    true,
+						CaptureLength:    96,
+						FileNameTemplate: "{{.ProcessName}}_{{.Timestamp}}",
+					},
+				},
+			},
+			{
+				testName:      "capture network with invalid file name template",
+				captureSlice:  []string{"network", "pcap-name:{{.ProcessName"},
+				expectedError: errors.New("invalid pcap file name template"),
+			},
+			{
+				testName:     "capture network with lost log interval",
+				captureSlice: []string{"network", "pcap-lost-log:1m"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:   true,
+						CaptureLength:   96,
+						LostLogInterval: time.Minute,
+					},
+				},
+			},
+			{
+				testName:      "capture network with invalid lost log interval",
+				captureSlice:  []string{"network", "pcap-lost-log:0s"},
+				expectedError: errors.New("pcap lost log interval must be positive"),
+			},
+			{
+				testName:     "pcap dry run",
+				captureSlice: []string{"network", "pcap-dry-run"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						DryRun:        true,
+					},
+				},
+			},
+			{
+				testName:     "pcap dry run interval",
+				captureSlice: []string{"network", "pcap-dry-run:30s"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:  true,
+						CaptureLength:  96,
+						DryRun:         true,
+						DryRunInterval: 30 * time.Second,
+					},
+				},
+			},
+			{
+				testName:      "invalid pcap dry run interval",
+				captureSlice:  []string{"network", "pcap-dry-run:0s"},
+				expectedError: errors.New("pcap dry run interval must be positive"),
+			},
+			{
+				testName:     "pcap container caps",
+				captureSlice: []string{"network", "pcap-container-packets:1000", "pcap-container-bytes:1kb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:           true,
+						CaptureLength:           96,
+						PerContainerPacketLimit: 1000,
+						PerContainerByteLimit:   1024,
+					},
+				},
+			},
+			{
+				testName:      "invalid pcap container packets",
+				captureSlice:  []string{"network", "pcap-container-packets:many"},
+				expectedError: errors.New("could not parse pcap container packets"),
+			},
+			{
+				testName:     "pcap vxlan ports",
+				captureSlice: []string{"network", "pcap-decap", "pcap-vxlan-port:8472,4789"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						Decap:         true,
+						VXLANPorts:    []uint16{8472, 4789},
+					},
+				},
+			},
+			{
+				testName:     "capture pcap ring buffer",
+				captureSlice: []string{"network", "pcap-ring-buffer:64mb"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle: true,
+						CaptureLength: 96,
+						RingBuffer:    64 * 1024 * 1024,
+					},
+				},
+			},
+			{
+				testName:     "capture pcap ring buffer with post-trigger duration",
+				captureSlice: []string{"network", "pcap-ring-buffer:1kb:30s"},
+				expectedCapture: config.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Net: config.PcapsConfig{
+						CaptureSingle:   true,
+						CaptureLength:   96,
+						RingBuffer:      1024,
+						RingBufferAfter: 30 * time.Second,
+					},
+				},
+			},
+			{
+				testName:        "capture pcap ring buffer invalid size",
+				captureSlice:    []string{"network", "pcap-ring-buffer:0"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap ring buffer size: pcap-ring-buffer:0"),
+			},
+			{
+				testName:        "capture pcap ring buffer invalid duration",
+				captureSlice:    []string{"network", "pcap-ring-buffer:1kb:-1s"},
+				expectedCapture: config.CaptureConfig{},
+				expectedError:   errors.New("invalid pcap ring buffer duration: pcap-ring-buffer:1kb:-1s"),
+			},
 			{
 				testName:     "capture bpf",
 				captureSlice: []string{"bpf"},
@@ -252,3 +1498,15 @@ func TestPrepareCapture(t *testing.T) {
 		require.NoDirExists(t, d.Name()+"out")
 	})
 }
+
+func TestParseCaptureSyscalls(t *testing.T) {
+	t.Parallel()
+
+	// given by name or id (architecture dependent)
+	syscalls, err := parseCaptureSyscalls(fmt.Sprintf("connect,%d", events.Sendto))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"connect", "sendto"}, syscalls)
+
+	_, err = parseCaptureSyscalls("nosuchsyscall")
+	require.ErrorContains(t, err, "invalid pcap syscall: nosuchsyscall")
+}
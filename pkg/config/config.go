@@ -2,6 +2,7 @@ package config
 
 import (
 	"io"
+	"time"
 
 	"github.com/aquasecurity/libbpfgo/helpers"
 
@@ -131,12 +132,119 @@ const (
 )
 
 type PcapsConfig struct {
-	CaptureSingle    bool
-	CaptureProcess   bool
-	CaptureContainer bool
-	CaptureCommand   bool
-	CaptureFiltered  bool
-	CaptureLength    uint32
+	CaptureSingle      bool
+	CaptureProcess     bool
+	CaptureContainer   bool
+	CaptureCommand     bool
+	CaptureUser        bool
+	PerConnection      bool // 1 pcap file per TCP connection (requires flow tracking)
+	CaptureFiltered    bool
+	ExcludeLoopback    bool // do not capture loopback (127.0.0.0/8, ::1) packets
+	CaptureLength      uint32
+	CaptureLengths     map[string]uint32 // capture length of given protocols (see pcaps.ParseSnaplenProtocol), CaptureLength being the default
+	LatencySampling    uint32            // measure processing latency of 1 in N packets (0: disabled)
+	LostLogInterval    time.Duration     // log lost network capture events once per interval (0: default, 10s)
+	PayloadCeiling     uint32            // absolute max payload (after last known header) per packet
+	PayloadWindowStart uint32            // first payload byte kept from each packet (with PayloadWindowEnd)
+	PayloadWindowEnd   uint32            // payload byte after the last one kept from each packet (0: disabled)
+	RingFileSize       uint64            // fixed size of each pcap file, overwriting oldest packets (0: disabled)
+	RotateSize         uint64            // rotate each pcap file once bigger than this many bytes (0: disabled)
+	RotateInterval     time.Duration     // rotate each pcap file once this interval elapsed (0: disabled)
+	Compress           string            // compress pcap files once finalized (rotated or capture ended): none (default) or gzip
+	OpenHook           string            // shell command run (in the background) when a pcap file is opened, given its path and target
+	CloseHook          string            // shell command run (in the background) when a pcap file is finalized, given its path and target
+	HookTimeout        time.Duration     // pcap hook commands running longer are killed (0: default)
+	MinLifetime        time.Duration     // remove pcap files of targets whose packets span less than this, once finalized (0: disabled)
+	RateLimitPackets   uint64            // max packets per second written to each pcap file
+	RateLimitBytes     uint64            // max bytes per second written to each pcap file
+	UidFilter          []uint32          // only capture packets from processes owned by these UIDs
+	PortFilter         []uint16          // only capture packets from or to these ports
+	AlwaysPorts        []uint16          // always capture packets from or to these ports (bypassing packet filters)
+	Filter             string            // only capture packets matching this libpcap style filter expression
+	MinEntropy         float64           // only capture packets whose payload entropy (bits per byte) is above this (0: disabled)
+	SampleRate         float64           // fraction of flows captured, by hash of their 5-tuple (0: all)
+	EntropyPorts       []uint16          // only apply the entropy filter to packets from or to these ports (empty: all)
+	ICMPAnomalous      bool              // only capture ICMP echoes with oversized or non-standard payloads
+	ICMPMaxPayload     uint32            // max payload size of an ordinary ICMP echo (0: default ping size)
+	Sources            []string          // only capture packets from these sources (eBPF hooks, see pcaps.ParseSource)
+	Syscalls           []string          // only capture packets originated by these syscalls (names, egress packets only)
+	Family             string            // only capture packets of this address family: both (default), ipv4 or ipv6
+	Decap              bool              // capture the inner packets of GRE and VXLAN tunnels instead of the outer ones
+	VXLANPorts         []uint16          // UDP ports of VXLAN tunnels, decapsulated (default: 4789)
+	Defrag             bool              // reassemble fragmented IPv4 datagrams, capturing the datagrams instead of their fragments
+	TCPUrgent          bool              // tag TCP segments with the URG flag set (urgent pointer in packet metadata)
+	TCPUrgentOnly      bool              // only capture TCP segments with the URG flag set
+	BadChecksum        bool              // tag packets with an invalid checksum (layer in packet metadata)
+	BadChecksumOnly    bool              // only capture packets with an invalid checksum
+	FixChecksums       bool              // recompute IPv4 header, TCP and UDP checksums of captured packets
+	L2Mode             string            // fake layer 2 header written before packets: auto (default), prepend or overwrite
+	LinkType           string            // link type of pcap files: null (default, BSD loopback) or ethernet
+	FileNameTemplate   string            // text/template naming per process and per command pcap files (empty: default scheme)
+	ASNDatabases       []string          // GeoLite2-ASN CSV files used to resolve destination ASNs
+	ASNAllow           []uint32          // only capture packets to these destination ASNs
+	ASNDeny            []uint32          // never capture packets to these destination ASNs
+	GeoDatabase        string            // GeoLite2 CSV database directory used to geolocate destinations
+	CountryAllow       []string          // only capture packets to these destination countries (ISO codes)
+	CountryDeny        []string          // never capture packets to these destination countries (ISO codes)
+	Index              bool              // maintain an index of all written packets
+	FilesIndex         bool              // maintain an index of all created pcap files (with their container and command)
+	Sidecar            bool              // write a binary 5-tuple sidecar next to each pcap file
+	Chain              bool              // keep a hash chain of the blocks written to each pcap file
+	SplitDirection     bool              // write inbound and outbound packets to separate pcap files
+	UnknownDirection   string            // file packets of unknown direction go to: unknown (default), inbound or outbound
+	Flows              bool              // track flows (close summaries and TCP RTT estimates)
+	FlowLog            bool              // log flows that are over to a CSV file (requires Flows)
+	FlowHTTP           bool              // record HTTP request/response metadata of flows (requires Flows)
+	FlowIDs            bool              // record deterministic flow IDs (5-tuple and start time) of flows (requires Flows)
+	MaxFlows           uint32            // max tracked flows (0: default)
+	FlowEviction       string            // flow evicted when the flow table is full: idle (default) or lru
+	FlowReorderWindow  time.Duration     // TCP segments filling sequence gaps within this window are reordered, not retransmitted (requires Flows)
+	FlowByteThreshold  uint64            // only write packets of flows once they exceed this many bytes (0: disabled, requires Flows)
+	FlowBackfill       uint32            // last packets withheld below the byte threshold written once it is crossed
+	FlowHandshakes     bool              // export TCP handshake times of flows as a metric histogram (requires Flows)
+	FlowLogOnly        bool              // only log flows (no pcap files are written)
+	FlowZeekLog        bool              // log flows that are over to a Zeek conn.log file (requires Flows)
+	FlowTimeline       bool              // draw the flows of each capture session as an SVG timeline (requires Flows)
+	FlowEvents         bool              // emit summaries of flows that are over to the events stream (net_flow_summary, requires Flows)
+	ProtocolDirs       map[string]string // protocol (dns, tcp, udp, icmp, sctp) to its own output dir
+	NoiseFile          bool              // write broadcast-heavy protocols (netbios, ssdp, mdns, llmnr) to a dedicated file
+	NoiseFileSize      uint64            // fixed size of the dedicated noise file, overwriting oldest packets (0: unlimited)
+	ExtractMaxStream   uint64            // reassemble TCP streams up to this size to extract files (0: disabled)
+	ExtractProtocols   []string          // only reassemble TCP streams of these protocols: http, ftp (empty: all)
+	ControlFIFO        string            // FIFO to read capture control commands from
+	TLSKeyLog          bool              // embed TLS key log secrets into pcap files (when available)
+	JA3                bool              // record JA3 and JA3S fingerprints of TLS hellos in their metadata
+	JA3Allow           []string          // only capture TLS flows with these JA3 or JA3S fingerprints (requires Flows)
+	JA3Deny            []string          // never capture TLS flows with these JA3 or JA3S fingerprints (requires Flows)
+	Journal            bool              // embed systemd journal entries into pcap files (when available)
+	PacketContext      bool              // record the process (pid, name and container) of every packet in its metadata
+	DNSDedupWindow     time.Duration     // suppress identical DNS queries within this window (0: disabled)
+	ProtocolSample     bool              // only write the first packet of each distinct L7 protocol of each capture target
+	DetectionWindow    time.Duration     // only capture targets this long after their last detection (0: disabled)
+	ShortLived         time.Duration     // only capture processes living less than this (0: disabled)
+	RingBuffer         uint64            // hold the last packets (up to this many bytes) in memory until a trigger dumps them (0: disabled)
+	RingBufferAfter    time.Duration     // write packets right away this long after a ring buffer trigger
+	StatsInterval      time.Duration     // write per target stats files (next to pcap files) on this interval (0: disabled)
+	MemoryThreshold    uint64            // disable memory hungry features above this heap usage (bytes)
+	DegradeOrder       []string          // order in which features are disabled under memory pressure
+	Events             bool              // emit captured packets to the events stream (net_packet_captured)
+	EventsOnly         bool              // only emit captured packets to the events stream (no pcap files are written)
+	SinkNetwork        string            // stream captured packets to a collector over this network (unix or tcp) instead of pcap files (if set)
+	SinkAddress        string            // address of the collector (unix socket path or host:port)
+	DryRun             bool              // count packets (and bytes) per capture target instead of writing them
+	DryRunInterval     time.Duration     // log dry run counts on this interval (0: default, 1m)
+	EventPayloadSize   uint32            // max packet bytes (base64) carried by each emitted event (0: unlimited)
+	DNSEvents          bool              // emit DNS messages parsed from captured packets to the events stream (net_packet_captured_dns)
+	DNSPorts           []uint16          // ports DNS messages are parsed from (default: 53)
+	TLSEvents          bool              // emit TLS hellos (server name and version) parsed from captured packets to the events stream (net_packet_tls)
+	TLSPorts           []uint16          // ports TLS hellos are parsed from (default: 443)
+	HTTPEvents         bool              // emit HTTP requests (method, host and path) parsed from captured packets to the events stream (net_packet_http)
+	HTTPPorts          []uint16          // ports HTTP requests are parsed from (default: 80)
+
+	// per container caps: once reached, next packets of the container are
+	// dropped (0: unlimited)
+	PerContainerPacketLimit uint64 // max packets written to the pcap files of each container
+	PerContainerByteLimit   uint64 // max bytes written to the pcap files of each container
 }
 
 //
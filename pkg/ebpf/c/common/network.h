@@ -108,6 +108,7 @@ typedef enum net_packet {
     // Layer 7
     SUB_NET_PACKET_DNS = 1 << 6,
     SUB_NET_PACKET_HTTP = 1 << 7,
+    SUB_NET_PACKET_DHCP = 1 << 8,
 } net_packet_t;
 
 typedef struct net_event_contextmd {
@@ -225,6 +226,9 @@ struct {
 #define flow_udp_begin          (1 << 8)  // first flow packet
 #define flow_udp_end            (1 << 9)  // last flow packet
 #define flow_src_initiator      (1 << 10) // src is the flow initiator
+// Packet Verdict (allow/drop) Flags, set by programs making verdicts on packets
+#define packet_verdict_allow    (1 << 11)
+#define packet_verdict_drop     (1 << 12)
 
 // payload size: full packets, only headers
 #define FULL    65536       // 1 << 16
@@ -233,6 +237,10 @@ struct {
 // when guessing by src/dst ports, declare at network.h
 #define UDP_PORT_DNS 53
 #define TCP_PORT_DNS 53
+#define UDP_PORT_DHCP_SERVER   67
+#define UDP_PORT_DHCP_CLIENT   68
+#define UDP_PORT_DHCPV6_CLIENT 546
+#define UDP_PORT_DHCPV6_SERVER 547
 
 // layer 7 parsing related constants
 #define http_min_len 7 // longest http command is "DELETE "
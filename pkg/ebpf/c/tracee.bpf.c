@@ -5250,6 +5250,8 @@ statfunc enum event_id_e net_packet_to_net_event(net_packet_t packet_type)
             return NET_PACKET_DNS;
         case SUB_NET_PACKET_HTTP:
             return NET_PACKET_HTTP;
+        case SUB_NET_PACKET_DHCP:
+            return NET_PACKET_DHCP;
     };
     return MAX_EVENT_ID;
 }
@@ -5341,6 +5343,7 @@ CGROUP_SKB_HANDLE_FUNCTION(proto_tcp_dns);
 CGROUP_SKB_HANDLE_FUNCTION(proto_tcp_http);
 CGROUP_SKB_HANDLE_FUNCTION(proto_udp);
 CGROUP_SKB_HANDLE_FUNCTION(proto_udp_dns);
+CGROUP_SKB_HANDLE_FUNCTION(proto_udp_dhcp);
 CGROUP_SKB_HANDLE_FUNCTION(proto_icmp);
 CGROUP_SKB_HANDLE_FUNCTION(proto_icmpv6);
 
@@ -5517,6 +5520,10 @@ statfunc u32 cgroup_skb_capture_event(struct __sk_buff *ctx,
     if (nc == NULL)
         return 0;
 
+    // Capture might be stopped at runtime (programs are kept attached).
+    if (nc->capture_options & NET_CAP_OPT_STOPPED)
+        return 0;
+
     // Submit the capture base event.
     return cgroup_skb_submit(&net_cap_events, ctx, neteventctx, event_type, nc->capture_length);
 }
@@ -6251,6 +6258,21 @@ CGROUP_SKB_HANDLE_FUNCTION(proto)
 
 // when guessing by src/dst ports, declare at network.h
 
+statfunc bool net_l7_is_dhcp(u16 source, u16 dest)
+{
+    // DHCPv4 (client <-> server and relays)
+    if ((source == UDP_PORT_DHCP_SERVER || source == UDP_PORT_DHCP_CLIENT) &&
+        (dest == UDP_PORT_DHCP_SERVER || dest == UDP_PORT_DHCP_CLIENT))
+        return true;
+
+    // DHCPv6 (client <-> server and relays)
+    if ((source == UDP_PORT_DHCPV6_SERVER || source == UDP_PORT_DHCPV6_CLIENT) &&
+        (dest == UDP_PORT_DHCPV6_SERVER || dest == UDP_PORT_DHCPV6_CLIENT))
+        return true;
+
+    return false;
+}
+
 // when guessing through l7 layer, here
 
 statfunc int net_l7_is_http(struct __sk_buff *skb, u32 l7_off)
@@ -6363,6 +6385,15 @@ CGROUP_SKB_HANDLE_FUNCTION(proto_udp)
     if (should_submit_net_event(neteventctx, SUB_NET_PACKET_UDP))
         cgroup_skb_submit_event(ctx, neteventctx, NET_PACKET_UDP, HEADERS);
 
+    u16 source = bpf_ntohs(nethdrs->protohdrs.udphdr.source);
+    u16 dest = bpf_ntohs(nethdrs->protohdrs.udphdr.dest);
+
+    // DHCP is guessed before the fastpath: DHCP packets are rare and always
+    // captured in full (IP-assignment auditing needs the whole message).
+
+    if (net_l7_is_dhcp(source, dest))
+        return CGROUP_SKB_HANDLE(proto_udp_dhcp);
+
     // Fastpath: return if no other L7 network events.
 
     if (!should_submit_net_event(neteventctx, SUB_NET_PACKET_DNS) &&
@@ -6371,9 +6402,6 @@ CGROUP_SKB_HANDLE_FUNCTION(proto_udp)
 
     // Guess layer 7 protocols ...
 
-    u16 source = bpf_ntohs(nethdrs->protohdrs.udphdr.source);
-    u16 dest = bpf_ntohs(nethdrs->protohdrs.udphdr.dest);
-
     // ... by src/dst ports
 
     switch (source < dest ? source : dest) {
@@ -6466,6 +6494,23 @@ CGROUP_SKB_HANDLE_FUNCTION(proto_udp_dns)
     return 1; // NOTE: might block DNS here if needed (return 0)
 }
 
+CGROUP_SKB_HANDLE_FUNCTION(proto_udp_dhcp)
+{
+    // submit DHCP base event if needed (full packet)
+    if (should_submit_net_event(neteventctx, SUB_NET_PACKET_DHCP))
+        cgroup_skb_submit_event(ctx, neteventctx, NET_PACKET_DHCP, FULL);
+
+    // capture DHCP-UDP, UDP or IP packets (filtered)
+    if (should_capture_net_event(neteventctx, SUB_NET_PACKET_IP) ||
+        should_capture_net_event(neteventctx, SUB_NET_PACKET_UDP) ||
+        should_capture_net_event(neteventctx, SUB_NET_PACKET_DHCP)) {
+        neteventctx->md.header_size = ctx->len; // full dhcp message
+        cgroup_skb_capture();
+    }
+
+    return 1; // NOTE: might block DHCP here if needed (return 0)
+}
+
 CGROUP_SKB_HANDLE_FUNCTION(proto_tcp_http)
 {
     // submit HTTP base event if needed (full packet)
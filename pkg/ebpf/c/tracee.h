@@ -18,6 +18,7 @@ statfunc bool should_submit_flow_event(net_event_context_t *);
 statfunc u64 should_capture_net_event(net_event_context_t *, net_packet_t);
 statfunc u32 cgroup_skb_generic(struct __sk_buff *, void *);
 statfunc int net_l7_is_http(struct __sk_buff *, u32);
+statfunc bool net_l7_is_dhcp(u16, u16);
 statfunc u32 update_net_inodemap(struct socket *, event_data_t *);
 statfunc int send_socket_dup(program_data_t *, u64, u64);
 statfunc u32 cgroup_skb_submit(void *, struct __sk_buff *, net_event_context_t *, u32, u32);
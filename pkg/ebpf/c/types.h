@@ -49,6 +49,7 @@ enum event_id_e
     NET_PACKET_ICMPV6,
     NET_PACKET_DNS,
     NET_PACKET_HTTP,
+    NET_PACKET_DHCP,
     NET_CAPTURE_BASE,
     NET_FLOW_BASE,
     MAX_NET_EVENT_ID,
@@ -340,6 +341,7 @@ typedef struct event_config {
 enum capture_options_e
 {
     NET_CAP_OPT_FILTERED = (1 << 0), // pcap should obey event filters
+    NET_CAP_OPT_STOPPED = (1 << 1),  // no packets are captured (capture stopped at runtime)
 };
 
 typedef struct netconfig_entry {
@@ -4,12 +4,19 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"net"
+	"slices"
+	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 
+	"github.com/aquasecurity/tracee/pkg/errfmt"
 	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
 	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
 	"github.com/aquasecurity/tracee/types/trace"
 )
 
@@ -27,55 +34,133 @@ import (
 const (
 	familyIpv4 int = 1 << iota
 	familyIpv6
+	familyArp
 )
 
-func (t *Tracee) handleNetCaptureEvents(ctx context.Context) {
-	logger.Debugw("Starting handleNetCaptureEvents goroutine")
-	defer logger.Debugw("Stopped handleNetCaptureEvents goroutine")
+// netCapPrefixSize is the size of the prefix (room for the fake layer 2
+// header) of the captured payload. Payloads must be bigger than the prefix to
+// carry any packet data.
+const netCapPrefixSize = 4
 
-	var errChanList []<-chan error
+// netCapDrainTimeout bounds the time spent processing queued network capture
+// events when capture is stopped.
+const netCapDrainTimeout = 2 * time.Second
 
-	// source pipeline stage (re-used from regular pipeline)
-	eventsChan, errChan := t.decodeEvents(ctx, t.netCapChannel)
-	errChanList = append(errChanList, errChan)
+// netCapLostLogInterval is the default interval lost network capture events
+// are logged on (coalesced, so busy hosts don't flood the logs).
+const netCapLostLogInterval = 10 * time.Second
 
-	// process events stage (network capture only)
-	errChan = t.processNetCapEvents(ctx, eventsChan)
-	errChanList = append(errChanList, errChan)
+// handleNetCaptureEvents starts network capture (its pipeline runs until
+// stopped, see StopNetCapture, or until the given context is done).
+func (t *Tracee) handleNetCaptureEvents(ctx context.Context) {
+	// capture control commands (pause, resume, sessions...)
+	if t.netCapControl != nil {
+		go func() {
+			if err := t.netCapControl.Run(ctx); err != nil {
+				logger.Errorw("Capture control", "error", err)
+			}
+		}()
+	}
 
-	// pipeline started, wait for completion.
-	if err := t.WaitForPipeline(errChanList...); err != nil {
-		logger.Errorw("Pipeline", "error", err)
+	// summaries of flows that are over, emitted as flows are over
+	if t.config.Capture.Net.FlowEvents {
+		t.netCapturePcap.OnFlowOver(func(s pcaps.FlowSummary) {
+			t.emitNetCapFlowEvent(ctx, s)
+		})
 	}
+
+	t.netCapMutex.Lock()
+	defer t.netCapMutex.Unlock()
+
+	t.netCapCtx = ctx
+	t.startNetCapPipeline()
 }
 
 func (t *Tracee) processNetCapEvents(ctx context.Context, in <-chan *trace.Event) <-chan error {
 	errc := make(chan error, 1)
 
+	// measure processing latency of 1 in N packets only (keeps overhead low)
+	sampling := uint64(t.config.Capture.Net.LatencySampling)
+
+	drained := t.netCapDrained
+
 	go func() {
 		defer close(errc)
+		if drained != nil {
+			defer close(drained)
+		}
+
+		var processed uint64
+
+		process := func(event *trace.Event) {
+			var start time.Time
+			sampled := sampling > 0 && processed%sampling == 0
+			processed++
+			if sampled {
+				start = time.Now()
+			}
+
+			// TODO: Support captures pipeline in t.processEvent
+			err := t.normalizeEventCtxTimes(event)
+			if err != nil {
+				t.handleError(err)
+				t.eventsPool.Put(event)
+				return
+			}
+			t.processNetCapEvent(ctx, event)
+			_ = t.stats.NetCapCount.Increment()
+			t.stats.NetCapTargets.Set(uint64(t.netCapturePcap.ActiveTargets()))
+			t.stats.NetCapWritten.Set(t.netCapturePcap.Stats().Written.Get())
+			t.stats.NetCapWrittenBytes.Set(t.netCapturePcap.Stats().WrittenBytes.Get())
+			t.stats.NetCapLongLived.Set(t.netCapturePcap.Stats().LongLived.Get())
+			t.stats.NetCapUntriggered.Set(t.netCapturePcap.Stats().Untriggered.Get())
+			t.eventsPool.Put(event)
+
+			if sampled {
+				t.stats.NetCapLatency.Observe(time.Since(start))
+			}
+		}
+
+		// lost events are counted as they are reported, but only logged once
+		// per interval (the total lost over the interval, if any)
+
+		lostInterval := t.config.Capture.Net.LostLogInterval
+		if lostInterval <= 0 {
+			lostInterval = netCapLostLogInterval
+		}
+		lostTicker := time.NewTicker(lostInterval)
+		defer lostTicker.Stop()
+
+		var lostLogged uint64 // lost events not logged yet
+		logLost := func() {
+			if lostLogged > 0 {
+				logger.Warnw(fmt.Sprintf("Lost %d network capture events", lostLogged), "interval", lostInterval)
+				lostLogged = 0
+			}
+		}
 
 		for {
 			select {
-			case event := <-in:
-				// TODO: Support captures pipeline in t.processEvent
-				err := t.normalizeEventCtxTimes(event)
-				if err != nil {
-					t.handleError(err)
-					t.eventsPool.Put(event)
-					continue
+			case event, ok := <-in:
+				if !ok { // pipeline stopped (source closed)
+					logLost()
+					t.drainNetCapEvents(in, process)
+					return
 				}
-				t.processNetCapEvent(event)
-				_ = t.stats.NetCapCount.Increment()
-				t.eventsPool.Put(event)
+				process(event)
 
 			case lost := <-t.lostNetCapChannel:
 				if err := t.stats.LostNtCapCount.Increment(lost); err != nil {
 					logger.Errorw("Incrementing lost network events count", "error", err)
 				}
-				logger.Warnw(fmt.Sprintf("Lost %d network capture events", lost))
+				lostLogged += lost
+
+			case <-lostTicker.C:
+				logLost()
 
 			case <-ctx.Done():
+				logLost()
+				t.drainNetCapEvents(in, process)
 				return
 			}
 		}
@@ -84,12 +169,44 @@ func (t *Tracee) processNetCapEvents(ctx context.Context, in <-chan *trace.Event
 	return errc
 }
 
+// drainNetCapEvents processes the events still queued once capture is stopped
+// (the tail of the capture, often the interesting part), for up to
+// netCapDrainTimeout, and flushes the pcap files to disk.
+func (t *Tracee) drainNetCapEvents(in <-chan *trace.Event, process func(*trace.Event)) {
+	timeout := time.NewTimer(netCapDrainTimeout)
+	defer timeout.Stop()
+
+	drained := 0
+	defer func() {
+		if err := t.netCapturePcap.Flush(); err != nil {
+			logger.Errorw("Flushing network capture", "error", err)
+		}
+		logger.Debugw("Drained network capture events", "count", drained)
+	}()
+
+	for {
+		select {
+		case event, ok := <-in:
+			if !ok {
+				return
+			}
+			process(event)
+			drained++
+		case <-timeout.C:
+			logger.Warnw("Network capture events left undrained", "count", len(in))
+			return
+		default:
+			return
+		}
+	}
+}
+
 // processNetCapEvent processes network packets meant to be captured.
 //
 // TODO: usually networking parsing functions are big, still, this might need
 // some refactoring to make it smaller (code reuse might not be a key for the
 // refactor).
-func (t *Tracee) processNetCapEvent(event *trace.Event) {
+func (t *Tracee) processNetCapEvent(ctx context.Context, event *trace.Event) {
 	eventId := events.ID(event.EventID)
 
 	switch eventId {
@@ -113,30 +230,98 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 			return
 		}
 		payloadLayer3Size := len(payloadLayer3)
-		if payloadLayer3Size < 1 {
-			logger.Debugw("Network capture: empty payload")
+		if payloadLayer3Size <= netCapPrefixSize {
+			// prefix only (or nothing at all): no packet data to parse
+			logger.Debugw("Network capture: empty payload", "size", payloadLayer3Size)
+			_ = t.stats.NetCapEmptyCount.Increment()
 			return
 		}
 
+		// skip packets of the address family not captured (if requested)
+
+		family := t.config.Capture.Net.Family
+		if (family == pcaps.FamilyIPv4 && event.ReturnValue&familyIpv4 != familyIpv4) ||
+			(family == pcaps.FamilyIPv6 && event.ReturnValue&familyIpv6 != familyIpv6) {
+			_ = t.stats.NetCapFamilySkip.Increment()
+			return
+		}
+
+		// skip packets from sources (eBPF hooks) not allowed (if requested)
+
+		source := pcaps.PacketSource(event)
+		if sources := t.config.Capture.Net.Sources; len(sources) > 0 && !slices.Contains(sources, source) {
+			_ = t.stats.NetCapSrcSkipped.Increment()
+			return
+		}
+		setNetCapArg(event, trace.ArgMeta{Type: "const char *", Name: "source"}, source)
+
+		// skip packets not originated by the syscalls allowed (if requested):
+		// only egress packets carry the syscall of their socket task context
+
+		if syscalls := t.config.Capture.Net.Syscalls; len(syscalls) > 0 && !slices.Contains(syscalls, event.Syscall) {
+			_ = t.stats.NetCapSyscallSkip.Increment()
+			return
+		}
+
+		// tag packets the kernel made a verdict on (allow or drop)
+
+		if verdict := pcaps.PacketVerdict(event); verdict != "" {
+			setNetCapArg(event, trace.ArgMeta{Type: "const char *", Name: "verdict"}, verdict)
+		}
+
 		// event retval encodes layer 3 protocol type
 
 		if event.ReturnValue&familyIpv4 == familyIpv4 {
 			layerType = layers.LayerTypeIPv4
 		} else if event.ReturnValue&familyIpv6 == familyIpv6 {
 			layerType = layers.LayerTypeIPv6
+		} else if event.ReturnValue&familyArp == familyArp {
+			layerType = layers.LayerTypeARP
 		} else {
-			logger.Debugw("Unsupported layer3 protocol")
+			// not parsed as garbage: skipped (and counted) instead
+			logger.Debugw("Network capture: unsupported layer3 protocol", "retval", event.ReturnValue)
+			_ = t.stats.NetCapNoFamily.Increment()
+			return
+		}
+
+		// make room for fake layer 2 header: prepended to bare packets, or
+		// overwriting the prefix of the sources carrying one (see NOTES below)
+
+		l2Mode := t.config.Capture.Net.L2Mode
+		if l2Mode == "" || l2Mode == pcaps.L2ModeAuto {
+			l2Mode = pcaps.SourceL2Mode(source)
+		}
+		if l2Mode == pcaps.L2ModeAuto {
+			l2Mode = pcaps.DetectL2Mode(payloadLayer3)
 		}
 
-		// make room for fake layer 2 header
+		switch l2Mode {
+		case pcaps.L2ModeOverwrite:
+			payloadLayer2 = append([]byte(nil), payloadLayer3...) // event payload is kept intact
+		default:
+			layer2Slice := make([]byte, netCapPrefixSize)
+			payloadLayer2 = append(layer2Slice[:], payloadLayer3...)
+		}
 
-		layer2Slice := make([]byte, 4)
-		payloadLayer2 = append(layer2Slice[:], payloadLayer3...)
+		// ARP packets are captured as is (none of the IP processing below)
+
+		if layerType == layers.LayerTypeARP {
+			packetEnd := payloadLayer3Size
+			if l2Mode == pcaps.L2ModeOverwrite {
+				packetEnd = len(payloadLayer2)
+			}
+			t.processNetCapARP(ctx, event, payloadLayer2[:packetEnd])
+			return
+		}
 
 		// parse packet
 
+		packetEnd := payloadLayer3Size
+		if l2Mode == pcaps.L2ModeOverwrite {
+			packetEnd = len(payloadLayer2)
+		}
 		packet := gopacket.NewPacket(
-			payloadLayer2[4:payloadLayer3Size],
+			payloadLayer2[netCapPrefixSize:packetEnd],
 			layerType,
 			gopacket.Default,
 		)
@@ -145,6 +330,137 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 			return
 		}
 
+		// decapsulate GRE and VXLAN tunneled packets (if requested): the
+		// innermost packet is captured instead of the outer one
+
+		if t.config.Capture.Net.Decap {
+			vxlanPorts := t.config.Capture.Net.VXLANPorts
+			if len(vxlanPorts) == 0 {
+				vxlanPorts = netCapVXLANPorts
+			}
+			if inner, innerType, ok := decapNetCapPacket(payloadLayer2[netCapPrefixSize:], layerType, vxlanPorts); ok {
+				payloadLayer2 = append(make([]byte, netCapPrefixSize, netCapPrefixSize+len(inner)), inner...)
+				layerType = innerType
+				packet = gopacket.NewPacket(payloadLayer2[netCapPrefixSize:], layerType, gopacket.Default)
+				_ = t.stats.NetCapDecapsulated.Increment()
+			}
+		}
+
+		// reassemble fragmented IPv4 datagrams (if requested): fragments are
+		// held until their datagram is complete, and only the datagram is
+		// captured
+
+		if t.netCapDefrag != nil && layerType == layers.LayerTypeIPv4 {
+			datagram, held := t.netCapDefrag.defrag(payloadLayer2[netCapPrefixSize:], time.Unix(0, int64(event.Timestamp)))
+			if held {
+				_ = t.stats.NetCapFragHeld.Increment()
+				return
+			}
+			if datagram != nil {
+				payloadLayer2 = append(make([]byte, netCapPrefixSize, netCapPrefixSize+len(datagram)), datagram...)
+				packet = gopacket.NewPacket(payloadLayer2[netCapPrefixSize:], layerType, gopacket.Default)
+				_ = t.stats.NetCapReassembled.Increment()
+			}
+		}
+
+		// emit parsed DNS messages to the events stream (if requested), no
+		// matter the capture filters below or pcap files being written
+
+		if t.config.Capture.Net.DNSEvents {
+			// decoding the whole packet, as messages end with the payload
+			whole := gopacket.NewPacket(payloadLayer2[netCapPrefixSize:], layerType, gopacket.Default)
+			t.emitNetCapDNSEvent(ctx, event, whole)
+		}
+
+		// emit parsed TLS hellos to the events stream (if requested), the
+		// same way
+
+		if t.config.Capture.Net.TLSEvents {
+			whole := gopacket.NewPacket(payloadLayer2[netCapPrefixSize:], layerType, gopacket.Default)
+			t.emitNetCapTLSEvent(ctx, event, whole)
+		}
+
+		// emit parsed HTTP requests to the events stream (if requested), the
+		// same way
+
+		if t.config.Capture.Net.HTTPEvents {
+			whole := gopacket.NewPacket(payloadLayer2[netCapPrefixSize:], layerType, gopacket.Default)
+			t.emitNetCapHTTPEvent(ctx, event, whole)
+		}
+
+		// packets from or to always interesting ports bypass the filters below
+		// (if requested)
+
+		always := len(t.config.Capture.Net.AlwaysPorts) > 0 &&
+			matchPacketPorts(packet, t.config.Capture.Net.AlwaysPorts)
+
+		// skip loopback traffic (if requested)
+
+		if !always && t.config.Capture.Net.ExcludeLoopback && isLoopbackPacket(packet) {
+			_ = t.stats.NetCapLoopCount.Increment()
+			return
+		}
+
+		// skip packets not matching the capture filter (if requested)
+
+		if !always && t.netCapFilter != nil && !t.netCapFilter.Match(packet) {
+			_ = t.stats.NetCapFiltered.Increment()
+			return
+		}
+
+		// skip packets of flows not sampled (if requested)
+
+		if rate := t.config.Capture.Net.SampleRate; !always && rate > 0 && !sampleNetCapFlow(packet, rate) {
+			_ = t.stats.NetCapSampledOut.Increment()
+			return
+		}
+
+		// skip low entropy payloads (if requested)
+
+		if minEntropy := t.config.Capture.Net.MinEntropy; !always && minEntropy > 0 &&
+			matchPacketPorts(packet, t.config.Capture.Net.EntropyPorts) &&
+			payloadEntropy(packet) <= minEntropy {
+			_ = t.stats.NetCapLowEntropy.Increment()
+			return
+		}
+
+		// skip ordinary ICMP echoes, keeping covert channel candidates (if requested)
+
+		if !always && t.config.Capture.Net.ICMPAnomalous &&
+			isOrdinaryICMPEcho(packet, t.config.Capture.Net.ICMPMaxPayload) {
+			_ = t.stats.NetCapICMPNormal.Increment()
+			return
+		}
+
+		// tag TCP segments with the URG flag set, or capture only those (if requested)
+
+		if t.config.Capture.Net.TCPUrgent || t.config.Capture.Net.TCPUrgentOnly {
+			tcp, ok := packet.TransportLayer().(*layers.TCP)
+			urgent := ok && tcp.URG
+			if !urgent && !always && t.config.Capture.Net.TCPUrgentOnly {
+				_ = t.stats.NetCapNotUrgent.Increment()
+				return
+			}
+			if urgent {
+				setNetCapArg(event, trace.ArgMeta{Type: "u16", Name: "tcp_urgent_ptr"}, tcp.Urgent)
+			}
+		}
+
+		// tag packets with an invalid checksum, or capture only those (if requested)
+
+		if t.config.Capture.Net.BadChecksum || t.config.Capture.Net.BadChecksumOnly {
+			// as captured (before any mangling), decoding the whole packet
+			whole := gopacket.NewPacket(payloadLayer2[netCapPrefixSize:], layerType, gopacket.Default)
+			layer := invalidChecksum(whole)
+			if layer == "" && !always && t.config.Capture.Net.BadChecksumOnly {
+				_ = t.stats.NetCapChecksumOK.Increment()
+				return
+			}
+			if layer != "" {
+				setNetCapArg(event, trace.ArgMeta{Type: "const char *", Name: "bad_checksum"}, layer)
+			}
+		}
+
 		// amount of bytes the TCP header has based on data offset field
 
 		tcpDoff := func(l4 gopacket.TransportLayer) uint32 {
@@ -181,15 +497,41 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 		// length field to the length of the captured data.
 		//
 
-		captureLength := t.config.Capture.Net.CaptureLength // after last known header
+		// after last known header, captured up to the biggest of the protocol
+		// capture lengths (if any): cut to the packet own capture length
+
+		captureLength := pcaps.PacketSnaplen(t.config.Capture.Net, packet)
+		truncate := captureLength < pcaps.MaxSnaplen(t.config.Capture.Net)
+
+		// an absolute payload ceiling takes precedence over the capture length
+		if ceiling := t.config.Capture.Net.PayloadCeiling; ceiling > 0 && captureLength > ceiling {
+			captureLength = ceiling
+			truncate = true
+		}
 
 		// parse packet
 		layer3 := packet.NetworkLayer()
 		layer4 := packet.TransportLayer()
 
-		ipHeaderLength := uint32(0)  // IP header length is dynamic
-		udpHeaderLength := uint32(8) // UDP header length is 8 bytes
-		tcpHeaderLength := uint32(0) // TCP header length is dynamic
+		// keep only a window of the TCP/UDP payload (if requested): the window
+		// bytes are moved right after the headers, so payload offsets are lost
+		// and the lengths below are mangled to the (new) packet size
+
+		if end := t.config.Capture.Net.PayloadWindowEnd; end > 0 && layer3 != nil && layer4 != nil {
+			headersEnd := netCapPrefixSize + len(layer3.LayerContents()) + len(layer4.LayerContents())
+			window := payloadWindow(payloadLayer2[headersEnd:], t.config.Capture.Net.PayloadWindowStart, end)
+			payloadLayer2 = append(payloadLayer2[:headersEnd:headersEnd], window...)
+			captureLength = uint32(len(window))
+			if ceiling := t.config.Capture.Net.PayloadCeiling; ceiling > 0 && captureLength > ceiling {
+				captureLength = ceiling
+			}
+			truncate = true
+		}
+
+		ipHeaderLength := uint32(0)    // IP header length is dynamic
+		udpHeaderLength := uint32(8)   // UDP header length is 8 bytes
+		tcpHeaderLength := uint32(0)   // TCP header length is dynamic
+		sctpHeaderLength := uint32(12) // SCTP common header length is 12 bytes
 
 		// will calculate L4 protocol headers length value
 		ipHeaderLengthValue := uint32(0)
@@ -198,7 +540,9 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 		switch v := layer3.(type) {
 		case (*layers.IPv4):
 			// Fake L2 header: IPv4 (BSD encap header spec)
-			binary.BigEndian.PutUint32(payloadLayer2, 2) // set value 2 to first 4 bytes (uint32)
+			if !putNetCapUint32(payloadLayer2, 0, 2) { // set value 2 to first 4 bytes (uint32)
+				return
+			}
 
 			// IP header depends on IHL flag (default: 5 * 4 = 20 bytes)
 			ipHeaderLength += uint32(v.IHL) * 4
@@ -210,22 +554,46 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 				break // always has "headers" only (payload = 0)
 			case layers.IPProtocolUDP:
 				// UDP
-				udpHeaderLengthValue += udpHeaderLength
 				ipHeaderLengthValue += udpHeaderLength
 			case layers.IPProtocolTCP:
 				// TCP
 				tcpHeaderLength = tcpDoff(layer4)
 				ipHeaderLengthValue += tcpHeaderLength
+			case layers.IPProtocolSCTP:
+				// SCTP (chunks are taken as payload)
+				ipHeaderLengthValue += sctpHeaderLength
+			case layers.IPProtocolGRE:
+				// GRE (tunneled packets are taken as payload)
+				ipHeaderLengthValue += greHeaderLength(packet)
+			case layers.IPProtocolESP:
+				// ESP (encrypted data is taken as payload)
+				ipHeaderLengthValue += espHeaderLength
+			case layers.IPProtocolAH:
+				// AH (protected packets are taken as payload)
+				ipHeaderLengthValue += ahHeaderLength(packet)
 			}
 
-			// add capture length (length to capture after last known proto header)
-			ipHeaderLengthValue += captureLength
-			udpHeaderLengthValue += captureLength
+			// the packet is what was captured: headers and the bytes after the
+			// last known header (derived from the captured bytes, whatever the
+			// header lengths counted by eBPF programs, e.g. TCP options)
+			captured := uint32(len(payloadLayer2[4:]))
 
-			// capture length is bigger than the pkt payload: no need for mangling
-			if ipHeaderLengthValue != uint32(len(payloadLayer2[4:])) {
+			// capture length is smaller than the pkt payload: truncate it
+			if truncate && ipHeaderLengthValue+captureLength < captured {
+				captured = ipHeaderLengthValue + captureLength
+				payloadLayer2 = payloadLayer2[:4+captured]
+			}
+
+			// whole packet captured: no need for mangling (packets cut short,
+			// decapsulated inner ones included, have their lengths set to what
+			// was captured)
+			if captured >= uint32(v.Length) {
 				break
-			} // else: mangle the packet (below) due to capture length
+			}
+			ipHeaderLengthValue = captured
+			if captured >= ipHeaderLength+udpHeaderLength {
+				udpHeaderLengthValue = captured - ipHeaderLength
+			}
 
 			// sanity check for max uint16 size in IP header length field
 			if ipHeaderLengthValue >= (1 << 16) {
@@ -233,11 +601,13 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 			}
 
 			// change IPv4 total length field for the correct (new) packet size
-			binary.BigEndian.PutUint16(payloadLayer2[6:], uint16(ipHeaderLengthValue))
+			if !putNetCapUint16(payloadLayer2, 4+2, uint16(ipHeaderLengthValue)) {
+				return
+			}
 			// no flags, frag offset OR checksum changes (tcpdump does not complain)
 
 			switch v.Protocol {
-			// TCP does not have a length field (uses checksum to verify)
+			// TCP and SCTP do not have a length field (use checksums to verify)
 			// no checksum recalculation (tcpdump does not complain)
 			case layers.IPProtocolUDP:
 				// NOTE: tcpdump might complain when parsing UDP packets that
@@ -251,67 +621,123 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 				//       default pcap snaplen is 96b.
 				//
 				// change UDP header length field for the correct (new) size
-				binary.BigEndian.PutUint16(
-					payloadLayer2[4+ipHeaderLength+4:],
-					uint16(udpHeaderLengthValue),
-				)
+				// (after the IP header, options or extension headers included)
+				if !putNetCapUint16(payloadLayer2, 4+ipHeaderLength+4, uint16(udpHeaderLengthValue)) {
+					return
+				}
 			}
 
 		case (*layers.IPv6):
 			// Fake L2 header: IPv6 (BSD encap header spec)
-			binary.BigEndian.PutUint32(payloadLayer2, 28) // set value 28 to first 4 bytes (uint32)
+			if !putNetCapUint32(payloadLayer2, 0, 28) { // set value 28 to first 4 bytes (uint32)
+				return
+			}
 
 			ipHeaderLength = uint32(40) // IPv6 does not have an IHL field
+
+			// extension headers are part of the header stack: the L4 protocol
+			// is given by the last one (no L4 header math for fragments)
+			nextHeader := v.NextHeader
+			for _, layer := range packet.Layers() {
+				switch ext := layer.(type) {
+				case *layers.IPv6HopByHop:
+					nextHeader = ext.NextHeader
+				case *layers.IPv6Routing:
+					nextHeader = ext.NextHeader
+				case *layers.IPv6Destination:
+					nextHeader = ext.NextHeader
+				case *layers.IPv6Fragment:
+					nextHeader = layers.IPProtocolIPv6Fragment
+				default:
+					continue
+				}
+				ipHeaderLength += uint32(len(layer.LayerContents()))
+			}
 			ipHeaderLengthValue += ipHeaderLength
 
-			switch v.NextHeader {
+			switch nextHeader {
 			case layers.IPProtocolICMPv6:
 				// ICMPv6
 				break // always has "headers" only (payload = 0)
 			case layers.IPProtocolUDP:
 				// UDP
-				udpHeaderLengthValue += udpHeaderLength
 				ipHeaderLengthValue += udpHeaderLength
 			case layers.IPProtocolTCP:
 				// TCP
 				tcpHeaderLength = tcpDoff(layer4)
 				ipHeaderLengthValue += tcpHeaderLength
+			case layers.IPProtocolSCTP:
+				// SCTP (chunks are taken as payload)
+				ipHeaderLengthValue += sctpHeaderLength
+			case layers.IPProtocolGRE:
+				// GRE (tunneled packets are taken as payload)
+				ipHeaderLengthValue += greHeaderLength(packet)
+			case layers.IPProtocolESP:
+				// ESP (encrypted data is taken as payload)
+				ipHeaderLengthValue += espHeaderLength
+			case layers.IPProtocolAH:
+				// AH (protected packets are taken as payload)
+				ipHeaderLengthValue += ahHeaderLength(packet)
 			}
 
-			// add capture length (length to capture after last known proto header)
-			ipHeaderLengthValue += captureLength
-			udpHeaderLengthValue += captureLength
+			// the packet is what was captured: headers and the bytes after the
+			// last known header (derived from the captured bytes, whatever the
+			// header lengths counted by eBPF programs, e.g. TCP options)
+			captured := uint32(len(payloadLayer2[4:]))
+
+			// capture length is smaller than the pkt payload: truncate it
+			if truncate && ipHeaderLengthValue+captureLength < captured {
+				captured = ipHeaderLengthValue + captureLength
+				payloadLayer2 = payloadLayer2[:4+captured]
+			}
 
-			// capture length is bigger than the pkt payload: no need for mangling
-			if ipHeaderLengthValue != uint32(len(payloadLayer2[4:])) {
+			// whole packet captured: no need for mangling (packets cut short,
+			// decapsulated inner ones included, have their lengths set to what
+			// was captured)
+			if captured >= 40+uint32(v.Length) {
 				break
-			} // else: mangle the packet (below) due to capture length
+			}
+			ipHeaderLengthValue = captured
+			if captured >= ipHeaderLength+udpHeaderLength {
+				udpHeaderLengthValue = captured - ipHeaderLength
+			}
 
-			// sanity check for max uint16 size in IP header length field
-			if ipHeaderLengthValue >= (1 << 16) {
-				ipHeaderLengthValue = (1 << 16) - 1
+			// IPv6 payload length does not count the fixed header (40 bytes)
+			payloadLengthValue := ipHeaderLengthValue - 40
+
+			// sanity check for max uint16 size in IP payload length field
+			if payloadLengthValue >= (1 << 16) {
+				payloadLengthValue = (1 << 16) - 1
 			}
 
 			// change IPv6 payload length field for the correct (new) packet size
-			binary.BigEndian.PutUint16(payloadLayer2[12:], uint16(ipHeaderLengthValue))
+			if !putNetCapUint16(payloadLayer2, 4+4, uint16(payloadLengthValue)) {
+				return
+			}
 			// no flags, frag offset OR checksum changes (tcpdump does not complain)
 
-			switch v.NextHeader {
-			// TCP does not have a length field (uses checksum to verify)
+			switch nextHeader {
+			// TCP and SCTP do not have a length field (use checksums to verify)
 			// no checksum recalculation (tcpdump does not complain)
 			case layers.IPProtocolUDP:
 				// NOTE: same as IPv4 note
 				// change UDP header length field for the correct (new) size
-				binary.BigEndian.PutUint16(
-					payloadLayer2[4+ipHeaderLength+4:],
-					uint16(udpHeaderLengthValue),
-				)
+				// (after the IP header, options or extension headers included)
+				if !putNetCapUint16(payloadLayer2, 4+ipHeaderLength+4, uint16(udpHeaderLengthValue)) {
+					return
+				}
 			}
 
 		default:
 			return
 		}
 
+		// recompute checksums stale after mangling (if requested)
+
+		if t.config.Capture.Net.FixChecksums {
+			fixChecksums(payloadLayer2[4:])
+		}
+
 		// This might be too much, but keep it here for now
 
 		// logger.Debugw(
@@ -321,9 +747,33 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 		// 	"dstIP", dstIP,
 		// )
 
-		// capture the packet to all enabled pcap files
+		// destination IP information (might filter the packet out)
+
+		if t.netCapIPInfo != nil {
+			var dstIP net.IP
+			switch v := layer3.(type) {
+			case *layers.IPv4:
+				dstIP = v.DstIP
+			case *layers.IPv6:
+				dstIP = v.DstIP
+			}
+			if !t.netCapIPInfo.process(event, dstIP) && !always {
+				return
+			}
+		}
+
+		// emit the packet to the events stream (if requested)
 
-		err := t.netCapturePcap.Write(event, payloadLayer2)
+		if t.config.Capture.Net.Events || t.config.Capture.Net.EventsOnly {
+			t.emitNetCapEvent(ctx, event, packet, payloadLayer2[netCapPrefixSize:])
+			if t.config.Capture.Net.EventsOnly {
+				return
+			}
+		}
+
+		// capture the packet to all enabled pcap files (or the capture sink)
+
+		err := t.netCaptureSink.Write(event, payloadLayer2)
 		if err != nil {
 			logger.Errorw("Could not write pcap data", "err", err)
 		}
@@ -332,3 +782,220 @@ func (t *Tracee) processNetCapEvent(event *trace.Event) {
 		logger.Debugw("Network capture: wrong net capture event type")
 	}
 }
+
+// isLoopbackPacket returns true if the packet source or destination address is
+// a loopback address (127.0.0.0/8 or ::1).
+func isLoopbackPacket(packet gopacket.Packet) bool {
+	switch v := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		return v.SrcIP.IsLoopback() || v.DstIP.IsLoopback()
+	case *layers.IPv6:
+		return v.SrcIP.IsLoopback() || v.DstIP.IsLoopback()
+	}
+
+	return false
+}
+
+// matchPacketPorts returns true if the packet source or destination port is one
+// of the given ports (or if no ports are given at all).
+func matchPacketPorts(packet gopacket.Packet, ports []uint16) bool {
+	if len(ports) == 0 {
+		return true
+	}
+
+	var src, dst uint16
+	switch v := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		src, dst = uint16(v.SrcPort), uint16(v.DstPort)
+	case *layers.UDP:
+		src, dst = uint16(v.SrcPort), uint16(v.DstPort)
+	case *layers.SCTP:
+		src, dst = uint16(v.SrcPort), uint16(v.DstPort)
+	default:
+		return false
+	}
+	for _, port := range ports {
+		if port == src || port == dst {
+			return true
+		}
+	}
+
+	return false
+}
+
+// payloadEntropy returns the Shannon entropy, in bits per byte (0 to 8), of the
+// packet payload tail (the data after the last known header). Packets without
+// payload have zero entropy. It costs a pass over every payload byte plus a
+// log2 per distinct byte value, for every packet. Payloads shorter than 256
+// bytes cannot reach 8 bits per byte (n bytes have at most log2(n) bits).
+func payloadEntropy(packet gopacket.Packet) float64 {
+	var payload []byte
+	if l4 := packet.TransportLayer(); l4 != nil {
+		payload = l4.LayerPayload()
+	} else if l3 := packet.NetworkLayer(); l3 != nil {
+		payload = l3.LayerPayload()
+	}
+	if len(payload) == 0 {
+		return 0
+	}
+
+	var counts [256]uint32
+	for _, b := range payload {
+		counts[b]++
+	}
+
+	entropy := 0.0
+	size := float64(len(payload))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / size
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// icmpDefaultMaxPayload is the payload size of a default ping (iputils, BSD and
+// macOS ping send 56 bytes, Windows ping sends 32 bytes).
+const icmpDefaultMaxPayload = 56
+
+// icmpTimestampSize is the room ping tools use for a timestamp at the start of
+// the echo payload (struct timeval: 8 or 16 bytes).
+const icmpTimestampSize = 16
+
+// isOrdinaryICMPEcho reports whether the packet is an ICMP (v4 or v6) echo
+// request or reply looking like a regular ping: a payload up to the given size
+// (default ping size if zero) filled the way ping tools fill it. Oversized or
+// oddly filled echoes are likely tunneling data (ICMP covert channels).
+func isOrdinaryICMPEcho(packet gopacket.Packet, maxPayload uint32) bool {
+	var payload []byte
+
+	if icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+		switch icmp.TypeCode.Type() {
+		case layers.ICMPv4TypeEchoRequest, layers.ICMPv4TypeEchoReply:
+			payload = icmp.LayerPayload()
+		default:
+			return false // not an echo
+		}
+	} else if echo, ok := packet.Layer(layers.LayerTypeICMPv6Echo).(*layers.ICMPv6Echo); ok {
+		payload = echo.LayerPayload()
+	} else {
+		return false // not an echo
+	}
+
+	if maxPayload == 0 {
+		maxPayload = icmpDefaultMaxPayload
+	}
+	if uint32(len(payload)) > maxPayload {
+		return false
+	}
+
+	return isPingPattern(payload)
+}
+
+// isPingPattern reports whether an echo payload is filled as ping tools do:
+// the Windows alphabet ("abcdefghijklmnopqrstuvwabcdefghi") or, after room for
+// a timestamp, incrementing bytes (iputils and BSD default) or a pattern of up
+// to 16 bytes repeated at least twice (ping -p). The timestamp is not checked.
+func isPingPattern(payload []byte) bool {
+	windows := true
+	for i, b := range payload {
+		if b != 'a'+byte(i%23) {
+			windows = false
+			break
+		}
+	}
+	if windows {
+		return true
+	}
+
+	if len(payload) <= icmpTimestampSize {
+		return true // timestamp only
+	}
+	data := payload[icmpTimestampSize:]
+
+	incrementing := true
+	for i := 1; i < len(data); i++ {
+		if data[i] != data[i-1]+1 {
+			incrementing = false
+			break
+		}
+	}
+	if incrementing {
+		return true
+	}
+
+	for period := 1; period <= 16 && period <= len(data)/2; period++ {
+		repeated := true
+		for i := period; i < len(data); i++ {
+			if data[i] != data[i-period] {
+				repeated = false
+				break
+			}
+		}
+		if repeated {
+			return true
+		}
+	}
+
+	return false
+}
+
+// putNetCapUint16 sets a (big endian) 16-bit field of a captured packet at the
+// given offset, returning false, and logging, if the packet is too short to
+// hold it (malformed or truncated headers).
+func putNetCapUint16(payload []byte, offset uint32, value uint16) bool {
+	if uint64(offset)+2 > uint64(len(payload)) {
+		logger.Debugw("Network capture: field out of packet bounds",
+			"offset", offset, "size", len(payload))
+		return false
+	}
+	binary.BigEndian.PutUint16(payload[offset:], value)
+	return true
+}
+
+// putNetCapUint32 sets a (big endian) 32-bit field of a captured packet at the
+// given offset, returning false, and logging, if the packet is too short to
+// hold it.
+func putNetCapUint32(payload []byte, offset uint32, value uint32) bool {
+	if uint64(offset)+4 > uint64(len(payload)) {
+		logger.Debugw("Network capture: field out of packet bounds",
+			"offset", offset, "size", len(payload))
+		return false
+	}
+	binary.BigEndian.PutUint32(payload[offset:], value)
+	return true
+}
+
+// payloadWindow returns the [start, end) byte range of given payload, clamped
+// to the payload size (empty if the payload ends before start).
+func payloadWindow(payload []byte, start, end uint32) []byte {
+	size := uint32(len(payload))
+	if start >= size || start >= end {
+		return nil
+	}
+	if end > size {
+		end = size
+	}
+
+	return payload[start:end]
+}
+
+// netCapProcessExit feeds process exits (of whole thread groups) to network
+// capture, so packets of short-lived processes are written.
+func (t *Tracee) netCapProcessExit(event *trace.Event) error {
+	if events.ID(event.EventID) != events.SchedProcessExit || t.netCapturePcap == nil {
+		return nil
+	}
+	groupExit, err := parse.ArgVal[bool](event.Args, "process_group_exit")
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	if groupExit {
+		t.netCapturePcap.ProcessExit(event)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package ebpf
+
+import (
+	"context"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// processNetCapARP captures an ARP packet (as delivered by eBPF programs, with
+// room for the fake layer 2 header). ARP packets have no IP header, ports or
+// L4 payload: they are captured as is (no snaplen, no length mangling), only
+// the capture filter applies.
+func (t *Tracee) processNetCapARP(ctx context.Context, event *trace.Event, payloadLayer2 []byte) {
+	packet := gopacket.NewPacket(payloadLayer2[netCapPrefixSize:], layers.LayerTypeARP, gopacket.Default)
+	if packet.Layer(layers.LayerTypeARP) == nil {
+		logger.Debugw("Network capture: invalid ARP packet")
+		return
+	}
+
+	// skip packets not matching the capture filter (if requested)
+
+	if t.netCapFilter != nil && !t.netCapFilter.Match(packet) {
+		_ = t.stats.NetCapFiltered.Increment()
+		return
+	}
+
+	// Fake L2 header: ARP (no address family, its EtherType instead)
+	if !putNetCapUint32(payloadLayer2, 0, pcaps.LoopbackARP) {
+		return
+	}
+	_ = t.stats.NetCapARP.Increment()
+
+	// emit the packet to the events stream (if requested)
+
+	if t.config.Capture.Net.Events || t.config.Capture.Net.EventsOnly {
+		t.emitNetCapEvent(ctx, event, packet, payloadLayer2[netCapPrefixSize:])
+		if t.config.Capture.Net.EventsOnly {
+			return
+		}
+	}
+
+	// capture the packet to all enabled pcap files (or the capture sink)
+
+	err := t.netCaptureSink.Write(event, payloadLayer2)
+	if err != nil {
+		logger.Errorw("Could not write pcap data", "err", err)
+	}
+}
@@ -0,0 +1,185 @@
+package ebpf
+
+import (
+	"encoding/binary"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+//
+// Packets with bad checksums are interesting on their own (faulty hardware,
+// injection attacks, evasion attempts relying on end hosts dropping them while
+// inspection devices don't). Checksums are validated as captured (before any
+// length mangling):
+//
+// - IPv4 header checksum: always (the header is never truncated).
+// - TCP, UDP, ICMP and ICMPv6 checksums: only if the whole packet was captured
+//   (not truncated by the capture length) and is not an IPv4 fragment.
+//
+// Checksums offloaded to the NIC are not invalid: outgoing packets are seen
+// before the NIC computes them, holding a zero checksum or only the (folded,
+// not complemented) pseudo-header sum (CHECKSUM_PARTIAL). SCTP (CRC32c) is not
+// validated.
+//
+
+// invalidChecksum returns the layer (ip, tcp, udp, icmp or icmpv6) of the
+// packet holding an invalid checksum, or an empty string if all checksums that
+// could be validated are valid.
+func invalidChecksum(packet gopacket.Packet) string {
+	var (
+		payload []byte // L3 payload
+		pseudo  uint32 // pseudo-header addresses sum
+	)
+
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		header := ip.LayerContents()
+		if ip.Checksum != 0 && checksumFold(checksumSum(0, header)) != 0xffff {
+			return "ip"
+		}
+		payload = ip.LayerPayload()
+		if int(ip.Length) != len(header)+len(payload) || ip.Flags&layers.IPv4MoreFragments != 0 || ip.FragOffset != 0 {
+			return "" // truncated (or a fragment)
+		}
+		pseudo = checksumSum(checksumSum(0, ip.SrcIP.To4()), ip.DstIP.To4())
+	case *layers.IPv6:
+		payload = ip.LayerPayload()
+		if int(ip.Length) != len(payload) {
+			return "" // truncated (or a jumbogram)
+		}
+		pseudo = checksumSum(checksumSum(0, ip.SrcIP.To16()), ip.DstIP.To16())
+	default:
+		return ""
+	}
+
+	var (
+		name     string
+		l4       gopacket.Layer
+		protocol layers.IPProtocol
+		checksum uint16
+	)
+
+	if tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		name, l4, protocol, checksum = "tcp", tcp, layers.IPProtocolTCP, tcp.Checksum
+	} else if udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		name, l4, protocol, checksum = "udp", udp, layers.IPProtocolUDP, udp.Checksum
+	} else if icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+		name, l4, checksum = "icmp", icmp, icmp.Checksum
+	} else if icmp, ok := packet.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6); ok {
+		name, l4, protocol, checksum = "icmpv6", icmp, layers.IPProtocolICMPv6, icmp.Checksum
+	} else {
+		return ""
+	}
+
+	// L4 data: the end of the L3 payload (after IPv6 extension headers)
+	length := len(l4.LayerContents()) + len(l4.LayerPayload())
+	if length > len(payload) {
+		return ""
+	}
+	data := payload[len(payload)-length:]
+
+	sum := uint32(0)
+	if protocol != 0 { // ICMP (v4) checksum does not cover a pseudo-header
+		sum = pseudo + uint32(protocol) + uint32(length)
+		if checksum == checksumFold(sum) {
+			return "" // offloaded: pseudo-header sum only (CHECKSUM_PARTIAL)
+		}
+	}
+	if checksum == 0 {
+		return "" // offloaded (or no UDP checksum)
+	}
+	if checksumFold(checksumSum(sum, data)) != 0xffff {
+		return name
+	}
+
+	return ""
+}
+
+// fixChecksums recomputes, in place, the IPv4 header checksum and the TCP or
+// UDP checksum of the given (IP) packet, over the captured bytes: truncated
+// packets, with mangled length fields, then hold coherent checksums. IPv4
+// fragments and IPv6 packets with extension headers are left untouched (their
+// L4 headers are missing or out of reach).
+func fixChecksums(data []byte) {
+	var (
+		l4       []byte // L4 header and payload
+		protocol layers.IPProtocol
+		pseudo   uint32 // pseudo-header addresses sum
+	)
+
+	if len(data) < 1 {
+		return
+	}
+
+	switch data[0] >> 4 {
+	case 4:
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return
+		}
+		binary.BigEndian.PutUint16(data[10:], 0)
+		binary.BigEndian.PutUint16(data[10:], ^checksumFold(checksumSum(0, data[:ihl])))
+		if binary.BigEndian.Uint16(data[6:])&0x3fff != 0 {
+			return // fragment (more fragments flag or fragment offset set)
+		}
+		l4, protocol = data[ihl:], layers.IPProtocol(data[9])
+		pseudo = checksumSum(0, data[12:20])
+	case 6:
+		if len(data) < 40 {
+			return
+		}
+		l4, protocol = data[40:], layers.IPProtocol(data[6])
+		pseudo = checksumSum(0, data[8:40])
+	default:
+		return
+	}
+
+	var offset int // checksum offset in the L4 header
+
+	switch protocol {
+	case layers.IPProtocolTCP:
+		offset = 16
+		if len(l4) < 20 {
+			return
+		}
+	case layers.IPProtocolUDP:
+		offset = 6
+		if len(l4) < 8 {
+			return
+		}
+	default:
+		return
+	}
+
+	binary.BigEndian.PutUint16(l4[offset:], 0)
+	sum := pseudo + uint32(protocol) + uint32(len(l4))
+	checksum := ^checksumFold(checksumSum(sum, l4))
+	if checksum == 0 && protocol == layers.IPProtocolUDP {
+		checksum = 0xffff // zero means no checksum for UDP
+	}
+	binary.BigEndian.PutUint16(l4[offset:], checksum)
+}
+
+// checksumSum adds given data, as 16-bit big endian words, to an internet
+// checksum sum.
+func checksumSum(sum uint32, data []byte) uint32 {
+	for len(data) > 1 {
+		sum += uint32(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		sum += uint32(data[0]) << 8
+	}
+
+	return sum
+}
+
+// checksumFold folds an internet checksum sum into 16 bits.
+func checksumFold(sum uint32) uint16 {
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return uint16(sum)
+}
@@ -0,0 +1,201 @@
+package ebpf
+
+import (
+	"context"
+	"encoding/binary"
+	"unsafe"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
+)
+
+//
+// Network capture might be stopped and started again while tracee runs (e.g.
+// for incident response), without restarting it:
+//
+// - StopNetCapture() stops eBPF programs from submitting packets, tears down
+//   the capture pipeline (queued packets are processed first) and ends the
+//   capture session (pcap files are closed and the manifest is written).
+// - StartNetCapture() starts a new capture session, a new capture pipeline,
+//   and lets eBPF programs submit packets again.
+//
+// Network capture must be enabled at startup: eBPF programs stay attached
+// while capture is stopped (they are shared with network events), only the
+// packets submission is turned off.
+//
+
+// NetCaptureOptions are the options network capture is started with.
+type NetCaptureOptions struct {
+	Filter string // capture filter expression, replacing the current one (empty: current one kept)
+}
+
+// StartNetCapture starts network capture (stopped by StopNetCapture).
+func (t *Tracee) StartNetCapture(opts NetCaptureOptions) error {
+	t.netCapMutex.Lock()
+	defer t.netCapMutex.Unlock()
+
+	if t.netCapChannel == nil {
+		return errfmt.Errorf("network capture not enabled")
+	}
+	if t.netCapCtx == nil || t.netCapCtx.Err() != nil {
+		return errfmt.Errorf("tracee is not running")
+	}
+	if t.netCapStop != nil {
+		return errfmt.Errorf("network capture already started")
+	}
+
+	if opts.Filter != "" {
+		filter, err := pcaps.CompileFilter(opts.Filter)
+		if err != nil {
+			return errfmt.WrapError(err)
+		}
+		t.netCapFilter = filter // the pipeline is stopped: no packets being processed
+	}
+	if !t.netCapturePcap.InSession() {
+		if err := t.netCapturePcap.StartSession(); err != nil {
+			return errfmt.WrapError(err)
+		}
+	}
+
+	t.startNetCapPipeline()
+
+	if err := t.updateNetConfigMap(false); err != nil {
+		return errfmt.WrapError(err)
+	}
+	logger.Infow("Network capture started")
+
+	return nil
+}
+
+// StopNetCapture stops network capture, until started again.
+func (t *Tracee) StopNetCapture() error {
+	t.netCapMutex.Lock()
+	defer t.netCapMutex.Unlock()
+
+	if t.netCapStop == nil {
+		return errfmt.Errorf("network capture not started")
+	}
+
+	// packets still in flight are captured (processed by the pipeline)
+	if err := t.updateNetConfigMap(true); err != nil {
+		logger.Errorw("Stopping network capture in eBPF", "error", err)
+	}
+	t.stopNetCapPipeline()
+
+	if t.netCapturePcap.InSession() {
+		if err := t.netCapturePcap.EndSession(); err != nil {
+			return errfmt.WrapError(err)
+		}
+	}
+	logger.Infow("Network capture stopped")
+
+	return nil
+}
+
+// TriggerNetCapture dumps the packets held in the network capture ring buffer
+// to pcap files (see pcaps.Pcaps.Trigger).
+func (t *Tracee) TriggerNetCapture() error {
+	if t.netCapturePcap == nil {
+		return errfmt.Errorf("network capture not enabled")
+	}
+	if t.config.Capture.Net.RingBuffer == 0 {
+		return errfmt.Errorf("network capture ring buffer not enabled")
+	}
+
+	return errfmt.WrapError(t.netCapturePcap.Trigger())
+}
+
+// startNetCapPipeline starts the network capture pipeline (decoding and
+// processing network capture events), until stopped by stopNetCapPipeline or
+// tracee stops. The caller holds netCapMutex.
+func (t *Tracee) startNetCapPipeline() {
+	ctx, stop := context.WithCancel(t.netCapCtx)
+	t.netCapStop = stop
+	t.netCapDrained = make(chan struct{})
+
+	var errChanList []<-chan error
+
+	// source pipeline stage (re-used from regular pipeline)
+	eventsChan, errChan := t.decodeEvents(ctx, t.netCapSource(ctx))
+	errChanList = append(errChanList, errChan)
+
+	// process events stage (network capture only)
+	errChan = t.processNetCapEvents(ctx, eventsChan)
+	errChanList = append(errChanList, errChan)
+
+	go func() {
+		// pipeline started, wait for completion.
+		if err := t.WaitForPipeline(errChanList...); err != nil {
+			logger.Errorw("Pipeline", "error", err)
+		}
+	}()
+}
+
+// stopNetCapPipeline stops the network capture pipeline (if started), once
+// queued captures are processed. The caller holds netCapMutex.
+func (t *Tracee) stopNetCapPipeline() {
+	if t.netCapStop == nil {
+		return
+	}
+	t.netCapStop()
+	t.netCapStop = nil
+	<-t.netCapDrained
+}
+
+// netCapSource forwards raw network capture events to a capture pipeline,
+// until the given context is done: the returned channel is then closed, so
+// the pipeline stages stop (and the next pipeline gets the next events).
+func (t *Tracee) netCapSource(ctx context.Context) chan []byte {
+	out := make(chan []byte)
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case dataRaw, ok := <-t.netCapChannel:
+				if !ok {
+					return
+				}
+				select {
+				case out <- dataRaw:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// updateNetConfigMap updates the network capture configuration of eBPF
+// programs: capture options and length, and whether packets are submitted
+// (not while capture is stopped).
+func (t *Tracee) updateNetConfigMap(stopped bool) error {
+	if t.bpfModule == nil {
+		return nil // eBPF programs not loaded
+	}
+	bpfNetConfigMap, err := t.bpfModule.GetMap("netconfig_map")
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	netConfigVal := make([]byte, 8) // u32 capture_options + u32 capture_length
+	options := pcaps.GetPcapOptions(t.config.Capture.Net)
+	if stopped {
+		options |= pcaps.Stopped
+	}
+	binary.LittleEndian.PutUint32(netConfigVal[0:4], uint32(options))
+	binary.LittleEndian.PutUint32(netConfigVal[4:8], pcaps.MaxSnaplen(t.config.Capture.Net))
+
+	cZero := uint32(0)
+	err = bpfNetConfigMap.Update(unsafe.Pointer(&cZero), unsafe.Pointer(&netConfigVal[0]))
+	if err != nil {
+		return errfmt.Errorf("error updating net config eBPF map: %v", err)
+	}
+
+	return nil
+}
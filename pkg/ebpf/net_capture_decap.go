@@ -0,0 +1,113 @@
+package ebpf
+
+import (
+	"slices"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// netCapDecapMaxDepth is the max number of nested tunnels decapsulated.
+const netCapDecapMaxDepth = 4
+
+// netCapVXLANPorts are the UDP (destination) ports of VXLAN tunnels, unless
+// configured.
+var netCapVXLANPorts = []uint16{4789}
+
+// decapNetCapPacket returns the innermost packet tunneled in GRE, or in VXLAN
+// (to one of the given UDP ports), by the given IP packet (of the given layer
+// type) and its layer type: nested tunnels are decapsulated as well (up to
+// netCapDecapMaxDepth). It returns false if the packet does not tunnel an IPv4
+// or IPv6 packet.
+func decapNetCapPacket(data []byte, layerType gopacket.LayerType, vxlanPorts []uint16) ([]byte, gopacket.LayerType, bool) {
+	decapsulated := false
+
+	for depth := 0; depth < netCapDecapMaxDepth; depth++ {
+		packet := gopacket.NewPacket(data, layerType, gopacket.Default)
+		tunnel := netCapTunnelLayer(packet)
+		if udp, ok := tunnel.(*layers.UDP); ok {
+			if !slices.Contains(vxlanPorts, uint16(udp.DstPort)) {
+				break
+			}
+			inner, innerType, ok := vxlanInnerPacket(udp.Payload)
+			if !ok {
+				break
+			}
+			data, layerType = inner, innerType
+			decapsulated = true
+			continue
+		}
+		gre, ok := tunnel.(*layers.GRE)
+		if !ok || len(gre.Payload) == 0 {
+			break
+		}
+
+		switch gre.Protocol {
+		case layers.EthernetTypeIPv4:
+			layerType = layers.LayerTypeIPv4
+		case layers.EthernetTypeIPv6:
+			layerType = layers.LayerTypeIPv6
+		default:
+			return data, layerType, decapsulated // not an IP tunnel (e.g. ERSPAN)
+		}
+		data = gre.Payload
+		decapsulated = true
+	}
+
+	return data, layerType, decapsulated
+}
+
+// netCapTunnelLayer returns the layer right after the (outer) IP header of a
+// packet, its IPv6 extension headers skipped, or nil if none: tunneled layers
+// are decoded as well, so they must not be taken as the tunnel.
+func netCapTunnelLayer(packet gopacket.Packet) gopacket.Layer {
+	if len(packet.Layers()) == 0 {
+		return nil
+	}
+	for _, layer := range packet.Layers()[1:] {
+		switch layer.LayerType() {
+		case layers.LayerTypeIPv6HopByHop, layers.LayerTypeIPv6Routing,
+			layers.LayerTypeIPv6Fragment, layers.LayerTypeIPv6Destination:
+			continue
+		}
+		return layer
+	}
+
+	return nil
+}
+
+// vxlanInnerPacket returns the IPv4 or IPv6 packet carried by the Ethernet
+// frame (VLAN tagged or not) of a VXLAN header and payload, and its layer
+// type. It returns false if the frame does not carry an IP packet.
+func vxlanInnerPacket(data []byte) ([]byte, gopacket.LayerType, bool) {
+	frame := gopacket.NewPacket(data, layers.LayerTypeVXLAN, gopacket.Default)
+	if _, ok := frame.Layer(layers.LayerTypeVXLAN).(*layers.VXLAN); !ok {
+		return nil, 0, false
+	}
+
+	var layerType gopacket.LayerType
+	switch frame.NetworkLayer().(type) {
+	case *layers.IPv4:
+		layerType = layers.LayerTypeIPv4
+	case *layers.IPv6:
+		layerType = layers.LayerTypeIPv6
+	default:
+		return nil, 0, false // not an IP packet (e.g. ARP)
+	}
+	ip := frame.NetworkLayer()
+	inner := make([]byte, 0, len(ip.LayerContents())+len(ip.LayerPayload()))
+	inner = append(inner, ip.LayerContents()...)
+
+	return append(inner, ip.LayerPayload()...), layerType, true
+}
+
+// greHeaderLength returns the length of the GRE header of a packet (its
+// optional fields included), or the length of the base header if it could
+// not be parsed.
+func greHeaderLength(packet gopacket.Packet) uint32 {
+	if gre, ok := packet.Layer(layers.LayerTypeGRE).(*layers.GRE); ok {
+		return uint32(len(gre.LayerContents()))
+	}
+
+	return 4
+}
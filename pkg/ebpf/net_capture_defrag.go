@@ -0,0 +1,134 @@
+package ebpf
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/ip4defrag"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+const (
+	netCapDefragTimeout = 30 * time.Second // incomplete fragment sets idle for longer are discarded
+	netCapDefragSweep   = time.Second      // how often idle fragment sets are looked for
+	netCapDefragMaxSets = 1024             // max incomplete fragment sets buffered at once
+)
+
+// netCapFragKey identifies the fragments of an IPv4 datagram.
+type netCapFragKey struct {
+	src      netip.Addr
+	dst      netip.Addr
+	id       uint16
+	protocol layers.IPProtocol
+}
+
+// netCapDefrag reassembles fragmented IPv4 datagrams: fragments are buffered
+// until the datagram is complete. Incomplete fragment sets are discarded once
+// idle for netCapDefragTimeout (packet time) or, when too many are buffered,
+// starting with the least recently active ones.
+type netCapDefrag struct {
+	defragmenters map[layers.IPProtocol]*ip4defrag.IPv4Defragmenter // by protocol (fragments are keyed by src, dst and id)
+	pending       map[netCapFragKey]time.Time                       // incomplete fragment sets (last fragment time)
+	swept         time.Time                                         // last time idle fragment sets were looked for
+	discarded     func(count int)                                   // called when incomplete fragment sets are discarded (if set)
+}
+
+func newNetCapDefrag() *netCapDefrag {
+	return &netCapDefrag{
+		defragmenters: make(map[layers.IPProtocol]*ip4defrag.IPv4Defragmenter),
+		pending:       make(map[netCapFragKey]time.Time),
+	}
+}
+
+// defrag returns the datagram the given IPv4 packet completes, if it is its
+// last missing fragment, or true if the packet is a fragment held until its
+// datagram is complete. Other packets (not fragments, or fragments that can't
+// be reassembled as not captured in full) are to be captured as is.
+func (d *netCapDefrag) defrag(data []byte, ts time.Time) ([]byte, bool) {
+	d.sweep(ts)
+
+	packet := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+	ip, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok || (ip.Flags&layers.IPv4MoreFragments == 0 && ip.FragOffset == 0) {
+		return nil, false // not a fragment
+	}
+	if int(ip.Length) > len(data) {
+		return nil, false // truncated
+	}
+	src, _ := netip.AddrFromSlice(ip.SrcIP)
+	dst, _ := netip.AddrFromSlice(ip.DstIP)
+	key := netCapFragKey{src: src, dst: dst, id: ip.Id, protocol: ip.Protocol}
+
+	defragmenter, ok := d.defragmenters[ip.Protocol]
+	if !ok {
+		defragmenter = ip4defrag.NewIPv4Defragmenter()
+		d.defragmenters[ip.Protocol] = defragmenter
+	}
+	if _, ok := d.pending[key]; !ok && len(d.pending) >= netCapDefragMaxSets {
+		d.evict()
+	}
+
+	datagram, err := defragmenter.DefragIPv4WithTimestamp(ip, ts)
+	if err != nil {
+		logger.Debugw("Network capture: invalid fragment", "error", err)
+		delete(d.pending, key) // flushed by the defragmenter (if it was the cause)
+		return nil, false
+	}
+	if datagram == nil {
+		d.pending[key] = ts
+		return nil, true
+	}
+	delete(d.pending, key)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, datagram, gopacket.Payload(datagram.Payload)); err != nil {
+		logger.Debugw("Network capture: could not reassemble datagram", "error", err)
+		return nil, true
+	}
+
+	return buf.Bytes(), false
+}
+
+// sweep discards the fragment sets idle for too long (looked for every
+// netCapDefragSweep at most).
+func (d *netCapDefrag) sweep(ts time.Time) {
+	if ts.Sub(d.swept) < netCapDefragSweep {
+		return
+	}
+	d.swept = ts
+
+	d.discard(ts.Add(-netCapDefragTimeout))
+}
+
+// evict discards the least recently active fragment sets.
+func (d *netCapDefrag) evict() {
+	var oldest time.Time
+	for _, last := range d.pending {
+		if oldest.IsZero() || last.Before(oldest) {
+			oldest = last
+		}
+	}
+
+	d.discard(oldest.Add(time.Nanosecond))
+}
+
+// discard discards the fragment sets without fragments since the given time.
+func (d *netCapDefrag) discard(before time.Time) {
+	count := 0
+	for _, defragmenter := range d.defragmenters {
+		count += defragmenter.DiscardOlderThan(before)
+	}
+	for key, last := range d.pending {
+		if last.Before(before) {
+			delete(d.pending, key)
+		}
+	}
+
+	if count > 0 && d.discarded != nil {
+		d.discarded(count)
+	}
+}
@@ -0,0 +1,172 @@
+package ebpf
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// netCapDNSPorts are the ports DNS messages are parsed from, unless configured.
+var netCapDNSPorts = []uint16{53}
+
+// emitNetCapDNSEvent emits the DNS message carried by a captured packet (if
+// any) to the events stream, as a net_packet_captured_dns event holding the
+// parsed query (or response). The event keeps the context (process,
+// container, policies...) of the network capture event.
+func (t *Tracee) emitNetCapDNSEvent(ctx context.Context, event *trace.Event, packet gopacket.Packet) {
+	ports := t.config.Capture.Net.DNSPorts
+	if len(ports) == 0 {
+		ports = netCapDNSPorts
+	}
+
+	dns := netCapDNS(packet, ports)
+	if dns == nil {
+		return
+	}
+
+	parsed := newNetCapDNSEvent(event, packet, dns)
+
+	// packets captured regardless of policies (pcap-options:none) did not
+	// match any: emit them to all streams anyway
+	if parsed.MatchedPoliciesUser == 0 {
+		parsed.MatchedPoliciesUser = ^uint64(0)
+	}
+
+	t.streamsManager.Publish(ctx, parsed)
+	_ = t.stats.NetCapDNSEvents.Increment()
+}
+
+// netCapDNS returns the DNS message carried by a packet from or to one of the
+// given DNS ports, over UDP or TCP (nil if none, or if not entirely captured).
+func netCapDNS(packet gopacket.Packet, ports []uint16) *layers.DNS {
+	if !matchPacketPorts(packet, ports) {
+		return nil
+	}
+
+	switch l4 := packet.TransportLayer().(type) {
+	case *layers.UDP:
+		if dns, ok := packet.Layer(layers.LayerTypeDNS).(*layers.DNS); ok {
+			return dns
+		}
+		return decodeNetCapDNS(l4.Payload)
+	case *layers.TCP:
+		// messages over TCP are prefixed by their length (2 bytes): only
+		// segments starting with a whole message are parsed
+		payload := l4.Payload
+		if len(payload) < 2 || len(payload[2:]) < int(binary.BigEndian.Uint16(payload)) {
+			return nil
+		}
+		return decodeNetCapDNS(payload[2 : 2+int(binary.BigEndian.Uint16(payload))])
+	}
+
+	return nil
+}
+
+// decodeNetCapDNS decodes a DNS message (nil if malformed or truncated).
+func decodeNetCapDNS(message []byte) *layers.DNS {
+	// decoding through a packet recovers from the decoder panics on
+	// truncated messages
+	decoded := gopacket.NewPacket(message, layers.LayerTypeDNS, gopacket.Default)
+	if decoded.ErrorLayer() != nil {
+		return nil
+	}
+	dns, _ := decoded.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	return dns
+}
+
+// newNetCapDNSEvent creates a net_packet_captured_dns event out of a network
+// capture event and the DNS message its packet carries.
+func newNetCapDNSEvent(event *trace.Event, packet gopacket.Packet, dns *layers.DNS) trace.Event {
+	var (
+		src, dst         string
+		srcPort, dstPort uint16
+		protocol         string
+	)
+
+	switch v := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, dst = v.SrcIP.String(), v.DstIP.String()
+	case *layers.IPv6:
+		src, dst = v.SrcIP.String(), v.DstIP.String()
+	}
+
+	switch v := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		srcPort, dstPort = uint16(v.SrcPort), uint16(v.DstPort)
+		protocol = "tcp"
+	case *layers.UDP:
+		srcPort, dstPort = uint16(v.SrcPort), uint16(v.DstPort)
+		protocol = "udp"
+	}
+
+	var qname, qtype string
+	if len(dns.Questions) > 0 {
+		qname = string(dns.Questions[0].Name)
+		qtype = dns.Questions[0].Type.String()
+	}
+	answers := make([]string, 0, len(dns.Answers))
+	for _, answer := range dns.Answers {
+		answers = append(answers, formatDNSAnswer(answer))
+	}
+
+	def := events.Core.GetDefinitionByID(events.CaptureNetPacketDNSEvent)
+	params := def.GetParams()
+
+	parsed := *event // keep the event context
+	parsed.EventID = int(events.CaptureNetPacketDNSEvent)
+	parsed.EventName = def.GetName()
+	parsed.ReturnValue = 0
+	parsed.ArgsNum = len(params)
+	parsed.Args = []trace.Argument{
+		{ArgMeta: params[0], Value: src},
+		{ArgMeta: params[1], Value: dst},
+		{ArgMeta: params[2], Value: srcPort},
+		{ArgMeta: params[3], Value: dstPort},
+		{ArgMeta: params[4], Value: protocol},
+		{ArgMeta: params[5], Value: dns.ID},
+		{ArgMeta: params[6], Value: dns.QR},
+		{ArgMeta: params[7], Value: qname},
+		{ArgMeta: params[8], Value: qtype},
+		{ArgMeta: params[9], Value: dns.ResponseCode.String()},
+		{ArgMeta: params[10], Value: answers},
+	}
+
+	return parsed
+}
+
+// formatDNSAnswer formats an answer record as "name type data".
+func formatDNSAnswer(answer layers.DNSResourceRecord) string {
+	var data string
+
+	switch answer.Type {
+	case layers.DNSTypeA, layers.DNSTypeAAAA:
+		data = answer.IP.String()
+	case layers.DNSTypeCNAME:
+		data = string(answer.CNAME)
+	case layers.DNSTypeNS:
+		data = string(answer.NS)
+	case layers.DNSTypePTR:
+		data = string(answer.PTR)
+	case layers.DNSTypeMX:
+		data = fmt.Sprintf("%d %s", answer.MX.Preference, answer.MX.Name)
+	case layers.DNSTypeSRV:
+		data = fmt.Sprintf("%d %d %d %s", answer.SRV.Priority, answer.SRV.Weight, answer.SRV.Port, answer.SRV.Name)
+	case layers.DNSTypeTXT:
+		txts := make([]string, 0, len(answer.TXTs))
+		for _, txt := range answer.TXTs {
+			txts = append(txts, string(txt))
+		}
+		data = strings.Join(txts, " ")
+	default:
+		data = fmt.Sprintf("%x", answer.Data)
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", answer.Name, answer.Type, data))
+}
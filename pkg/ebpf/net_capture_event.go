@@ -0,0 +1,86 @@
+package ebpf
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// emitNetCapEvent emits a captured packet to the events stream, as a
+// net_packet_captured event carrying a decoded L3/L4 summary of the packet and
+// its (base64 encoded, possibly truncated) contents. The event keeps the
+// context (process, container, policies...) of the network capture event.
+func (t *Tracee) emitNetCapEvent(ctx context.Context, event *trace.Event, packet gopacket.Packet, payload []byte) {
+	captured := newNetCapEvent(event, packet, payload, t.config.Capture.Net.EventPayloadSize)
+
+	// packets captured regardless of policies (pcap-options:none) did not
+	// match any: emit them to all streams anyway
+	if captured.MatchedPoliciesUser == 0 {
+		captured.MatchedPoliciesUser = ^uint64(0)
+	}
+
+	t.streamsManager.Publish(ctx, captured)
+	_ = t.stats.NetCapEvents.Increment()
+}
+
+// newNetCapEvent creates a net_packet_captured event out of a network capture
+// event and the (L3) packet it carries, encoding up to maxPayload bytes of the
+// packet (0: all of it).
+func newNetCapEvent(event *trace.Event, packet gopacket.Packet, payload []byte, maxPayload uint32) trace.Event {
+	var (
+		src, dst         string
+		srcPort, dstPort uint16
+		protocol         string
+	)
+
+	switch v := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, dst = v.SrcIP.String(), v.DstIP.String()
+		protocol = strings.ToLower(v.Protocol.String())
+	case *layers.IPv6:
+		src, dst = v.SrcIP.String(), v.DstIP.String()
+		protocol = strings.ToLower(v.NextHeader.String())
+	}
+
+	switch v := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		srcPort, dstPort = uint16(v.SrcPort), uint16(v.DstPort)
+	case *layers.UDP:
+		srcPort, dstPort = uint16(v.SrcPort), uint16(v.DstPort)
+	case *layers.SCTP:
+		srcPort, dstPort = uint16(v.SrcPort), uint16(v.DstPort)
+	}
+
+	length := len(payload)
+	truncated := maxPayload > 0 && uint32(length) > maxPayload
+	if truncated {
+		payload = payload[:maxPayload]
+	}
+
+	def := events.Core.GetDefinitionByID(events.CaptureNetPacketEvent)
+	params := def.GetParams()
+
+	captured := *event // keep the event context
+	captured.EventID = int(events.CaptureNetPacketEvent)
+	captured.EventName = def.GetName()
+	captured.ReturnValue = 0
+	captured.ArgsNum = len(params)
+	captured.Args = []trace.Argument{
+		{ArgMeta: params[0], Value: src},
+		{ArgMeta: params[1], Value: dst},
+		{ArgMeta: params[2], Value: srcPort},
+		{ArgMeta: params[3], Value: dstPort},
+		{ArgMeta: params[4], Value: protocol},
+		{ArgMeta: params[5], Value: uint32(length)},
+		{ArgMeta: params[6], Value: base64.StdEncoding.EncodeToString(payload)},
+		{ArgMeta: params[7], Value: truncated},
+	}
+
+	return captured
+}
@@ -0,0 +1,76 @@
+package ebpf
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// emitNetCapFlowEvent emits the summary of a tracked flow that is over (TCP
+// FIN or RST, idle timeout, eviction or end of capture) to the events stream,
+// as a net_flow_summary event. The event keeps the context (process,
+// container, policies...) of the first packet of the flow.
+func (t *Tracee) emitNetCapFlowEvent(ctx context.Context, s pcaps.FlowSummary) {
+	if s.Event == nil {
+		return
+	}
+
+	summary := newNetCapFlowEvent(s)
+
+	// packets captured regardless of policies (pcap-options:none) did not
+	// match any: emit them to all streams anyway
+	if summary.MatchedPoliciesUser == 0 {
+		summary.MatchedPoliciesUser = ^uint64(0)
+	}
+
+	t.streamsManager.Publish(ctx, summary)
+	_ = t.stats.NetCapFlowEvents.Increment()
+}
+
+// newNetCapFlowEvent creates a net_flow_summary event out of the summary of a
+// flow that is over.
+func newNetCapFlowEvent(s pcaps.FlowSummary) trace.Event {
+	src, srcPort := splitNetCapFlowEndpoint(s.Src)
+	dst, dstPort := splitNetCapFlowEndpoint(s.Dst)
+
+	def := events.Core.GetDefinitionByID(events.CaptureNetFlowSummaryEvent)
+	params := def.GetParams()
+
+	summary := *s.Event             // keep the event context
+	summary.Timestamp = int(s.Last) // over as of its last packet
+	summary.EventID = int(events.CaptureNetFlowSummaryEvent)
+	summary.EventName = def.GetName()
+	summary.ReturnValue = 0
+	summary.ArgsNum = len(params)
+	summary.Args = []trace.Argument{
+		{ArgMeta: params[0], Value: src},
+		{ArgMeta: params[1], Value: dst},
+		{ArgMeta: params[2], Value: srcPort},
+		{ArgMeta: params[3], Value: dstPort},
+		{ArgMeta: params[4], Value: s.Protocol},
+		{ArgMeta: params[5], Value: s.Packets},
+		{ArgMeta: params[6], Value: s.Bytes},
+		{ArgMeta: params[7], Value: uint64(s.First)},
+		{ArgMeta: params[8], Value: uint64(s.Last)},
+		{ArgMeta: params[9], Value: uint64(s.Last - s.First)},
+		{ArgMeta: params[10], Value: s.Reason},
+	}
+
+	return summary
+}
+
+// splitNetCapFlowEndpoint splits a flow endpoint ("ip:port") into its address
+// and port.
+func splitNetCapFlowEndpoint(endpoint string) (string, uint16) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return endpoint, 0
+	}
+	p, _ := strconv.ParseUint(port, 10, 16)
+
+	return host, uint16(p)
+}
@@ -0,0 +1,210 @@
+package ebpf
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// netCapHTTPPorts are the ports HTTP requests are parsed from, unless
+// configured.
+var netCapHTTPPorts = []uint16{80}
+
+const (
+	netCapHTTPTimeout  = 30 * time.Second // partial requests idle for longer are discarded
+	netCapHTTPSweep    = time.Second      // how often idle partial requests are looked for
+	netCapHTTPMaxFlows = 1024             // max flows with a partial request buffered at once
+)
+
+// netCapHTTPKey identifies the flow (client to server) of an HTTP request.
+type netCapHTTPKey struct {
+	src, dst         netip.Addr
+	srcPort, dstPort uint16
+}
+
+// netCapHTTPPartial is the beginning of an HTTP request whose head spans
+// multiple segments.
+type netCapHTTPPartial struct {
+	data []byte
+	next uint32    // sequence number of the next segment of the request
+	last time.Time // last segment time
+}
+
+// netCapHTTP buffers, per flow, the beginning of HTTP requests whose head
+// spans multiple segments, until their request line and Host header are
+// captured. Partial requests are discarded once idle for netCapHTTPTimeout
+// (packet time) or, when too many are buffered, starting with the least
+// recently active ones.
+type netCapHTTP struct {
+	partial map[netCapHTTPKey]*netCapHTTPPartial
+	swept   time.Time // last time idle partial requests were looked for
+}
+
+func newNetCapHTTP() *netCapHTTP {
+	return &netCapHTTP{
+		partial: make(map[netCapHTTPKey]*netCapHTTPPartial),
+	}
+}
+
+// segment returns the HTTP request a TCP segment (from a client) starts or
+// completes, buffering up to limit bytes per flow while the request head is
+// incomplete. Segments not starting a request (or not continuing one, in
+// order) are ignored.
+func (h *netCapHTTP) segment(ts time.Time, key netCapHTTPKey, tcp *layers.TCP, limit uint32) (pcaps.HTTPRequest, bool) {
+	h.sweep(ts)
+
+	payload := tcp.Payload
+	if len(payload) == 0 {
+		return pcaps.HTTPRequest{}, false
+	}
+
+	data := payload
+	if partial, ok := h.partial[key]; ok {
+		delete(h.partial, key)
+		if tcp.Seq == partial.next {
+			data = append(partial.data, payload...)
+		}
+		// otherwise: out of order (or retransmitted), start over from it
+	}
+	if uint32(len(data)) > limit {
+		data = data[:limit]
+	}
+
+	// bail on non-HTTP data right away
+	if !pcaps.MaybeHTTPRequest(data) {
+		return pcaps.HTTPRequest{}, false
+	}
+	request, ok := pcaps.ParseHTTPRequest(data)
+	if ok && (request.Host != "" || request.Complete || uint32(len(data)) >= limit) {
+		return request, true
+	}
+	if uint32(len(data)) >= limit {
+		return pcaps.HTTPRequest{}, false // request line not captured
+	}
+
+	if len(h.partial) >= netCapHTTPMaxFlows {
+		h.evict()
+	}
+	h.partial[key] = &netCapHTTPPartial{
+		data: append([]byte(nil), data...),
+		next: tcp.Seq + uint32(len(payload)),
+		last: ts,
+	}
+
+	return pcaps.HTTPRequest{}, false
+}
+
+// sweep discards the partial requests idle for too long (looked for every
+// netCapHTTPSweep at most).
+func (h *netCapHTTP) sweep(ts time.Time) {
+	if ts.Sub(h.swept) < netCapHTTPSweep {
+		return
+	}
+	h.swept = ts
+
+	for key, partial := range h.partial {
+		if ts.Sub(partial.last) > netCapHTTPTimeout {
+			delete(h.partial, key)
+		}
+	}
+}
+
+// evict discards the least recently active partial request.
+func (h *netCapHTTP) evict() {
+	var oldest netCapHTTPKey
+	var last time.Time
+	for key, partial := range h.partial {
+		if last.IsZero() || partial.last.Before(last) {
+			oldest, last = key, partial.last
+		}
+	}
+	delete(h.partial, oldest)
+}
+
+// emitNetCapHTTPEvent emits the HTTP request started (or completed) by a
+// captured packet, sent to one of the HTTP ports, to the events stream, as a
+// net_packet_http event holding the method, host and path of the request. The
+// event keeps the context (process, container, policies...) of the network
+// capture event.
+func (t *Tracee) emitNetCapHTTPEvent(ctx context.Context, event *trace.Event, packet gopacket.Packet) {
+	if t.netCapHTTP == nil {
+		return
+	}
+	ports := t.config.Capture.Net.HTTPPorts
+	if len(ports) == 0 {
+		ports = netCapHTTPPorts
+	}
+	tcp, ok := packet.TransportLayer().(*layers.TCP)
+	if !ok {
+		return
+	}
+	serverPort := false
+	for _, port := range ports {
+		serverPort = serverPort || uint16(tcp.DstPort) == port
+	}
+	if !serverPort {
+		return // requests are sent to the server port
+	}
+
+	var key netCapHTTPKey
+	switch v := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		key.src, _ = netip.AddrFromSlice(v.SrcIP.To4())
+		key.dst, _ = netip.AddrFromSlice(v.DstIP.To4())
+	case *layers.IPv6:
+		key.src, _ = netip.AddrFromSlice(v.SrcIP)
+		key.dst, _ = netip.AddrFromSlice(v.DstIP)
+	default:
+		return
+	}
+	key.srcPort, key.dstPort = uint16(tcp.SrcPort), uint16(tcp.DstPort)
+
+	// requests are buffered up to the packet capture length
+	limit := pcaps.PacketSnaplen(t.config.Capture.Net, packet)
+	request, ok := t.netCapHTTP.segment(time.Unix(0, int64(event.Timestamp)), key, tcp, limit)
+	if !ok {
+		return
+	}
+
+	parsed := newNetCapHTTPEvent(event, key, request)
+
+	// packets captured regardless of policies (pcap-options:none) did not
+	// match any: emit them to all streams anyway
+	if parsed.MatchedPoliciesUser == 0 {
+		parsed.MatchedPoliciesUser = ^uint64(0)
+	}
+
+	t.streamsManager.Publish(ctx, parsed)
+	_ = t.stats.NetCapHTTPEvents.Increment()
+}
+
+// newNetCapHTTPEvent creates a net_packet_http event out of a network capture
+// event and the HTTP request of its flow.
+func newNetCapHTTPEvent(event *trace.Event, key netCapHTTPKey, request pcaps.HTTPRequest) trace.Event {
+	def := events.Core.GetDefinitionByID(events.CaptureNetPacketHTTPEvent)
+	params := def.GetParams()
+
+	parsed := *event // keep the event context
+	parsed.EventID = int(events.CaptureNetPacketHTTPEvent)
+	parsed.EventName = def.GetName()
+	parsed.ReturnValue = 0
+	parsed.ArgsNum = len(params)
+	parsed.Args = []trace.Argument{
+		{ArgMeta: params[0], Value: key.src.String()},
+		{ArgMeta: params[1], Value: key.dst.String()},
+		{ArgMeta: params[2], Value: key.srcPort},
+		{ArgMeta: params[3], Value: key.dstPort},
+		{ArgMeta: params[4], Value: request.Method},
+		{ArgMeta: params[5], Value: request.Host},
+		{ArgMeta: params[6], Value: request.Path},
+	}
+
+	return parsed
+}
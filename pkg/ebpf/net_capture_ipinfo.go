@@ -0,0 +1,168 @@
+package ebpf
+
+import (
+	"net"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/ipinfo"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// netCapIPInfoCacheSize is the amount of destination addresses whose lookup
+// results are cached (lookups are done for every captured packet).
+const netCapIPInfoCacheSize = 10000
+
+// netCapIPInfo enriches, and filters, captured packets with information about
+// their destination IP address. The information is added to the network
+// capture event (as arguments) and recorded, by pcaps, as packet metadata.
+type netCapIPInfo struct {
+	asn          ipinfo.ASNResolver
+	asnAllow     map[uint32]struct{} // only capture packets to these ASNs (if set)
+	asnDeny      map[uint32]struct{} // never capture packets to these ASNs
+	geo          ipinfo.GeoResolver
+	countryAllow map[string]struct{} // only capture packets to these countries (if set)
+	countryDeny  map[string]struct{} // never capture packets to these countries
+}
+
+// newNetCapIPInfo returns nil if no destination IP information is needed.
+func newNetCapIPInfo(cfg config.PcapsConfig) (*netCapIPInfo, error) {
+	if len(cfg.ASNDatabases) == 0 && (len(cfg.ASNAllow) > 0 || len(cfg.ASNDeny) > 0) {
+		return nil, errfmt.Errorf("ASN filters require an ASN database")
+	}
+	if cfg.GeoDatabase == "" && (len(cfg.CountryAllow) > 0 || len(cfg.CountryDeny) > 0) {
+		return nil, errfmt.Errorf("country filters require a GeoIP database")
+	}
+	if len(cfg.ASNDatabases) == 0 && cfg.GeoDatabase == "" {
+		return nil, nil
+	}
+
+	n := &netCapIPInfo{
+		asnAllow:     uint32Set(cfg.ASNAllow),
+		asnDeny:      uint32Set(cfg.ASNDeny),
+		countryAllow: countrySet(cfg.CountryAllow),
+		countryDeny:  countrySet(cfg.CountryDeny),
+	}
+
+	if len(cfg.ASNDatabases) > 0 {
+		db, err := ipinfo.LoadASNDatabase(cfg.ASNDatabases...)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		n.asn, err = ipinfo.NewCachedASNResolver(db, netCapIPInfoCacheSize)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	if cfg.GeoDatabase != "" {
+		db, err := ipinfo.LoadGeoDatabase(cfg.GeoDatabase)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		n.geo, err = ipinfo.NewCachedGeoResolver(db, netCapIPInfoCacheSize)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	return n, nil
+}
+
+// process adds the destination IP information to the given event and returns
+// false if the packet should not be captured.
+func (n *netCapIPInfo) process(event *trace.Event, dstIP net.IP) bool {
+	return n.processASN(event, dstIP) && n.processGeo(event, dstIP)
+}
+
+func (n *netCapIPInfo) processASN(event *trace.Event, dstIP net.IP) bool {
+	if n.asn == nil || dstIP == nil {
+		return n.asnAllow == nil
+	}
+
+	asn, found := n.asn.LookupASN(dstIP)
+	if found {
+		setNetCapArg(event, trace.ArgMeta{Type: "u32", Name: "dst_asn"}, asn.Number)
+		setNetCapArg(event, trace.ArgMeta{Type: "const char *", Name: "dst_as_org"}, asn.Organization)
+	}
+
+	if n.asnAllow != nil {
+		if _, ok := n.asnAllow[asn.Number]; !found || !ok {
+			return false
+		}
+	}
+	if _, ok := n.asnDeny[asn.Number]; found && ok {
+		return false
+	}
+
+	return true
+}
+
+func (n *netCapIPInfo) processGeo(event *trace.Event, dstIP net.IP) bool {
+	// only external destinations are geolocated
+	if n.geo == nil || dstIP == nil || !isExternalIP(dstIP) {
+		return n.countryAllow == nil
+	}
+
+	location, found := n.geo.LookupLocation(dstIP)
+	if found {
+		setNetCapArg(event, trace.ArgMeta{Type: "const char *", Name: "dst_country"}, location.CountryCode)
+		if location.City != "" {
+			setNetCapArg(event, trace.ArgMeta{Type: "const char *", Name: "dst_city"}, location.City)
+		}
+	}
+
+	if n.countryAllow != nil {
+		if _, ok := n.countryAllow[location.CountryCode]; !found || !ok {
+			return false
+		}
+	}
+	if _, ok := n.countryDeny[location.CountryCode]; found && ok {
+		return false
+	}
+
+	return true
+}
+
+// isExternalIP returns true for globally routable unicast addresses.
+func isExternalIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// setNetCapArg sets the value of an optional event argument (adding it if the
+// event does not carry it at all).
+func setNetCapArg(event *trace.Event, meta trace.ArgMeta, value interface{}) {
+	if arg := events.GetArg(event, meta.Name); arg != nil {
+		arg.Value = value
+		return
+	}
+	event.Args = append(event.Args, trace.Argument{ArgMeta: meta, Value: value})
+}
+
+func countrySet(codes []string) map[string]struct{} {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	set := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(code)] = struct{}{}
+	}
+
+	return set
+}
+
+func uint32Set(values []uint32) map[uint32]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	set := make(map[uint32]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	return set
+}
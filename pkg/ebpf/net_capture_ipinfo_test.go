@@ -0,0 +1,144 @@
+package ebpf
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/ipinfo"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// stubASNResolver resolves ASNs from a fixed map (by IP address string).
+type stubASNResolver map[string]ipinfo.ASN
+
+func (s stubASNResolver) LookupASN(ip net.IP) (ipinfo.ASN, bool) {
+	asn, ok := s[ip.String()]
+	return asn, ok
+}
+
+// newNetCapTestUDPEvent returns a network capture event carrying an IPv4 UDP
+// packet to the given destination.
+func newNetCapTestUDPEvent(t *testing.T, dst string) *trace.Event {
+	t.Helper()
+
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	ip.DstIP = net.ParseIP(dst).To4()
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 443}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+
+	return newNetCapTestEvent(familyIpv4, serializeNetCapTestPacket(t, ip, udp))
+}
+
+func TestProcessNetCapEventASN(t *testing.T) {
+	resolver := stubASNResolver{
+		"1.1.1.1": {Number: 13335, Organization: "CLOUDFLARENET"},
+		"8.8.8.8": {Number: 15169, Organization: "GOOGLE"},
+	}
+
+	tests := []struct {
+		name    string
+		allow   []uint32
+		deny    []uint32
+		written []string // destinations of the captured packets
+	}{
+		{
+			name:    "no filters",
+			written: []string{"1.1.1.1", "8.8.8.8", "10.0.0.2"},
+		},
+		{
+			name:    "deny list",
+			deny:    []uint32{15169},
+			written: []string{"1.1.1.1", "10.0.0.2"},
+		},
+		{
+			name:    "allow list",
+			allow:   []uint32{13335},
+			written: []string{"1.1.1.1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{CaptureLength: 96})
+			tracee.netCapIPInfo = &netCapIPInfo{
+				asn:      resolver,
+				asnAllow: uint32Set(tc.allow),
+				asnDeny:  uint32Set(tc.deny),
+			}
+
+			byDst := map[string]*trace.Event{}
+			for _, dst := range []string{"1.1.1.1", "8.8.8.8", "10.0.0.2"} {
+				byDst[dst] = newNetCapTestUDPEvent(t, dst)
+				tracee.processNetCapEvent(context.Background(), byDst[dst])
+			}
+
+			// known destinations are tagged (even if filtered out)
+			asn := events.GetArg(byDst["1.1.1.1"], "dst_asn")
+			require.NotNil(t, asn)
+			require.Equal(t, uint32(13335), asn.Value)
+			org := events.GetArg(byDst["1.1.1.1"], "dst_as_org")
+			require.NotNil(t, org)
+			require.Equal(t, "CLOUDFLARENET", org.Value)
+			require.Nil(t, events.GetArg(byDst["10.0.0.2"], "dst_asn"))
+
+			var written []string
+			for _, pkt := range readNetCapTestPackets(t, tracee, dir) {
+				written = append(written, net.IP(pkt[4+16:4+20]).String())
+			}
+			require.Equal(t, tc.written, written)
+		})
+	}
+}
+
+// stubGeoResolver resolves locations from a fixed map (by IP address string).
+type stubGeoResolver map[string]ipinfo.Location
+
+func (s stubGeoResolver) LookupLocation(ip net.IP) (ipinfo.Location, bool) {
+	location, ok := s[ip.String()]
+	return location, ok
+}
+
+func TestProcessNetCapEventGeo(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{CaptureLength: 96})
+	tracee.netCapIPInfo = &netCapIPInfo{
+		geo: stubGeoResolver{
+			"8.8.8.8":  {CountryCode: "US", Country: "United States", City: "Mountain View"},
+			"5.9.1.1":  {CountryCode: "DE", Country: "Germany"},
+			"10.0.0.2": {CountryCode: "XX"}, // private: never looked up
+		},
+		countryDeny: countrySet([]string{"de"}),
+	}
+
+	byDst := map[string]*trace.Event{}
+	for _, dst := range []string{"8.8.8.8", "5.9.1.1", "10.0.0.2"} {
+		byDst[dst] = newNetCapTestUDPEvent(t, dst)
+		tracee.processNetCapEvent(context.Background(), byDst[dst])
+	}
+
+	country := events.GetArg(byDst["8.8.8.8"], "dst_country")
+	require.NotNil(t, country)
+	require.Equal(t, "US", country.Value)
+	city := events.GetArg(byDst["8.8.8.8"], "dst_city")
+	require.NotNil(t, city)
+	require.Equal(t, "Mountain View", city.Value)
+
+	country = events.GetArg(byDst["5.9.1.1"], "dst_country")
+	require.NotNil(t, country)
+	require.Equal(t, "DE", country.Value)
+	require.Nil(t, events.GetArg(byDst["5.9.1.1"], "dst_city"))
+
+	require.Nil(t, events.GetArg(byDst["10.0.0.2"], "dst_country"))
+
+	// packets to denied countries are not captured
+	var written []string
+	for _, pkt := range readNetCapTestPackets(t, tracee, dir) {
+		written = append(written, net.IP(pkt[4+16:4+20]).String())
+	}
+	require.Equal(t, []string{"8.8.8.8", "10.0.0.2"}, written)
+}
@@ -0,0 +1,22 @@
+package ebpf
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// espHeaderLength is the ESP header length: SPI (4) | sequence number (4). The
+// rest (encrypted payload, padding, trailer and ICV) can't be told apart once
+// encrypted: it is taken as payload.
+const espHeaderLength = 8
+
+// ahHeaderLength returns the AH header length of the given packet (its ICV
+// included): the protected packet is taken as payload. Headers not decoded
+// (e.g. truncated) are taken as the minimum AH header (no ICV).
+func ahHeaderLength(packet gopacket.Packet) uint32 {
+	if ah, ok := packet.Layer(layers.LayerTypeIPSecAH).(*layers.IPSecAH); ok {
+		return uint32(len(ah.LayerContents()))
+	}
+
+	return 12
+}
@@ -0,0 +1,37 @@
+package ebpf
+
+import (
+	"math"
+
+	"github.com/google/gopacket"
+)
+
+// sampleNetCapFlow returns true if the flow of the given packet is sampled (to
+// be captured) for the given sample rate (fraction of flows captured). The
+// decision is made by hashing the flow 5-tuple, so it is the same for all the
+// packets of a flow (both directions), and packets without a network layer are
+// always sampled.
+func sampleNetCapFlow(packet gopacket.Packet, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	network := packet.NetworkLayer()
+	if network == nil {
+		return true
+	}
+
+	// flow hashes are symmetric (same for both directions)
+	hash := network.NetworkFlow().FastHash()
+	if transport := packet.TransportLayer(); transport != nil {
+		hash = hash*31 + transport.TransportFlow().FastHash()
+	}
+
+	// mixed (splitmix64 finalizer) to spread hashes evenly
+	hash ^= hash >> 30
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 27
+	hash *= 0x94d049bb133111eb
+	hash ^= hash >> 31
+
+	return float64(hash) < rate*math.MaxUint64
+}
@@ -0,0 +1,35 @@
+package ebpf
+
+// NetCaptureStats are the network capture statistics (also exported as
+// metrics), for operators to read at runtime.
+type NetCaptureStats struct {
+	Captured     uint64 // network capture events processed
+	Lost         uint64 // network capture events lost (perf buffer full)
+	Filtered     uint64 // packets dropped by userspace capture filters (loopback, entropy, filter expression...)
+	Written      uint64 // packets written to pcap files (once per file)
+	WrittenBytes uint64 // packet bytes written to pcap files (once per file)
+}
+
+// NetCaptureStats returns the network capture statistics (all zeroed if
+// network capture is disabled).
+func (t *Tracee) NetCaptureStats() NetCaptureStats {
+	stats := NetCaptureStats{
+		Captured: t.stats.NetCapCount.Get(),
+		Lost:     t.stats.LostNtCapCount.Get(),
+		Filtered: t.stats.NetCapLoopCount.Get() +
+			t.stats.NetCapLowEntropy.Get() +
+			t.stats.NetCapICMPNormal.Get() +
+			t.stats.NetCapSrcSkipped.Get() +
+			t.stats.NetCapNotUrgent.Get() +
+			t.stats.NetCapChecksumOK.Get() +
+			t.stats.NetCapFiltered.Get(),
+	}
+
+	// read from the capture writer (metrics are only updated per event)
+	if t.netCapturePcap != nil {
+		stats.Written = t.netCapturePcap.Stats().Written.Get()
+		stats.WrittenBytes = t.netCapturePcap.Stats().WrittenBytes.Get()
+	}
+
+	return stats
+}
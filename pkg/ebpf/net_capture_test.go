@@ -0,0 +1,1854 @@
+package ebpf
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
+	"github.com/aquasecurity/tracee/pkg/streams"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// newNetCapTestTracee creates a Tracee instance able to process network
+// capture events, writing a single pcap file into a temporary directory.
+// NOTE: pcaps output directory is a package global: tests using it must not
+// run in parallel.
+func newNetCapTestTracee(t *testing.T, netCfg config.PcapsConfig) (*Tracee, string) {
+	t.Helper()
+
+	netCfg.CaptureSingle = true
+
+	dir := t.TempDir()
+	outDir, err := utils.OpenExistingDir(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = outDir.Close() })
+
+	netCapturePcap, err := pcaps.New(netCfg, outDir)
+	require.NoError(t, err)
+
+	var netCapFilter *pcaps.Filter
+	if netCfg.Filter != "" {
+		netCapFilter, err = pcaps.CompileFilter(netCfg.Filter)
+		require.NoError(t, err)
+	}
+
+	var netCapDefrag *netCapDefrag
+	if netCfg.Defrag {
+		netCapDefrag = newNetCapDefrag()
+	}
+
+	var netCapHTTP *netCapHTTP
+	if netCfg.HTTPEvents {
+		netCapHTTP = newNetCapHTTP()
+	}
+
+	return &Tracee{
+		config: config.Config{
+			Capture: &config.CaptureConfig{Net: netCfg},
+		},
+		OutDir:         outDir,
+		netCapturePcap: netCapturePcap,
+		netCaptureSink: netCapturePcap,
+		netCapFilter:   netCapFilter,
+		netCapDefrag:   netCapDefrag,
+		netCapHTTP:     netCapHTTP,
+	}, dir
+}
+
+// newNetCapTestEvent creates a network capture event carrying given L3 packet.
+func newNetCapTestEvent(family int, packet []byte) *trace.Event {
+	return &trace.Event{
+		Timestamp:   1,
+		EventID:     int(events.NetPacketCapture),
+		ReturnValue: family,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Type: "bytes", Name: "payload"}, Value: packet},
+		},
+	}
+}
+
+// serializeNetCapTestPacket serializes given L3+ layers.
+func serializeNetCapTestPacket(t *testing.T, l ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, l...))
+
+	return buf.Bytes()
+}
+
+// newNetCapTestIPv4 returns an IPv4 header for given L4 protocol.
+func newNetCapTestIPv4(protocol layers.IPProtocol) *layers.IPv4 {
+	return &layers.IPv4{
+		Version:  4,
+		IHL:      5,
+		TTL:      64,
+		Protocol: protocol,
+		SrcIP:    net.IP{10, 0, 0, 1},
+		DstIP:    net.IP{10, 0, 0, 2},
+	}
+}
+
+// readNetCapTestPackets returns the packets written to the single pcap file.
+func readNetCapTestPackets(t *testing.T, tracee *Tracee, dir string) [][]byte {
+	t.Helper()
+
+	require.NoError(t, tracee.netCapturePcap.Destroy())
+
+	f, err := os.Open(filepath.Join(dir, "pcap", "single.pcap"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	r, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+
+	var pkts [][]byte
+	for {
+		data, _, err := r.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		pkts = append(pkts, data)
+	}
+
+	return pkts
+}
+
+func TestProcessNetCapEventPayloadCeiling(t *testing.T) {
+	payload := make([]byte, 4096)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload(payload))
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength:  (1 << 16) - 1, // max (full capture)
+		PayloadCeiling: 100,
+	})
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 1)
+	require.Len(t, pkts[0], 4+20+8+100) // fake L2 + IPv4 + UDP + ceiling
+
+	captured := gopacket.NewPacket(pkts[0], layers.LayerTypeLoopback, gopacket.Default)
+	ipv4 := captured.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	require.Equal(t, uint16(20+8+100), ipv4.Length)
+	udpLayer := captured.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	require.Equal(t, uint16(8+100), udpLayer.Length)
+	require.Equal(t, payload[:100], udpLayer.Payload)
+}
+
+func TestProcessNetCapEventProtocolSnaplen(t *testing.T) {
+	payload := make([]byte, 300)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	newUDPPacket := func(dstPort layers.UDPPort) []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 40000, DstPort: dstPort}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, udp, gopacket.Payload(payload))
+	}
+
+	// captured up to the biggest snaplen (dns), then cut to their own
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength:  16,
+		CaptureLengths: map[string]uint32{"dns": 200},
+	})
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newUDPPacket(53)[:20+8+200]))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newUDPPacket(5678)[:20+8+200]))
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 2)
+	require.Len(t, pkts[0], 4+20+8+200) // fake L2 + IPv4 + UDP + dns snaplen
+	require.Len(t, pkts[1], 4+20+8+16)  // fake L2 + IPv4 + UDP + default snaplen
+
+	captured := gopacket.NewPacket(pkts[1], layers.LayerTypeLoopback, gopacket.Default)
+	require.Equal(t, uint16(20+8+16), captured.Layer(layers.LayerTypeIPv4).(*layers.IPv4).Length)
+	require.Equal(t, payload[:16], captured.Layer(layers.LayerTypeUDP).(*layers.UDP).Payload)
+}
+
+func TestProcessNetCapEventPayloadWindow(t *testing.T) {
+	payload := make([]byte, 300)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+	tcp := &layers.TCP{SrcPort: 1234, DstPort: 80, Seq: 1, ACK: true, PSH: true, Window: 1024}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+	tcpPacket := serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload(payload))
+
+	ip = newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	udpPacket := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload(payload[:150]))
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength:      (1 << 16) - 1, // max (full capture)
+		PayloadWindowStart: 100,
+		PayloadWindowEnd:   200,
+	})
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, tcpPacket))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, udpPacket))
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 2)
+
+	// whole window available: bytes 100 up to 200 kept, right after the headers
+	require.Len(t, pkts[0], 4+20+20+100)
+	captured := gopacket.NewPacket(pkts[0], layers.LayerTypeLoopback, gopacket.Default)
+	ipv4 := captured.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	require.Equal(t, uint16(20+20+100), ipv4.Length)
+	tcpLayer := captured.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	require.Equal(t, payload[100:200], tcpLayer.Payload)
+
+	// payload ends inside the window: bytes 100 up to its end kept
+	require.Len(t, pkts[1], 4+20+8+50)
+	captured = gopacket.NewPacket(pkts[1], layers.LayerTypeLoopback, gopacket.Default)
+	ipv4 = captured.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	require.Equal(t, uint16(20+8+50), ipv4.Length)
+	udpLayer := captured.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	require.Equal(t, uint16(8+50), udpLayer.Length)
+	require.Equal(t, payload[100:150], udpLayer.Payload)
+}
+
+func TestProcessNetCapEventSCTP(t *testing.T) {
+	chunks := make([]byte, 100)
+	for i := range chunks {
+		chunks[i] = byte(i)
+	}
+
+	ip := newNetCapTestIPv4(layers.IPProtocolSCTP)
+	sctp := &layers.SCTP{SrcPort: 2905, DstPort: 2905, VerificationTag: 1}
+	packet := serializeNetCapTestPacket(t, ip, sctp, gopacket.Payload(chunks))
+
+	// truncated by the capture length (as done by the kernel side)
+	const captureLength = 20
+	packet = packet[:20+12+captureLength]
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{CaptureLength: captureLength})
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 1)
+	require.Len(t, pkts[0], 4+20+12+captureLength) // fake L2 + IPv4 + SCTP + capture length
+
+	// IPv4 total length mangled to the captured size
+	captured := gopacket.NewPacket(pkts[0], layers.LayerTypeLoopback, gopacket.Default)
+	ipv4 := captured.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	require.Equal(t, uint16(20+12+captureLength), ipv4.Length)
+	sctpLayer := captured.Layer(layers.LayerTypeSCTP).(*layers.SCTP)
+	require.Equal(t, uint16(2905), uint16(sctpLayer.SrcPort))
+}
+
+func TestProcessNetCapEventIPsec(t *testing.T) {
+	encrypted := make([]byte, 100)
+	for i := range encrypted {
+		encrypted[i] = byte(i)
+	}
+
+	// ESP: SPI (4) | sequence number (4) | encrypted data
+	esp := append([]byte{0, 0, 0x10, 0x01, 0, 0, 0, 1}, encrypted...)
+
+	// AH: next header (1) | payload length (1) | reserved (2) | SPI (4) |
+	// sequence number (4) | ICV (12), protecting a TCP segment
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 443, Seq: 1, ACK: true, Window: 1024}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(newNetCapTestIPv4(layers.IPProtocolTCP)))
+	ah := append([]byte{byte(layers.IPProtocolTCP), 24/4 - 2, 0, 0, 0, 0, 0x10, 0x02, 0, 0, 0, 1}, make([]byte, 12)...)
+	ah = append(ah, serializeNetCapTestPacket(t, tcp, gopacket.Payload(encrypted))...)
+
+	ip6 := &layers.IPv6{
+		Version:    6,
+		HopLimit:   64,
+		NextHeader: layers.IPProtocolESP,
+		SrcIP:      net.ParseIP("fd00::1"),
+		DstIP:      net.ParseIP("fd00::2"),
+	}
+
+	// truncated by the capture length (as done by the kernel side)
+	const captureLength = 20
+	testCases := []struct {
+		name         string
+		family       int
+		packet       []byte
+		headerLength int // IP and ESP/AH headers
+	}{
+		{"esp", familyIpv4, serializeNetCapTestPacket(t, newNetCapTestIPv4(layers.IPProtocolESP), gopacket.Payload(esp)), 20 + 8},
+		{"ah", familyIpv4, serializeNetCapTestPacket(t, newNetCapTestIPv4(layers.IPProtocolAH), gopacket.Payload(ah)), 20 + 24},
+		{"esp over ipv6", familyIpv6, serializeNetCapTestPacket(t, ip6, gopacket.Payload(esp)), 40 + 8},
+	}
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{CaptureLength: captureLength})
+	for _, tc := range testCases {
+		packet := tc.packet[:tc.headerLength+captureLength]
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(tc.family, packet))
+	}
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, len(testCases))
+	for i, tc := range testCases {
+		require.Len(t, pkts[i], 4+tc.headerLength+captureLength, tc.name)
+
+		// IP length mangled to the captured size
+		captured := gopacket.NewPacket(pkts[i], layers.LayerTypeLoopback, gopacket.Default)
+		switch ip := captured.NetworkLayer().(type) {
+		case *layers.IPv4:
+			require.Equal(t, uint16(tc.headerLength+captureLength), ip.Length, tc.name)
+		case *layers.IPv6:
+			require.Equal(t, uint16(tc.headerLength-40+captureLength), ip.Length, tc.name)
+		}
+	}
+}
+
+func TestProcessNetCapEventL2Mode(t *testing.T) {
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload("payload"))
+
+	prefixed := binary.LittleEndian.AppendUint32(nil, uint32(len(packet)))
+	prefixed = append(prefixed, packet...)
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+	})
+	// unknown source (no direction flags): the mode is detected from the payload
+	bare := newNetCapTestEvent(familyIpv4, packet)
+	tracee.processNetCapEvent(context.Background(), bare)
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, prefixed))
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 2)
+
+	fakeL2 := []byte{0, 0, 0, 2} // BSD loopback encapsulation: IPv4
+
+	// no prefix: fake header prepended, packet bytes kept intact
+	require.Equal(t, append(fakeL2, packet...), pkts[0])
+	require.Equal(t, packet, bare.Args[0].Value) // event payload untouched
+
+	// size prefix: fake header overwrote the prefix
+	require.Equal(t, append(fakeL2, packet...), pkts[1])
+}
+
+func TestProcessNetCapEventEmptyPayload(t *testing.T) {
+	t.Parallel()
+
+	tracee := &Tracee{}
+
+	// prefix only: no packet data, skipped (and counted) instead of parsed
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, make([]byte, netCapPrefixSize)))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, []byte{}))
+
+	require.Equal(t, uint64(2), tracee.stats.NetCapEmptyCount.Get())
+}
+
+func TestProcessNetCapEventUnknownFamily(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{})
+
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 53}
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload("query"))
+
+	// no family bit: skipped (and counted) instead of parsed as garbage
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(0, packet))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+
+	require.Equal(t, uint64(1), tracee.stats.NetCapNoFamily.Get())
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 1)
+}
+
+func TestProcessNetCapEventFilter(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: 96,
+		Filter:        "udp port 53 or tcp port 443",
+	})
+
+	newEvent := func(l4 gopacket.SerializableLayer) *trace.Event {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		switch v := l4.(type) {
+		case *layers.TCP:
+			ip.Protocol = layers.IPProtocolTCP
+			require.NoError(t, v.SetNetworkLayerForChecksum(ip))
+		case *layers.UDP:
+			require.NoError(t, v.SetNetworkLayerForChecksum(ip))
+		}
+		return newNetCapTestEvent(familyIpv4, serializeNetCapTestPacket(t, ip, l4, gopacket.Payload("data")))
+	}
+
+	dns := newEvent(&layers.UDP{SrcPort: 40000, DstPort: 53})
+	tls := newEvent(&layers.TCP{SrcPort: 40001, DstPort: 443, ACK: true, Window: 1024})
+	tracee.processNetCapEvent(context.Background(), dns)
+	tracee.processNetCapEvent(context.Background(), newEvent(&layers.UDP{SrcPort: 40002, DstPort: 5678}))
+	tracee.processNetCapEvent(context.Background(), tls)
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 2)
+	require.Equal(t, dns.Args[0].Value, pkts[0][4:])
+	require.Equal(t, tls.Args[0].Value, pkts[1][4:])
+	require.Equal(t, uint64(1), tracee.stats.NetCapFiltered.Get())
+
+	require.Equal(t, NetCaptureStats{
+		Filtered:     1,
+		Written:      2,
+		WrittenBytes: uint64(len(pkts[0]) + len(pkts[1])),
+	}, tracee.NetCaptureStats())
+}
+
+func TestProcessNetCapEventAlwaysPorts(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength:   96,
+		ExcludeLoopback: true,
+		TCPUrgentOnly:   true,
+		PortFilter:      []uint16{443},
+		AlwaysPorts:     []uint16{22, 3389},
+	})
+
+	newTCPEvent := func(dst net.IP, port layers.TCPPort) *trace.Event {
+		ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+		ip.DstIP = dst
+		tcp := &layers.TCP{SrcPort: 40000, DstPort: port, ACK: true, Window: 1024}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+		return newNetCapTestEvent(familyIpv4, serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload("data")))
+	}
+
+	// loopback, not urgent and not a filtered port: only always ports pass
+	ssh := newTCPEvent(net.IP{127, 0, 0, 1}, 22)
+	tracee.processNetCapEvent(context.Background(), ssh)
+	tracee.processNetCapEvent(context.Background(), newTCPEvent(net.IP{127, 0, 0, 1}, 80))
+	tracee.processNetCapEvent(context.Background(), newTCPEvent(net.IP{10, 0, 0, 2}, 443))
+	rdp := newTCPEvent(net.IP{10, 0, 0, 2}, 3389)
+	tracee.processNetCapEvent(context.Background(), rdp)
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 2)
+	require.Equal(t, ssh.Args[0].Value, pkts[0][4:])
+	require.Equal(t, rdp.Args[0].Value, pkts[1][4:])
+	require.Equal(t, uint64(1), tracee.stats.NetCapLoopCount.Get())
+	require.Equal(t, uint64(1), tracee.stats.NetCapNotUrgent.Get())
+}
+
+func TestProcessNetCapEventExcludeLoopback(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength:   96,
+		ExcludeLoopback: true,
+	})
+
+	newUDPEvent := func(src, dst net.IP, family int) *trace.Event {
+		var ip gopacket.NetworkLayer
+		switch family {
+		case familyIpv4:
+			ipv4 := newNetCapTestIPv4(layers.IPProtocolUDP)
+			ipv4.SrcIP, ipv4.DstIP = src, dst
+			ip = ipv4
+		case familyIpv6:
+			ip = &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: src, DstIP: dst}
+		}
+		udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		return newNetCapTestEvent(family, serializeNetCapTestPacket(t, ip.(gopacket.SerializableLayer), udp))
+	}
+
+	tracee.processNetCapEvent(context.Background(), newUDPEvent(net.IP{127, 0, 0, 1}, net.IP{127, 0, 0, 53}, familyIpv4))
+	tracee.processNetCapEvent(context.Background(), newUDPEvent(net.IP{10, 0, 0, 1}, net.IP{127, 1, 2, 3}, familyIpv4))
+	tracee.processNetCapEvent(context.Background(), newUDPEvent(net.IPv6loopback, net.IPv6loopback, familyIpv6))
+	tracee.processNetCapEvent(context.Background(), newUDPEvent(net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}, familyIpv4))
+
+	require.Equal(t, uint64(3), tracee.stats.NetCapLoopCount.Get())
+	require.Len(t, readNetCapTestPackets(t, tracee, dir), 1)
+}
+
+func TestProcessNetCapEventFamily(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: 96,
+		Family:        pcaps.FamilyIPv4,
+	})
+
+	ipv4 := newNetCapTestIPv4(layers.IPProtocolUDP)
+	ipv6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: net.ParseIP("fd00::1"), DstIP: net.ParseIP("fd00::2")}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ipv6))
+	ipv6Packet := serializeNetCapTestPacket(t, ipv6, udp)
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ipv4))
+	ipv4Packet := serializeNetCapTestPacket(t, ipv4, udp)
+
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv6, ipv6Packet))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, ipv4Packet))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv6, ipv6Packet))
+
+	require.Equal(t, uint64(2), tracee.stats.NetCapFamilySkip.Get())
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 1)
+	require.Equal(t, uint8(4), pkts[0][netCapPrefixSize]>>4) // IPv4 only
+}
+
+func TestProcessNetCapEventMinEntropy(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+		MinEntropy:    7,
+		EntropyPorts:  []uint16{443},
+	})
+
+	high := make([]byte, 1024)
+	_, err := rand.New(rand.NewSource(1)).Read(high)
+	require.NoError(t, err)
+	low := bytes.Repeat([]byte("GET /index.html HTTP/1.1\r\n"), 40)
+
+	newUDPEvent := func(dstPort layers.UDPPort, payload []byte) *trace.Event {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 1234, DstPort: dstPort}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		return newNetCapTestEvent(familyIpv4, serializeNetCapTestPacket(t, ip, udp, gopacket.Payload(payload)))
+	}
+
+	tracee.processNetCapEvent(context.Background(), newUDPEvent(443, high))
+	tracee.processNetCapEvent(context.Background(), newUDPEvent(443, low))
+	tracee.processNetCapEvent(context.Background(), newUDPEvent(80, low)) // not in scope
+
+	require.Equal(t, uint64(1), tracee.stats.NetCapLowEntropy.Get())
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 2)
+	for i, expected := range [][]byte{high, low} {
+		captured := gopacket.NewPacket(pkts[i], layers.LayerTypeLoopback, gopacket.Default)
+		require.Equal(t, expected, captured.Layer(layers.LayerTypeUDP).(*layers.UDP).Payload)
+	}
+}
+
+func TestProcessNetCapEventICMPAnomalous(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		ICMPAnomalous: true,
+	})
+
+	// iputils ping: timestamp followed by incrementing bytes (56 bytes)
+	ping := make([]byte, 56)
+	for i := range ping {
+		ping[i] = byte(i)
+	}
+	windows := []byte("abcdefghijklmnopqrstuvwabcdefghi")
+	oversized := bytes.Repeat([]byte{0x41}, 1024)
+	tunnel := append(make([]byte, 16), []byte("ssh-2.0-openssh_9.6 key exchange")...)
+
+	newICMPEvent := func(typ uint8, payload []byte) *trace.Event {
+		ip := newNetCapTestIPv4(layers.IPProtocolICMPv4)
+		icmp := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(typ, 0), Id: 1, Seq: 1}
+		// room for the bytes cut by the capture prefix (see newNetCapTestEvent)
+		payload = append(payload, make([]byte, netCapPrefixSize)...)
+		return newNetCapTestEvent(familyIpv4, serializeNetCapTestPacket(t, ip, icmp, gopacket.Payload(payload)))
+	}
+
+	tracee.processNetCapEvent(context.Background(), newICMPEvent(layers.ICMPv4TypeEchoRequest, ping[:56-netCapPrefixSize]))
+	tracee.processNetCapEvent(context.Background(), newICMPEvent(layers.ICMPv4TypeEchoReply, windows[:32-netCapPrefixSize]))
+	tracee.processNetCapEvent(context.Background(), newICMPEvent(layers.ICMPv4TypeEchoRequest, oversized))
+	tracee.processNetCapEvent(context.Background(), newICMPEvent(layers.ICMPv4TypeEchoRequest, tunnel))
+	tracee.processNetCapEvent(context.Background(), newICMPEvent(layers.ICMPv4TypeDestinationUnreachable, nil)) // not an echo
+
+	require.Equal(t, uint64(2), tracee.stats.NetCapICMPNormal.Get())
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 3)
+	for i, expected := range []layers.ICMPv4TypeCode{
+		layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+		layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+		layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, 0),
+	} {
+		captured := gopacket.NewPacket(pkts[i], layers.LayerTypeLoopback, gopacket.Default)
+		require.Equal(t, expected, captured.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4).TypeCode)
+	}
+}
+
+func TestProcessNetCapEventSources(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		Sources: []string{pcaps.SourceCgroupSkbEgress},
+	})
+
+	newUDPEvent := func(dstPort layers.UDPPort, flags int) *trace.Event {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 1234, DstPort: dstPort}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		event := newNetCapTestEvent(familyIpv4, serializeNetCapTestPacket(t, ip, udp, gopacket.Payload("data")))
+		event.ReturnValue |= flags
+		return event
+	}
+
+	ingress := newUDPEvent(1111, 1<<4)
+	egress := newUDPEvent(2222, 1<<5)
+	unknown := newUDPEvent(3333, 0)
+
+	tracee.processNetCapEvent(context.Background(), ingress)
+	tracee.processNetCapEvent(context.Background(), egress)
+	tracee.processNetCapEvent(context.Background(), unknown)
+
+	require.Equal(t, uint64(2), tracee.stats.NetCapSrcSkipped.Get())
+
+	// the source is recorded in the event
+	source := events.GetArg(egress, "source")
+	require.NotNil(t, source)
+	require.Equal(t, pcaps.SourceCgroupSkbEgress, source.Value)
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 1)
+	captured := gopacket.NewPacket(pkts[0], layers.LayerTypeLoopback, gopacket.Default)
+	require.Equal(t, layers.UDPPort(2222), captured.Layer(layers.LayerTypeUDP).(*layers.UDP).DstPort)
+}
+
+func TestProcessNetCapEventVerdict(t *testing.T) {
+	tracee, _ := newNetCapTestTracee(t, config.PcapsConfig{})
+
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload("data"))
+
+	dropped := newNetCapTestEvent(familyIpv4, packet)
+	dropped.ReturnValue |= 1<<5 | 1<<12 // egress, dropped
+	observed := newNetCapTestEvent(familyIpv4, packet)
+	observed.ReturnValue |= 1 << 5 // egress, no verdict
+
+	tracee.processNetCapEvent(context.Background(), dropped)
+	tracee.processNetCapEvent(context.Background(), observed)
+
+	// the verdict is recorded in the event (and, by pcaps, as packet metadata)
+	verdict := events.GetArg(dropped, "verdict")
+	require.NotNil(t, verdict)
+	require.Equal(t, pcaps.VerdictDrop, verdict.Value)
+	require.Nil(t, events.GetArg(observed, "verdict"))
+}
+
+func TestProcessNetCapEventTCPUrgent(t *testing.T) {
+	newTCPEvent := func(urg bool) *trace.Event {
+		ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+		tcp := &layers.TCP{SrcPort: 1234, DstPort: 80, ACK: true, PSH: true, URG: urg, Window: 1024}
+		if urg {
+			tcp.Urgent = 1
+		}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+		return newNetCapTestEvent(familyIpv4, serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload("!data")))
+	}
+
+	testCases := []struct {
+		name     string
+		only     bool
+		captured []bool // urgent and tagged
+	}{
+		{name: "tag", only: false, captured: []bool{false, true}},
+		{name: "only", only: true, captured: []bool{true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+				TCPUrgent:     true,
+				TCPUrgentOnly: tc.only,
+			})
+
+			urgent := newTCPEvent(true)
+			tracee.processNetCapEvent(context.Background(), newTCPEvent(false))
+			tracee.processNetCapEvent(context.Background(), urgent)
+
+			arg := events.GetArg(urgent, "tcp_urgent_ptr")
+			require.NotNil(t, arg)
+			require.Equal(t, uint16(1), arg.Value)
+
+			pkts := readNetCapTestPackets(t, tracee, dir)
+			require.Len(t, pkts, len(tc.captured))
+			for i, urg := range tc.captured {
+				captured := gopacket.NewPacket(pkts[i], layers.LayerTypeLoopback, gopacket.Default)
+				require.Equal(t, urg, captured.Layer(layers.LayerTypeTCP).(*layers.TCP).URG)
+			}
+			if tc.only {
+				require.Equal(t, uint64(1), tracee.stats.NetCapNotUrgent.Get())
+			}
+		})
+	}
+}
+
+func TestInvalidChecksum(t *testing.T) {
+	t.Parallel()
+
+	newUDP := func() []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 1234, DstPort: 53}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, udp, gopacket.Payload("query"))
+	}
+	newTCP := func() []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+		tcp := &layers.TCP{SrcPort: 1234, DstPort: 80, ACK: true, PSH: true, Window: 1024}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload("GET / HTTP/1.1"))
+	}
+	newICMP := func() []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolICMPv4)
+		icmp := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 1}
+		return serializeNetCapTestPacket(t, ip, icmp, gopacket.Payload("ping"))
+	}
+	newIPv6TCP := func() []byte {
+		ip := &layers.IPv6{
+			Version:    6,
+			NextHeader: layers.IPProtocolTCP,
+			HopLimit:   64,
+			SrcIP:      net.ParseIP("fd00::1"),
+			DstIP:      net.ParseIP("fd00::2"),
+		}
+		tcp := &layers.TCP{SrcPort: 1234, DstPort: 80, ACK: true, PSH: true, Window: 1024}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload("GET / HTTP/1.1"))
+	}
+	corrupt := func(pkt []byte, offset int) []byte {
+		pkt[offset] ^= 0xff
+		return pkt
+	}
+	setChecksum := func(pkt []byte, offset int, checksum uint16) []byte {
+		binary.BigEndian.PutUint16(pkt[offset:], checksum)
+		return pkt
+	}
+	partial := func(pkt []byte, protocol layers.IPProtocol) uint16 {
+		sum := checksumSum(checksumSum(0, pkt[12:16]), pkt[16:20])
+		return checksumFold(sum + uint32(protocol) + uint32(len(pkt)-20))
+	}
+
+	udpPartial := newUDP()
+	tcpPartial := newTCP()
+
+	testCases := []struct {
+		name     string
+		family   gopacket.LayerType
+		packet   []byte
+		expected string
+	}{
+		{name: "valid udp", packet: newUDP()},
+		{name: "valid tcp", packet: newTCP()},
+		{name: "valid icmp", packet: newICMP()},
+		{name: "valid ipv6 tcp", family: layers.LayerTypeIPv6, packet: newIPv6TCP()},
+		{name: "bad ip header", packet: corrupt(newUDP(), 10), expected: "ip"},
+		{name: "bad udp", packet: corrupt(newUDP(), 20+6), expected: "udp"},
+		{name: "bad tcp", packet: corrupt(newTCP(), 20+16), expected: "tcp"},
+		{name: "bad tcp payload", packet: corrupt(newTCP(), 20+20+1), expected: "tcp"},
+		{name: "bad icmp", packet: corrupt(newICMP(), 20+2), expected: "icmp"},
+		{name: "bad ipv6 tcp", family: layers.LayerTypeIPv6, packet: corrupt(newIPv6TCP(), 40+16), expected: "tcp"},
+		{name: "offloaded zero udp", packet: setChecksum(newUDP(), 20+6, 0)},
+		{name: "offloaded partial udp", packet: setChecksum(udpPartial, 20+6, partial(udpPartial, layers.IPProtocolUDP))},
+		{name: "offloaded partial tcp", packet: setChecksum(tcpPartial, 20+16, partial(tcpPartial, layers.IPProtocolTCP))},
+		{name: "truncated tcp", packet: corrupt(newTCP(), 20+16)[:20+20+4]},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			family := tc.family
+			if family == gopacket.LayerTypeZero {
+				family = layers.LayerTypeIPv4
+			}
+			packet := gopacket.NewPacket(tc.packet, family, gopacket.Default)
+			require.Equal(t, tc.expected, invalidChecksum(packet))
+		})
+	}
+}
+
+func TestProcessNetCapEventBadChecksum(t *testing.T) {
+	newUDPEvent := func(bad bool) *trace.Event {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload("data"))
+		if bad {
+			packet[20+6] ^= 0xff // corrupted UDP checksum
+		}
+		return newNetCapTestEvent(familyIpv4, packet)
+	}
+
+	testCases := []struct {
+		name     string
+		only     bool
+		captured []bool // bad checksum (and tagged)
+	}{
+		{name: "tag", only: false, captured: []bool{false, true}},
+		{name: "only", only: true, captured: []bool{true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+				CaptureLength:   (1 << 16) - 1, // max (full capture)
+				BadChecksum:     true,
+				BadChecksumOnly: tc.only,
+			})
+
+			valid, bad := newUDPEvent(false), newUDPEvent(true)
+			tracee.processNetCapEvent(context.Background(), valid)
+			tracee.processNetCapEvent(context.Background(), bad)
+
+			arg := events.GetArg(bad, "bad_checksum")
+			require.NotNil(t, arg)
+			require.Equal(t, "udp", arg.Value)
+			require.Nil(t, events.GetArg(valid, "bad_checksum"))
+
+			// captured as is (the bad checksum is kept)
+			pkts := readNetCapTestPackets(t, tracee, dir)
+			require.Len(t, pkts, len(tc.captured))
+			for i, isBad := range tc.captured {
+				expected := valid
+				if isBad {
+					expected = bad
+				}
+				require.Equal(t, expected.Args[0].Value, pkts[i][4:])
+			}
+			if tc.only {
+				require.Equal(t, uint64(1), tracee.stats.NetCapChecksumOK.Get())
+			}
+		})
+	}
+}
+
+func TestProcessNetCapEventFixChecksums(t *testing.T) {
+	payload := gopacket.Payload(bytes.Repeat([]byte("data"), 25))
+
+	newEvents := func() []*trace.Event {
+		tcpIP := newNetCapTestIPv4(layers.IPProtocolTCP)
+		tcp := &layers.TCP{SrcPort: 1234, DstPort: 80, Seq: 1, ACK: true, Window: 1024}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(tcpIP))
+		udpIP := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(udpIP))
+		// truncated to the capture length (as done by eBPF programs)
+		tcpPacket := serializeNetCapTestPacket(t, tcpIP, tcp, payload)
+		udpPacket := serializeNetCapTestPacket(t, udpIP, udp, payload)
+		return []*trace.Event{
+			newNetCapTestEvent(familyIpv4, tcpPacket[:len(tcpPacket)-len(payload)+8]),
+			newNetCapTestEvent(familyIpv4, udpPacket[:len(udpPacket)-len(payload)+8]),
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		fix      bool
+		expected string // invalid checksum of captured packets
+	}{
+		{name: "stale", fix: false, expected: "ip"},
+		{name: "fixed", fix: true, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+				CaptureLength: 8, // lengths mangled
+				FixChecksums:  tc.fix,
+			})
+
+			for _, event := range newEvents() {
+				tracee.processNetCapEvent(context.Background(), event)
+			}
+
+			pkts := readNetCapTestPackets(t, tracee, dir)
+			require.Len(t, pkts, 2)
+			for _, pkt := range pkts {
+				packet := gopacket.NewPacket(pkt[4:], layers.LayerTypeIPv4, gopacket.Default)
+				require.Len(t, packet.TransportLayer().LayerPayload(), 8)
+				require.Equal(t, tc.expected, invalidChecksum(packet))
+			}
+		})
+	}
+}
+
+func TestProcessNetCapEventIPv6ExtensionHeaders(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: 8, // lengths mangled
+	})
+
+	src, dst := net.ParseIP("fd00::1"), net.ParseIP("fd00::2")
+	ip := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolIPv6HopByHop, SrcIP: src, DstIP: dst}
+	hopByHop := gopacket.Payload{byte(layers.IPProtocolUDP), 0, 1, 4, 0, 0, 0, 0} // PadN option (8 bytes)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	payload := gopacket.Payload(bytes.Repeat([]byte("data"), 25))
+
+	// truncated to the capture length (as done by eBPF programs)
+	packet := serializeNetCapTestPacket(t, ip, hopByHop, udp, payload)
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv6, packet[:len(packet)-len(payload)+8]))
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 1)
+	captured := pkts[0][4:]
+	require.Len(t, captured, 40+8+8+8)
+
+	require.Equal(t, uint16(8+8+8), binary.BigEndian.Uint16(captured[4:])) // IPv6 payload length
+	require.Equal(t, src.To16(), net.IP(captured[8:24]))
+	require.Equal(t, uint16(8+8), binary.BigEndian.Uint16(captured[40+8+4:])) // UDP length
+
+	decoded := gopacket.NewPacket(captured, layers.LayerTypeIPv6, gopacket.Default)
+	require.NotNil(t, decoded.Layer(layers.LayerTypeIPv6HopByHop))
+	require.Len(t, decoded.Layer(layers.LayerTypeUDP).LayerPayload(), 8)
+}
+
+func TestProcessNetCapEventIPv4Options(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: 8, // lengths mangled
+	})
+
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	ip.Options = []layers.IPv4Option{{OptionType: 148, OptionLength: 4, OptionData: []byte{0, 0}}} // router alert
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	payload := gopacket.Payload(bytes.Repeat([]byte("data"), 25))
+
+	// truncated to the capture length (as done by eBPF programs)
+	packet := serializeNetCapTestPacket(t, ip, udp, payload)
+	require.Equal(t, byte(0x46), packet[0]) // IHL: 6 (24 bytes)
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet[:len(packet)-len(payload)+8]))
+
+	pkts := readNetCapTestPackets(t, tracee, dir)
+	require.Len(t, pkts, 1)
+	captured := pkts[0][4:]
+	require.Len(t, captured, 24+8+8)
+
+	require.Equal(t, uint16(24+8+8), binary.BigEndian.Uint16(captured[2:])) // IPv4 total length
+	require.Equal(t, []byte{148, 4, 0, 0}, captured[20:24])                 // options untouched
+	require.Equal(t, uint16(8+8), binary.BigEndian.Uint16(captured[24+4:])) // UDP length
+}
+
+// newNetCapTestFragments returns an IPv4 UDP datagram (of given IP id) and its
+// fragments (of up to given size of IP payload).
+func newNetCapTestFragments(t *testing.T, id uint16, size int) ([]byte, [][]byte) {
+	t.Helper()
+
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	ip.Id = id
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	datagram := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload(bytes.Repeat([]byte("data"), 25)))
+
+	var fragments [][]byte
+	for offset := 0; offset < len(datagram)-20; offset += size {
+		end := min(offset+size, len(datagram)-20)
+		frag := newNetCapTestIPv4(layers.IPProtocolUDP)
+		frag.Id = id
+		frag.FragOffset = uint16(offset / 8)
+		if end < len(datagram)-20 {
+			frag.Flags = layers.IPv4MoreFragments
+		}
+		fragments = append(fragments, serializeNetCapTestPacket(t, frag, gopacket.Payload(datagram[20+offset:20+end])))
+	}
+
+	return datagram, fragments
+}
+
+func TestProcessNetCapEventDefrag(t *testing.T) {
+	datagram, fragments := newNetCapTestFragments(t, 7, 64)
+	require.Len(t, fragments, 2)
+
+	t.Run("reassembled", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+			Defrag:        true,
+		})
+
+		// out of order fragments: only the datagram is captured
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, fragments[1]))
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, fragments[0]))
+		require.Equal(t, uint64(1), tracee.stats.NetCapFragHeld.Get())
+		require.Equal(t, uint64(1), tracee.stats.NetCapReassembled.Get())
+		require.Empty(t, tracee.netCapDefrag.pending)
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		require.Equal(t, datagram, pkts[0][4:])
+	})
+
+	t.Run("fragments", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+		})
+		for _, fragment := range fragments {
+			tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, fragment))
+		}
+		require.Len(t, readNetCapTestPackets(t, tracee, dir), 2)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: 8,
+			Defrag:        true,
+		})
+
+		// cut by the capture length (as done by eBPF programs): as is
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, fragments[0][:20+8+8]))
+		require.Zero(t, tracee.stats.NetCapFragHeld.Get())
+		require.Len(t, readNetCapTestPackets(t, tracee, dir), 1)
+	})
+}
+
+func TestNetCapDefragDiscard(t *testing.T) {
+	t.Parallel()
+
+	d := newNetCapDefrag()
+	discarded := 0
+	d.discarded = func(count int) { discarded += count }
+
+	start := time.Unix(1700000000, 0)
+
+	// incomplete fragment sets are evicted when too many are held
+	for id := 0; id <= netCapDefragMaxSets; id++ {
+		_, fragments := newNetCapTestFragments(t, uint16(id), 64)
+		datagram, held := d.defrag(fragments[0], start.Add(time.Duration(id)*time.Microsecond))
+		require.Nil(t, datagram)
+		require.True(t, held)
+	}
+	require.Len(t, d.pending, netCapDefragMaxSets)
+	require.Equal(t, 1, discarded)
+
+	// the first one is gone: its last fragment is held (and can't complete it)
+	_, fragments := newNetCapTestFragments(t, 0, 64)
+	_, held := d.defrag(fragments[1], start.Add(time.Millisecond))
+	require.True(t, held)
+	require.Len(t, d.pending, netCapDefragMaxSets)
+	require.Equal(t, 2, discarded)
+
+	// and idle ones are discarded once timed out
+	datagram, _ := newNetCapTestFragments(t, 1, 64)
+	datagram, held = d.defrag(datagram, start.Add(netCapDefragTimeout+time.Second))
+	require.Nil(t, datagram) // not a fragment
+	require.False(t, held)
+	require.Empty(t, d.pending)
+	require.Equal(t, 2+netCapDefragMaxSets, discarded)
+}
+
+func TestProcessNetCapEventDecap(t *testing.T) {
+	// inner UDP packet tunneled in GRE, itself tunneled in GRE (nested)
+	inner := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{192, 168, 0, 1}, DstIP: net.IP{192, 168, 0, 2}}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(inner))
+	payload := gopacket.Payload(bytes.Repeat([]byte("data"), 25))
+	innerPacket := serializeNetCapTestPacket(t, inner, udp, payload)
+
+	middle := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: layers.IPProtocolGRE, SrcIP: net.IP{172, 16, 0, 1}, DstIP: net.IP{172, 16, 0, 2}}
+	middlePacket := serializeNetCapTestPacket(t, middle, &layers.GRE{Protocol: layers.EthernetTypeIPv4}, gopacket.Payload(innerPacket))
+	packet := serializeNetCapTestPacket(t, newNetCapTestIPv4(layers.IPProtocolGRE), &layers.GRE{Protocol: layers.EthernetTypeIPv4, KeyPresent: true, Key: 42}, gopacket.Payload(middlePacket))
+
+	t.Run("outer packet", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+		})
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		require.Equal(t, packet, pkts[0][4:])
+		require.Zero(t, tracee.stats.NetCapDecapsulated.Get())
+	})
+
+	t.Run("outer packet lengths", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: 8, // lengths mangled
+		})
+
+		// truncated to the capture length (as done by eBPF programs)
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet[:20+8+8]))
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		captured := pkts[0][4:]
+		require.Len(t, captured, 20+8+8)
+		require.Equal(t, uint16(20+8+8), binary.BigEndian.Uint16(captured[2:])) // GRE header (with key) counted
+	})
+
+	t.Run("inner packet", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+			Decap:         true,
+		})
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		require.Equal(t, uint32(2), binary.BigEndian.Uint32(pkts[0])) // fake L2 header: IPv4
+		require.Equal(t, innerPacket, pkts[0][4:])
+		require.Equal(t, uint64(1), tracee.stats.NetCapDecapsulated.Get())
+	})
+
+	t.Run("inner packet cut short", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: 64,
+			Decap:         true,
+		})
+
+		// outer packet truncated to its capture length (as done by eBPF programs)
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet[:20+8+64]))
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		captured := pkts[0][4:]
+		require.Len(t, captured, 64-20-4)
+		require.Equal(t, net.IP{192, 168, 0, 1}, net.IP(captured[12:16]))
+		require.Equal(t, uint16(64-20-4), binary.BigEndian.Uint16(captured[2:]))     // inner IPv4 total length
+		require.Equal(t, uint16(64-20-4-20), binary.BigEndian.Uint16(captured[24:])) // inner UDP length
+	})
+
+	t.Run("not an ip tunnel", func(t *testing.T) {
+		erspan := serializeNetCapTestPacket(t, newNetCapTestIPv4(layers.IPProtocolGRE), &layers.GRE{Protocol: layers.EthernetTypeERSPAN}, payload)
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+			Decap:         true,
+		})
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, erspan))
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		require.Equal(t, erspan, pkts[0][4:])
+		require.Zero(t, tracee.stats.NetCapDecapsulated.Get())
+	})
+
+	// inner UDP packet tunneled in VXLAN (in an Ethernet frame)
+	frame := serializeNetCapTestPacket(t,
+		&layers.VXLAN{ValidIDFlag: true, VNI: 42},
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+			DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		gopacket.Payload(innerPacket),
+	)
+	newVXLANPacket := func(port uint16) []byte {
+		outer := newNetCapTestIPv4(layers.IPProtocolUDP)
+		outerUDP := &layers.UDP{SrcPort: 40000, DstPort: layers.UDPPort(port)}
+		require.NoError(t, outerUDP.SetNetworkLayerForChecksum(outer))
+		return serializeNetCapTestPacket(t, outer, outerUDP, gopacket.Payload(frame))
+	}
+
+	t.Run("vxlan inner packet", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+			Decap:         true,
+		})
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newVXLANPacket(4789)))
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		require.Equal(t, innerPacket, pkts[0][4:])
+		require.Equal(t, uint64(1), tracee.stats.NetCapDecapsulated.Get())
+	})
+
+	t.Run("vxlan port", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+			Decap:         true,
+			VXLANPorts:    []uint16{8472},
+		})
+		standard := newVXLANPacket(4789) // not a configured port
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, standard))
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newVXLANPacket(8472)))
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 2)
+		require.Equal(t, standard, pkts[0][4:])
+		require.Equal(t, innerPacket, pkts[1][4:])
+		require.Equal(t, uint64(1), tracee.stats.NetCapDecapsulated.Get())
+	})
+}
+
+func TestPutNetCapField(t *testing.T) {
+	t.Parallel()
+
+	payload := make([]byte, 6)
+	require.True(t, putNetCapUint16(payload, 4, 0xabcd))
+	require.True(t, putNetCapUint32(payload, 2, 0x01020304))
+	require.Equal(t, []byte{0, 0, 1, 2, 3, 4}, payload)
+
+	// out of bounds: left untouched
+	require.False(t, putNetCapUint16(payload, 5, 0xffff))
+	require.False(t, putNetCapUint16(payload, 1<<31, 0xffff))
+	require.False(t, putNetCapUint32(payload, 3, 0xffffffff))
+	require.False(t, putNetCapUint32(nil, 0, 2))
+	require.Equal(t, []byte{0, 0, 1, 2, 3, 4}, payload)
+}
+
+func TestIsPingPattern(t *testing.T) {
+	t.Parallel()
+
+	incrementing := make([]byte, 48)
+	for i := range incrementing {
+		incrementing[i] = byte(0x10 + i)
+	}
+	pattern := append(make([]byte, 16), bytes.Repeat([]byte{0xde, 0xad}, 20)...)
+	random := make([]byte, 56)
+	_, err := rand.New(rand.NewSource(1)).Read(random)
+	require.NoError(t, err)
+
+	require.True(t, isPingPattern(append(make([]byte, 16), incrementing...)))
+	require.True(t, isPingPattern([]byte("abcdefghijklmnopqrstuvwabcdefghi")))
+	require.True(t, isPingPattern(pattern))
+	require.True(t, isPingPattern(make([]byte, 8))) // timestamp only
+	require.False(t, isPingPattern(random))
+	require.False(t, isPingPattern(append(make([]byte, 16), []byte("exfiltrated data")...)))
+}
+
+func TestProcessNetCapEventEmitEvents(t *testing.T) {
+	payload := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+
+	ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+	tcp := &layers.TCP{SrcPort: 1234, DstPort: 80, Seq: 1, ACK: true, PSH: true, Window: 1024}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload(payload))
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength:    (1 << 16) - 1, // max (full capture)
+		Events:           true,
+		EventPayloadSize: 50,
+	})
+	tracee.streamsManager = streams.NewStreamsManager()
+	stream := tracee.streamsManager.Subscribe(1, 1)
+
+	event := newNetCapTestEvent(familyIpv4, packet)
+	event.ProcessName = "curl"
+	tracee.processNetCapEvent(context.Background(), event)
+
+	// emitted to the events stream...
+	var captured trace.Event
+	select {
+	case captured = <-stream.ReceiveEvents():
+	default:
+		t.Fatal("no event emitted")
+	}
+	require.Equal(t, uint64(1), tracee.stats.NetCapEvents.Get())
+
+	data, err := json.Marshal(captured)
+	require.NoError(t, err)
+
+	var decoded struct {
+		EventName   string `json:"eventName"`
+		ProcessName string `json:"processName"`
+		Args        []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"args"`
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, "net_packet_captured", decoded.EventName)
+	require.Equal(t, "curl", decoded.ProcessName)
+
+	args := make(map[string]interface{})
+	for _, arg := range decoded.Args {
+		args[arg.Name] = arg.Value
+	}
+	require.Equal(t, "10.0.0.1", args["src"])
+	require.Equal(t, "10.0.0.2", args["dst"])
+	require.Equal(t, float64(1234), args["src_port"])
+	require.Equal(t, float64(80), args["dst_port"])
+	require.Equal(t, "tcp", args["protocol"])
+	require.Equal(t, float64(len(packet)), args["length"])
+	require.Equal(t, true, args["payload_truncated"])
+
+	// ...carrying the first (configured) bytes of the packet, base64 encoded
+	raw, err := base64.StdEncoding.DecodeString(args["payload"].(string))
+	require.NoError(t, err)
+	require.Equal(t, packet[:50], raw)
+
+	// and still written to the pcap file
+	require.Len(t, readNetCapTestPackets(t, tracee, dir), 1)
+}
+
+func TestProcessNetCapEventDNSEvents(t *testing.T) {
+	query := &layers.DNS{
+		ID:        0x1234,
+		RD:        true,
+		Questions: []layers.DNSQuestion{{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+	}
+	response := &layers.DNS{
+		ID:           0x1234,
+		QR:           true,
+		RD:           true,
+		RA:           true,
+		ResponseCode: layers.DNSResponseCodeNoErr,
+		Questions:    query.Questions,
+		Answers: []layers.DNSResourceRecord{
+			{Name: []byte("example.com"), Type: layers.DNSTypeCNAME, Class: layers.DNSClassIN, TTL: 60, CNAME: []byte("edge.example.net")},
+			{Name: []byte("edge.example.net"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 60, IP: net.IP{93, 184, 216, 34}},
+		},
+	}
+
+	// query over UDP
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 40000, DstPort: 53}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	udpPacket := serializeNetCapTestPacket(t, ip, udp, query)
+
+	// response over TCP (length prefixed)
+	buf := gopacket.NewSerializeBuffer()
+	require.NoError(t, response.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}))
+	message := binary.BigEndian.AppendUint16(nil, uint16(len(buf.Bytes())))
+	message = append(message, buf.Bytes()...)
+	ip = newNetCapTestIPv4(layers.IPProtocolTCP)
+	ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+	tcp := &layers.TCP{SrcPort: 53, DstPort: 40001, Seq: 1, ACK: true, PSH: true, Window: 1024}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+	tcpPacket := serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload(message))
+
+	// not written to pcap files, and filtered out by the capture filter
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+		EventsOnly:    true,
+		Events:        true,
+		DNSEvents:     true,
+		Filter:        "icmp",
+	})
+	tracee.streamsManager = streams.NewStreamsManager()
+	stream := tracee.streamsManager.Subscribe(1, 10)
+
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, udpPacket))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, tcpPacket))
+	require.Equal(t, uint64(2), tracee.stats.NetCapDNSEvents.Get())
+	require.Zero(t, tracee.stats.NetCapEvents.Get())
+
+	var parsed []map[string]interface{}
+	for i := 0; i < 2; i++ {
+		var event trace.Event
+		select {
+		case event = <-stream.ReceiveEvents():
+		default:
+			t.Fatal("no event emitted")
+		}
+		require.Equal(t, "net_packet_captured_dns", event.EventName)
+		args := make(map[string]interface{})
+		for _, arg := range event.Args {
+			args[arg.Name] = arg.Value
+		}
+		parsed = append(parsed, args)
+	}
+
+	require.Equal(t, "udp", parsed[0]["protocol"])
+	require.Equal(t, uint16(0x1234), parsed[0]["id"])
+	require.Equal(t, false, parsed[0]["response"])
+	require.Equal(t, "example.com", parsed[0]["qname"])
+	require.Equal(t, "A", parsed[0]["qtype"])
+	require.Empty(t, parsed[0]["answers"])
+
+	require.Equal(t, "tcp", parsed[1]["protocol"])
+	require.Equal(t, uint16(53), parsed[1]["src_port"])
+	require.Equal(t, true, parsed[1]["response"])
+	require.Equal(t, "No Error", parsed[1]["rcode"])
+	require.Equal(t, []string{
+		"example.com CNAME edge.example.net",
+		"edge.example.net A 93.184.216.34",
+	}, parsed[1]["answers"])
+
+	require.NoFileExists(t, filepath.Join(dir, "pcap", "single.pcap"))
+}
+
+func TestProcessNetCapEventDNSPorts(t *testing.T) {
+	query := &layers.DNS{
+		ID:        0x4321,
+		RD:        true,
+		Questions: []layers.DNSQuestion{{Name: []byte("internal.example"), Type: layers.DNSTypeAAAA, Class: layers.DNSClassIN}},
+	}
+	newQuery := func(port layers.UDPPort) []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 40000, DstPort: port}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, udp, query)
+	}
+
+	tracee, _ := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+		DNSEvents:     true,
+		DNSPorts:      []uint16{8053},
+	})
+	tracee.streamsManager = streams.NewStreamsManager()
+	stream := tracee.streamsManager.Subscribe(1, 10)
+
+	// parsed on the configured port only (the default one is replaced)
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newQuery(53)))
+	require.Zero(t, tracee.stats.NetCapDNSEvents.Get())
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newQuery(8053)))
+	require.Equal(t, uint64(1), tracee.stats.NetCapDNSEvents.Get())
+
+	var event trace.Event
+	select {
+	case event = <-stream.ReceiveEvents():
+	default:
+		t.Fatal("no event emitted")
+	}
+	require.Equal(t, "net_packet_captured_dns", event.EventName)
+	args := make(map[string]interface{})
+	for _, arg := range event.Args {
+		args[arg.Name] = arg.Value
+	}
+	require.Equal(t, uint16(8053), args["dst_port"])
+	require.Equal(t, uint16(0x4321), args["id"])
+	require.Equal(t, "internal.example", args["qname"])
+	require.Equal(t, "AAAA", args["qtype"])
+}
+
+func TestProcessNetCapEventSyscalls(t *testing.T) {
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 40000, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload([]byte("payload")))
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: 96,
+		Syscalls:      []string{"sendto", "sendmsg"},
+	})
+
+	// only packets originated by the configured syscalls are captured
+	for _, syscall := range []string{"sendto", "write", "", "sendmsg"} {
+		event := newNetCapTestEvent(familyIpv4, packet)
+		event.Syscall = syscall
+		tracee.processNetCapEvent(context.Background(), event)
+	}
+	require.Equal(t, uint64(2), tracee.stats.NetCapSyscallSkip.Get())
+	require.Len(t, readNetCapTestPackets(t, tracee, dir), 2)
+}
+
+func TestProcessNetCapEventSampleRate(t *testing.T) {
+	newPacket := func(clientPort layers.UDPPort, fromClient bool) []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: clientPort, DstPort: 5678}
+		if !fromClient {
+			ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+			udp.SrcPort, udp.DstPort = udp.DstPort, udp.SrcPort
+		}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, udp, gopacket.Payload([]byte("payload")))
+	}
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{CaptureLength: 96, SampleRate: 0.5})
+
+	// flows are sampled as a whole (both directions)
+	sampled := 0
+	for port := layers.UDPPort(40000); port < 40200; port++ {
+		request := sampleNetCapFlow(gopacket.NewPacket(newPacket(port, true), layers.LayerTypeIPv4, gopacket.Default), 0.5)
+		reply := sampleNetCapFlow(gopacket.NewPacket(newPacket(port, false), layers.LayerTypeIPv4, gopacket.Default), 0.5)
+		require.Equal(t, request, reply, "port %d", port)
+		if request {
+			sampled++
+		}
+
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newPacket(port, true)))
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newPacket(port, false)))
+	}
+	require.InDelta(t, 100, sampled, 30)
+	require.Equal(t, uint64(2*(200-sampled)), tracee.stats.NetCapSampledOut.Get())
+	require.Len(t, readNetCapTestPackets(t, tracee, dir), 2*sampled)
+
+	// all flows sampled at the top rate
+	require.True(t, sampleNetCapFlow(gopacket.NewPacket(newPacket(40000, true), layers.LayerTypeIPv4, gopacket.Default), 1))
+}
+
+func TestProcessNetCapEventsDrain(t *testing.T) {
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload([]byte("payload")))
+
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{CaptureLength: 96})
+	tracee.config.Output = &config.OutputConfig{}
+	tracee.eventsPool = &sync.Pool{}
+	tracee.netCapDrained = make(chan struct{})
+
+	in := make(chan *trace.Event, 10)
+	for i := 0; i < 5; i++ {
+		in <- newNetCapTestEvent(familyIpv4, packet)
+	}
+
+	// events still queued when capture is stopped are processed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errc := tracee.processNetCapEvents(ctx, in)
+	for err := range errc {
+		require.NoError(t, err)
+	}
+	<-tracee.netCapDrained
+
+	require.Empty(t, in)
+	require.Equal(t, uint64(5), tracee.stats.NetCapCount.Get())
+	require.Len(t, readNetCapTestPackets(t, tracee, dir), 5)
+}
+
+func TestProcessNetCapEventsLost(t *testing.T) {
+	tracee, _ := newNetCapTestTracee(t, config.PcapsConfig{LostLogInterval: 10 * time.Millisecond})
+	tracee.config.Output = &config.OutputConfig{}
+	tracee.eventsPool = &sync.Pool{}
+	tracee.lostNetCapChannel = make(chan uint64)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := tracee.processNetCapEvents(ctx, make(chan *trace.Event))
+
+	// lost events are counted as reported, whatever the (coalesced) logging
+	for _, lost := range []uint64{3, 5, 7} {
+		tracee.lostNetCapChannel <- lost
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.Equal(t, uint64(15), tracee.stats.LostNtCapCount.Get())
+
+	cancel()
+	for err := range errc {
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint64(15), tracee.stats.LostNtCapCount.Get())
+}
+
+// newNetCapTestClientHello returns the TLS ClientHello record sent by a
+// crypto/tls client connecting to the given server name.
+func newNetCapTestClientHello(t *testing.T, serverName string) []byte {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		_ = tls.Client(clientConn, &tls.Config{ServerName: serverName}).Handshake()
+	}()
+	defer clientConn.Close()
+
+	header := make([]byte, 5) // record header: type (1) | version (2) | length (2)
+	_, err := io.ReadFull(serverConn, header)
+	require.NoError(t, err)
+	record := make([]byte, binary.BigEndian.Uint16(header[3:]))
+	_, err = io.ReadFull(serverConn, record)
+	require.NoError(t, err)
+
+	return append(header, record...)
+}
+
+func TestProcessNetCapEventTLSEvents(t *testing.T) {
+	hello := newNetCapTestClientHello(t, "example.com")
+	newHello := func(port layers.TCPPort, hello []byte) []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+		tcp := &layers.TCP{SrcPort: 40000, DstPort: port, Seq: 1, ACK: true, PSH: true, Window: 1024}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload(hello))
+	}
+
+	tracee, _ := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+		TLSEvents:     true,
+	})
+	tracee.streamsManager = streams.NewStreamsManager()
+	stream := tracee.streamsManager.Subscribe(1, 10)
+
+	// truncated below the handshake, or not on a TLS port: skipped
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newHello(443, hello[:64])))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newHello(8443, hello)))
+	require.Zero(t, tracee.stats.NetCapTLSEvents.Get())
+
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, newHello(443, hello)))
+	require.Equal(t, uint64(1), tracee.stats.NetCapTLSEvents.Get())
+
+	var event trace.Event
+	select {
+	case event = <-stream.ReceiveEvents():
+	default:
+		t.Fatal("no event emitted")
+	}
+	require.Equal(t, "net_packet_tls", event.EventName)
+	args := make(map[string]interface{})
+	for _, arg := range event.Args {
+		args[arg.Name] = arg.Value
+	}
+	require.Equal(t, "10.0.0.2", args["dst"])
+	require.Equal(t, uint16(443), args["dst_port"])
+	require.Equal(t, "client_hello", args["handshake"])
+	require.Equal(t, "example.com", args["sni"])
+	require.Equal(t, "TLS1.3", args["version"])
+}
+
+func TestProcessNetCapEventHTTPEvents(t *testing.T) {
+	newSegment := func(port layers.TCPPort, seq uint32, data string) []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+		tcp := &layers.TCP{SrcPort: 40000, DstPort: port, Seq: seq, ACK: true, PSH: true, Window: 1024}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload(data))
+	}
+
+	tracee, _ := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+		HTTPEvents:    true,
+	})
+	tracee.streamsManager = streams.NewStreamsManager()
+	stream := tracee.streamsManager.Subscribe(1, 10)
+	process := func(packet []byte) {
+		tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+	}
+
+	// not HTTP, or not on an HTTP port: skipped
+	process(newSegment(80, 1, "\x16\x03\x01 not http"))
+	process(newSegment(8080, 1, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.Zero(t, tracee.stats.NetCapHTTPEvents.Get())
+	require.Empty(t, tracee.netCapHTTP.partial)
+
+	// request head spanning two segments: buffered until the Host header
+	first := "POST /api/v1/items?id=1 HTTP/1.1\r\n"
+	process(newSegment(80, 1, first))
+	require.Zero(t, tracee.stats.NetCapHTTPEvents.Get())
+	require.Len(t, tracee.netCapHTTP.partial, 1)
+
+	process(newSegment(80, 1+uint32(len(first)), "Host: example.com\r\nContent-Length: 0\r\n\r\n"))
+	require.Equal(t, uint64(1), tracee.stats.NetCapHTTPEvents.Get())
+	require.Empty(t, tracee.netCapHTTP.partial)
+
+	var event trace.Event
+	select {
+	case event = <-stream.ReceiveEvents():
+	default:
+		t.Fatal("no event emitted")
+	}
+	require.Equal(t, "net_packet_captured_http", event.EventName)
+	args := make(map[string]interface{})
+	for _, arg := range event.Args {
+		args[arg.Name] = arg.Value
+	}
+	require.Equal(t, "10.0.0.1", args["src"])
+	require.Equal(t, uint16(80), args["dst_port"])
+	require.Equal(t, "POST", args["method"])
+	require.Equal(t, "example.com", args["host"])
+	require.Equal(t, "/api/v1/items?id=1", args["path"])
+}
+
+func TestStartStopNetCapture(t *testing.T) {
+	tracee, _ := newNetCapTestTracee(t, config.PcapsConfig{})
+	tracee.config.Output = &config.OutputConfig{}
+	tracee.eventsPool = &sync.Pool{}
+	tracee.netCapChannel = make(chan []byte)
+	tracee.lostNetCapChannel = make(chan uint64)
+
+	require.ErrorContains(t, tracee.StartNetCapture(NetCaptureOptions{}), "tracee is not running")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tracee.handleNetCaptureEvents(ctx) // capture started with tracee
+	require.ErrorContains(t, tracee.StartNetCapture(NetCaptureOptions{}), "network capture already started")
+
+	// start -> stop -> start
+	require.NoError(t, tracee.StopNetCapture())
+	require.False(t, tracee.netCapturePcap.InSession()) // pcap files closed
+	require.ErrorContains(t, tracee.StopNetCapture(), "network capture not started")
+
+	require.ErrorContains(t, tracee.StartNetCapture(NetCaptureOptions{Filter: "tcp port"}), "invalid")
+	require.Nil(t, tracee.netCapFilter)
+	require.NoError(t, tracee.StartNetCapture(NetCaptureOptions{Filter: "udp port 53"}))
+	require.True(t, tracee.netCapturePcap.InSession())
+	require.NotNil(t, tracee.netCapFilter)
+
+	// tracee stops: the running pipeline is stopped
+	cancel()
+	tracee.netCapMutex.Lock()
+	tracee.stopNetCapPipeline()
+	tracee.netCapMutex.Unlock()
+	require.ErrorContains(t, tracee.StartNetCapture(NetCaptureOptions{}), "tracee is not running")
+}
+
+func TestProcessNetCapEventTCPOptionsLength(t *testing.T) {
+	// TCP header with options (12 bytes: MSS, SACK permitted, window scale)
+	ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+	tcp := &layers.TCP{
+		SrcPort: 40000,
+		DstPort: 80,
+		Seq:     1,
+		ACK:     true,
+		PSH:     true,
+		Window:  1024,
+		Options: []layers.TCPOption{
+			{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}},
+			{OptionType: layers.TCPOptionKindSACKPermitted, OptionLength: 2},
+			{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{7}},
+			{OptionType: layers.TCPOptionKindNop},
+			{OptionType: layers.TCPOptionKindNop},
+			{OptionType: layers.TCPOptionKindNop},
+		},
+	}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, tcp, gopacket.Payload(bytes.Repeat([]byte("data"), 25)))
+	headers := 20 + 32
+	require.Equal(t, byte(8), packet[20+12]>>4) // TCP data offset: 32 bytes
+
+	testCases := []struct {
+		name     string
+		captured int // bytes captured by eBPF programs
+		expected int // bytes written (and IPv4 total length)
+	}{
+		{name: "capture length after options", captured: headers + 8, expected: headers + 8},
+		{name: "more than the capture length", captured: headers + 12, expected: headers + 8},
+		{name: "less than the capture length", captured: 20 + 20 + 8, expected: 20 + 20 + 8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+				CaptureLength:  8,
+				CaptureLengths: map[string]uint32{"dns": 64}, // packets truncated to their own length
+			})
+			tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet[:tc.captured]))
+
+			pkts := readNetCapTestPackets(t, tracee, dir)
+			require.Len(t, pkts, 1)
+			captured := pkts[0][4:]
+			require.Len(t, captured, tc.expected)
+			require.Equal(t, uint16(tc.expected), binary.BigEndian.Uint16(captured[2:])) // IPv4 total length
+		})
+	}
+}
+
+func TestProcessNetCapEventARP(t *testing.T) {
+	arp := serializeNetCapTestPacket(t, &layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         layers.ARPRequest,
+		SourceHwAddress:   []byte{0x02, 0, 0, 0, 0, 1},
+		SourceProtAddress: []byte{10, 0, 0, 1},
+		DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
+		DstProtAddress:    []byte{10, 0, 0, 2},
+	})
+	newARPEvent := func() *trace.Event {
+		// payload size accounts for the room of the fake L2 header
+		return newNetCapTestEvent(familyArp, append(append([]byte(nil), arp...), 0, 0, 0, 0))
+	}
+
+	t.Run("captured as is", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: 8, // no length mangling (nor truncation) for ARP
+		})
+		tracee.processNetCapEvent(context.Background(), newARPEvent())
+
+		pkts := readNetCapTestPackets(t, tracee, dir)
+		require.Len(t, pkts, 1)
+		require.Equal(t, pcaps.LoopbackARP, binary.BigEndian.Uint32(pkts[0])) // fake L2 header: ARP
+		require.Equal(t, arp, pkts[0][4:])
+		require.Equal(t, uint64(1), tracee.stats.NetCapARP.Get())
+		require.Zero(t, tracee.stats.NetCapNoFamily.Get())
+	})
+
+	t.Run("capture filter", func(t *testing.T) {
+		tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{
+			CaptureLength: (1 << 16) - 1, // max (full capture)
+			Filter:        "tcp or arp",
+		})
+		tracee.processNetCapEvent(context.Background(), newARPEvent())
+		tracee.netCapFilter, _ = pcaps.CompileFilter("tcp")
+		tracee.processNetCapEvent(context.Background(), newARPEvent())
+
+		require.Len(t, readNetCapTestPackets(t, tracee, dir), 1)
+		require.Equal(t, uint64(1), tracee.stats.NetCapFiltered.Get())
+	})
+}
+
+func TestProcessNetCapEventFlowEvents(t *testing.T) {
+	newSegment := func(fromClient bool, flags string, seq, ack uint32) []byte {
+		ip := newNetCapTestIPv4(layers.IPProtocolTCP)
+		tcp := &layers.TCP{SrcPort: 40000, DstPort: 80, Seq: seq, Ack: ack, Window: 1024}
+		if !fromClient {
+			ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+			tcp.SrcPort, tcp.DstPort = tcp.DstPort, tcp.SrcPort
+		}
+		tcp.SYN = strings.Contains(flags, "S")
+		tcp.ACK = strings.Contains(flags, "A")
+		tcp.FIN = strings.Contains(flags, "F")
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+		return serializeNetCapTestPacket(t, ip, tcp)
+	}
+	segments := [][]byte{
+		newSegment(true, "S", 1000, 0),
+		newSegment(false, "SA", 5000, 1001),
+		newSegment(true, "A", 1001, 5001),
+		newSegment(true, "FA", 1001, 5001),
+		newSegment(false, "FA", 5001, 1002),
+		newSegment(true, "A", 1002, 5002),
+	}
+
+	tracee, _ := newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+		Flows:         true,
+		FlowEvents:    true,
+	})
+	tracee.streamsManager = streams.NewStreamsManager()
+	stream := tracee.streamsManager.Subscribe(1, 10)
+
+	ctx := context.Background()
+	tracee.netCapturePcap.OnFlowOver(func(s pcaps.FlowSummary) { // as handleNetCaptureEvents
+		tracee.emitNetCapFlowEvent(ctx, s)
+	})
+
+	for i, segment := range segments {
+		event := newNetCapTestEvent(familyIpv4, segment)
+		event.Timestamp = 1000 + i
+		event.Container = trace.Container{ID: "abc123"}
+		tracee.processNetCapEvent(ctx, event)
+	}
+	require.Equal(t, uint64(1), tracee.stats.NetCapFlowEvents.Get())
+
+	var event trace.Event
+	select {
+	case event = <-stream.ReceiveEvents():
+	default:
+		t.Fatal("no event emitted")
+	}
+	require.Equal(t, "net_flow_summary", event.EventName)
+	require.Equal(t, "abc123", event.Container.ID) // first packet context
+	require.Equal(t, 1005, event.Timestamp)
+	args := make(map[string]interface{})
+	for _, arg := range event.Args {
+		args[arg.Name] = arg.Value
+	}
+	require.Equal(t, "10.0.0.1", args["src"])
+	require.Equal(t, "10.0.0.2", args["dst"])
+	require.Equal(t, uint16(40000), args["src_port"])
+	require.Equal(t, uint16(80), args["dst_port"])
+	require.Equal(t, "tcp", args["protocol"])
+	require.Equal(t, uint64(len(segments)), args["packets"])
+	require.Equal(t, uint64(1000), args["first_timestamp"])
+	require.Equal(t, uint64(1005), args["last_timestamp"])
+	require.Equal(t, uint64(5), args["duration"])
+	require.Equal(t, "fin", args["reason"])
+}
+
+func TestTriggerNetCapture(t *testing.T) {
+	tracee, dir := newNetCapTestTracee(t, config.PcapsConfig{})
+	require.ErrorContains(t, tracee.TriggerNetCapture(), "ring buffer not enabled")
+
+	tracee, dir = newNetCapTestTracee(t, config.PcapsConfig{
+		CaptureLength: (1 << 16) - 1, // max (full capture)
+		RingBuffer:    1 << 20,
+	})
+	ip := newNetCapTestIPv4(layers.IPProtocolUDP)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 53}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	packet := serializeNetCapTestPacket(t, ip, udp, gopacket.Payload(make([]byte, 32)))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+	tracee.processNetCapEvent(context.Background(), newNetCapTestEvent(familyIpv4, packet))
+
+	require.NoError(t, tracee.TriggerNetCapture()) // both packets dumped
+	require.Len(t, readNetCapTestPackets(t, tracee, dir), 2)
+}
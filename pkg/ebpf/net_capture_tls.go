@@ -0,0 +1,89 @@
+package ebpf
+
+import (
+	"context"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/pcaps"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// netCapTLSPorts are the ports TLS hellos are parsed from, unless configured.
+var netCapTLSPorts = []uint16{443}
+
+// emitNetCapTLSEvent emits the TLS hello carried by a captured packet (if
+// any) to the events stream, as a net_packet_tls event holding the server name
+// (SNI) and version of the hello. The event keeps the context (process,
+// container, policies...) of the network capture event.
+func (t *Tracee) emitNetCapTLSEvent(ctx context.Context, event *trace.Event, packet gopacket.Packet) {
+	ports := t.config.Capture.Net.TLSPorts
+	if len(ports) == 0 {
+		ports = netCapTLSPorts
+	}
+	if !matchPacketPorts(packet, ports) {
+		return
+	}
+	tcp, ok := packet.TransportLayer().(*layers.TCP)
+	if !ok {
+		return
+	}
+
+	// hellos truncated below the handshake (e.g. by a small snaplen) are
+	// skipped
+	hello, ok := pcaps.ParseTLSHello(tcp.Payload)
+	if !ok {
+		return
+	}
+
+	parsed := newNetCapTLSEvent(event, packet, tcp, hello)
+
+	// packets captured regardless of policies (pcap-options:none) did not
+	// match any: emit them to all streams anyway
+	if parsed.MatchedPoliciesUser == 0 {
+		parsed.MatchedPoliciesUser = ^uint64(0)
+	}
+
+	t.streamsManager.Publish(ctx, parsed)
+	_ = t.stats.NetCapTLSEvents.Increment()
+}
+
+// newNetCapTLSEvent creates a net_packet_tls event out of a network capture
+// event and the TLS hello its packet carries.
+func newNetCapTLSEvent(event *trace.Event, packet gopacket.Packet, tcp *layers.TCP, hello pcaps.TLSHello) trace.Event {
+	var src, dst string
+
+	switch v := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, dst = v.SrcIP.String(), v.DstIP.String()
+	case *layers.IPv6:
+		src, dst = v.SrcIP.String(), v.DstIP.String()
+	}
+
+	handshake := "server_hello"
+	if hello.Client {
+		handshake = "client_hello"
+	}
+
+	def := events.Core.GetDefinitionByID(events.CaptureNetPacketTLSEvent)
+	params := def.GetParams()
+
+	parsed := *event // keep the event context
+	parsed.EventID = int(events.CaptureNetPacketTLSEvent)
+	parsed.EventName = def.GetName()
+	parsed.ReturnValue = 0
+	parsed.ArgsNum = len(params)
+	parsed.Args = []trace.Argument{
+		{ArgMeta: params[0], Value: src},
+		{ArgMeta: params[1], Value: dst},
+		{ArgMeta: params[2], Value: uint16(tcp.SrcPort)},
+		{ArgMeta: params[3], Value: uint16(tcp.DstPort)},
+		{ArgMeta: params[4], Value: handshake},
+		{ArgMeta: params[5], Value: hello.ServerName},
+		{ArgMeta: params[6], Value: hello.Version},
+	}
+
+	return parsed
+}
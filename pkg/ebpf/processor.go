@@ -131,6 +131,15 @@ func (t *Tracee) registerEventProcessors() {
 	// NOTE: Make sure to convert time related args (of your event) in here.
 	t.RegisterEventProcessor(events.SchedProcessFork, t.processSchedProcessFork)
 	t.RegisterEventProcessor(events.All, t.normalizeEventCtxTimes)
+
+	//
+	// Network Capture Processors
+	//
+
+	// Registered after the timestamps normalization (compared to packet ones).
+	if t.config.Capture != nil && t.config.Capture.Net.ShortLived > 0 {
+		t.RegisterEventProcessor(events.All, t.netCapProcessExit)
+	}
 }
 
 func initKernelReadFileTypes() {
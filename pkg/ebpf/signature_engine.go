@@ -123,6 +123,11 @@ func (t *Tracee) engineEvents(ctx context.Context, in <-chan *trace.Event) (<-ch
 					continue
 				}
 
+				// open (or extend) capture detection windows (if enabled)
+				if t.netCapturePcap != nil {
+					t.netCapturePcap.Detection(event)
+				}
+
 				engineOutputEvents <- event
 			case <-ctx.Done():
 				return
@@ -2,7 +2,6 @@ package ebpf
 
 import (
 	gocontext "context"
-	"encoding/binary"
 	"fmt"
 	"os"
 	"strconv"
@@ -75,6 +74,17 @@ type Tracee struct {
 	capturedFiles  map[string]int64
 	writtenFiles   map[string]string
 	netCapturePcap *pcaps.Pcaps
+	netCaptureSink pcaps.CaptureSink  // where captured packets are written: netCapturePcap, unless streamed (or dry run)
+	netCapIPInfo   *netCapIPInfo      // destination IP information (if enabled)
+	netCapFilter   *pcaps.Filter      // capture filter (if enabled)
+	netCapDefrag   *netCapDefrag      // IPv4 fragments reassembly (if enabled)
+	netCapHTTP     *netCapHTTP        // HTTP requests buffering (if enabled)
+	netCapControl  *pcaps.ControlFIFO // capture control commands (if enabled)
+	netCapDrained  chan struct{}      // closed once queued captures are processed (pipeline stopped)
+	// Network capture start and stop (at runtime)
+	netCapMutex sync.Mutex           // guards starting and stopping network capture
+	netCapCtx   gocontext.Context    // context network capture runs in (while tracee runs)
+	netCapStop  gocontext.CancelFunc // stops the network capture pipeline (nil: capture stopped)
 	// Internal Data
 	readFiles     map[string]string
 	pidsInMntns   bucketscache.BucketsCache // first n PIDs in each mountns
@@ -156,6 +166,10 @@ func GetCaptureEventsList(cfg config.Config) map[events.ID]events.EventState {
 	}
 	if pcaps.PcapsEnabled(cfg.Capture.Net) {
 		captureEvents[events.CaptureNetPacket] = policy.AlwaysSubmit
+		if cfg.Capture.Net.ShortLived > 0 {
+			// process exits tell short-lived processes
+			captureEvents[events.SchedProcessExit] = policy.AlwaysSubmit
+		}
 	}
 
 	return captureEvents
@@ -487,6 +501,56 @@ func (t *Tracee) Init(ctx gocontext.Context) error {
 		t.Close()
 		return errfmt.Errorf("error initializing network capture: %v", err)
 	}
+	if t.config.Capture.Net.FlowHandshakes {
+		t.netCapturePcap.ObserveHandshakes(&t.stats.NetCapHandshake)
+	}
+	t.netCaptureSink = t.netCapturePcap
+	if t.config.Capture.Net.DryRun {
+		t.netCaptureSink = pcaps.NewDryRunSink(t.config.Capture.Net)
+	} else if t.config.Capture.Net.SinkNetwork != "" {
+		t.netCaptureSink, err = pcaps.NewStreamSink(
+			t.config.Capture.Net.SinkNetwork,
+			t.config.Capture.Net.SinkAddress,
+			t.config.Capture.Net.LinkType,
+		)
+		if err != nil {
+			t.Close()
+			return errfmt.Errorf("error initializing network capture sink: %v", err)
+		}
+	}
+
+	t.netCapIPInfo, err = newNetCapIPInfo(t.config.Capture.Net)
+	if err != nil {
+		t.Close()
+		return errfmt.Errorf("error initializing network capture: %v", err)
+	}
+
+	if t.config.Capture.Net.Filter != "" {
+		t.netCapFilter, err = pcaps.CompileFilter(t.config.Capture.Net.Filter)
+		if err != nil {
+			t.Close()
+			return errfmt.Errorf("error initializing network capture: %v", err)
+		}
+	}
+
+	if t.config.Capture.Net.Defrag {
+		t.netCapDefrag = newNetCapDefrag()
+		t.netCapDefrag.discarded = func(count int) {
+			_ = t.stats.NetCapFragDiscarded.Increment(uint64(count))
+		}
+	}
+
+	if t.config.Capture.Net.HTTPEvents {
+		t.netCapHTTP = newNetCapHTTP()
+	}
+
+	if t.config.Capture.Net.ControlFIFO != "" {
+		t.netCapControl, err = pcaps.NewControlFIFO(t.config.Capture.Net.ControlFIFO, t.netCapturePcap)
+		if err != nil {
+			t.Close()
+			return errfmt.Errorf("error initializing network capture control: %v", err)
+		}
+	}
 
 	// Get reference to stack trace addresses map
 
@@ -735,6 +799,12 @@ func (t *Tracee) initDerivationTable() error {
 				DeriveFunction: derive.NetPacketHTTPResponse(),
 			},
 		},
+		events.NetPacketDHCPBase: {
+			events.NetPacketDHCP: {
+				Enabled:        shouldSubmit(events.NetPacketDHCP),
+				DeriveFunction: derive.NetPacketDHCP(),
+			},
+		},
 		//
 		// Network Flow Derivations
 		//
@@ -940,21 +1010,9 @@ func (t *Tracee) populateBPFMaps() error {
 
 	// Initialize the net_packet configuration eBPF map.
 	if pcaps.PcapsEnabled(t.config.Capture.Net) {
-		bpfNetConfigMap, err := t.bpfModule.GetMap("netconfig_map")
-		if err != nil {
+		if err := t.updateNetConfigMap(false); err != nil {
 			return errfmt.WrapError(err)
 		}
-
-		netConfigVal := make([]byte, 8) // u32 capture_options + u32 capture_length
-		options := pcaps.GetPcapOptions(t.config.Capture.Net)
-		binary.LittleEndian.PutUint32(netConfigVal[0:4], uint32(options))
-		binary.LittleEndian.PutUint32(netConfigVal[4:8], t.config.Capture.Net.CaptureLength)
-
-		cZero := uint32(0)
-		err = bpfNetConfigMap.Update(unsafe.Pointer(&cZero), unsafe.Pointer(&netConfigVal[0]))
-		if err != nil {
-			return errfmt.Errorf("error updating net config eBPF map: %v", err)
-		}
 	}
 
 	// Initialize config and filter maps
@@ -1276,7 +1334,7 @@ func (t *Tracee) Run(ctx gocontext.Context) error {
 
 	if pcaps.PcapsEnabled(t.config.Capture.Net) {
 		t.netCapPerfMap.Poll(pollTimeout)
-		go t.handleNetCaptureEvents(ctx)
+		t.handleNetCaptureEvents(ctx)
 	}
 
 	// Logging perf buffer
@@ -1299,6 +1357,10 @@ func (t *Tracee) Run(ctx gocontext.Context) error {
 	}
 	if pcaps.PcapsEnabled(t.config.Capture.Net) {
 		t.netCapPerfMap.Close()
+		// queued captures are written before capture is destroyed
+		t.netCapMutex.Lock()
+		t.stopNetCapPipeline()
+		t.netCapMutex.Unlock()
 	}
 	t.bpfLogsPerfMap.Close()
 
@@ -1373,6 +1435,19 @@ func (t *Tracee) Close() {
 			logger.Errorw("failed to detach probes when closing tracee", "err", err)
 		}
 	}
+	if t.netCapturePcap != nil {
+		// packets still queued are dropped (counted as late) from now on
+		err := t.netCapturePcap.Destroy()
+		if err != nil {
+			logger.Errorw("failed to destroy network capture when closing tracee", "err", err)
+		}
+	}
+	if t.netCaptureSink != nil && t.netCaptureSink != t.netCapturePcap {
+		err := t.netCaptureSink.Close()
+		if err != nil {
+			logger.Errorw("failed to close network capture sink when closing tracee", "err", err)
+		}
+	}
 	if t.bpfModule != nil {
 		t.bpfModule.Close()
 	}
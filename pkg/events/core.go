@@ -31,6 +31,7 @@ const (
 	NetPacketICMPv6Base
 	NetPacketDNSBase
 	NetPacketHTTPBase
+	NetPacketDHCPBase
 	NetPacketCapture
 	NetPacketFlow
 	MaxNetID // network base events go ABOVE this item
@@ -126,6 +127,7 @@ const (
 	NetFlowEnd
 	NetFlowTCPBegin
 	NetFlowTCPEnd
+	NetPacketDHCP
 	MaxUserNetID
 	NetTCPConnect
 	InitNamespaces
@@ -151,6 +153,11 @@ const (
 	CaptureNetPacket
 	CaptureBpf
 	CaptureFileRead
+	CaptureNetPacketEvent
+	CaptureNetPacketDNSEvent
+	CaptureNetPacketTLSEvent
+	CaptureNetFlowSummaryEvent
+	CaptureNetPacketHTTPEvent
 )
 
 // Signal meta-events
@@ -13446,6 +13453,48 @@ var CoreEvents = map[ID]Definition{
 			{Type: "trace.ProtoHTTPResponse", Name: "http_response"},
 		},
 	},
+	NetPacketDHCPBase: {
+		id:       NetPacketDHCPBase,
+		id32Bit:  Sys32Undefined,
+		name:     "net_packet_dhcp_base",
+		version:  NewVersion(1, 0, 0),
+		internal: true,
+		dependencies: Dependencies{
+			ids: []ID{
+				NetPacketBase,
+			},
+		},
+		sets: []string{"network_events"},
+		params: []trace.ArgMeta{
+			{Type: "bytes", Name: "payload"},
+		},
+	},
+	NetPacketDHCP: {
+		id:      NetPacketDHCP,
+		id32Bit: Sys32Undefined,
+		name:    "net_packet_dhcp",
+		version: NewVersion(1, 0, 0),
+		dependencies: Dependencies{
+			ids: []ID{
+				NetPacketDHCPBase,
+			},
+		},
+		sets: []string{"network_events"},
+		params: []trace.ArgMeta{
+			{Type: "const char*", Name: "src"}, // TODO: pack and remove into trace.PacketMetadata after it supports filtering
+			{Type: "const char*", Name: "dst"}, // TODO: pack and remove into trace.PacketMetadata after it supports filtering
+			{Type: "u16", Name: "src_port"},    // TODO: pack and remove into trace.PacketMetadata after it supports filtering
+			{Type: "u16", Name: "dst_port"},    // TODO: pack and remove into trace.PacketMetadata after it supports filtering
+			{Type: "trace.PacketMetadata", Name: "metadata"},
+			{Type: "const char*", Name: "version"},      // dhcpv4 or dhcpv6
+			{Type: "const char*", Name: "message_type"}, // e.g. discover, offer, request, ack, solicit, reply
+			{Type: "u32", Name: "xid"},                  // transaction id
+			{Type: "const char*", Name: "client_mac"},   // client hardware address (if known)
+			{Type: "const char*", Name: "requested_ip"}, // address requested by the client (if any)
+			{Type: "const char*", Name: "assigned_ip"},  // address offered or assigned by the server (if any)
+			{Type: "u32", Name: "lease_time"},           // lease (or valid lifetime) in seconds (if any)
+		},
+	},
 	NetPacketCapture: {
 		id:       NetPacketCapture, // Packets with full payload (sent in a dedicated perfbuffer)
 		id32Bit:  Sys32Undefined,
@@ -13459,6 +13508,20 @@ var CoreEvents = map[ID]Definition{
 		},
 		params: []trace.ArgMeta{
 			{Type: "bytes", Name: "payload"},
+			{Type: "u32", Name: "mark"},                  // optional: netfilter (skb) mark
+			{Type: "const char *", Name: "sha256"},       // optional: hash of the executing binary
+			{Type: "u32", Name: "dst_asn"},               // optional: destination ASN (userspace enrichment)
+			{Type: "const char *", Name: "dst_as_org"},   // optional: destination AS organization
+			{Type: "const char *", Name: "dst_country"},  // optional: destination country (ISO code)
+			{Type: "const char *", Name: "dst_city"},     // optional: destination city
+			{Type: "const char *", Name: "orig_src_ip"},  // optional: conntrack original tuple (pre NAT)
+			{Type: "const char *", Name: "orig_dst_ip"},  // optional: conntrack original tuple (pre NAT)
+			{Type: "u16", Name: "orig_src_port"},         // optional: conntrack original tuple (pre NAT)
+			{Type: "u16", Name: "orig_dst_port"},         // optional: conntrack original tuple (pre NAT)
+			{Type: "const char *", Name: "source"},       // optional: eBPF hook the packet was captured from
+			{Type: "u16", Name: "tcp_urgent_ptr"},        // optional: TCP urgent pointer (URG segments)
+			{Type: "const char *", Name: "verdict"},      // optional: kernel verdict on the packet (allow or drop)
+			{Type: "const char *", Name: "bad_checksum"}, // optional: layer holding an invalid checksum (ip, tcp, udp, icmp or icmpv6)
 		},
 	},
 	CaptureNetPacket: {
@@ -13473,6 +13536,95 @@ var CoreEvents = map[ID]Definition{
 			},
 		},
 	},
+	CaptureNetPacketEvent: {
+		id:       CaptureNetPacketEvent, // Captured packets emitted to the events stream (instead of, or with, pcap files)
+		id32Bit:  Sys32Undefined,
+		name:     "net_packet_captured",
+		version:  NewVersion(1, 0, 0),
+		internal: true,
+		params: []trace.ArgMeta{
+			{Type: "const char*", Name: "src"},
+			{Type: "const char*", Name: "dst"},
+			{Type: "u16", Name: "src_port"},
+			{Type: "u16", Name: "dst_port"},
+			{Type: "const char*", Name: "protocol"},   // l4 protocol (tcp, udp, icmp, icmpv6...)
+			{Type: "u32", Name: "length"},             // captured packet length (from the l3 header on)
+			{Type: "const char*", Name: "payload"},    // base64 encoded packet (from the l3 header on)
+			{Type: "bool", Name: "payload_truncated"}, // payload was cut to the configured size
+		},
+	},
+	CaptureNetPacketDNSEvent: {
+		id:       CaptureNetPacketDNSEvent, // DNS messages parsed from captured packets, emitted to the events stream
+		id32Bit:  Sys32Undefined,
+		name:     "net_packet_captured_dns",
+		version:  NewVersion(1, 0, 0),
+		internal: true,
+		params: []trace.ArgMeta{
+			{Type: "const char*", Name: "src"},
+			{Type: "const char*", Name: "dst"},
+			{Type: "u16", Name: "src_port"},
+			{Type: "u16", Name: "dst_port"},
+			{Type: "const char*", Name: "protocol"}, // l4 protocol (udp or tcp)
+			{Type: "u16", Name: "id"},               // dns message id (matches queries and responses)
+			{Type: "bool", Name: "response"},        // response (or query)
+			{Type: "const char*", Name: "qname"},    // name of the first question (empty if none)
+			{Type: "const char*", Name: "qtype"},    // type of the first question (e.g. A, AAAA)
+			{Type: "const char*", Name: "rcode"},    // response code (e.g. No Error, Non-Existent Domain)
+			{Type: "const char**", Name: "answers"}, // answer records ("name type data")
+		},
+	},
+	CaptureNetPacketTLSEvent: {
+		id:       CaptureNetPacketTLSEvent, // TLS hellos parsed from captured packets, emitted to the events stream
+		id32Bit:  Sys32Undefined,
+		name:     "net_packet_tls",
+		version:  NewVersion(1, 0, 0),
+		internal: true,
+		params: []trace.ArgMeta{
+			{Type: "const char*", Name: "src"},
+			{Type: "const char*", Name: "dst"},
+			{Type: "u16", Name: "src_port"},
+			{Type: "u16", Name: "dst_port"},
+			{Type: "const char*", Name: "handshake"}, // client_hello or server_hello
+			{Type: "const char*", Name: "sni"},       // server name requested (client_hello only, empty if none)
+			{Type: "const char*", Name: "version"},   // highest version offered (client_hello) or negotiated (server_hello), e.g. TLS1.3
+		},
+	},
+	CaptureNetFlowSummaryEvent: {
+		id:       CaptureNetFlowSummaryEvent, // Summaries of flows tracked from captured packets, emitted to the events stream when over
+		id32Bit:  Sys32Undefined,
+		name:     "net_flow_summary",
+		version:  NewVersion(1, 0, 0),
+		internal: true,
+		params: []trace.ArgMeta{
+			{Type: "const char*", Name: "src"}, // initiator
+			{Type: "const char*", Name: "dst"}, // responder
+			{Type: "u16", Name: "src_port"},
+			{Type: "u16", Name: "dst_port"},
+			{Type: "const char*", Name: "protocol"}, // l4 protocol (tcp, udp, icmp, sctp)
+			{Type: "u64", Name: "packets"},
+			{Type: "u64", Name: "bytes"},           // as written to pcap files (as the flow log counts them)
+			{Type: "u64", Name: "first_timestamp"}, // first packet time (nanoseconds since epoch)
+			{Type: "u64", Name: "last_timestamp"},  // last packet time (nanoseconds since epoch)
+			{Type: "u64", Name: "duration"},        // last_timestamp - first_timestamp (nanoseconds)
+			{Type: "const char*", Name: "reason"},  // why the flow is over: fin, rst, idle, evicted or end (of capture)
+		},
+	},
+	CaptureNetPacketHTTPEvent: {
+		id:       CaptureNetPacketHTTPEvent, // HTTP requests parsed from captured packets, emitted to the events stream
+		id32Bit:  Sys32Undefined,
+		name:     "net_packet_captured_http",
+		version:  NewVersion(1, 0, 0),
+		internal: true,
+		params: []trace.ArgMeta{
+			{Type: "const char*", Name: "src"},
+			{Type: "const char*", Name: "dst"},
+			{Type: "u16", Name: "src_port"},
+			{Type: "u16", Name: "dst_port"},
+			{Type: "const char*", Name: "method"}, // request method, e.g. GET
+			{Type: "const char*", Name: "host"},   // Host header (empty if none, or not captured)
+			{Type: "const char*", Name: "path"},   // request target, e.g. /index.html
+		},
+	},
 	NetPacketFlow: {
 		id:       NetPacketFlow,
 		id32Bit:  Sys32Undefined,
@@ -471,3 +471,42 @@ func NetPacketHTTPResponse() DeriveFunction {
 		},
 	)
 }
+
+func NetPacketDHCP() DeriveFunction {
+	return deriveSingleEvent(events.NetPacketDHCP,
+		func(event trace.Event) ([]interface{}, error) {
+			packet, err := createPacketFromEvent(&event)
+			if err != nil {
+				return nil, err
+			}
+			dhcp, err := getDHCPFromPacket(packet)
+			if err != nil {
+				return nil, nil // regular udp/ip packet without DHCP payload
+			}
+			srcIP, dstIP, err := getLayer3SrcDstFromPacket(packet)
+			if err != nil {
+				return nil, err
+			}
+			srcPort, dstPort, err := getLayer4SrcPortDstPortFromPacket(packet)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{
+				srcIP,
+				dstIP,
+				srcPort,
+				dstPort,
+				trace.PacketMetadata{
+					Direction: getPacketDirection(&event),
+				},
+				dhcp.version,
+				dhcp.messageType,
+				dhcp.xid,
+				dhcp.clientMAC,
+				dhcp.requestedIP,
+				dhcp.assignedIP,
+				dhcp.leaseTime,
+			}, nil
+		},
+	)
+}
@@ -3,6 +3,7 @@ package derive
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"net"
 	"net/http"
@@ -404,6 +405,17 @@ func getLayer7DNSFromPacket(packet gopacket.Packet) (*layers.DNS, error) {
 	return nil, fmt.Errorf("wrong layer 7 protocol type")
 }
 
+// getDHCPFromPacket returns the DHCP (v4 or v6) message from the packet.
+func getDHCPFromPacket(packet gopacket.Packet) (*dhcpMessage, error) {
+	if layer, ok := packet.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4); ok {
+		return getDHCPv4Message(layer), nil
+	}
+	if layer, ok := packet.Layer(layers.LayerTypeDHCPv6).(*layers.DHCPv6); ok {
+		return getDHCPv6Message(layer), nil
+	}
+	return nil, fmt.Errorf("wrong layer 7 protocol type")
+}
+
 // getLayer7FromPacket returns the layer 7 protocol from the packet.
 func getLayer7FromPacket(packet gopacket.Packet) (gopacket.ApplicationLayer, error) {
 	layer7 := packet.ApplicationLayer()
@@ -756,3 +768,151 @@ func getDNSResponseFromProtoDNS(query trace.DnsQueryData, answers []trace.ProtoD
 		},
 	}
 }
+
+//
+// DHCP
+//
+
+// dhcpMessage holds the IP-assignment related fields of a DHCP message.
+type dhcpMessage struct {
+	version     string
+	messageType string
+	xid         uint32
+	clientMAC   string
+	requestedIP string
+	assignedIP  string
+	leaseTime   uint32
+}
+
+var dhcpv4MessageTypes = map[layers.DHCPMsgType]string{
+	layers.DHCPMsgTypeDiscover: "discover",
+	layers.DHCPMsgTypeOffer:    "offer",
+	layers.DHCPMsgTypeRequest:  "request",
+	layers.DHCPMsgTypeDecline:  "decline",
+	layers.DHCPMsgTypeAck:      "ack",
+	layers.DHCPMsgTypeNak:      "nak",
+	layers.DHCPMsgTypeRelease:  "release",
+	layers.DHCPMsgTypeInform:   "inform",
+}
+
+var dhcpv6MessageTypes = map[layers.DHCPv6MsgType]string{
+	layers.DHCPv6MsgTypeSolicit:            "solicit",
+	layers.DHCPv6MsgTypeAdverstise:         "advertise",
+	layers.DHCPv6MsgTypeRequest:            "request",
+	layers.DHCPv6MsgTypeConfirm:            "confirm",
+	layers.DHCPv6MsgTypeRenew:              "renew",
+	layers.DHCPv6MsgTypeRebind:             "rebind",
+	layers.DHCPv6MsgTypeReply:              "reply",
+	layers.DHCPv6MsgTypeRelease:            "release",
+	layers.DHCPv6MsgTypeDecline:            "decline",
+	layers.DHCPv6MsgTypeReconfigure:        "reconfigure",
+	layers.DHCPv6MsgTypeInformationRequest: "information-request",
+	layers.DHCPv6MsgTypeRelayForward:       "relay-forward",
+	layers.DHCPv6MsgTypeRelayReply:         "relay-reply",
+}
+
+// getDHCPv4Message returns the IP-assignment related fields of a DHCPv4 message.
+func getDHCPv4Message(dhcp *layers.DHCPv4) *dhcpMessage {
+	msg := &dhcpMessage{
+		version:     "dhcpv4",
+		messageType: "unknown",
+		xid:         dhcp.Xid,
+	}
+	if len(dhcp.ClientHWAddr) > 0 {
+		msg.clientMAC = dhcp.ClientHWAddr.String()
+	}
+	if dhcp.ClientIP != nil && !dhcp.ClientIP.IsUnspecified() {
+		msg.requestedIP = dhcp.ClientIP.String() // renewing clients
+	}
+	if dhcp.YourClientIP != nil && !dhcp.YourClientIP.IsUnspecified() {
+		msg.assignedIP = dhcp.YourClientIP.String()
+	}
+
+	for _, option := range dhcp.Options {
+		switch option.Type {
+		case layers.DHCPOptMessageType:
+			if len(option.Data) == 1 {
+				if name, ok := dhcpv4MessageTypes[layers.DHCPMsgType(option.Data[0])]; ok {
+					msg.messageType = name
+				}
+			}
+		case layers.DHCPOptRequestIP:
+			if len(option.Data) == net.IPv4len {
+				msg.requestedIP = net.IP(option.Data).String()
+			}
+		case layers.DHCPOptLeaseTime:
+			if len(option.Data) == 4 {
+				msg.leaseTime = binary.BigEndian.Uint32(option.Data)
+			}
+		}
+	}
+
+	return msg
+}
+
+// getDHCPv6Message returns the IP-assignment related fields of a DHCPv6 message.
+// Addresses (and their valid lifetimes) come from IA_NA options: requested if
+// sent by a client, assigned if sent by a server.
+func getDHCPv6Message(dhcp *layers.DHCPv6) *dhcpMessage {
+	msg := &dhcpMessage{
+		version:     "dhcpv6",
+		messageType: "unknown",
+	}
+	if name, ok := dhcpv6MessageTypes[dhcp.MsgType]; ok {
+		msg.messageType = name
+	}
+	for _, b := range dhcp.TransactionID {
+		msg.xid = msg.xid<<8 | uint32(b)
+	}
+
+	fromServer := dhcp.MsgType == layers.DHCPv6MsgTypeAdverstise ||
+		dhcp.MsgType == layers.DHCPv6MsgTypeReply
+
+	for _, option := range dhcp.Options {
+		switch option.Code {
+		case layers.DHCPv6OptClientID:
+			duid := &layers.DHCPv6DUID{}
+			if duid.DecodeFromBytes(option.Data) == nil && len(duid.LinkLayerAddress) > 0 {
+				msg.clientMAC = duid.LinkLayerAddress.String()
+			}
+		case layers.DHCPv6OptIANA:
+			addr, valid, ok := getDHCPv6IANAAddress(option.Data)
+			if !ok {
+				continue
+			}
+			if fromServer {
+				msg.assignedIP = addr.String()
+				msg.leaseTime = valid
+			} else {
+				msg.requestedIP = addr.String()
+			}
+		}
+	}
+
+	return msg
+}
+
+// getDHCPv6IANAAddress returns the first address, and its valid lifetime, of
+// an IA_NA option: IAID (4) | T1 (4) | T2 (4) | options, where an IA address
+// option is: code (2) | length (2) | address (16) | preferred (4) | valid (4).
+func getDHCPv6IANAAddress(data []byte) (net.IP, uint32, bool) {
+	if len(data) < 12 {
+		return nil, 0, false
+	}
+	options := data[12:]
+
+	for len(options) >= 4 {
+		code := layers.DHCPv6Opt(binary.BigEndian.Uint16(options[0:]))
+		length := int(binary.BigEndian.Uint16(options[2:]))
+		if len(options) < 4+length {
+			break
+		}
+		if code == layers.DHCPv6OptIAAddr && length >= 24 {
+			addr := net.IP(bytes.Clone(options[4:20]))
+			return addr, binary.BigEndian.Uint32(options[24:]), true
+		}
+		options = options[4+length:]
+	}
+
+	return nil, 0, false
+}
@@ -0,0 +1,203 @@
+package derive
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// newTestNetPacketEvent creates a network base event carrying given packet.
+func newTestNetPacketEvent(t *testing.T, id events.ID, family int, l ...gopacket.SerializableLayer) trace.Event {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, l...))
+
+	return trace.Event{
+		EventID:     int(id),
+		ReturnValue: family | packetEgress,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Type: "bytes", Name: "payload"}, Value: buf.Bytes()},
+		},
+	}
+}
+
+// newTestDHCPv4Event creates a DHCPv4 base event.
+func newTestDHCPv4Event(t *testing.T, src, dst net.IP, srcPort, dstPort layers.UDPPort, dhcp *layers.DHCPv4) trace.Event {
+	t.Helper()
+
+	ip := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: src, DstIP: dst}
+	udp := &layers.UDP{SrcPort: srcPort, DstPort: dstPort}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+
+	return newTestNetPacketEvent(t, events.NetPacketDHCPBase, familyIPv4, ip, udp, dhcp)
+}
+
+// newTestDHCPv6Event creates a DHCPv6 base event.
+func newTestDHCPv6Event(t *testing.T, src, dst net.IP, srcPort, dstPort layers.UDPPort, dhcp *layers.DHCPv6) trace.Event {
+	t.Helper()
+
+	ip := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: src, DstIP: dst}
+	udp := &layers.UDP{SrcPort: srcPort, DstPort: dstPort}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+
+	return newTestNetPacketEvent(t, events.NetPacketDHCPBase, familyIPv6, ip, udp, dhcp)
+}
+
+// deriveTestDHCPArgs derives a net_packet_dhcp event and returns its DHCP
+// arguments (after the packet metadata).
+func deriveTestDHCPArgs(t *testing.T, event trace.Event) map[string]interface{} {
+	t.Helper()
+
+	derived, errs := NetPacketDHCP()(event)
+	require.Empty(t, errs)
+	require.Len(t, derived, 1)
+	require.Equal(t, int(events.NetPacketDHCP), derived[0].EventID)
+
+	args := make(map[string]interface{})
+	for _, arg := range derived[0].Args[5:] {
+		args[arg.Name] = arg.Value
+	}
+
+	return args
+}
+
+func TestNetPacketDHCPv4(t *testing.T) {
+	t.Parallel()
+
+	mac := net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}
+	lease := make([]byte, 4)
+	binary.BigEndian.PutUint32(lease, 3600)
+
+	discover := newTestDHCPv4Event(t, net.IPv4zero, net.IPv4bcast, 68, 67, &layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		HardwareLen:  6,
+		Xid:          0x3903f326,
+		ClientIP:     net.IPv4zero,
+		YourClientIP: net.IPv4zero,
+		NextServerIP: net.IPv4zero,
+		RelayAgentIP: net.IPv4zero,
+		ClientHWAddr: mac,
+		Options: layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeDiscover)}),
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, net.IP{192, 168, 1, 100}.To4()),
+		},
+	})
+	offer := newTestDHCPv4Event(t, net.IP{192, 168, 1, 1}, net.IPv4bcast, 67, 68, &layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: layers.LinkTypeEthernet,
+		HardwareLen:  6,
+		Xid:          0x3903f326,
+		ClientIP:     net.IPv4zero,
+		YourClientIP: net.IP{192, 168, 1, 100},
+		NextServerIP: net.IPv4zero,
+		RelayAgentIP: net.IPv4zero,
+		ClientHWAddr: mac,
+		Options: layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeOffer)}),
+			layers.NewDHCPOption(layers.DHCPOptServerID, net.IP{192, 168, 1, 1}.To4()),
+			layers.NewDHCPOption(layers.DHCPOptLeaseTime, lease),
+		},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"version":      "dhcpv4",
+		"message_type": "discover",
+		"xid":          uint32(0x3903f326),
+		"client_mac":   "02:42:ac:11:00:02",
+		"requested_ip": "192.168.1.100",
+		"assigned_ip":  "",
+		"lease_time":   uint32(0),
+	}, deriveTestDHCPArgs(t, discover))
+
+	assert.Equal(t, map[string]interface{}{
+		"version":      "dhcpv4",
+		"message_type": "offer",
+		"xid":          uint32(0x3903f326),
+		"client_mac":   "02:42:ac:11:00:02",
+		"requested_ip": "",
+		"assigned_ip":  "192.168.1.100",
+		"lease_time":   uint32(3600),
+	}, deriveTestDHCPArgs(t, offer))
+}
+
+func TestNetPacketDHCPv6(t *testing.T) {
+	t.Parallel()
+
+	mac := net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}
+	duid := &layers.DHCPv6DUID{
+		Type:             layers.DHCPv6DUIDTypeLL,
+		HardwareType:     []byte{0, 1},
+		LinkLayerAddress: mac,
+	}
+	addr := net.ParseIP("2001:db8::100")
+
+	// IA_NA: IAID | T1 | T2 | IA address option (address | preferred | valid)
+	iana := make([]byte, 12, 12+28)
+	binary.BigEndian.PutUint32(iana, 1)
+	iana = binary.BigEndian.AppendUint16(iana, uint16(layers.DHCPv6OptIAAddr))
+	iana = binary.BigEndian.AppendUint16(iana, 24)
+	iana = append(iana, addr...)
+	iana = binary.BigEndian.AppendUint32(iana, 1800)
+	iana = binary.BigEndian.AppendUint32(iana, 7200)
+
+	clientLL := net.ParseIP("fe80::42:acff:fe11:2")
+	solicit := newTestDHCPv6Event(t, clientLL, net.ParseIP("ff02::1:2"), 546, 547, &layers.DHCPv6{
+		MsgType:       layers.DHCPv6MsgTypeSolicit,
+		TransactionID: []byte{0x0a, 0x0b, 0x0c},
+		Options: layers.DHCPv6Options{
+			layers.NewDHCPv6Option(layers.DHCPv6OptClientID, duid.Encode()),
+		},
+	})
+	advertise := newTestDHCPv6Event(t, net.ParseIP("fe80::1"), clientLL, 547, 546, &layers.DHCPv6{
+		MsgType:       layers.DHCPv6MsgTypeAdverstise,
+		TransactionID: []byte{0x0a, 0x0b, 0x0c},
+		Options: layers.DHCPv6Options{
+			layers.NewDHCPv6Option(layers.DHCPv6OptClientID, duid.Encode()),
+			layers.NewDHCPv6Option(layers.DHCPv6OptIANA, iana),
+		},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"version":      "dhcpv6",
+		"message_type": "solicit",
+		"xid":          uint32(0x0a0b0c),
+		"client_mac":   "02:42:ac:11:00:02",
+		"requested_ip": "",
+		"assigned_ip":  "",
+		"lease_time":   uint32(0),
+	}, deriveTestDHCPArgs(t, solicit))
+
+	assert.Equal(t, map[string]interface{}{
+		"version":      "dhcpv6",
+		"message_type": "advertise",
+		"xid":          uint32(0x0a0b0c),
+		"client_mac":   "02:42:ac:11:00:02",
+		"requested_ip": "",
+		"assigned_ip":  "2001:db8::100",
+		"lease_time":   uint32(7200),
+	}, deriveTestDHCPArgs(t, advertise))
+}
+
+func TestNetPacketDHCPNotDHCP(t *testing.T) {
+	t.Parallel()
+
+	ip := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 5678}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	event := newTestNetPacketEvent(t, events.NetPacketDHCPBase, familyIPv4, ip, udp, gopacket.Payload("not dhcp"))
+
+	derived, errs := NetPacketDHCP()(event)
+	require.Empty(t, errs)
+	require.Empty(t, derived)
+}
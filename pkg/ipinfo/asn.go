@@ -0,0 +1,77 @@
+package ipinfo
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+// ASN describes an autonomous system.
+type ASN struct {
+	Number       uint32
+	Organization string
+}
+
+// ASNResolver resolves the autonomous system an IP address belongs to.
+type ASNResolver interface {
+	LookupASN(ip net.IP) (ASN, bool)
+}
+
+// ASNDatabase is an ASNResolver loaded from GeoLite2-ASN CSV files (columns:
+// network, autonomous_system_number, autonomous_system_organization). IPv4
+// and IPv6 files might be given together.
+type ASNDatabase struct {
+	table rangeTable[ASN]
+}
+
+// LoadASNDatabase loads the given GeoLite2-ASN CSV files.
+func LoadASNDatabase(paths ...string) (*ASNDatabase, error) {
+	db := &ASNDatabase{}
+
+	for _, path := range paths {
+		err := readCSV(path, func(field func(string) string) error {
+			number, err := strconv.ParseUint(field("autonomous_system_number"), 10, 32)
+			if err != nil {
+				return errfmt.WrapError(err)
+			}
+			asn := ASN{
+				Number:       uint32(number),
+				Organization: field("autonomous_system_organization"),
+			}
+			return db.table.add(field("network"), asn)
+		})
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+	db.table.sort()
+
+	return db, nil
+}
+
+// LookupASN returns the autonomous system the given IP address belongs to.
+func (db *ASNDatabase) LookupASN(ip net.IP) (ASN, bool) {
+	return db.table.lookup(ip)
+}
+
+// cachedASNResolver caches the results of another ASNResolver.
+type cachedASNResolver struct {
+	resolver ASNResolver
+	cache    *lookupCache[ASN]
+}
+
+// NewCachedASNResolver caches the results of the given resolver for the most
+// recently looked up addresses (up to size addresses).
+func NewCachedASNResolver(resolver ASNResolver, size int) (ASNResolver, error) {
+	cache, err := newLookupCache[ASN](size)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	return &cachedASNResolver{resolver: resolver, cache: cache}, nil
+}
+
+func (c *cachedASNResolver) LookupASN(ip net.IP) (ASN, bool) {
+	return c.cache.lookup(ip, c.resolver.LookupASN)
+}
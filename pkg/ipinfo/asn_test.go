@@ -0,0 +1,64 @@
+package ipinfo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCSV(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "db.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	return path
+}
+
+func TestASNDatabase(t *testing.T) {
+	t.Parallel()
+
+	ipv4 := writeTestCSV(t, `network,autonomous_system_number,autonomous_system_organization
+1.1.1.0/24,13335,CLOUDFLARENET
+8.8.8.0/24,15169,GOOGLE
+8.8.4.0/24,15169,GOOGLE
+`)
+	ipv6 := writeTestCSV(t, `network,autonomous_system_number,autonomous_system_organization
+2001:4860::/32,15169,GOOGLE
+`)
+
+	db, err := LoadASNDatabase(ipv4, ipv6)
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip    string
+		asn   ASN
+		found bool
+	}{
+		{ip: "1.1.1.1", asn: ASN{13335, "CLOUDFLARENET"}, found: true},
+		{ip: "8.8.8.255", asn: ASN{15169, "GOOGLE"}, found: true},
+		{ip: "8.8.4.0", asn: ASN{15169, "GOOGLE"}, found: true},
+		{ip: "8.8.9.0", found: false},
+		{ip: "10.0.0.1", found: false},
+		{ip: "0.0.0.1", found: false},
+		{ip: "2001:4860:4860::8888", asn: ASN{15169, "GOOGLE"}, found: true},
+		{ip: "2001:4861::1", found: false},
+	}
+
+	resolver, err := NewCachedASNResolver(db, 10)
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		for _, r := range []ASNResolver{db, resolver, resolver} { // cache miss & hit
+			asn, found := r.LookupASN(net.ParseIP(tc.ip))
+			require.Equal(t, tc.found, found, tc.ip)
+			require.Equal(t, tc.asn, asn, tc.ip)
+		}
+	}
+
+	_, err = LoadASNDatabase(writeTestCSV(t, "network,autonomous_system_number\nbad,1\n"))
+	require.Error(t, err)
+}
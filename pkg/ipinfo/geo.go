@@ -0,0 +1,123 @@
+package ipinfo
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+// Location is the geolocation of an IP address.
+type Location struct {
+	CountryCode string // ISO 3166-1 country code
+	Country     string
+	City        string // might be empty (or unknown)
+}
+
+// GeoResolver resolves the geolocation of an IP address.
+type GeoResolver interface {
+	LookupLocation(ip net.IP) (Location, bool)
+}
+
+// GeoDatabase is a GeoResolver loaded from a GeoLite2-City (or GeoLite2-Country)
+// CSV database directory, holding the *-Blocks-IPv4.csv, *-Blocks-IPv6.csv
+// and *-Locations-en.csv files.
+type GeoDatabase struct {
+	table rangeTable[*Location]
+}
+
+// LoadGeoDatabase loads the GeoLite2 CSV database from the given directory.
+func LoadGeoDatabase(dir string) (*GeoDatabase, error) {
+	locationFiles, err := filepath.Glob(filepath.Join(dir, "*-Locations-en.csv"))
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	blockFiles, err := filepath.Glob(filepath.Join(dir, "*-Blocks-IPv[46].csv"))
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	if len(locationFiles) == 0 || len(blockFiles) == 0 {
+		return nil, errfmt.Errorf("no geolite2 csv database found in %s", dir)
+	}
+
+	locations := make(map[uint64]*Location)
+	for _, path := range locationFiles {
+		err := readCSV(path, func(field func(string) string) error {
+			id, err := strconv.ParseUint(field("geoname_id"), 10, 64)
+			if err != nil {
+				return errfmt.WrapError(err)
+			}
+			locations[id] = &Location{
+				CountryCode: field("country_iso_code"),
+				Country:     field("country_name"),
+				City:        field("city_name"),
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	db := &GeoDatabase{}
+
+	for _, path := range blockFiles {
+		err := readCSV(path, func(field func(string) string) error {
+			// networks might only be known by their registered country
+			id := field("geoname_id")
+			if id == "" {
+				id = field("registered_country_geoname_id")
+			}
+			if id == "" {
+				return nil // anonymous proxies, satellite providers, ...
+			}
+			geonameID, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				return errfmt.WrapError(err)
+			}
+			location, ok := locations[geonameID]
+			if !ok {
+				return errfmt.Errorf("unknown geoname id: %d", geonameID)
+			}
+			return db.table.add(field("network"), location)
+		})
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+	db.table.sort()
+
+	return db, nil
+}
+
+// LookupLocation returns the geolocation of the given IP address.
+func (db *GeoDatabase) LookupLocation(ip net.IP) (Location, bool) {
+	location, ok := db.table.lookup(ip)
+	if !ok {
+		return Location{}, false
+	}
+
+	return *location, true
+}
+
+// cachedGeoResolver caches the results of another GeoResolver.
+type cachedGeoResolver struct {
+	resolver GeoResolver
+	cache    *lookupCache[Location]
+}
+
+// NewCachedGeoResolver caches the results of the given resolver for the most
+// recently looked up addresses (up to size addresses).
+func NewCachedGeoResolver(resolver GeoResolver, size int) (GeoResolver, error) {
+	cache, err := newLookupCache[Location](size)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	return &cachedGeoResolver{resolver: resolver, cache: cache}, nil
+}
+
+func (c *cachedGeoResolver) LookupLocation(ip net.IP) (Location, bool) {
+	return c.cache.lookup(ip, c.resolver.LookupLocation)
+}
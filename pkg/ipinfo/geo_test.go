@@ -0,0 +1,64 @@
+package ipinfo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoDatabase(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"GeoLite2-City-Locations-en.csv": `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,city_name
+5375480,en,NA,"North America",US,"United States","Mountain View"
+2921044,en,EU,Europe,DE,Germany,
+`,
+		"GeoLite2-City-Blocks-IPv4.csv": `network,geoname_id,registered_country_geoname_id,is_anonymous_proxy
+8.8.8.0/24,5375480,6252001,0
+5.9.0.0/16,,2921044,0
+1.2.3.0/24,,,1
+`,
+		"GeoLite2-City-Blocks-IPv6.csv": `network,geoname_id,registered_country_geoname_id,is_anonymous_proxy
+2001:4860::/32,5375480,6252001,0
+`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	db, err := LoadGeoDatabase(dir)
+	require.NoError(t, err)
+
+	resolver, err := NewCachedGeoResolver(db, 10)
+	require.NoError(t, err)
+
+	mountainView := Location{CountryCode: "US", Country: "United States", City: "Mountain View"}
+
+	tests := []struct {
+		ip       string
+		location Location
+		found    bool
+	}{
+		{ip: "8.8.8.8", location: mountainView, found: true},
+		{ip: "2001:4860:4860::8888", location: mountainView, found: true},
+		{ip: "5.9.1.1", location: Location{CountryCode: "DE", Country: "Germany"}, found: true},
+		{ip: "1.2.3.4", found: false},
+		{ip: "192.168.0.1", found: false},
+	}
+
+	for _, tc := range tests {
+		for _, r := range []GeoResolver{db, resolver, resolver} { // cache miss & hit
+			location, found := r.LookupLocation(net.ParseIP(tc.ip))
+			require.Equal(t, tc.found, found, tc.ip)
+			require.Equal(t, tc.location, location, tc.ip)
+		}
+	}
+
+	_, err = LoadGeoDatabase(t.TempDir())
+	require.Error(t, err)
+}
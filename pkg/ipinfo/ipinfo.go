@@ -0,0 +1,173 @@
+// Package ipinfo resolves information about IP addresses (such as their
+// autonomous system or geolocation) from local databases.
+//
+// Databases are MaxMind GeoLite2 (or compatible) CSV files, fully loaded into
+// memory as sorted address ranges: a lookup is a binary search (O(log n)), but
+// it still costs enough, for every captured packet, to be worth caching (see
+// the cached resolvers).
+package ipinfo
+
+import (
+	"encoding/csv"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+// rangeEntry is an address range and its associated value.
+type rangeEntry[T any] struct {
+	first netip.Addr
+	last  netip.Addr
+	value T
+}
+
+// rangeTable is a sorted table of non overlapping address ranges.
+type rangeTable[T any] struct {
+	entries []rangeEntry[T]
+}
+
+// add adds the range of the given network (CIDR) to the table.
+func (t *rangeTable[T]) add(network string, value T) error {
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	prefix = prefix.Masked()
+
+	t.entries = append(t.entries, rangeEntry[T]{
+		first: prefix.Addr(),
+		last:  lastAddr(prefix),
+		value: value,
+	})
+
+	return nil
+}
+
+// sort sorts the table (needed after adding ranges, before looking them up).
+func (t *rangeTable[T]) sort() {
+	sort.Slice(t.entries, func(i, j int) bool {
+		return t.entries[i].first.Less(t.entries[j].first)
+	})
+}
+
+// lookup returns the value of the range holding the given address.
+func (t *rangeTable[T]) lookup(ip net.IP) (T, bool) {
+	var zero T
+
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return zero, false
+	}
+	addr = addr.Unmap()
+
+	// first range starting after the address: the previous one might hold it
+	i := sort.Search(len(t.entries), func(i int) bool {
+		return addr.Less(t.entries[i].first)
+	})
+	if i == 0 {
+		return zero, false
+	}
+	entry := t.entries[i-1]
+	if entry.last.Less(addr) {
+		return zero, false
+	}
+
+	return entry.value, true
+}
+
+// lookupResult is a cached lookup result (misses are cached as well).
+type lookupResult[T any] struct {
+	value T
+	found bool
+}
+
+// lookupCache caches lookup results of the most recently looked up addresses.
+type lookupCache[T any] struct {
+	cache *lru.Cache[string, lookupResult[T]]
+}
+
+func newLookupCache[T any](size int) (*lookupCache[T], error) {
+	cache, err := lru.New[string, lookupResult[T]](size)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	return &lookupCache[T]{cache: cache}, nil
+}
+
+// lookup returns the cached result for the given address, or the result of
+// the given lookup function (caching it).
+func (c *lookupCache[T]) lookup(ip net.IP, fn func(net.IP) (T, bool)) (T, bool) {
+	key := string(ip)
+	if result, ok := c.cache.Get(key); ok {
+		return result.value, result.found
+	}
+
+	value, found := fn(ip)
+	c.cache.Add(key, lookupResult[T]{value: value, found: found})
+
+	return value, found
+}
+
+// lastAddr returns the last address of the given (masked) prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 1 << (7 - bit%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+
+	return addr
+}
+
+// readCSV calls fn for every record (but the header) of the given CSV file,
+// giving it the record fields by header name.
+func readCSV(path string, fn func(field func(name string) string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	r := csv.NewReader(file)
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return errfmt.Errorf("reading %s header: %v", path, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	var record []string
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	for {
+		record, err = r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errfmt.Errorf("reading %s: %v", path, err)
+		}
+		if err := fn(field); err != nil {
+			return errfmt.Errorf("parsing %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// latencyBuckets are the upper bounds of the Histogram buckets. The last
+// (implicit) bucket holds everything above the last bound (+Inf).
+var latencyBuckets = [...]time.Duration{
+	1 * time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	25 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	1 * time.Second,
+}
+
+// Histogram is a fixed buckets latency histogram. Its zero value is ready to be
+// used and all its methods are thread-safe (lock free, so Stats can be copied).
+type Histogram struct {
+	buckets [len(latencyBuckets) + 1]uint64 // non cumulative bucket counts
+	count   uint64                          // number of observed samples
+	sum     uint64                          // sum of all samples (in nanoseconds)
+}
+
+// Observe records a single latency sample.
+func (h *Histogram) Observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	i := 0
+	for ; i < len(latencyBuckets); i++ {
+		if d <= latencyBuckets[i] {
+			break
+		}
+	}
+
+	atomic.AddUint64(&h.buckets[i], 1)
+	atomic.AddUint64(&h.sum, uint64(d))
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Count returns the number of observed samples.
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Average returns the average of all observed samples.
+func (h *Histogram) Average() time.Duration {
+	count := atomic.LoadUint64(&h.count)
+	if count == 0 {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadUint64(&h.sum) / count)
+}
+
+// Quantile returns the upper bound of the bucket holding the given quantile
+// (0.0 - 1.0) of all observed samples. Samples above the last bucket bound are
+// reported as the last bucket bound.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	count := atomic.LoadUint64(&h.count)
+	if count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(count))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var accumulated uint64
+	for i := range latencyBuckets {
+		accumulated += atomic.LoadUint64(&h.buckets[i])
+		if accumulated >= rank {
+			return latencyBuckets[i]
+		}
+	}
+
+	return latencyBuckets[len(latencyBuckets)-1]
+}
+
+// String returns a short summary of the histogram (used when printing Stats).
+func (h Histogram) String() string {
+	return fmt.Sprintf("{count:%d avg:%v p99:%v}", h.Count(), h.Average(), h.Quantile(0.99))
+}
+
+// constHistogram returns a prometheus (cumulative) histogram snapshot.
+func (h *Histogram) constHistogram(desc *prometheus.Desc) (prometheus.Metric, error) {
+	buckets := make(map[float64]uint64, len(latencyBuckets))
+
+	var accumulated uint64
+	for i, bound := range latencyBuckets {
+		accumulated += atomic.LoadUint64(&h.buckets[i])
+		buckets[bound.Seconds()] = accumulated
+	}
+
+	sum := time.Duration(atomic.LoadUint64(&h.sum)).Seconds()
+
+	return prometheus.NewConstHistogram(desc, h.Count(), sum, buckets)
+}
+
+// histogramCollector exports a Histogram as a prometheus histogram.
+type histogramCollector struct {
+	desc      *prometheus.Desc
+	histogram *Histogram
+}
+
+func newHistogramCollector(namespace, name, help string, h *Histogram) *histogramCollector {
+	return &histogramCollector{
+		desc:      prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, nil, nil),
+		histogram: h,
+	}
+}
+
+func (c *histogramCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *histogramCollector) Collect(ch chan<- prometheus.Metric) {
+	m, err := c.histogram.constHistogram(c.desc)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- m
+}
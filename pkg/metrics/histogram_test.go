@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	t.Parallel()
+
+	h := Histogram{}
+
+	require.Equal(t, uint64(0), h.Count())
+	require.Equal(t, time.Duration(0), h.Average())
+	require.Equal(t, time.Duration(0), h.Quantile(0.99))
+
+	for i := 0; i < 99; i++ {
+		h.Observe(3 * time.Microsecond)
+	}
+	h.Observe(2 * time.Millisecond)
+
+	require.Equal(t, uint64(100), h.Count())
+	require.Equal(t, (99*3*time.Microsecond+2*time.Millisecond)/100, h.Average())
+	require.Equal(t, 5*time.Microsecond, h.Quantile(0.5))
+	require.Equal(t, 5*time.Microsecond, h.Quantile(0.99))
+	require.Equal(t, 5*time.Millisecond, h.Quantile(1))
+}
+
+func TestHistogramObserveAboveLastBucket(t *testing.T) {
+	t.Parallel()
+
+	h := Histogram{}
+	h.Observe(time.Minute)
+
+	require.Equal(t, uint64(1), h.Count())
+	require.Equal(t, time.Minute, h.Average())
+	require.Equal(t, time.Second, h.Quantile(0.99))
+}
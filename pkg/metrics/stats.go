@@ -9,15 +9,44 @@ import (
 
 // When updating this struct, please make sure to update the relevant exporting functions
 type Stats struct {
-	EventCount       counter.Counter
-	EventsFiltered   counter.Counter
-	NetCapCount      counter.Counter // network capture events
-	BPFLogsCount     counter.Counter
-	ErrorCount       counter.Counter
-	LostEvCount      counter.Counter
-	LostWrCount      counter.Counter
-	LostNtCapCount   counter.Counter // lost network capture events
-	LostBPFLogsCount counter.Counter
+	EventCount          counter.Counter
+	EventsFiltered      counter.Counter
+	NetCapCount         counter.Counter // network capture events
+	BPFLogsCount        counter.Counter
+	ErrorCount          counter.Counter
+	LostEvCount         counter.Counter
+	LostWrCount         counter.Counter
+	LostNtCapCount      counter.Counter // lost network capture events
+	NetCapEmptyCount    counter.Counter // network capture events without packet data (skipped)
+	NetCapLoopCount     counter.Counter // network capture loopback packets (skipped)
+	NetCapLowEntropy    counter.Counter // network capture packets below the payload entropy threshold (skipped)
+	NetCapICMPNormal    counter.Counter // network capture ordinary ICMP echoes, when only anomalous ones are captured (skipped)
+	NetCapSrcSkipped    counter.Counter // network capture packets from sources not allowed (skipped)
+	NetCapFamilySkip    counter.Counter // network capture packets of the address family not captured (skipped)
+	NetCapNoFamily      counter.Counter // network capture packets without a valid address family (skipped)
+	NetCapARP           counter.Counter // network capture ARP packets (captured as is)
+	NetCapSyscallSkip   counter.Counter // network capture packets not originated by the syscalls allowed (skipped)
+	NetCapDecapsulated  counter.Counter // network capture packets tunneled in GRE or VXLAN (decapsulated)
+	NetCapReassembled   counter.Counter // network capture IPv4 datagrams reassembled from their fragments
+	NetCapFragHeld      counter.Counter // network capture IPv4 fragments held until their datagram is complete
+	NetCapFragDiscarded counter.Counter // network capture incomplete IPv4 fragment sets discarded (timed out or evicted)
+	NetCapNotUrgent     counter.Counter // network capture packets without TCP URG, when only urgent ones are captured (skipped)
+	NetCapChecksumOK    counter.Counter // network capture packets with valid checksums, when only invalid ones are captured (skipped)
+	NetCapFiltered      counter.Counter // network capture packets not matching the capture filter (skipped)
+	NetCapSampledOut    counter.Counter // network capture packets of flows not sampled (skipped)
+	NetCapTargets       counter.Counter // network capture targets currently active (gauge)
+	NetCapEvents        counter.Counter // network capture packets emitted to the events stream
+	NetCapDNSEvents     counter.Counter // network capture DNS messages emitted to the events stream
+	NetCapTLSEvents     counter.Counter // network capture TLS hellos emitted to the events stream
+	NetCapHTTPEvents    counter.Counter // network capture HTTP requests emitted to the events stream
+	NetCapFlowEvents    counter.Counter // network capture flow summaries emitted to the events stream
+	NetCapWritten       counter.Counter // network capture packets written to pcap files (once per file)
+	NetCapWrittenBytes  counter.Counter // network capture packet bytes written to pcap files (once per file)
+	NetCapLongLived     counter.Counter // network capture packets of processes not known to be short-lived, when only short-lived ones are captured (discarded)
+	NetCapUntriggered   counter.Counter // network capture packets dropped from the ring buffer, never dumped by a trigger
+	LostBPFLogsCount    counter.Counter
+	NetCapLatency       Histogram // network capture packet processing latency (sampled)
+	NetCapHandshake     Histogram // network capture TCP handshake times of flows (if enabled)
 }
 
 // Register Stats to prometheus metrics exporter
@@ -82,6 +111,318 @@ func (stats *Stats) RegisterPrometheus() error {
 		return errfmt.WrapError(err)
 	}
 
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_empty_total",
+		Help:      "network capture events skipped for carrying no packet data",
+	}, func() float64 { return float64(stats.NetCapEmptyCount.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_unknown_family_total",
+		Help:      "network capture packets skipped for carrying no valid address family",
+	}, func() float64 { return float64(stats.NetCapNoFamily.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_arp_total",
+		Help:      "network capture ARP packets captured",
+	}, func() float64 { return float64(stats.NetCapARP.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_loopback_total",
+		Help:      "network capture loopback packets skipped",
+	}, func() float64 { return float64(stats.NetCapLoopCount.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_low_entropy_total",
+		Help:      "network capture packets skipped for a payload entropy below the threshold",
+	}, func() float64 { return float64(stats.NetCapLowEntropy.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_icmp_normal_total",
+		Help:      "network capture ordinary ICMP echoes skipped when only anomalous ICMP echoes are captured",
+	}, func() float64 { return float64(stats.NetCapICMPNormal.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_source_skipped_total",
+		Help:      "network capture packets skipped for coming from a source (eBPF hook) not allowed",
+	}, func() float64 { return float64(stats.NetCapSrcSkipped.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_syscall_skipped_total",
+		Help:      "network capture packets skipped for not being originated by a syscall allowed",
+	}, func() float64 { return float64(stats.NetCapSyscallSkip.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_family_skipped_total",
+		Help:      "network capture packets skipped for being of the address family not captured",
+	}, func() float64 { return float64(stats.NetCapFamilySkip.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_decapsulated_total",
+		Help:      "network capture packets tunneled in GRE or VXLAN, decapsulated",
+	}, func() float64 { return float64(stats.NetCapDecapsulated.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_reassembled_total",
+		Help:      "network capture IPv4 datagrams reassembled from their fragments",
+	}, func() float64 { return float64(stats.NetCapReassembled.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_fragments_held_total",
+		Help:      "network capture IPv4 fragments held until their datagram is complete",
+	}, func() float64 { return float64(stats.NetCapFragHeld.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_fragment_sets_discarded_total",
+		Help:      "network capture incomplete IPv4 fragment sets discarded (timed out or evicted)",
+	}, func() float64 { return float64(stats.NetCapFragDiscarded.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_not_urgent_total",
+		Help:      "network capture packets skipped for not being TCP segments with the URG flag set, when only urgent ones are captured",
+	}, func() float64 { return float64(stats.NetCapNotUrgent.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_valid_checksum_total",
+		Help:      "network capture packets skipped for having valid checksums, when only packets with invalid checksums are captured",
+	}, func() float64 { return float64(stats.NetCapChecksumOK.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_filtered_total",
+		Help:      "network capture packets skipped for not matching the capture filter",
+	}, func() float64 { return float64(stats.NetCapFiltered.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_sampled_out_total",
+		Help:      "network capture packets skipped for being of flows not sampled",
+	}, func() float64 { return float64(stats.NetCapSampledOut.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_active_targets",
+		Help:      "network capture targets currently active (pcap files kept open)",
+	}, func() float64 { return float64(stats.NetCapTargets.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_emitted_total",
+		Help:      "network capture packets emitted to the events stream (net_packet_captured events)",
+	}, func() float64 { return float64(stats.NetCapEvents.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_dns_emitted_total",
+		Help:      "network capture DNS messages emitted to the events stream (net_packet_captured_dns events)",
+	}, func() float64 { return float64(stats.NetCapDNSEvents.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_tls_emitted_total",
+		Help:      "network capture TLS hellos emitted to the events stream (net_packet_tls events)",
+	}, func() float64 { return float64(stats.NetCapTLSEvents.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_http_emitted_total",
+		Help:      "network capture HTTP requests emitted to the events stream (net_packet_http events)",
+	}, func() float64 { return float64(stats.NetCapHTTPEvents.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_flow_emitted_total",
+		Help:      "network capture flow summaries emitted to the events stream (net_flow_summary events)",
+	}, func() float64 { return float64(stats.NetCapFlowEvents.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_written_packets_total",
+		Help:      "network capture packets written to pcap files (once per file)",
+	}, func() float64 { return float64(stats.NetCapWritten.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_written_bytes_total",
+		Help:      "network capture packet bytes written to pcap files (once per file)",
+	}, func() float64 { return float64(stats.NetCapWrittenBytes.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_long_lived_total",
+		Help:      "network capture packets of processes not known to be short-lived, when only short-lived ones are captured (discarded)",
+	}, func() float64 { return float64(stats.NetCapLongLived.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_untriggered_total",
+		Help:      "network capture packets dropped from the ring buffer, never dumped by a trigger",
+	}, func() float64 { return float64(stats.NetCapUntriggered.Get()) }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(newHistogramCollector(
+		"tracee_ebpf",
+		"network_capture_latency_seconds",
+		"network capture packet processing latency (sampled)",
+		&stats.NetCapLatency,
+	))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(newHistogramCollector(
+		"tracee_ebpf",
+		"network_capture_tcp_handshake_seconds",
+		"network capture TCP handshake (SYN to ACK) times of flows",
+		&stats.NetCapHandshake,
+	))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_latency_avg_seconds",
+		Help:      "network capture packet processing average latency (sampled)",
+	}, func() float64 { return stats.NetCapLatency.Average().Seconds() }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "network_capture_latency_p99_seconds",
+		Help:      "network capture packet processing p99 latency (sampled)",
+	}, func() float64 { return stats.NetCapLatency.Quantile(0.99).Seconds() }))
+
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
 	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
 		Namespace: "tracee_ebpf",
 		Name:      "bpf_logs_total",
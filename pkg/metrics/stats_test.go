@@ -0,0 +1,12 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsRegisterPrometheus(t *testing.T) {
+	// every metric has its own name (registering fails on duplicates)
+	require.NoError(t, (&Stats{}).RegisterPrometheus())
+}
@@ -3,6 +3,7 @@ package pcaps
 import (
 	lru "github.com/hashicorp/golang-lru/v2"
 
+	"github.com/aquasecurity/tracee/pkg/config"
 	"github.com/aquasecurity/tracee/pkg/errfmt"
 	"github.com/aquasecurity/tracee/pkg/logger"
 	"github.com/aquasecurity/tracee/types/trace"
@@ -12,25 +13,98 @@ import (
 type PcapCache struct {
 	itemCache *lru.Cache[string, *Pcap]
 	itemType  PcapType
+	config    config.PcapsConfig
+	output    *pcapOutput                // where pcap files are written to
+	rotations map[string]string          // rotation suffix of the current file of rotated targets
+	closed    map[string]*Pcap           // pcap files closed, but not finalized
+	lifetimes map[string]*targetLifetime // packets span of targets (if a min lifetime is set)
+	hooks     *fileHooks                 // hook commands run when pcap files are opened or finalized (if any)
+	limits    *containerLimits           // packets and bytes written per container (container cache only, if limited)
+	// created is called once a pcap file is opened for the first time in the
+	// capture session (if set)
+	created func(item *Pcap, event *trace.Event)
+	// compressed is called once a finalized pcap file is compressed (if set)
+	compressed func(path string, compressedPath string)
+	// removed is called once a finalized pcap file is removed (if set)
+	removed func(path string)
 }
 
-func newPcapCache(itemType PcapType) (*PcapCache, error) {
-	cache, err := lru.NewWithEvict(
+func newPcapCache(itemType PcapType, cfg config.PcapsConfig, output *pcapOutput) (*PcapCache, error) {
+	p := &PcapCache{
+		itemType:  itemType,
+		config:    cfg,
+		output:    output,
+		rotations: make(map[string]string),
+		closed:    make(map[string]*Pcap),
+		lifetimes: make(map[string]*targetLifetime),
+	}
+	if itemType == Container {
+		p.limits = newContainerLimits(cfg.PerContainerPacketLimit, cfg.PerContainerByteLimit)
+	}
+
+	var err error
+	p.itemCache, err = lru.NewWithEvict(
 		pcapsToCache,
 		func(_ string, item *Pcap,
 		) {
-			if err := item.pcapWriter.Flush(); err != nil {
-				logger.Errorw("Flushing pcap", "error", err)
-			}
-			if err := item.pcapFile.Close(); err != nil {
-				logger.Errorw("Closing file", "error", err)
-			}
+			p.closeItem(item)
 		})
 
-	return &PcapCache{
-		itemCache: cache,
-		itemType:  itemType,
-	}, errfmt.WrapError(err)
+	return p, errfmt.WrapError(err)
+}
+
+// closeItem closes a pcap file evicted from the cache, finalizing it if closed
+// for good.
+func (p *PcapCache) closeItem(item *Pcap) {
+	if err := item.close(); err != nil {
+		logger.Errorw("Closing file", "error", err)
+	}
+	if !item.finalized {
+		p.closed[item.pcapPath] = item // finalized later
+		return
+	}
+	p.finalize(item)
+}
+
+// finalize removes a pcap file closed for good if its target was short-lived
+// (if a min lifetime is set) or, otherwise, compresses it (if compression is
+// enabled) and runs its close hook (if any).
+func (p *PcapCache) finalize(item *Pcap) {
+	delete(p.closed, item.pcapPath)
+
+	if p.shortLived(item) {
+		if err := removePcap(item); err != nil {
+			logger.Errorw("Removing pcap file", "filename", item.pcapPath, "error", err)
+			return
+		}
+		if p.removed != nil {
+			p.removed(item.pcapPath)
+		}
+		return
+	}
+
+	path := item.pcapPath
+	if p.config.Compress == CompressGzip && p.config.RingFileSize == 0 {
+		path = p.compress(path)
+	}
+	if p.hooks != nil {
+		p.hooks.closed(path, item.target)
+	}
+}
+
+// compress compresses a finalized pcap file, returning the path of the
+// compressed file (or the given path, if it could not be compressed).
+func (p *PcapCache) compress(path string) string {
+	compressedPath, err := compressPcap(path)
+	if err != nil {
+		logger.Errorw("Compressing pcap file", "filename", path, "error", err)
+		return path
+	}
+	if p.compressed != nil {
+		p.compressed(path, compressedPath)
+	}
+
+	return compressedPath
 }
 
 func (p *PcapCache) get(event *trace.Event) (*Pcap, error) {
@@ -38,14 +112,80 @@ func (p *PcapCache) get(event *trace.Event) (*Pcap, error) {
 	var item *Pcap
 	var i interface{}
 
-	i, ok = p.itemCache.Get(getItemIndexFromEvent(event, p.itemType))
+	// packets of each direction go to their own file (if split by direction)
+	var direction string
+	index := getItemIndexFromEvent(event, p.itemType)
+	if p.config.SplitDirection {
+		direction = packetDirection(event, p.config.UnknownDirection)
+		index += "/" + direction
+	}
+
+	// packets span of the target (if a min lifetime is set)
+	var lifetime *targetLifetime
+	if p.config.MinLifetime > 0 {
+		lifetime = p.lifetimes[index]
+		if lifetime == nil {
+			lifetime = &targetLifetime{}
+			p.lifetimes[index] = lifetime
+		}
+		lifetime.packet(int64(event.Timestamp))
+	}
+
+	i, ok = p.itemCache.Get(index)
+	if cached, isPcap := i.(*Pcap); ok && isPcap && p.rotate(cached, int64(event.Timestamp)) {
+		// next packets go to a new file (the current one is closed)
+		p.rotations[index] = rotationSuffix(int64(event.Timestamp))
+		cached.finalized = true
+		cached.rotated = true
+		p.itemCache.Remove(index)
+		ok = false
+	}
 	if !ok {
 		// create an item and return it
-		n, err := NewPcap(event, p.itemType)
+		var n *Pcap
+		var err error
+		if p.config.RingFileSize > 0 {
+			n, err = newRingPcap(p.output, event, p.itemType, direction, p.config.RingFileSize)
+		} else {
+			n, err = newPcap(p.output, event, p.itemType, direction, p.rotations[index])
+		}
 		if err != nil {
 			return nil, errfmt.WrapError(err)
 		}
-		p.itemCache.Add(getItemIndexFromEvent(event, p.itemType), n)
+		n.opened = int64(event.Timestamp)
+		n.target = getItemTarget(event, p.itemType)
+		n.lifetime = lifetime
+		if closed, reopened := p.closed[n.pcapPath]; reopened {
+			n.created = closed.created
+		}
+		n.limiter = newRateLimiter(p.config.RateLimitPackets, p.config.RateLimitBytes)
+		if p.config.Sidecar {
+			n.sidecar, err = openSidecar(n.pcapPath)
+			if err != nil {
+				_ = n.close()
+				return nil, errfmt.WrapError(err)
+			}
+		}
+		if p.config.Chain {
+			n.chain, err = openChain(n.pcapPath)
+			if err != nil {
+				_ = n.close()
+				return nil, errfmt.WrapError(err)
+			}
+		}
+		if p.config.StatsInterval > 0 {
+			n.stats = openTargetStats(n.pcapPath, n.target)
+		}
+		if _, reopened := p.closed[n.pcapPath]; !reopened {
+			if p.created != nil {
+				p.created(n, event)
+			}
+			if p.hooks != nil {
+				p.hooks.opened(n.pcapPath, n.target)
+			}
+		}
+		delete(p.closed, n.pcapPath)
+		p.itemCache.Add(index, n)
 		item = n
 	} else {
 		// return the cached item
@@ -58,14 +198,51 @@ func (p *PcapCache) get(event *trace.Event) (*Pcap, error) {
 	return item, nil
 }
 
+// sync flushes all open pcap files and commits them to disk.
+func (p *PcapCache) sync() error {
+	for _, index := range p.itemCache.Keys() {
+		if item, ok := p.itemCache.Peek(index); ok {
+			if err := item.sync(); err != nil {
+				return errfmt.WrapError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (p *PcapCache) destroy() error {
-	for _, key := range p.itemCache.Keys() {
-		item, _ := p.itemCache.Get(key)
-		if err := item.close(); err != nil {
-			logger.Errorw("Closing file", "error", err)
+	for _, index := range p.itemCache.Keys() {
+		if item, ok := p.itemCache.Peek(index); ok {
+			item.finalized = true
 		}
 	}
-	p.itemCache.Purge()
+	p.itemCache.Purge() // evicted items are closed
+
+	// files closed earlier are finalized as well
+	for _, path := range sortedKeys(p.closed) {
+		p.finalize(p.closed[path])
+	}
+	p.lifetimes = make(map[string]*targetLifetime)
 
 	return nil
 }
+
+// newPcapCaches creates the caches of all given pcap types, writing pcap files
+// to the given output directory.
+func newPcapCaches(types PcapType, cfg config.PcapsConfig, output *pcapOutput) (map[PcapType]*PcapCache, error) {
+	caches := make(map[PcapType]*PcapCache)
+
+	for _, t := range []PcapType{Single, Process, Container, Command, User, Noise} {
+		if types&t != t {
+			continue
+		}
+		cache, err := newPcapCache(t, cfg, output)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		caches[t] = cache
+	}
+
+	return caches, nil
+}
@@ -0,0 +1,296 @@
+package pcaps
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// The hash chain makes pcap files tamper-evident: every block written to a
+// pcap file (packets, summaries, secrets...) is chained to the previous ones
+// (head = sha256(previous head | block)) and the chain head is persisted, once
+// in a while and when the file is closed, to a checkpoint file next to the
+// pcap file (same path plus the ".chain" suffix). Altering, removing or
+// reordering any chained block changes all the following heads, and truncating
+// the pcap file leaves checkpoints behind, so both are detected by VerifyChain.
+//
+// Section header and interface description blocks are not chained (they are
+// written by gopacket every time the file is reopened).
+//
+// Checkpoint file format (little endian):
+//
+//	header: magic (8 bytes)
+//	record: chained blocks (8) | pcap file offset after them (8) | head (32)
+//
+// NOTE: The chain detects changes to pcap files, not to checkpoint files: for
+//       tamper-evidence against someone able to rewrite both, the checkpoint
+//       files (or the session manifest, holding their hashes) must be kept
+//       somewhere else.
+//
+
+const (
+	chainSuffix           = ".chain"
+	chainMagic            = "TRCPCHN1"
+	chainRecordSize       = 48
+	chainCheckpointBlocks = 100 // persist the chain head every X chained blocks
+)
+
+// chainCheckpoint is the state of a chain after a number of blocks.
+type chainCheckpoint struct {
+	blocks uint64
+	offset int64
+	head   [sha256.Size]byte
+}
+
+func (c *chainCheckpoint) encode() []byte {
+	b := make([]byte, 0, chainRecordSize)
+	b = binary.LittleEndian.AppendUint64(b, c.blocks)
+	b = binary.LittleEndian.AppendUint64(b, uint64(c.offset))
+	b = append(b, c.head[:]...)
+
+	return b
+}
+
+func decodeChainCheckpoint(b []byte) chainCheckpoint {
+	c := chainCheckpoint{
+		blocks: binary.LittleEndian.Uint64(b[0:]),
+		offset: int64(binary.LittleEndian.Uint64(b[8:])),
+	}
+	copy(c.head[:], b[16:])
+
+	return c
+}
+
+// next chains a block, ending at the given offset, to the checkpoint.
+func (c *chainCheckpoint) next(block []byte, offset int64) {
+	h := sha256.New()
+	h.Write(c.head[:])
+	h.Write(block)
+	h.Sum(c.head[:0])
+	c.blocks++
+	c.offset = offset
+}
+
+// pcapChain chains the blocks written to a pcap file.
+type pcapChain struct {
+	file    *os.File
+	current chainCheckpoint
+	pending int // blocks chained since last checkpoint
+	dirty   bool
+}
+
+// openChain opens (creating it if needed) the checkpoint file of the given pcap
+// file. The chain continues from its last checkpoint.
+func openChain(pcapPath string) (*pcapChain, error) {
+	file, err := utils.OpenAt(
+		outputDirectory,
+		pcapPath+chainSuffix,
+		os.O_RDWR|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	c := &pcapChain{file: file}
+
+	checkpoints, err := readChainCheckpoints(file)
+	switch {
+	case errors.Is(err, errChainEmpty):
+		_, err = file.Write([]byte(chainMagic))
+		c.dirty = true // first checkpoint covers the empty file
+	case err == nil:
+		c.current = checkpoints[len(checkpoints)-1]
+		_, err = file.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, errfmt.WrapError(err)
+	}
+
+	return c, nil
+}
+
+// add chains a block written to the pcap file, ending at the given offset.
+func (c *pcapChain) add(block []byte, offset int64) error {
+	c.current.next(block, offset)
+	c.dirty = true
+	c.pending++
+	if c.pending < chainCheckpointBlocks {
+		return nil
+	}
+
+	return c.checkpoint()
+}
+
+// checkpoint persists the current chain head (if it changed).
+func (c *pcapChain) checkpoint() error {
+	if !c.dirty {
+		return nil
+	}
+	if _, err := c.file.Write(c.current.encode()); err != nil {
+		return errfmt.WrapError(err)
+	}
+	c.dirty = false
+	c.pending = 0
+
+	return nil
+}
+
+func (c *pcapChain) close() error {
+	err := c.checkpoint()
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return errfmt.WrapError(err)
+}
+
+var errChainEmpty = errors.New("empty pcap chain file")
+
+// readChainCheckpoints returns all checkpoints of a checkpoint file.
+func readChainCheckpoints(r io.Reader) ([]chainCheckpoint, error) {
+	br := bufio.NewReaderSize(r, 64*chainRecordSize)
+
+	magic := make([]byte, len(chainMagic))
+	n, err := io.ReadFull(br, magic)
+	if n == 0 && err == io.EOF {
+		return nil, errChainEmpty
+	}
+	if err != nil || string(magic) != chainMagic {
+		return nil, errfmt.Errorf("not a pcap chain file")
+	}
+
+	var checkpoints []chainCheckpoint
+
+	b := make([]byte, chainRecordSize)
+	for {
+		_, err := io.ReadFull(br, b)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, errfmt.Errorf("truncated pcap chain checkpoint")
+		}
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		checkpoints = append(checkpoints, decodeChainCheckpoint(b))
+	}
+	if len(checkpoints) == 0 {
+		return nil, errfmt.Errorf("no pcap chain checkpoints")
+	}
+
+	return checkpoints, nil
+}
+
+// VerifyChain verifies the given pcap file against its chain checkpoints (the
+// checkpoint file next to it) and returns the number of verified blocks. An
+// error is returned if any chained block was altered, removed or added, or if
+// the file was truncated.
+func VerifyChain(pcapPath string) (uint64, error) {
+	chainFile, err := os.Open(pcapPath + chainSuffix)
+	if err != nil {
+		return 0, errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = chainFile.Close()
+	}()
+
+	checkpoints, err := readChainCheckpoints(chainFile)
+	if err != nil {
+		return 0, errfmt.Errorf("%s: %v", pcapPath+chainSuffix, err)
+	}
+
+	file, err := os.Open(pcapPath)
+	if err != nil {
+		return 0, errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	r := bufio.NewReader(file)
+
+	var current chainCheckpoint
+	next := 0 // next checkpoint to match
+
+	// check matches the checkpoints reached by the chain
+	check := func() error {
+		for next < len(checkpoints) && checkpoints[next].blocks == current.blocks {
+			expected := checkpoints[next]
+			if expected.head != current.head || expected.offset != current.offset {
+				return errfmt.Errorf(
+					"pcap chain mismatch at block %d (offset %d): file was altered",
+					current.blocks, current.offset,
+				)
+			}
+			next++
+		}
+		return nil
+	}
+
+	if err := check(); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			break
+		} else if err != nil {
+			return current.blocks, errfmt.Errorf("truncated pcap block at offset %d", current.offset)
+		}
+		blockType := binary.LittleEndian.Uint32(header[0:])
+		length := binary.LittleEndian.Uint32(header[4:])
+		if length < 12 || length%4 != 0 {
+			return current.blocks, errfmt.Errorf("invalid pcap block at offset %d", current.offset)
+		}
+		block := make([]byte, length)
+		copy(block, header)
+		if _, err := io.ReadFull(r, block[8:]); err != nil {
+			return current.blocks, errfmt.Errorf("truncated pcap block at offset %d", current.offset)
+		}
+		if !bytes.Equal(block[length-4:], header[4:]) {
+			return current.blocks, errfmt.Errorf("invalid pcap block at offset %d", current.offset)
+		}
+
+		offset := current.offset + int64(length)
+		switch blockType {
+		case ngBlockTypeSectionHeader:
+			if binary.LittleEndian.Uint32(block[8:]) != 0x1A2B3C4D {
+				return current.blocks, errfmt.Errorf("unsupported pcap byte order at offset %d", current.offset)
+			}
+			current.offset = offset
+		case ngBlockTypeInterfaceDescription:
+			current.offset = offset
+		default:
+			current.next(block, offset)
+			if err := check(); err != nil {
+				return current.blocks, err
+			}
+		}
+	}
+
+	last := checkpoints[len(checkpoints)-1]
+	if next < len(checkpoints) {
+		return current.blocks, errfmt.Errorf(
+			"pcap file truncated: %d blocks found, %d blocks checkpointed", current.blocks, last.blocks,
+		)
+	}
+	if current.blocks > last.blocks {
+		return last.blocks, errfmt.Errorf(
+			"%d pcap blocks after the last chain checkpoint are not covered", current.blocks-last.blocks,
+		)
+	}
+
+	return current.blocks, nil
+}
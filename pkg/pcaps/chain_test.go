@@ -0,0 +1,89 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// readTestPacketOffsets returns the offsets of the packet blocks of a pcap file.
+func readTestPacketOffsets(t *testing.T, data []byte) []int64 {
+	t.Helper()
+
+	var offsets []int64
+	for offset := 0; offset < len(data); {
+		blockType := binary.LittleEndian.Uint32(data[offset:])
+		if blockType == ngBlockTypeEnhancedPacket {
+			offsets = append(offsets, int64(offset))
+		}
+		offset += int(binary.LittleEndian.Uint32(data[offset+4:]))
+	}
+
+	return offsets
+}
+
+func TestPcapsChainVerify(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		Chain:         true,
+	})
+
+	// two sessions: the chain continues when the file is reopened
+	for i := 0; i < 250; i++ {
+		if i == 150 {
+			require.NoError(t, p.EndSession())
+			require.NoError(t, p.StartSession())
+		}
+		payload := []byte(fmt.Sprintf("packet %d", i))
+		require.NoError(t, p.Write(newTestEvent(i+1), newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, payload)))
+	}
+	require.NoError(t, p.Destroy())
+
+	pcapPath := filepath.Join(dir, pcapSingleDir, "single.pcap")
+
+	blocks, err := VerifyChain(pcapPath)
+	require.NoError(t, err)
+	require.Equal(t, uint64(250), blocks)
+
+	original, err := os.ReadFile(pcapPath)
+	require.NoError(t, err)
+	offsets := readTestPacketOffsets(t, original)
+	require.Len(t, offsets, 250)
+
+	// modifying any packet breaks verification
+	for i, offset := range offsets {
+		modified := append([]byte(nil), original...)
+		modified[offset+28+4+20+8] ^= 0xff // first byte of the UDP payload
+		require.NoError(t, os.WriteFile(pcapPath, modified, 0644))
+
+		_, err := VerifyChain(pcapPath)
+		require.ErrorContains(t, err, "file was altered", "packet %d", i)
+	}
+
+	// so does truncating the file (removing the last packet)
+	require.NoError(t, os.WriteFile(pcapPath, original[:offsets[len(offsets)-1]], 0644))
+	_, err = VerifyChain(pcapPath)
+	require.ErrorContains(t, err, "pcap file truncated")
+
+	// and appending packets (not covered by the chain)
+	appended := append(append([]byte(nil), original...), original[offsets[0]:offsets[1]]...)
+	require.NoError(t, os.WriteFile(pcapPath, appended, 0644))
+	_, err = VerifyChain(pcapPath)
+	require.ErrorContains(t, err, "not covered")
+}
+
+func TestPcapsChainRing(t *testing.T) {
+	dir := t.TempDir()
+	outDir, err := os.Open(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+
+	_, err = New(config.PcapsConfig{CaptureSingle: true, Chain: true, RingFileSize: 1 << 20}, outDir)
+	require.ErrorContains(t, err, "can't be used with ring files")
+}
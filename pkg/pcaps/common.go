@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/google/gopacket/layers"
@@ -25,6 +26,9 @@ const (
 	pcapProcDir   string = pcapDir + "processes/"
 	pcapContDir   string = pcapDir + "containers/"
 	pcapCommDir   string = pcapDir + "commands/"
+	pcapUserDir   string = pcapDir + "users/"
+	pcapNoiseDir  string = pcapDir
+	pcapConnDir   string = pcapDir + "connections/"
 )
 
 const (
@@ -41,7 +45,7 @@ const AF_INET6 = 10
 // Functions
 //
 
-func initializeGlobalVars(output *os.File) {
+func initializeGlobalVars(output *os.File, linkType layers.LinkType) {
 	outputDirectory = output // where to save pcap files
 
 	// fake interface to be added to each pcap file (needed)
@@ -49,16 +53,28 @@ func initializeGlobalVars(output *os.File) {
 		Name:        "tracee",
 		Comment:     "trace fake interface",
 		Description: "non-existing interface",
-		LinkType:    layers.LinkTypeNull, // layer2 is 4 bytes (or 32bit)
+		LinkType:    linkType, // null: layer2 is 4 bytes (or 32bit), ethernet: 14 bytes
 		SnapLength:  uint32(math.MaxUint32),
 	}
 }
 
+// pcapOutput is a directory pcap files are written to: the capture output
+// directory or a protocol output directory (see protocol.go).
+type pcapOutput struct {
+	dir  *os.File
+	path string // absolute path of dir (empty for the capture output directory)
+}
+
+// defaultOutput returns the capture output directory.
+func defaultOutput() *pcapOutput {
+	return &pcapOutput{dir: outputDirectory}
+}
+
 // getItemIndexFromEvent returns correct trace.Event variable according to
 // given PcapType
 func getItemIndexFromEvent(event *trace.Event, itemType PcapType) string {
 	switch itemType {
-	case Single:
+	case Single, Noise:
 		return itemType.String()
 	case Process:
 		return fmt.Sprint(event.HostThreadID)
@@ -70,26 +86,47 @@ func getItemIndexFromEvent(event *trace.Event, itemType PcapType) string {
 		// indexing by container_id only).
 		ret := fmt.Sprintf("%s:%s", event.Container.ID, event.ProcessName)
 		return ret
+	case User:
+		return fmt.Sprint(event.UserID)
+	case Connection:
+		return connectionName(event)
 	}
 
 	return ""
 }
 
-// getPcapFileName returns a string used to create a pcap file under the
-// capture output directory.
-func getPcapFileName(event *trace.Event, pcapType PcapType) (string, error) {
+// getItemTarget returns a human readable identification of the capture target
+// (e.g. "process:1234") of given event according to given PcapType
+func getItemTarget(event *trace.Event, itemType PcapType) string {
+	if itemType == Single || itemType == Noise {
+		return strings.ToLower(itemType.String())
+	}
+
+	return strings.ToLower(itemType.String()) + ":" + getItemIndexFromEvent(event, itemType)
+}
+
+// getPcapFileName returns a string used to create a pcap file, of the given
+// packet direction (if split by direction), under the given output directory:
+// relative to the capture output directory or, for other output directories,
+// an absolute path.
+func getPcapFileName(output *pcapOutput, event *trace.Event, pcapType PcapType, direction string) (string, error) {
 	var err error
 
 	contID := getContainerID(event.Container.ID)
 
 	// create needed dirs
-	err = mkdirForPcapType(outputDirectory, contID, pcapType)
+	err = mkdirForPcapType(output.dir, contID, pcapType)
 	if err != nil {
 		return "", errfmt.WrapError(err)
 	}
 
 	// return filename in format according to pcap type
-	return getFileStringFormat(event, contID, pcapType), nil
+	format := directionFileName(getFileStringFormat(event, contID, pcapType), direction)
+	if output.path != "" {
+		format = filepath.Join(output.path, format)
+	}
+
+	return format, nil
 }
 
 // getContainerID returns the container string to be used in pcap files or dirs
@@ -116,6 +153,9 @@ func getFileStringFormat(e *trace.Event, c string, t PcapType) string {
 			strings.ToLower(t.String()),
 		)
 	case Process:
+		if name, ok := templateFileName(e); ok {
+			return pcapProcDir + c + "/" + name
+		}
 		format = fmt.Sprintf(
 			pcapProcDir+"%v/%v_%v_%v.pcap",
 			c,
@@ -129,11 +169,26 @@ func getFileStringFormat(e *trace.Event, c string, t PcapType) string {
 			c,
 		)
 	case Command:
+		if name, ok := templateFileName(e); ok {
+			return pcapCommDir + c + "/" + name
+		}
 		format = fmt.Sprintf(
 			pcapCommDir+"%v/%v.pcap",
 			c,
 			e.ProcessName,
 		)
+	case User:
+		format = fmt.Sprintf(
+			pcapUserDir+"%v.pcap",
+			e.UserID,
+		)
+	case Noise:
+		format = pcapNoiseDir + "noise.pcap"
+	case Connection:
+		format = fmt.Sprintf(
+			pcapConnDir+"%v.pcap",
+			connectionName(e),
+		)
 	}
 
 	return format
@@ -151,7 +206,7 @@ func mkdirForPcapType(o *os.File, c string, t PcapType) error {
 	}
 
 	switch t {
-	case Single:
+	case Single, Noise:
 		e = utils.MkdirAtExist(o, pcapSingleDir, os.ModePerm)
 		if e != nil {
 			return errfmt.WrapError(e)
@@ -179,6 +234,16 @@ func mkdirForPcapType(o *os.File, c string, t PcapType) error {
 		if e != nil {
 			return errfmt.WrapError(e)
 		}
+	case User:
+		e = utils.MkdirAtExist(o, pcapUserDir, os.ModePerm)
+		if e != nil {
+			return errfmt.WrapError(e)
+		}
+	case Connection:
+		e = utils.MkdirAtExist(o, pcapConnDir, os.ModePerm)
+		if e != nil {
+			return errfmt.WrapError(e)
+		}
 	}
 
 	return nil
@@ -186,15 +251,18 @@ func mkdirForPcapType(o *os.File, c string, t PcapType) error {
 
 // getPcapFileAndWriter returns a file descriptor and and its associated pcap
 // writer depending on the type "t" given (a Pcap interface implementation).
-func getPcapFileAndWriter(event *trace.Event, t PcapType) (
+func getPcapFileAndWriter(output *pcapOutput, event *trace.Event, t PcapType, direction string, rotation string) (
+	string,
 	*os.File,
 	*pcapgo.NgWriter,
+	bool, // file created (it did not exist, or was empty)
 	error,
 ) {
-	pcapFilePath, err := getPcapFileName(event, t)
+	pcapFilePath, err := getPcapFileName(output, event, t, direction)
 	if err != nil {
-		return nil, nil, errfmt.WrapError(err)
+		return "", nil, nil, false, errfmt.WrapError(err)
 	}
+	pcapFilePath = rotationFileName(pcapFilePath, rotation)
 	file, err := utils.OpenAt(
 		outputDirectory,
 		pcapFilePath,
@@ -202,7 +270,12 @@ func getPcapFileAndWriter(event *trace.Event, t PcapType) (
 		0644,
 	)
 	if err != nil {
-		return nil, nil, errfmt.WrapError(err)
+		return "", nil, nil, false, errfmt.WrapError(err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return "", nil, nil, false, errfmt.WrapError(err)
 	}
 
 	logger.Debugw("pcap file (re)opened", "filename", pcapFilePath)
@@ -210,17 +283,17 @@ func getPcapFileAndWriter(event *trace.Event, t PcapType) (
 	writer, err := pcapgo.NewNgWriterInterface(
 		file,
 		fake,
-		pcapgo.DefaultNgWriterOptions,
+		ngWriterOptions(event, t),
 	)
 	if err != nil {
-		return nil, nil, errfmt.WrapError(err)
+		return "", nil, nil, false, errfmt.WrapError(err)
 	}
 	err = writer.Flush()
 	if err != nil {
-		return nil, nil, errfmt.WrapError(err)
+		return "", nil, nil, false, errfmt.WrapError(err)
 	}
 
-	return file, writer, nil
+	return pcapFilePath, file, writer, stat.Size() == 0, nil
 }
 
 // configToPcapType converts a simple bool like config struct to internal config
@@ -239,6 +312,12 @@ func configToPcapType(simple config.PcapsConfig) PcapType {
 	if simple.CaptureCommand {
 		cfg |= Command
 	}
+	if simple.CaptureUser {
+		cfg |= User
+	}
+	if simple.PerConnection {
+		cfg |= Connection
+	}
 
 	return cfg
 }
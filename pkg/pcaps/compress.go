@@ -0,0 +1,116 @@
+package pcaps
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// Pcap files might be compressed once finalized: when rotated, or when the
+// capture session ends (shutdown). Files being written are never compressed
+// (a crash leaves them readable, as plain pcap files): a finalized file is
+// compressed to FILE.pcap.gz, and only removed once compressed. Files closed
+// by the LRU cache are not finalized (their targets might get more packets),
+// they are compressed when the session ends.
+//
+// A target captured again after its file was compressed (e.g. in a new
+// session) gets a new plain file which, once finalized, is appended to the
+// compressed file as a new gzip member: decompressed, members are a single
+// stream, and pcapng sections simply follow each other.
+//
+// Sidecar, hash chain and index records refer to offsets in the uncompressed
+// pcap file: decompress it to use them.
+//
+
+const (
+	CompressGzip = "gzip"
+	CompressNone = "none"
+)
+
+const compressedSuffix = ".gz"
+
+// ParseCompression parses the name of a pcap files compression.
+func ParseCompression(compression string) (string, error) {
+	switch compression = strings.ToLower(compression); compression {
+	case CompressGzip, CompressNone:
+		return compression, nil
+	}
+
+	return "", errfmt.Errorf(
+		"invalid pcap compression (%s or %s): %s",
+		CompressGzip, CompressNone, compression,
+	)
+}
+
+// compressPcap compresses a finalized pcap file (relative to output dir,
+// unless absolute) to (or appends it to) the same file with the gzip suffix,
+// and removes it. It returns the path of the compressed file.
+func compressPcap(path string) (string, error) {
+	compressedPath := path + compressedSuffix
+
+	file, err := utils.OpenAt(outputDirectory, path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	compressed, err := utils.OpenAt(outputDirectory, compressedPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = compressed.Close()
+	}()
+
+	// a new gzip member goes to the end, dropped if not fully written
+	size, err := compressed.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", errfmt.WrapError(err)
+	}
+	err = compressMember(compressed, file)
+	if err == nil {
+		err = compressed.Sync()
+	}
+	if err != nil {
+		_ = compressed.Truncate(size)
+		return "", errfmt.WrapError(err)
+	}
+
+	if err := utils.RemoveAt(outputDirectory, path, 0); err != nil {
+		return "", errfmt.WrapError(err)
+	}
+	logger.Debugw("pcap file compressed", "filename", compressedPath)
+
+	return compressedPath, nil
+}
+
+// pcapCompressed accounts a pcap file compressed (finalized) in the current
+// session, if any, and in the files index (if enabled).
+func (p *Pcaps) pcapCompressed(path string, compressedPath string) {
+	if p.session != nil {
+		p.session.renamed(path, compressedPath)
+	}
+	if p.filesIndex != nil {
+		if err := p.filesIndex.renamed(path, compressedPath); err != nil {
+			logger.Errorw("Writing pcap files index", "error", err)
+		}
+	}
+}
+
+// compressMember writes the given reader contents, gzip compressed, to w.
+func compressMember(w io.Writer, r io.Reader) error {
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, r); err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	return errfmt.WrapError(gz.Close())
+}
@@ -0,0 +1,103 @@
+package pcaps
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+// readTestGzipPcap returns the number of packets of a gzip compressed pcap
+// file (of one or more pcapng sections).
+func readTestGzipPcap(t *testing.T, path string) int {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	r, err := pcapgo.NewNgReader(gz, pcapgo.NgReaderOptions{SkipUnknownVersion: true})
+	require.NoError(t, err)
+
+	var packets int
+	for {
+		_, _, err := r.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		packets++
+	}
+
+	return packets
+}
+
+func TestPcapsCompressGzip(t *testing.T) {
+	cfg := config.PcapsConfig{CaptureContainer: true, RotateInterval: time.Second, Compress: CompressGzip}
+	p, dir := newTestPcaps(t, cfg)
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	write := func(p *Pcaps, ts time.Duration) {
+		event := newTestEvent(int(time.Unix(1700000000, 0).Add(ts).UnixNano()))
+		event.Container.ID = "abc"
+		require.NoError(t, p.Write(event, pkt))
+	}
+
+	write(p, 0)
+	write(p, 500*time.Millisecond)
+	write(p, 1200*time.Millisecond) // rotated: the first file is compressed
+
+	containers := filepath.Join(dir, pcapContDir)
+	require.Equal(t, 2, readTestGzipPcap(t, filepath.Join(containers, "abc.pcap.gz")))
+	require.NoFileExists(t, filepath.Join(containers, "abc.pcap"))
+	require.FileExists(t, filepath.Join(containers, "abc.20231114T221321.200000Z.pcap")) // still open
+
+	require.NoError(t, p.Destroy())
+	require.Equal(t, 1, readTestGzipPcap(t, filepath.Join(containers, "abc.20231114T221321.200000Z.pcap.gz")))
+	require.NoFileExists(t, filepath.Join(containers, "abc.20231114T221321.200000Z.pcap"))
+
+	// the manifest lists the compressed files
+	manifests, err := filepath.Glob(filepath.Join(dir, pcapDir, "manifest-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	manifest, err := os.ReadFile(manifests[0])
+	require.NoError(t, err)
+	require.Contains(t, string(manifest), " "+pcapContDir+"abc.pcap.gz\n")
+	require.Contains(t, string(manifest), " "+pcapContDir+"abc.20231114T221321.200000Z.pcap.gz\n")
+	require.NotContains(t, strings.ReplaceAll(string(manifest), ".pcap.gz", ""), ".pcap")
+
+	// a target captured again is appended to its compressed file
+	outDir, err := utils.OpenExistingDir(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+	p, err = New(cfg, outDir)
+	require.NoError(t, err)
+	write(p, 2000*time.Millisecond)
+	write(p, 2500*time.Millisecond)
+	require.NoError(t, p.Destroy())
+
+	require.Equal(t, 4, readTestGzipPcap(t, filepath.Join(containers, "abc.pcap.gz")))
+	require.NoFileExists(t, filepath.Join(containers, "abc.pcap"))
+}
+
+func TestParseCompression(t *testing.T) {
+	t.Parallel()
+
+	compression, err := ParseCompression("GZIP")
+	require.NoError(t, err)
+	require.Equal(t, CompressGzip, compression)
+
+	_, err = ParseCompression("zstd")
+	require.ErrorContains(t, err, "invalid pcap compression (gzip or none): zstd")
+}
@@ -0,0 +1,134 @@
+package pcaps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// For single connection analysis, each TCP connection might be written to its
+// own pcap file (besides the files of other pcap types, if any), named by its
+// 5-tuple (as seen in its SYN) and start time. Connections are told by the
+// flow table (flow tracking is required): a connection file is opened on the
+// SYN starting the flow, and finalized once the flow is over (FIN from both
+// sides, RST, idle for too long, evicted from the flow table, or when the
+// capture session ends). The flow summary is recorded in the connection file
+// as well.
+//
+// Connections whose SYN was not captured (e.g. established before capture
+// started) do not have a file. As for other pcap types, connection files are
+// kept open by a LRU cache (pcapsToCache files at most): the least recently
+// active ones are closed, and reopened (appended to) when they have packets
+// again.
+//
+
+// connectionArg is the argument, of event copies, naming the connection file
+// of their packets.
+const connectionArg = "pcap_connection"
+
+// connectionFileName returns the name of the connection file of a packet
+// starting a TCP connection (e.g. "tcp_10.0.0.1_1234_10.0.0.2_80_TIME").
+func connectionFileName(ts int64, info *packetInfo) string {
+	return fmt.Sprintf("tcp_%s_%d_%s_%d_%s",
+		info.srcIP, info.srcPort, info.dstIP, info.dstPort, rotationSuffix(ts),
+	)
+}
+
+// connectionEvent returns a copy of the given event naming the connection file
+// its packet is written to.
+func connectionEvent(event *trace.Event, name string) *trace.Event {
+	copied := *event
+	copied.Args = append(event.Args[:len(event.Args):len(event.Args)], trace.Argument{
+		ArgMeta: trace.ArgMeta{Type: "const char*", Name: connectionArg},
+		Value:   name,
+	})
+
+	return &copied
+}
+
+// connectionName returns the name of the connection file of an event packet
+// (empty if none).
+func connectionName(event *trace.Event) string {
+	name, _ := getStringArg(event, connectionArg)
+	return name
+}
+
+// connection returns the event and the caches a packet of the given flow is
+// to be written to: those of its connection file as well if the flow has one,
+// opening it if the packet starts the flow with a SYN.
+func (p *Pcaps) connection(f *flow, event *trace.Event, info *packetInfo, caches map[PcapType]*PcapCache) (*trace.Event, map[PcapType]*PcapCache) {
+	if f.connection == "" {
+		tcp, ok := info.packet.TransportLayer().(*layers.TCP)
+		if !ok || f.packets != 1 || !tcp.SYN || tcp.ACK {
+			return event, caches
+		}
+		f.connection = connectionFileName(int64(event.Timestamp), info)
+	}
+
+	withConnection := make(map[PcapType]*PcapCache, len(caches)+1)
+	for k, cache := range caches {
+		withConnection[k] = cache
+	}
+	withConnection[Connection] = p.connections
+
+	return connectionEvent(event, f.connection), withConnection
+}
+
+// finishConnection records the summary of a flow that is over in its
+// connection file, and finalizes it.
+func (p *Pcaps) finishConnection(s *flowSummary) {
+	event := connectionEvent(s.flow.event, s.flow.connection)
+
+	if !s.flow.withheld { // nothing of the flow was written otherwise
+		block := encodeNgInterfaceStatistics(s.flow.last, commentOptions(s.comments()))
+		item, err := p.connections.get(event)
+		if err == nil {
+			err = item.writeBlock(block)
+		}
+		if err != nil {
+			logger.Errorw("Writing pcap flow summary", "error", err)
+		} else if p.session != nil {
+			p.session.file(item.pcapPath)
+		}
+	}
+
+	p.connections.finish(event)
+}
+
+// finish closes for good the pcap files of the capture target of the given
+// event (of each direction, if split by direction), whether kept open or
+// closed by the LRU cache.
+func (p *PcapCache) finish(event *trace.Event) {
+	index := getItemIndexFromEvent(event, p.itemType)
+	target := getItemTarget(event, p.itemType)
+	owned := func(key string) bool {
+		return key == index || strings.HasPrefix(key, index+"/")
+	}
+
+	for _, key := range p.itemCache.Keys() {
+		if item, ok := p.itemCache.Peek(key); ok && owned(key) {
+			item.finalized = true
+			p.itemCache.Remove(key) // evicted items are closed
+		}
+	}
+	for _, path := range sortedKeys(p.closed) {
+		if item := p.closed[path]; item.target == target {
+			p.finalize(item)
+		}
+	}
+	for key := range p.rotations {
+		if owned(key) {
+			delete(p.rotations, key)
+		}
+	}
+	for key := range p.lifetimes {
+		if owned(key) {
+			delete(p.lifetimes, key)
+		}
+	}
+}
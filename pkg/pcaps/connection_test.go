@@ -0,0 +1,82 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsPerConnection(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		PerConnection: true,
+		Flows:         true,
+	})
+
+	a := &testTCPConn{t: t, client: "10.0.0.1", server: "10.0.0.80", clientPort: 40000, serverPort: 80}
+	b := &testTCPConn{t: t, client: "10.0.0.2", server: "10.0.0.80", clientPort: 40001, serverPort: 80}
+	mid := &testTCPConn{t: t, client: "10.0.0.3", server: "10.0.0.80", clientPort: 40002, serverPort: 80}
+
+	ts := 1700000000000000000
+	write := func(pkt []byte) {
+		ts += 1000000
+		require.NoError(t, p.Write(newTestEvent(ts), pkt))
+	}
+
+	// two interleaved connections
+	write(a.packet(true, "S", nil))
+	write(b.packet(true, "S", nil))
+	write(a.packet(false, "SA", nil))
+	write(b.packet(false, "SA", nil))
+	write(a.packet(true, "A", nil))
+	write(b.packet(true, "A", nil))
+	write(a.packet(true, "PA", []byte("GET / HTTP/1.1\r\n\r\n")))
+	write(b.packet(true, "PA", []byte("GET /b HTTP/1.1\r\n\r\n")))
+
+	// a connection whose SYN was not captured has no file
+	write(mid.packet(true, "PA", []byte("data")))
+
+	require.Equal(t, 3, p.ActiveTargets()) // single, a and b
+
+	// the first connection is over: its file is finalized
+	write(a.packet(true, "FA", nil))
+	write(a.packet(false, "FA", nil))
+	write(a.packet(true, "A", nil))
+	require.Equal(t, 2, p.ActiveTargets())
+
+	require.NoError(t, p.Destroy())
+
+	entries, err := os.ReadDir(filepath.Join(dir, pcapConnDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	first := filepath.Join(dir, pcapConnDir, "tcp_10.0.0.1_40000_10.0.0.80_80_20231114T221320.001000Z.pcap")
+	second := filepath.Join(dir, pcapConnDir, "tcp_10.0.0.2_40001_10.0.0.80_80_20231114T221320.002000Z.pcap")
+	require.Len(t, readTestPcap(t, first), 7)
+	require.Len(t, readTestPcap(t, second), 4)
+
+	// all packets still written to the single file
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")), 12)
+
+	// flow summaries recorded in connection files
+	summaries := readTestStatsComments(t, first)
+	require.Len(t, summaries, 1)
+	require.True(t, strings.HasPrefix(summaries[0], "flow_closed=fin proto=tcp src=10.0.0.1:40000 "), summaries[0])
+	summaries = readTestStatsComments(t, second)
+	require.Len(t, summaries, 1)
+	require.True(t, strings.HasPrefix(summaries[0], "flow_closed=end proto=tcp src=10.0.0.2:40001 "), summaries[0])
+}
+
+func TestPcapsPerConnectionRequiresFlows(t *testing.T) {
+	dir, err := os.Open(t.TempDir())
+	require.NoError(t, err)
+	defer dir.Close()
+
+	_, err = New(config.PcapsConfig{PerConnection: true}, dir)
+	require.ErrorContains(t, err, "pcap per connection files require flow tracking")
+}
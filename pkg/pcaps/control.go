@@ -0,0 +1,120 @@
+package pcaps
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+//
+// Capture might be controlled, by orchestration tools, through a control FIFO:
+// a named pipe tracee reads commands from, one command per line:
+//
+//	pause          stop capturing packets (pcap files are kept open)
+//	resume         resume capturing packets
+//	start-session  start a new capture session
+//	end-session    end the current capture session (closing its pcap files
+//	               and writing its manifest)
+//	trigger        dump the packets held in the ring buffer (if enabled)
+//
+// Commands are case insensitive and surrounding spaces are ignored, as well as
+// empty lines. Invalid commands (or commands failing, like ending a session
+// that was not started) are logged and ignored. There are no replies: the
+// outcome of each command is logged.
+//
+// Example: echo pause > /tmp/tracee/capture.ctl
+//
+
+// Controller is the capture subsystem driven by control commands.
+type Controller interface {
+	Pause()
+	Resume()
+	StartSession() error
+	EndSession() error
+	Trigger() error
+}
+
+// ControlFIFO reads capture control commands from a FIFO.
+type ControlFIFO struct {
+	path   string
+	target Controller
+}
+
+// NewControlFIFO creates the FIFO at the given path (if it does not exist yet)
+// to control the given capture subsystem.
+func NewControlFIFO(path string, target Controller) (*ControlFIFO, error) {
+	err := unix.Mkfifo(path, 0600)
+	if err != nil && !errors.Is(err, unix.EEXIST) {
+		return nil, errfmt.WrapError(err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	if stat.Mode()&os.ModeNamedPipe == 0 {
+		return nil, errfmt.Errorf("capture control path is not a fifo: %s", path)
+	}
+
+	return &ControlFIFO{path: path, target: target}, nil
+}
+
+// Run reads and executes commands until the given context is done.
+func (c *ControlFIFO) Run(ctx context.Context) error {
+	// opened for writing as well, so reads don't hit EOF when writers go away
+	fifo, err := os.OpenFile(c.path, os.O_RDWR, 0)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = fifo.Close() // unblocks the scanner
+	}()
+
+	scanner := bufio.NewScanner(fifo)
+	for scanner.Scan() {
+		command := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if command == "" {
+			continue
+		}
+		if err := c.exec(command); err != nil {
+			logger.Errorw("Capture control command failed", "command", command, "error", err)
+			continue
+		}
+		logger.Infow("Capture control command executed", "command", command)
+	}
+
+	if ctx.Err() != nil {
+		return nil // closed on purpose
+	}
+
+	return errfmt.WrapError(scanner.Err())
+}
+
+// exec executes a single command.
+func (c *ControlFIFO) exec(command string) error {
+	switch command {
+	case "pause":
+		c.target.Pause()
+	case "resume":
+		c.target.Resume()
+	case "start-session":
+		return c.target.StartSession()
+	case "end-session":
+		return c.target.EndSession()
+	case "trigger":
+		return c.target.Trigger()
+	default:
+		return errfmt.Errorf("unknown command")
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package pcaps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestControlFIFO(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true})
+
+	path := filepath.Join(t.TempDir(), "capture.ctl")
+	control, err := NewControlFIFO(path, p)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- control.Run(ctx)
+	}()
+
+	fifo, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer fifo.Close()
+
+	command := func(cmd string, condition func() bool) {
+		_, err := fifo.WriteString(cmd + "\n")
+		require.NoError(t, err)
+		require.Eventually(t, condition, 5*time.Second, time.Millisecond, cmd)
+	}
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+
+	require.NoError(t, p.Write(newTestEvent(1), pkt))
+
+	command("pause", p.Paused)
+	require.NoError(t, p.Write(newTestEvent(2), pkt)) // not captured
+
+	command("  RESUME ", func() bool { return !p.Paused() })
+	require.NoError(t, p.Write(newTestEvent(3), pkt))
+
+	command("end-session", func() bool { return !p.InSession() })
+	require.NoError(t, p.Write(newTestEvent(4), pkt)) // not captured
+
+	command("bogus", func() bool { return !p.InSession() })       // ignored
+	command("end-session", func() bool { return !p.InSession() }) // fails, ignored
+
+	command("start-session", p.InSession)
+	require.NoError(t, p.Write(newTestEvent(5), pkt))
+
+	cancel()
+	require.NoError(t, <-done)
+	require.NoError(t, p.Destroy())
+
+	require.Equal(t, uint64(2), p.Stats().NotCaptured.Get())
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")), 3)
+
+	manifests, err := filepath.Glob(filepath.Join(dir, pcapDir, "manifest-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 2) // one per session
+}
+
+func TestPcapsLatePackets(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true, Index: true})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+
+	require.NoError(t, p.Write(newTestEvent(1), pkt))
+	require.NoError(t, p.EndSession())
+
+	// late packets (session ended): dropped, files are not reopened
+	require.NoError(t, p.Write(newTestEvent(2), pkt))
+	require.NoError(t, p.WriteTLSKeyLog(newTestEvent(3), []byte("CLIENT_RANDOM 00 00")))
+
+	// late packets racing with shutdown
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 100; i++ {
+			if err := p.Write(newTestEvent(10+i), pkt); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	require.NoError(t, p.Destroy())
+	require.NoError(t, <-done)
+
+	// after destroy: still dropped, no new sessions
+	require.NoError(t, p.Write(newTestEvent(200), pkt))
+	require.ErrorContains(t, p.StartSession(), "capture destroyed")
+	require.NoError(t, p.Destroy())
+
+	require.Equal(t, uint64(102), p.Stats().Late.Get())
+	require.Equal(t, uint64(102), p.Stats().NotCaptured.Get())
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")), 1)
+
+	entries, err := QueryIndex(filepath.Join(dir, pcapIndexFile), IndexQuery{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
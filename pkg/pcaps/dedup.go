@@ -0,0 +1,150 @@
+package pcaps
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Chatty applications repeat the same DNS queries constantly. With DNS dedup
+// enabled, only the first of identical queries (same name and type, from the
+// same capture target) within the configured window is written to the pcap
+// file. Once the window is over, the number of suppressed duplicates is
+// recorded in a pcapng interface statistics block (as a "key=value" comment).
+//
+
+// dnsDedupKey identifies identical DNS queries of a capture target.
+type dnsDedupKey struct {
+	target string
+	qname  string
+	qtype  layers.DNSType
+}
+
+// dnsDedupEntry is an open dedup window.
+type dnsDedupEntry struct {
+	start      int64        // timestamp of the first query
+	last       int64        // timestamp of the last suppressed query
+	duplicates uint64       // suppressed queries within the window
+	event      *trace.Event // event of the first query (locates the pcap file)
+	cache      *PcapCache   // cache of the pcap file the first query was written to
+}
+
+// dnsDedupSummary describes a closed dedup window with suppressed queries.
+type dnsDedupSummary struct {
+	key   dnsDedupKey
+	entry *dnsDedupEntry
+}
+
+// comment returns the summary as a pcapng comment.
+func (s *dnsDedupSummary) comment() string {
+	return fmt.Sprintf(
+		"dns_duplicates=%d qname=%s qtype=%s",
+		s.entry.duplicates, s.key.qname, s.key.qtype,
+	)
+}
+
+// dnsDedup tracks the open dedup windows.
+type dnsDedup struct {
+	window    int64 // window duration (nanoseconds)
+	entries   map[dnsDedupKey]*dnsDedupEntry
+	lastSweep int64
+}
+
+func newDNSDedup(window int64) *dnsDedup {
+	return &dnsDedup{
+		window:  window,
+		entries: make(map[dnsDedupKey]*dnsDedupEntry),
+	}
+}
+
+// dnsQueryKey returns the dedup key of the given packet if it is a DNS query.
+func dnsQueryKey(info *packetInfo, target string) (dnsDedupKey, bool) {
+	dns, ok := info.packet.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	if !ok || dns.QR || len(dns.Questions) == 0 {
+		return dnsDedupKey{}, false
+	}
+
+	return dnsDedupKey{
+		target: target,
+		qname:  string(dns.Questions[0].Name),
+		qtype:  dns.Questions[0].Type,
+	}, true
+}
+
+// check accounts a query and returns true if it is a duplicate (and should not
+// be written). If it closes a previous window of the same query (with
+// suppressed duplicates), its summary is also returned.
+func (d *dnsDedup) check(ts int64, key dnsDedupKey, event *trace.Event, cache *PcapCache) (*dnsDedupSummary, bool) {
+	var summary *dnsDedupSummary
+
+	entry, ok := d.entries[key]
+	if ok {
+		if ts-entry.start < d.window {
+			entry.duplicates++
+			entry.last = ts
+			return nil, true
+		}
+		if entry.duplicates > 0 {
+			summary = &dnsDedupSummary{key: key, entry: entry}
+		}
+	}
+
+	target := *event
+	target.Args = nil // only needed to locate the pcap file
+
+	d.entries[key] = &dnsDedupEntry{
+		start: ts,
+		event: &target,
+		cache: cache,
+	}
+
+	return summary, false
+}
+
+// sweep closes all windows that are over by the given timestamp. Windows are
+// swept at most once per window duration.
+func (d *dnsDedup) sweep(ts int64) []*dnsDedupSummary {
+	if ts-d.lastSweep < d.window {
+		return nil
+	}
+	d.lastSweep = ts
+
+	var summaries []*dnsDedupSummary
+	for key, entry := range d.entries {
+		if ts-entry.start < d.window {
+			continue
+		}
+		if entry.duplicates > 0 {
+			summaries = append(summaries, &dnsDedupSummary{key: key, entry: entry})
+		}
+		delete(d.entries, key)
+	}
+	sortDNSDedupSummaries(summaries)
+
+	return summaries
+}
+
+// flush closes all open windows.
+func (d *dnsDedup) flush() []*dnsDedupSummary {
+	var summaries []*dnsDedupSummary
+	for key, entry := range d.entries {
+		if entry.duplicates > 0 {
+			summaries = append(summaries, &dnsDedupSummary{key: key, entry: entry})
+		}
+	}
+	d.entries = make(map[dnsDedupKey]*dnsDedupEntry)
+	sortDNSDedupSummaries(summaries)
+
+	return summaries
+}
+
+// sortDNSDedupSummaries sorts summaries by their last suppressed query.
+func sortDNSDedupSummaries(summaries []*dnsDedupSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].entry.last < summaries[j].entry.last
+	})
+}
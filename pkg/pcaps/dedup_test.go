@@ -0,0 +1,63 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// newTestDNSQuery builds a DNS query packet, as given to Pcaps.Write().
+func newTestDNSQuery(t *testing.T, name string, qtype layers.DNSType) []byte {
+	t.Helper()
+
+	dns := &layers.DNS{
+		ID:      1,
+		RD:      true,
+		QDCount: 1,
+		Questions: []layers.DNSQuestion{
+			{Name: []byte(name), Type: qtype, Class: layers.DNSClassIN},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	require.NoError(t, dns.SerializeTo(buf, gopacket.SerializeOptions{}))
+
+	return newTestUDPPacket(t, "10.0.0.1", "10.0.0.53", 4321, 53, buf.Bytes())
+}
+
+func TestPcapsDNSDedup(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:  true,
+		DNSDedupWindow: 10 * time.Second,
+	})
+
+	sec := int(time.Second)
+	queryA := newTestDNSQuery(t, "example.com", layers.DNSTypeA)
+	queryAAAA := newTestDNSQuery(t, "example.com", layers.DNSTypeAAAA)
+
+	// first window: 1 written + 4 duplicates, other qtype is not a duplicate
+	for i := 0; i < 5; i++ {
+		require.NoError(t, p.Write(newTestEvent(sec*(1+i)), queryA))
+	}
+	require.NoError(t, p.Write(newTestEvent(sec*2), queryAAAA))
+
+	// second window: the first window is closed, new query is written
+	require.NoError(t, p.Write(newTestEvent(sec*20), queryA))
+	require.NoError(t, p.Write(newTestEvent(sec*21), queryA))
+	require.NoError(t, p.Destroy())
+
+	require.Equal(t, uint64(5), p.Stats().DNSDeduplicated.Get())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+	require.Equal(t, [][]byte{queryA, queryAAAA, queryA}, readTestPcap(t, path))
+
+	require.Equal(t, []string{
+		"dns_duplicates=4 qname=example.com qtype=A",
+		"dns_duplicates=1 qname=example.com qtype=A",
+	}, readTestStatsComments(t, path))
+}
@@ -0,0 +1,107 @@
+package pcaps
+
+import (
+	"runtime"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+//
+// Some capture features (indexes, flow tables, dedup windows, ring buffers...)
+// consume memory proportionally to the captured traffic. Under memory pressure,
+// instead of letting tracee be OOM killed, those features are disabled, one at
+// a time and in a configurable order, while the core capture (writing packets
+// to the pcap files) keeps running.
+//
+
+const memoryCheckPackets = 1000 // check memory usage every X written packets
+
+// degradable is a capture feature that can be disabled under memory pressure.
+// Its disable function returns false if the feature was not enabled at all.
+type degradable struct {
+	name    string
+	disable func() bool
+}
+
+// defaultDegradeOrder is the order features are disabled by default (the most
+// memory hungry features first).
+var defaultDegradeOrder = []string{
+	"extract",
+	"flows",
+	"index",
+	"dns-dedup",
+}
+
+// memoryMonitor disables degradable features when the memory in use goes
+// above the given threshold.
+type memoryMonitor struct {
+	threshold  uint64        // bytes of heap memory in use
+	features   []degradable  // features to disable, in order
+	disabled   int           // number of features already disabled
+	packets    uint64        // packets seen since last check
+	readMemory func() uint64 // returns current memory usage
+}
+
+func newMemoryMonitor(threshold uint64, order []string, available map[string]func() bool) (*memoryMonitor, error) {
+	if len(order) == 0 {
+		order = defaultDegradeOrder
+	}
+
+	features := make([]degradable, 0, len(order))
+	for _, name := range order {
+		disable, ok := available[name]
+		if !ok {
+			return nil, errfmt.Errorf("unknown degradable capture feature: %s", name)
+		}
+		features = append(features, degradable{name: name, disable: disable})
+	}
+
+	return &memoryMonitor{
+		threshold:  threshold,
+		features:   features,
+		readMemory: heapInUse,
+	}, nil
+}
+
+// heapInUse returns the amount of heap memory in use.
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// packet accounts a written packet and, once in a while, checks memory usage.
+func (m *memoryMonitor) packet() {
+	m.packets++
+	if m.packets < memoryCheckPackets {
+		return
+	}
+	m.packets = 0
+	m.check()
+}
+
+// check disables the next enabled feature if memory usage is above threshold.
+func (m *memoryMonitor) check() {
+	if m.disabled >= len(m.features) {
+		return // nothing else to shed
+	}
+
+	inUse := m.readMemory()
+	if inUse <= m.threshold {
+		return
+	}
+
+	for m.disabled < len(m.features) {
+		feature := m.features[m.disabled]
+		m.disabled++
+		if feature.disable() {
+			logger.Warnw("Memory pressure: capture feature disabled",
+				"feature", feature.name,
+				"memory", inUse,
+				"threshold", m.threshold,
+			)
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pcaps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestMemoryMonitorDegradationOrder(t *testing.T) {
+	t.Parallel()
+
+	var disabled []string
+	feature := func(name string, enabled bool) func() bool {
+		return func() bool {
+			if enabled {
+				disabled = append(disabled, name)
+			}
+			return enabled
+		}
+	}
+
+	available := map[string]func() bool{
+		"ring":  feature("ring", true),
+		"flows": feature("flows", true),
+		"dedup": feature("dedup", false), // not enabled: skipped
+		"index": feature("index", true),
+	}
+
+	m, err := newMemoryMonitor(100, []string{"ring", "dedup", "flows", "index"}, available)
+	require.NoError(t, err)
+
+	memory := uint64(50)
+	m.readMemory = func() uint64 { return memory }
+
+	m.check()
+	require.Empty(t, disabled) // below threshold
+
+	memory = 200
+	m.check()
+	require.Equal(t, []string{"ring"}, disabled)
+	m.check()
+	require.Equal(t, []string{"ring", "flows"}, disabled)
+
+	memory = 50
+	m.check()
+	require.Equal(t, []string{"ring", "flows"}, disabled) // pressure is gone
+
+	memory = 200
+	m.check()
+	m.check()
+	require.Equal(t, []string{"ring", "flows", "index"}, disabled)
+
+	_, err = newMemoryMonitor(100, []string{"unknown"}, available)
+	require.Error(t, err)
+}
+
+func TestPcapsMemoryPressureDisablesIndex(t *testing.T) {
+	p, _ := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:   true,
+		Index:           true,
+		MemoryThreshold: 1,
+	})
+	p.memory.readMemory = func() uint64 { return 2 }
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	for i := 0; i < memoryCheckPackets; i++ {
+		require.NoError(t, p.Write(newTestEvent(i), pkt))
+	}
+
+	require.Nil(t, p.index) // index disabled ...
+	require.NoError(t, p.Write(newTestEvent(memoryCheckPackets), pkt))
+	require.NoError(t, p.Destroy()) // ... while capture keeps running
+}
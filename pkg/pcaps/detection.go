@@ -0,0 +1,88 @@
+package pcaps
+
+import (
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Capture might be tied to detections (signature findings): capture targets
+// are only captured during their detection windows. A detection for a target
+// (the process, container, command... it was made for) opens the target
+// window, lasting the configured duration. Further detections for the target
+// extend its window, so it ends the configured duration after the last one
+// (windows are never shortened). Once a window is over, packets of the target
+// are no longer written (until a new detection opens a new window).
+//
+// Windows are kept per capture target of every pcap type: a detection for a
+// process opens the window of the process, of its container, of its command,
+// and so on. The single and noise files are shared by all targets: they are
+// written while any detection window is open.
+//
+// Windows are measured in event time (the detection event timestamp and the
+// packet timestamps), so packets queued before a detection are not captured.
+//
+
+type detectionWindows struct {
+	duration int64            // window duration (nanoseconds)
+	until    map[string]int64 // capture target to the end of its window
+}
+
+func newDetectionWindows(duration int64) *detectionWindows {
+	return &detectionWindows{
+		duration: duration,
+		until:    make(map[string]int64),
+	}
+}
+
+// detection opens (or extends) the window of given capture target at given
+// time.
+func (w *detectionWindows) detection(ts int64, target string) {
+	until := ts + w.duration
+	current, ok := w.until[target]
+	if ok && current >= until {
+		return
+	}
+	if !ok || current < ts {
+		logger.Debugw("Capture detection window opened", "target", target)
+	}
+	w.until[target] = until
+}
+
+// active returns true if the window of given capture target is open at given
+// time (forgetting the window once it is over).
+func (w *detectionWindows) active(ts int64, target string) bool {
+	until, ok := w.until[target]
+	if !ok {
+		return false
+	}
+	if ts > until {
+		delete(w.until, target)
+		logger.Debugw("Capture detection window closed", "target", target)
+		return false
+	}
+
+	return true
+}
+
+// Detection opens (or extends) the detection windows of the capture targets
+// of given detection event, and dumps the packets held in the ring buffer. It
+// does nothing unless capture is tied to detections or held in a ring buffer.
+func (p *Pcaps) Detection(event *trace.Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.windows != nil {
+		for _, caches := range p.allCaches() {
+			for k := range caches {
+				p.windows.detection(int64(event.Timestamp), getItemTarget(event, k))
+			}
+		}
+	}
+
+	// dumped once windows are open (packets held are written for the
+	// targets of the detection only, if capture is tied to detections)
+	if err := p.trigger(event); err != nil {
+		logger.Errorw("Dumping capture ring buffer", "error", err)
+	}
+}
@@ -0,0 +1,47 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+func TestPcapsDetectionWindow(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureCommand: true, DetectionWindow: 100})
+
+	pkts := make([][]byte, 4)
+	for i := range pkts {
+		pkts[i] = newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 5000, []byte{byte(i)})
+	}
+	newOtherEvent := func(ts int) *trace.Event {
+		event := newTestEvent(ts)
+		event.ProcessName = "other"
+		return event
+	}
+
+	// no detection yet: not captured
+	require.NoError(t, p.Write(newTestEvent(1), pkts[0]))
+
+	// detection opens the window (up to 105), for the detected command only
+	p.Detection(newTestEvent(5))
+	require.NoError(t, p.Write(newTestEvent(10), pkts[1]))
+	require.NoError(t, p.Write(newOtherEvent(10), pkts[1]))
+
+	// another detection extends the window (up to 150)
+	p.Detection(newTestEvent(50))
+	require.NoError(t, p.Write(newTestEvent(120), pkts[2]))
+
+	// window over: writes stop
+	require.NoError(t, p.Write(newTestEvent(151), pkts[3]))
+	require.NoError(t, p.Destroy())
+
+	require.Equal(t, uint64(3), p.Stats().OutOfWindow.Get())
+	require.Equal(t, [][]byte{pkts[1], pkts[2]}, readTestPcap(t, filepath.Join(dir, pcapCommDir, "host", "proc.pcap")))
+	_, err := os.Stat(filepath.Join(dir, pcapCommDir, "host", "other.pcap"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
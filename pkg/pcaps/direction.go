@@ -0,0 +1,85 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// When split by direction, each capture target has its pcap file split in two:
+// inbound (ingress) packets go to FILE.inbound.pcap and outbound (egress) ones
+// go to FILE.outbound.pcap. The direction comes from the capture event return
+// value (set by the cgroup skb programs). Packets of unknown direction (none
+// of the flags set) go to FILE.unknown.pcap, unless configured to go to the
+// inbound or outbound file instead.
+//
+// Whether split or not, the direction of each packet (if known) is recorded in
+// its epb_flags option (inbound or outbound).
+//
+
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+	DirectionUnknown  = "unknown"
+)
+
+// packet direction flags of the capture event return value (see the retval
+// flags in pkg/events/derive)
+const (
+	packetIngress = 1 << 4
+	packetEgress  = 1 << 5
+)
+
+// ParseDirection parses the direction packets of unknown direction go to.
+func ParseDirection(direction string) (string, error) {
+	switch direction = strings.ToLower(direction); direction {
+	case DirectionInbound, DirectionOutbound, DirectionUnknown:
+		return direction, nil
+	}
+
+	return "", errfmt.Errorf("invalid pcap direction (inbound, outbound or unknown): %s", direction)
+}
+
+// packetDirection returns the direction of the packet of given capture event,
+// or the given default if unknown.
+func packetDirection(event *trace.Event, unknown string) string {
+	switch {
+	case event.ReturnValue&packetIngress == packetIngress:
+		return DirectionInbound
+	case event.ReturnValue&packetEgress == packetEgress:
+		return DirectionOutbound
+	case unknown != "":
+		return unknown
+	}
+
+	return DirectionUnknown
+}
+
+// directionOptions returns the epb_flags option recording the direction of the
+// packet of given capture event (none if unknown), so readers (e.g. Wireshark,
+// as frame.packet_flags_direction) tell sent from received packets.
+func directionOptions(event *trace.Event) []ngOption {
+	var flags uint32
+	switch packetDirection(event, "") {
+	case DirectionInbound:
+		flags = ngPacketFlagsInbound
+	case DirectionOutbound:
+		flags = ngPacketFlagsOutbound
+	default:
+		return nil
+	}
+
+	return []ngOption{{code: ngOptionCodePacketFlags, value: binary.LittleEndian.AppendUint32(nil, flags)}}
+}
+
+// directionFileName returns the pcap file name of the given direction.
+func directionFileName(name string, direction string) string {
+	if direction == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(name, ".pcap") + "." + direction + ".pcap"
+}
@@ -0,0 +1,112 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// newTestDirectionEvent creates a network capture event of the given packet
+// direction flag (0 for unknown).
+func newTestDirectionEvent(ts int, flag int) *trace.Event {
+	event := newTestEvent(ts)
+	event.ReturnValue = flag
+	return event
+}
+
+func TestPcapsSplitDirection(t *testing.T) {
+	in := newTestUDPPacket(t, "10.0.0.2", "10.0.0.1", 53, 40000, []byte("inbound"))
+	out := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 53, []byte("outbound"))
+	unknown := newTestUDPPacket(t, "10.0.0.1", "10.0.0.3", 1234, 5678, []byte("unknown"))
+
+	testCases := []struct {
+		name     string
+		unknown  string
+		expected map[string][][]byte
+	}{
+		{
+			name:    "unknown to its own file",
+			unknown: "",
+			expected: map[string][][]byte{
+				"single.inbound.pcap":  {in, in},
+				"single.outbound.pcap": {out},
+				"single.unknown.pcap":  {unknown},
+			},
+		},
+		{
+			name:    "unknown to outbound",
+			unknown: DirectionOutbound,
+			expected: map[string][][]byte{
+				"single.inbound.pcap":  {in, in},
+				"single.outbound.pcap": {out, unknown},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, dir := newTestPcaps(t, config.PcapsConfig{
+				CaptureSingle:    true,
+				SplitDirection:   true,
+				UnknownDirection: tc.unknown,
+			})
+
+			require.NoError(t, p.Write(newTestDirectionEvent(1, packetEgress), out))
+			require.NoError(t, p.Write(newTestDirectionEvent(2, packetIngress), in))
+			require.NoError(t, p.Write(newTestDirectionEvent(3, 0), unknown))
+			require.NoError(t, p.Write(newTestDirectionEvent(4, packetIngress), in))
+			require.NoError(t, p.Destroy())
+
+			files, err := filepath.Glob(filepath.Join(dir, pcapSingleDir, "*.pcap"))
+			require.NoError(t, err)
+			require.Len(t, files, len(tc.expected))
+
+			for name, packets := range tc.expected {
+				require.Equal(t, packets, readTestPcap(t, filepath.Join(dir, pcapSingleDir, name)), name)
+			}
+		})
+	}
+}
+
+func TestPcapsDirectionFlags(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 53, []byte("payload"))
+	require.NoError(t, p.Write(newTestDirectionEvent(1, packetEgress), pkt))
+	require.NoError(t, p.Write(newTestDirectionEvent(2, packetIngress), pkt))
+	require.NoError(t, p.Write(newTestDirectionEvent(3, 0), pkt))
+	require.NoError(t, p.Destroy())
+
+	// epb_flags direction bits (none if unknown)
+	var flags []uint32
+	for _, block := range readTestNgBlocks(t, filepath.Join(dir, pcapSingleDir, "single.pcap")) {
+		if block.blockType != ngBlockTypeEnhancedPacket {
+			continue
+		}
+		var flag uint32
+		for _, o := range block.options {
+			if o.code == ngOptionCodePacketFlags {
+				require.Len(t, o.value, 4)
+				flag = binary.LittleEndian.Uint32(o.value)
+			}
+		}
+		flags = append(flags, flag)
+	}
+	require.Equal(t, []uint32{ngPacketFlagsOutbound, ngPacketFlagsInbound, 0}, flags)
+}
+
+func TestParseDirection(t *testing.T) {
+	t.Parallel()
+
+	direction, err := ParseDirection("Inbound")
+	require.NoError(t, err)
+	require.Equal(t, DirectionInbound, direction)
+
+	_, err = ParseDirection("sideways")
+	require.ErrorContains(t, err, "invalid pcap direction")
+}
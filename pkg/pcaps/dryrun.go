@@ -0,0 +1,153 @@
+package pcaps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// A dry run estimates the volume of a capture before enabling it: packets go
+// through the whole processing path (decoding, filters, length mangling) but,
+// instead of being written, they are counted per capture target (the targets
+// pcap files would be created for: the single file, processes, containers,
+// commands or users). Totals are logged periodically (packet time), as well as
+// the targets with the most bytes, and once more when capture stops.
+//
+// Counts are those of what would be written: packets (and bytes, as captured
+// after the capture length) reaching the pcap files. Features applied while
+// writing pcap files (rate limits, dedup, detection windows...) are not.
+//
+
+const (
+	dryRunInterval   = time.Minute // default interval dry run totals are logged on
+	dryRunTopTargets = 10          // targets logged (with the most bytes)
+)
+
+// DryRunCount holds the packets (and their bytes) counted for a target.
+type DryRunCount struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// DryRunSink counts captured packets per target, without writing them.
+type DryRunSink struct {
+	mutex    sync.Mutex
+	types    []PcapType // types of the targets counted
+	interval int64      // totals logged on this interval (packet time)
+	logged   int64      // last time totals were logged (packet time)
+	total    DryRunCount
+	targets  map[string]*DryRunCount
+}
+
+// NewDryRunSink returns a sink counting packets per target of the pcap types
+// enabled in the given config.
+func NewDryRunSink(simple config.PcapsConfig) *DryRunSink {
+	cfg := configToPcapType(simple)
+
+	s := &DryRunSink{
+		interval: int64(simple.DryRunInterval),
+		targets:  make(map[string]*DryRunCount),
+	}
+	if s.interval <= 0 {
+		s.interval = int64(dryRunInterval)
+	}
+	for _, t := range []PcapType{Single, Process, Container, Command, User} {
+		if cfg&t == t {
+			s.types = append(s.types, t)
+		}
+	}
+
+	return s
+}
+
+// Write counts a packet, for all its targets.
+func (s *DryRunSink) Write(event *trace.Event, payload []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ts := int64(event.Timestamp)
+	if s.logged == 0 {
+		s.logged = ts
+	}
+
+	length := uint64(len(payload))
+	s.total.Packets++
+	s.total.Bytes += length
+	for _, t := range s.types {
+		target := getItemTarget(event, t)
+		count, ok := s.targets[target]
+		if !ok {
+			count = &DryRunCount{}
+			s.targets[target] = count
+		}
+		count.Packets++
+		count.Bytes += length
+	}
+
+	if ts-s.logged >= s.interval {
+		s.logged = ts
+		s.log()
+	}
+
+	return nil
+}
+
+// Counts returns the packets counted so far, per target.
+func (s *DryRunSink) Counts() map[string]DryRunCount {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counts := make(map[string]DryRunCount, len(s.targets))
+	for target, count := range s.targets {
+		counts[target] = *count
+	}
+
+	return counts
+}
+
+// log logs the totals counted so far, and the targets with the most bytes.
+func (s *DryRunSink) log() {
+	targets := make([]string, 0, len(s.targets))
+	for target := range s.targets {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if s.targets[targets[i]].Bytes != s.targets[targets[j]].Bytes {
+			return s.targets[targets[i]].Bytes > s.targets[targets[j]].Bytes
+		}
+		return targets[i] < targets[j]
+	})
+	if len(targets) > dryRunTopTargets {
+		targets = targets[:dryRunTopTargets]
+	}
+
+	top := make([]string, 0, len(targets))
+	for _, target := range targets {
+		count := s.targets[target]
+		top = append(top, fmt.Sprintf("%s=%d/%d", target, count.Packets, count.Bytes))
+	}
+
+	logger.Infow("Network capture dry run (nothing written)",
+		"packets", s.total.Packets,
+		"bytes", s.total.Bytes,
+		"targets", len(s.targets),
+		"top (packets/bytes)", strings.Join(top, " "),
+	)
+}
+
+// Close logs the final totals.
+func (s *DryRunSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.log()
+
+	return nil
+}
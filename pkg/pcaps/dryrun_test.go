@@ -0,0 +1,38 @@
+package pcaps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestDryRunSink(t *testing.T) {
+	t.Parallel()
+
+	sink := NewDryRunSink(config.PcapsConfig{
+		CaptureSingle:  true,
+		CaptureProcess: true,
+		DryRunInterval: time.Second,
+	})
+
+	packet := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1000, 53, []byte("query"))
+
+	other := newTestEvent(int(2 * time.Second))
+	other.HostThreadID = 2000
+
+	require.NoError(t, sink.Write(newTestEvent(int(time.Second)), packet))
+	require.NoError(t, sink.Write(newTestEvent(int(time.Second)), packet))
+	require.NoError(t, sink.Write(other, packet)) // logs totals
+	require.NoError(t, sink.Close())
+
+	length := uint64(len(packet))
+	require.Equal(t, map[string]DryRunCount{
+		"single":       {Packets: 3, Bytes: 3 * length},
+		"process:1000": {Packets: 2, Bytes: 2 * length},
+		"process:2000": {Packets: 1, Bytes: length},
+	}, sink.Counts())
+	require.Equal(t, DryRunCount{Packets: 3, Bytes: 3 * length}, sink.total)
+}
@@ -0,0 +1,528 @@
+package pcaps
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// With object extraction enabled, the TCP streams of captured packets are
+// reassembled (gopacket tcpassembly) and the files transferred over them are
+// extracted to the pcap/extracted/ directory:
+//
+// - HTTP/1.x request and response bodies (with a body).
+// - FTP transfers: data connections announced by FTP control connections
+//   (passive and active mode).
+//
+// Each extracted object is described (content type, size and flow) by a JSON
+// line in the pcap/extracted/objects.jsonl file.
+//
+// Streams are buffered in memory until they are complete (FIN, RST or timeout)
+// and are only parsed then: memory usage grows with the number of concurrent
+// streams and their sizes (bound by the configured max stream size) and CPU
+// usage with the amount of captured TCP payload. Streams with missing bytes
+// (e.g. truncated by snaplen) are discarded, so full packets must be captured.
+//
+// Reassembly can be scoped to some protocols (http, ftp): the protocol of each
+// connection is then recognized from its first packets (FTP control port or
+// announced data endpoints, HTTP request or response line in the first
+// payload), which are held until it is, and connections of other protocols
+// are never reassembled (their packets are only written to pcap files).
+//
+
+const (
+	pcapExtractDir        string = pcapDir + "extracted/"
+	pcapExtractObjectFile string = pcapExtractDir + "objects.jsonl"
+)
+
+const (
+	extractStreamTimeout = 2 * time.Minute  // streams without new data are completed
+	extractFlushInterval = 30 * time.Second // how often timed out streams are completed
+	extractPagesPerConn  = 512              // max out of order pages (1900 bytes) per connection
+	extractPagesTotal    = 32768            // max out of order pages (1900 bytes) overall
+	extractMaxFTPData    = 1024             // max announced FTP data connections tracked
+	extractMaxConns      = 65536            // max connections whose protocol is tracked (if scoped)
+	extractMaxPending    = 4                // max segments held until the protocol is recognized
+	ftpControlPort       = 21
+)
+
+// protocols of extracted objects
+const (
+	extractHTTP = "http"
+	extractFTP  = "ftp"
+)
+
+// ParseExtractProtocol parses the name of a protocol whose streams are
+// reassembled (to extract objects).
+func ParseExtractProtocol(protocol string) (string, error) {
+	switch protocol = strings.ToLower(protocol); protocol {
+	case extractHTTP, extractFTP:
+		return protocol, nil
+	}
+
+	return "", errfmt.Errorf("invalid pcap extract protocol (%s or %s): %s", extractHTTP, extractFTP, protocol)
+}
+
+// ExtractedObject describes a file extracted from a reassembled stream.
+type ExtractedObject struct {
+	Timestamp   int64  `json:"ts"`    // timestamp of the stream first packet
+	Protocol    string `json:"proto"` // "http" or "ftp"
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SrcIP       string `json:"src"` // sender of the object
+	SrcPort     uint16 `json:"sport"`
+	DstIP       string `json:"dst"` // receiver of the object
+	DstPort     uint16 `json:"dport"`
+	Info        string `json:"info,omitempty"` // e.g. HTTP request line or status
+	File        string `json:"file"`           // object file (relative to output dir)
+}
+
+// objectExtractor reassembles TCP streams and extracts the objects within.
+type objectExtractor struct {
+	output    *os.File
+	maxStream int64 // max bytes buffered per stream
+	assembler *tcpassembly.Assembler
+	objects   *os.File // objects description file
+	encoder   *json.Encoder
+	ftpData   map[string]struct{} // announced FTP data endpoints ("ip:port")
+	lastFlush time.Time
+	count     uint64            // extracted objects
+	extracted func(path string) // called for each extracted object
+	protocols map[string]bool   // protocols reassembled (nil: all)
+	conns     map[flowKey]*extractConn
+	skipped   func() // called for each connection not reassembled
+}
+
+// extractConn is a connection whose protocol is recognized before it is
+// reassembled (or not).
+type extractConn struct {
+	pending    []*layers.TCP // segments held until the protocol is recognized
+	times      []time.Time   // of the pending segments
+	decided    bool
+	reassemble bool
+	last       time.Time
+}
+
+// newObjectExtractor creates an object extractor reassembling streams of the
+// given protocols (all, if empty).
+func newObjectExtractor(output *os.File, maxStream uint64, protocols []string, extracted func(path string), skipped func()) (*objectExtractor, error) {
+	err := utils.MkdirAtExist(output, pcapDir, os.ModePerm)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	err = utils.MkdirAtExist(output, pcapExtractDir, os.ModePerm)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	objects, err := utils.OpenAt(
+		output,
+		pcapExtractObjectFile,
+		os.O_APPEND|os.O_WRONLY|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	e := &objectExtractor{
+		output:    output,
+		maxStream: int64(maxStream),
+		objects:   objects,
+		encoder:   json.NewEncoder(objects),
+		ftpData:   make(map[string]struct{}),
+		extracted: extracted,
+		skipped:   skipped,
+	}
+	if len(protocols) > 0 {
+		e.protocols = make(map[string]bool, len(protocols))
+		for _, protocol := range protocols {
+			e.protocols[protocol] = true
+		}
+		e.conns = make(map[flowKey]*extractConn)
+	}
+
+	e.assembler = tcpassembly.NewAssembler(tcpassembly.NewStreamPool(e))
+	e.assembler.MaxBufferedPagesPerConnection = extractPagesPerConn
+	e.assembler.MaxBufferedPagesTotal = extractPagesTotal
+
+	return e, nil
+}
+
+// New creates the stream of a TCP connection direction (tcpassembly.StreamFactory).
+func (e *objectExtractor) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+	s := &extractStream{extractor: e, netFlow: netFlow, tcpFlow: tcpFlow}
+
+	src, dst := tcpFlow.Endpoints()
+	srcPort := binaryPort(src.Raw())
+	dstPort := binaryPort(dst.Raw())
+	s.control = srcPort == ftpControlPort || dstPort == ftpControlPort
+
+	return s
+}
+
+// packet feeds a captured packet to the stream reassembly.
+func (e *objectExtractor) packet(ts int64, info *packetInfo) {
+	now := time.Unix(0, ts)
+
+	if tcp, ok := info.packet.TransportLayer().(*layers.TCP); ok && info.srcIP != nil {
+		flow := info.packet.NetworkLayer().NetworkFlow()
+		if e.protocols == nil {
+			e.assembler.AssembleWithTimestamp(flow, tcp, now)
+		} else {
+			e.scoped(now, info, flow, tcp)
+		}
+	}
+
+	if now.Sub(e.lastFlush) >= extractFlushInterval {
+		e.lastFlush = now
+		e.assembler.FlushOlderThan(now.Add(-extractStreamTimeout))
+		for key, c := range e.conns {
+			if now.Sub(c.last) >= extractStreamTimeout {
+				delete(e.conns, key)
+			}
+		}
+	}
+}
+
+// scoped feeds a segment to the stream reassembly only if its connection is
+// of a protocol to reassemble, holding segments until the protocol is known.
+func (e *objectExtractor) scoped(now time.Time, info *packetInfo, flow gopacket.Flow, tcp *layers.TCP) {
+	key, _ := newFlowKey(info)
+
+	c, ok := e.conns[key]
+	if !ok {
+		if len(e.conns) >= extractMaxConns {
+			return // not tracked: not reassembled
+		}
+		c = &extractConn{}
+		e.conns[key] = c
+	}
+	c.last = now
+
+	if !c.decided {
+		protocol := e.protocol(info, tcp)
+		if protocol == "" && len(tcp.Payload) == 0 && len(c.pending) < extractMaxPending {
+			held := *tcp // no payload to copy
+			c.pending = append(c.pending, &held)
+			c.times = append(c.times, now)
+			return
+		}
+		c.decided = true
+		c.reassemble = e.protocols[protocol]
+		if !c.reassemble {
+			c.pending, c.times = nil, nil
+			if e.skipped != nil {
+				e.skipped()
+			}
+			return
+		}
+		for i, held := range c.pending {
+			if held.SrcPort == tcp.SrcPort {
+				e.assembler.AssembleWithTimestamp(flow, held, c.times[i])
+			} else {
+				e.assembler.AssembleWithTimestamp(flow.Reverse(), held, c.times[i])
+			}
+		}
+		c.pending, c.times = nil, nil
+	}
+
+	if c.reassemble {
+		e.assembler.AssembleWithTimestamp(flow, tcp, now)
+	}
+	if tcp.RST {
+		delete(e.conns, key)
+	}
+}
+
+// protocol returns the protocol (http or ftp) of a TCP connection, recognized
+// from one of its segments (empty if not recognized).
+func (e *objectExtractor) protocol(info *packetInfo, tcp *layers.TCP) string {
+	switch {
+	case uint16(tcp.SrcPort) == ftpControlPort || uint16(tcp.DstPort) == ftpControlPort:
+		return extractFTP
+	case e.isFTPData(info.srcIP, uint16(tcp.SrcPort)) || e.isFTPData(info.dstIP, uint16(tcp.DstPort)):
+		return extractFTP
+	case isHTTPRequest(tcp.Payload) || bytes.HasPrefix(tcp.Payload, []byte("HTTP/1.")):
+		return extractHTTP
+	}
+
+	return ""
+}
+
+// flush completes all streams (extracting their objects).
+func (e *objectExtractor) flush() {
+	e.assembler.FlushAll()
+}
+
+func (e *objectExtractor) close() error {
+	e.flush()
+	return e.objects.Close()
+}
+
+// announceFTPData records an FTP data endpoint announced by a control stream.
+func (e *objectExtractor) announceFTPData(ip net.IP, port uint16) {
+	if len(e.ftpData) >= extractMaxFTPData {
+		e.ftpData = make(map[string]struct{}) // never completed transfers
+	}
+	e.ftpData[net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))] = struct{}{}
+}
+
+// isFTPData returns true if the given endpoint was announced as a FTP data one.
+func (e *objectExtractor) isFTPData(ip net.IP, port uint16) bool {
+	_, ok := e.ftpData[net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))]
+	return ok
+}
+
+// extract writes an object, transferred over the given stream, and its description.
+func (e *objectExtractor) extract(s *extractStream, protocol, contentType, info string, body []byte) {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	src, dst := s.netFlow.Endpoints()
+	tsrc, tdst := s.tcpFlow.Endpoints()
+
+	e.count++
+	path := fmt.Sprintf("%s%d-%d-%s%s", pcapExtractDir, s.start.UnixNano(), e.count, protocol, extension(contentType))
+
+	object := ExtractedObject{
+		Timestamp:   s.start.UnixNano(),
+		Protocol:    protocol,
+		ContentType: contentType,
+		Size:        int64(len(body)),
+		SrcIP:       net.IP(src.Raw()).String(),
+		SrcPort:     binaryPort(tsrc.Raw()),
+		DstIP:       net.IP(dst.Raw()).String(),
+		DstPort:     binaryPort(tdst.Raw()),
+		Info:        info,
+		File:        path,
+	}
+
+	err := e.writeObject(path, body, &object)
+	if err != nil {
+		logger.Errorw("Extracting captured object", "file", path, "error", err)
+		return
+	}
+	e.extracted(path)
+}
+
+func (e *objectExtractor) writeObject(path string, body []byte, object *ExtractedObject) error {
+	file, err := utils.OpenAt(e.output, path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	_, err = file.Write(body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	return errfmt.WrapError(e.encoder.Encode(object))
+}
+
+// extension returns the file extension of a content type (if known).
+func extension(contentType string) string {
+	exts, err := mime.ExtensionsByType(contentType)
+	if err != nil || len(exts) == 0 {
+		return ".bin"
+	}
+
+	return exts[0]
+}
+
+// binaryPort decodes a (big endian) TCP port endpoint.
+func binaryPort(raw []byte) uint16 {
+	if len(raw) != 2 {
+		return 0
+	}
+
+	return uint16(raw[0])<<8 | uint16(raw[1])
+}
+
+// extractStream is a reassembled TCP connection direction (tcpassembly.Stream).
+type extractStream struct {
+	extractor *objectExtractor
+	netFlow   gopacket.Flow
+	tcpFlow   gopacket.Flow
+	start     time.Time
+	data      bytes.Buffer
+	broken    bool // missing bytes or bigger than max stream size
+	control   bool // FTP control stream
+	line      []byte
+}
+
+// Reassembled accounts reassembled data of the stream.
+func (s *extractStream) Reassembled(reassemblies []tcpassembly.Reassembly) {
+	for _, r := range reassemblies {
+		if s.start.IsZero() {
+			s.start = r.Seen
+		}
+		if r.Skip != 0 {
+			s.broken = true
+		}
+		if s.control {
+			s.controlData(r.Bytes)
+			continue
+		}
+		if s.broken {
+			continue
+		}
+		if int64(s.data.Len()+len(r.Bytes)) > s.extractor.maxStream {
+			s.broken = true
+			s.data = bytes.Buffer{}
+			continue
+		}
+		s.data.Write(r.Bytes)
+	}
+}
+
+// ReassemblyComplete extracts the objects of a complete stream.
+func (s *extractStream) ReassemblyComplete() {
+	if s.control || s.broken || s.data.Len() == 0 {
+		return
+	}
+
+	data := s.data.Bytes()
+
+	switch {
+	case bytes.HasPrefix(data, []byte("HTTP/1.")):
+		s.extractHTTPResponses(data)
+	case isHTTPRequest(data):
+		s.extractHTTPRequests(data)
+	default:
+		src, dst := s.netFlow.Endpoints()
+		tsrc, tdst := s.tcpFlow.Endpoints()
+		srcIP, dstIP := net.IP(src.Raw()), net.IP(dst.Raw())
+		srcPort, dstPort := binaryPort(tsrc.Raw()), binaryPort(tdst.Raw())
+		if s.extractor.isFTPData(srcIP, srcPort) || s.extractor.isFTPData(dstIP, dstPort) {
+			s.extractor.extract(s, extractFTP, "", "", data)
+		}
+	}
+}
+
+func (s *extractStream) extractHTTPResponses(data []byte) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		resp, err := http.ReadResponse(r, nil)
+		if err != nil {
+			return
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return
+		}
+		if len(body) > 0 {
+			s.extractor.extract(s, extractHTTP, resp.Header.Get("Content-Type"), resp.Status, body)
+		}
+	}
+}
+
+func (s *extractStream) extractHTTPRequests(data []byte) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return
+		}
+		if len(body) > 0 {
+			info := req.Method + " " + req.Host + req.URL.RequestURI()
+			s.extractor.extract(s, extractHTTP, req.Header.Get("Content-Type"), info, body)
+		}
+	}
+}
+
+// isHTTPRequest returns true if data starts with an HTTP/1.x request line.
+func isHTTPRequest(data []byte) bool {
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		return false
+	}
+	line := strings.TrimRight(string(data[:end]), "\r")
+	parts := strings.Split(line, " ")
+
+	return len(parts) == 3 && strings.HasPrefix(parts[2], "HTTP/1.")
+}
+
+var (
+	// 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2)
+	ftpPassive = regexp.MustCompile(`^227 .*?(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+	// 229 Entering Extended Passive Mode (|||port|)
+	ftpExtendedPassive = regexp.MustCompile(`^229 .*\(\|\|\|(\d+)\|\)`)
+	// PORT h1,h2,h3,h4,p1,p2
+	ftpActive = regexp.MustCompile(`^(?i:PORT) (\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+)
+
+// controlData parses the lines of an FTP control stream, looking for announced
+// data connections.
+func (s *extractStream) controlData(data []byte) {
+	s.line = append(s.line, data...)
+
+	for {
+		end := bytes.IndexByte(s.line, '\n')
+		if end < 0 {
+			break
+		}
+		s.controlLine(strings.TrimRight(string(s.line[:end]), "\r"))
+		s.line = s.line[end+1:]
+	}
+
+	if len(s.line) > 1024 {
+		s.line = nil // not a control stream
+	}
+}
+
+func (s *extractStream) controlLine(line string) {
+	if m := ftpPassive.FindStringSubmatch(line); m != nil {
+		s.extractor.announceFTPData(ftpAddress(m[1:5]), ftpPort(m[5], m[6]))
+		return
+	}
+	if m := ftpActive.FindStringSubmatch(line); m != nil {
+		s.extractor.announceFTPData(ftpAddress(m[1:5]), ftpPort(m[5], m[6]))
+		return
+	}
+	if m := ftpExtendedPassive.FindStringSubmatch(line); m != nil {
+		port, err := strconv.ParseUint(m[1], 10, 16)
+		if err == nil {
+			src, _ := s.netFlow.Endpoints() // the server
+			s.extractor.announceFTPData(net.IP(src.Raw()), uint16(port))
+		}
+	}
+}
+
+func ftpAddress(octets []string) net.IP {
+	return net.ParseIP(strings.Join(octets, "."))
+}
+
+func ftpPort(high, low string) uint16 {
+	h, _ := strconv.Atoi(high)
+	l, _ := strconv.Atoi(low)
+
+	return uint16(h<<8 | l)
+}
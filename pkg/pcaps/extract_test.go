@@ -0,0 +1,256 @@
+package pcaps
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// testTCPConn builds the packets of a TCP connection, as given to Pcaps.Write().
+type testTCPConn struct {
+	t          *testing.T
+	client     string
+	server     string
+	clientPort uint16
+	serverPort uint16
+	clientSeq  uint32
+	serverSeq  uint32
+}
+
+// packet builds a packet of the connection (from the client if fromClient).
+func (c *testTCPConn) packet(fromClient bool, flags string, payload []byte) []byte {
+	c.t.Helper()
+
+	src, dst, srcPort, dstPort := c.server, c.client, c.serverPort, c.clientPort
+	seq, ack := &c.serverSeq, c.clientSeq
+	if fromClient {
+		src, dst, srcPort, dstPort = c.client, c.server, c.clientPort, c.serverPort
+		seq, ack = &c.clientSeq, c.serverSeq
+	}
+
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.ParseIP(src).To4(),
+		DstIP:    net.ParseIP(dst).To4(),
+	}
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		Seq:     *seq,
+		Ack:     ack,
+		Window:  65535,
+	}
+	for _, f := range flags {
+		switch f {
+		case 'S':
+			tcp.SYN = true
+		case 'A':
+			tcp.ACK = true
+		case 'F':
+			tcp.FIN = true
+		case 'P':
+			tcp.PSH = true
+		}
+	}
+	require.NoError(c.t, tcp.SetNetworkLayerForChecksum(ip))
+
+	*seq += uint32(len(payload))
+	if tcp.SYN || tcp.FIN {
+		*seq++
+	}
+
+	return serializeTestPacket(c.t, ip, tcp, gopacket.Payload(payload))
+}
+
+// readTestExtractedObjects returns the descriptions of all extracted objects.
+func readTestExtractedObjects(t *testing.T, dir string) []ExtractedObject {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(dir, pcapExtractObjectFile))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var objects []ExtractedObject
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var object ExtractedObject
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &object))
+		objects = append(objects, object)
+	}
+	require.NoError(t, scanner.Err())
+
+	return objects
+}
+
+func TestPcapsExtractHTTPResponse(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:    true,
+		CaptureLength:    (1 << 16) - 1,
+		ExtractMaxStream: 1 << 20,
+	})
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+
+	body := "<html><body>extracted</body></html>"
+	request := "GET /index.html HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	response := "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"Content-Length: 35\r\n\r\n" + body
+
+	pkts := [][]byte{
+		conn.packet(true, "S", nil),
+		conn.packet(false, "SA", nil),
+		conn.packet(true, "A", nil),
+		conn.packet(true, "PA", []byte(request)),
+		conn.packet(false, "PA", []byte(response[:40])), // response in 2 segments
+		conn.packet(false, "PA", []byte(response[40:])),
+		conn.packet(false, "FA", nil),
+		conn.packet(true, "FA", nil),
+	}
+	for i, pkt := range pkts {
+		require.NoError(t, p.Write(newTestEvent(1000+i), pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	objects := readTestExtractedObjects(t, dir)
+	require.Len(t, objects, 1)
+
+	object := objects[0]
+	require.Equal(t, "http", object.Protocol)
+	require.Equal(t, "text/html; charset=utf-8", object.ContentType)
+	require.Equal(t, int64(len(body)), object.Size)
+	require.Equal(t, "10.0.0.80", object.SrcIP)
+	require.Equal(t, uint16(80), object.SrcPort)
+	require.Equal(t, "10.0.0.1", object.DstIP)
+	require.Equal(t, uint16(40000), object.DstPort)
+	require.Equal(t, "200 OK", object.Info)
+
+	extracted, err := os.ReadFile(filepath.Join(dir, object.File))
+	require.NoError(t, err)
+	require.Equal(t, body, string(extracted))
+	require.Equal(t, uint64(1), p.Stats().Extracted.Get())
+}
+
+func TestPcapsExtractFTPTransfer(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:    true,
+		CaptureLength:    (1 << 16) - 1,
+		ExtractMaxStream: 1 << 20,
+	})
+
+	control := &testTCPConn{
+		t: t, client: "10.0.0.1", server: "10.0.0.21",
+		clientPort: 40000, serverPort: 21, clientSeq: 1, serverSeq: 1,
+	}
+	data := &testTCPConn{
+		t: t, client: "10.0.0.1", server: "10.0.0.21",
+		clientPort: 40001, serverPort: 50000, clientSeq: 1, serverSeq: 1,
+	}
+
+	file := []byte("%PDF-1.4 secret document")
+
+	pkts := [][]byte{
+		control.packet(true, "S", nil),
+		control.packet(false, "SA", nil),
+		control.packet(true, "PA", []byte("PASV\r\n")),
+		// 50000 = 195 * 256 + 80
+		control.packet(false, "PA", []byte("227 Entering Passive Mode (10,0,0,21,195,80).\r\n")),
+		control.packet(true, "PA", []byte("RETR secret.pdf\r\n")),
+		data.packet(true, "S", nil),
+		data.packet(false, "SA", nil),
+		data.packet(false, "PA", file),
+		data.packet(false, "FA", nil),
+		data.packet(true, "FA", nil),
+	}
+	for i, pkt := range pkts {
+		require.NoError(t, p.Write(newTestEvent(1000+i), pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	objects := readTestExtractedObjects(t, dir)
+	require.Len(t, objects, 1)
+	require.Equal(t, "ftp", objects[0].Protocol)
+	require.Equal(t, "application/pdf", objects[0].ContentType)
+	require.Equal(t, uint16(50000), objects[0].SrcPort)
+
+	extracted, err := os.ReadFile(filepath.Join(dir, objects[0].File))
+	require.NoError(t, err)
+	require.Equal(t, file, extracted)
+}
+
+func TestPcapsExtractProtocols(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:    true,
+		CaptureLength:    (1 << 16) - 1,
+		ExtractMaxStream: 1 << 20,
+		ExtractProtocols: []string{"http"},
+	})
+
+	web := &testTCPConn{
+		t: t, client: "10.0.0.1", server: "10.0.0.80",
+		clientPort: 40000, serverPort: 8080, clientSeq: 1000, serverSeq: 5000,
+	}
+	control := &testTCPConn{
+		t: t, client: "10.0.0.1", server: "10.0.0.21",
+		clientPort: 40001, serverPort: 21, clientSeq: 1, serverSeq: 1,
+	}
+	data := &testTCPConn{
+		t: t, client: "10.0.0.1", server: "10.0.0.21",
+		clientPort: 40002, serverPort: 50000, clientSeq: 1, serverSeq: 1,
+	}
+
+	body := "<html><body>extracted</body></html>"
+	response := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 35\r\n\r\n" + body
+
+	pkts := [][]byte{
+		web.packet(true, "S", nil),
+		web.packet(false, "SA", nil),
+		web.packet(true, "A", nil),
+		control.packet(true, "S", nil),
+		control.packet(false, "SA", nil),
+		web.packet(true, "PA", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")),
+		control.packet(true, "PA", []byte("PASV\r\n")),
+		control.packet(false, "PA", []byte("227 Entering Passive Mode (10,0,0,21,195,80).\r\n")),
+		web.packet(false, "PA", []byte(response)),
+		data.packet(true, "S", nil),
+		data.packet(false, "SA", nil),
+		data.packet(false, "PA", []byte("%PDF-1.4 secret document")),
+		data.packet(false, "FA", nil),
+		data.packet(true, "FA", nil),
+		web.packet(false, "FA", nil),
+		web.packet(true, "FA", nil),
+	}
+	for i, pkt := range pkts {
+		require.NoError(t, p.Write(newTestEvent(1000+i), pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	// only the HTTP connection was reassembled
+	objects := readTestExtractedObjects(t, dir)
+	require.Len(t, objects, 1)
+	require.Equal(t, "http", objects[0].Protocol)
+	require.Equal(t, int64(len(body)), objects[0].Size)
+	require.Equal(t, uint64(2), p.Stats().NotReassembled.Get()) // ftp control and data
+
+	// all packets are captured untouched
+	require.Equal(t, pkts, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")))
+}
@@ -0,0 +1,182 @@
+package pcaps
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// The files index is a single JSON file, shared by all capture sessions using
+// the same output directory, describing every pcap file created: its capture
+// target, the container (id and image) and process command of the packet that
+// created it, and its creation time. Unlike the packet index, it tells where
+// captures of a given container or command are without reading any pcap file.
+//
+// The index is rewritten whenever a pcap file is created (a new target, or a
+// rotated file), compressed (renamed) or removed (e.g. of a short-lived
+// target). It is written to a temporary file first and then renamed over the
+// previous one, so readers never see a partially written index.
+//
+
+const (
+	pcapFilesIndexFile    string = pcapDir + "files.json"
+	pcapFilesIndexTmpFile string = pcapDir + ".files.json.tmp"
+)
+
+// FileEntry describes a pcap file created by capture.
+type FileEntry struct {
+	File        string `json:"file"`   // pcap file (relative to output dir, unless absolute)
+	Target      string `json:"target"` // capture target (e.g. "container:abc")
+	ContainerID string `json:"container_id,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Command     string `json:"command,omitempty"`
+	Created     int64  `json:"created"` // timestamp of the first packet (nanoseconds since epoch)
+}
+
+// filesIndex maintains the files index.
+type filesIndex struct {
+	output  *os.File
+	entries map[string]*FileEntry // entries by pcap file
+}
+
+// newFilesIndex returns the files index of the given output dir, with the
+// entries of previous capture sessions (if any).
+func newFilesIndex(output *os.File) (*filesIndex, error) {
+	err := utils.MkdirAtExist(output, pcapDir, os.ModePerm)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	i := &filesIndex{
+		output:  output,
+		entries: make(map[string]*FileEntry),
+	}
+
+	file, err := utils.OpenAt(output, pcapFilesIndexFile, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var entries []*FileEntry
+	err = json.NewDecoder(file).Decode(&entries)
+	if errors.Is(err, io.EOF) {
+		return i, nil // new index
+	}
+	if err != nil {
+		return nil, errfmt.Errorf("corrupted files index: %v", err)
+	}
+	for _, entry := range entries {
+		i.entries[entry.File] = entry
+	}
+
+	return i, nil
+}
+
+// created indexes a pcap file created for the packet of the given event.
+func (i *filesIndex) created(item *Pcap, event *trace.Event) error {
+	if _, ok := i.entries[item.pcapPath]; ok && !item.created {
+		return nil // appended to: indexed by a previous session
+	}
+	i.entries[item.pcapPath] = &FileEntry{
+		File:        item.pcapPath,
+		Target:      item.target,
+		ContainerID: event.Container.ID,
+		Image:       event.Container.ImageName,
+		Command:     event.ProcessName,
+		Created:     int64(event.Timestamp),
+	}
+
+	return i.write()
+}
+
+// renamed updates the entry of a renamed (e.g. compressed) pcap file. A file
+// renamed over (appended to) an indexed one keeps the entry of the latter.
+func (i *filesIndex) renamed(path string, newPath string) error {
+	entry, ok := i.entries[path]
+	if !ok {
+		return nil
+	}
+	delete(i.entries, path)
+	if _, ok := i.entries[newPath]; !ok {
+		entry.File = newPath
+		i.entries[newPath] = entry
+	}
+
+	return i.write()
+}
+
+// removed drops the entry of a removed pcap file.
+func (i *filesIndex) removed(path string) error {
+	if _, ok := i.entries[path]; !ok {
+		return nil
+	}
+	delete(i.entries, path)
+
+	return i.write()
+}
+
+// write atomically rewrites the index file.
+func (i *filesIndex) write() error {
+	entries := make([]*FileEntry, 0, len(i.entries))
+	for _, path := range sortedKeys(i.entries) {
+		entries = append(entries, i.entries[path])
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	file, err := utils.OpenAt(i.output, pcapFilesIndexTmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	err = utils.RenameAt(i.output, pcapFilesIndexTmpFile, i.output, pcapFilesIndexFile)
+	return errfmt.WrapError(err)
+}
+
+// ReadFilesIndex returns all entries of the given files index.
+func ReadFilesIndex(indexPath string) ([]FileEntry, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	var entries []FileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, errfmt.Errorf("corrupted files index: %v", err)
+	}
+
+	return entries, nil
+}
+
+// pcapCreated indexes a pcap file created in the current session (if the
+// files index is enabled).
+func (p *Pcaps) pcapCreated(item *Pcap, event *trace.Event) {
+	if p.filesIndex == nil {
+		return
+	}
+	if err := p.filesIndex.created(item, event); err != nil {
+		logger.Errorw("Writing pcap files index", "error", err)
+	}
+}
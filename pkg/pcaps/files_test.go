@@ -0,0 +1,84 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+func TestPcapsFilesIndex(t *testing.T) {
+	cfg := config.PcapsConfig{
+		CaptureContainer: true,
+		RotateInterval:   time.Second,
+		Compress:         CompressGzip,
+		FilesIndex:       true,
+	}
+	p, dir := newTestPcaps(t, cfg)
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	write := func(p *Pcaps, container string, ts time.Duration) {
+		event := newTestEvent(int(time.Unix(1700000000, 0).Add(ts).UnixNano()))
+		event.Container.ID = container
+		event.Container.ImageName = container + "-image"
+		event.ProcessName = container + "-cmd"
+		require.NoError(t, p.Write(event, pkt))
+	}
+
+	indexPath := filepath.Join(dir, pcapFilesIndexFile)
+
+	write(p, "abc", 0)
+	entries, err := ReadFilesIndex(indexPath)
+	require.NoError(t, err)
+	require.Equal(t, []FileEntry{
+		{
+			File:        pcapContDir + "abc.pcap",
+			Target:      "container:abc",
+			ContainerID: "abc",
+			Image:       "abc-image",
+			Command:     "abc-cmd",
+			Created:     time.Unix(1700000000, 0).UnixNano(),
+		},
+	}, entries)
+
+	write(p, "def", 500*time.Millisecond)
+	write(p, "abc", 1200*time.Millisecond) // rotated: the first file is compressed
+	require.NoError(t, p.Destroy())
+
+	entries, err = ReadFilesIndex(indexPath)
+	require.NoError(t, err)
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.File)
+	}
+	require.Equal(t, []string{
+		pcapContDir + "abc.20231114T221321.200000Z.pcap.gz",
+		pcapContDir + "abc.pcap.gz",
+		pcapContDir + "def.pcap.gz",
+	}, files)
+	require.Equal(t, "def-image", entries[2].Image)
+	require.Equal(t, time.Unix(1700000000, 0).Add(1200*time.Millisecond).UnixNano(), entries[0].Created)
+	require.NoFileExists(t, filepath.Join(dir, pcapFilesIndexTmpFile))
+
+	// entries of previous sessions are kept, and files appended to keep theirs
+	outDir, err := utils.OpenExistingDir(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+	p, err = New(cfg, outDir)
+	require.NoError(t, err)
+	write(p, "abc", 2000*time.Millisecond)
+	write(p, "ghi", 2100*time.Millisecond)
+	require.NoError(t, p.Destroy())
+
+	entries, err = ReadFilesIndex(indexPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	require.Equal(t, pcapContDir+"abc.pcap.gz", entries[1].File)
+	require.Equal(t, time.Unix(1700000000, 0).UnixNano(), entries[1].Created)
+	require.Equal(t, pcapContDir+"ghi.pcap.gz", entries[3].File)
+	require.Equal(t, "ghi-cmd", entries[3].Command)
+}
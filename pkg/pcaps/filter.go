@@ -0,0 +1,458 @@
+package pcaps
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+//
+// Capture filters are libpcap style filter expressions (see pcap-filter(7)),
+// e.g. "udp port 53 or tcp port 443", compiled once and matched against each
+// parsed packet. Compiling them with libpcap would require cgo (and libpcap),
+// so the commonly used subset of the syntax is implemented instead:
+//
+// - primitives: [PROTO] [DIR] TYPE VALUE, where PROTO is ip, ip6, tcp, udp or
+//   sctp, DIR is src or dst (either, if missing) and TYPE is host (an address),
+//   net (an address or a CIDR), port (a number) or portrange (N-M).
+// - protocols alone: ip, ip6, tcp, udp, sctp, icmp, icmp6 or arp.
+// - packet length (as given by the IP header): less N and greater N.
+// - operators: not (!), and (&&) and or (||), with parentheses. As in libpcap,
+//   and and or have the same precedence (left associative) and not binds
+//   tighter.
+// - values following an operator reuse the qualifiers of the previous
+//   primitive: "port 53 or 443" is "port 53 or port 443".
+//
+
+// Filter is a compiled capture filter.
+type Filter struct {
+	expression string
+	root       filterNode
+}
+
+// filterNode is a node of a compiled filter expression.
+type filterNode interface {
+	match(packet gopacket.Packet) bool
+}
+
+// CompileFilter compiles a libpcap style filter expression.
+func CompileFilter(expression string) (*Filter, error) {
+	tokens, err := filterTokens(expression)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	if len(tokens) == 0 {
+		return nil, errfmt.Errorf("empty pcap filter")
+	}
+
+	parser := &filterParser{tokens: tokens}
+	root, err := parser.expression()
+	if err != nil {
+		return nil, errfmt.Errorf("invalid pcap filter %q: %v", expression, err)
+	}
+	if parser.pos < len(tokens) {
+		return nil, errfmt.Errorf("invalid pcap filter %q: unexpected %q", expression, tokens[parser.pos])
+	}
+
+	return &Filter{expression: expression, root: root}, nil
+}
+
+// Match returns true if the packet matches the filter.
+func (f *Filter) Match(packet gopacket.Packet) bool {
+	return f.root.match(packet)
+}
+
+// String returns the filter expression.
+func (f *Filter) String() string {
+	return f.expression
+}
+
+// filterTokens splits a filter expression into tokens.
+func filterTokens(expression string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '!':
+			tokens = append(tokens, "not")
+			i++
+		case strings.HasPrefix(expression[i:], "&&"):
+			tokens = append(tokens, "and")
+			i += 2
+		case strings.HasPrefix(expression[i:], "||"):
+			tokens = append(tokens, "or")
+			i += 2
+		case c == '&' || c == '|':
+			return nil, errfmt.Errorf("invalid pcap filter %q: unexpected %q", expression, c)
+		default:
+			end := strings.IndexAny(expression[i:], " \t\n()!&|")
+			if end < 0 {
+				end = len(expression) - i
+			}
+			tokens = append(tokens, strings.ToLower(expression[i:i+end]))
+			i += end
+		}
+	}
+
+	return tokens, nil
+}
+
+// filterQualifiers are the qualifiers of a primitive.
+type filterQualifiers struct {
+	proto string // ip, ip6, tcp, udp or sctp (empty: any)
+	dir   string // src or dst (empty: either)
+	kind  string // host, net, port or portrange
+}
+
+// filterParser is a recursive descent parser of filter expressions.
+type filterParser struct {
+	tokens []string
+	pos    int
+	last   *filterQualifiers // of the previous primitive (reused by bare values)
+}
+
+// peek returns the current token (empty at the end).
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+// next consumes and returns the current token (empty at the end).
+func (p *filterParser) next() string {
+	token := p.peek()
+	if token != "" {
+		p.pos++
+	}
+
+	return token
+}
+
+// expression parses: unary (("and" | "or") unary)*
+func (p *filterParser) expression() (filterNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != "and" && op != "or" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "and" {
+			left = filterAnd{left, right}
+		} else {
+			left = filterOr{left, right}
+		}
+	}
+}
+
+// unary parses: "not" unary | "(" expression ")" | primitive
+func (p *filterParser) unary() (filterNode, error) {
+	switch p.peek() {
+	case "not":
+		p.next()
+		node, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node}, nil
+	case "(":
+		p.next()
+		node, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errfmt.Errorf("missing )")
+		}
+		return node, nil
+	case "":
+		return nil, errfmt.Errorf("unexpected end")
+	}
+
+	return p.primitive()
+}
+
+// primitive parses a primitive (see filter syntax above).
+func (p *filterParser) primitive() (filterNode, error) {
+	token := p.next()
+
+	switch token {
+	case "less", "greater":
+		value := p.next()
+		length, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, errfmt.Errorf("invalid %s length: %q", token, value)
+		}
+		return filterLength{greater: token == "greater", length: int(length)}, nil
+	}
+
+	var q filterQualifiers
+
+	switch token {
+	case "ip", "ip6", "tcp", "udp", "sctp":
+		q.proto = token
+		token = p.peek()
+		if !isFilterDir(token) && !isFilterKind(token) {
+			return filterProto{proto: q.proto}, nil // protocol alone
+		}
+		p.next()
+	case "icmp", "icmp6", "arp":
+		return filterProto{proto: token}, nil
+	}
+	if isFilterDir(token) {
+		q.dir = token
+		token = p.next()
+	}
+
+	if !isFilterKind(token) {
+		if q.proto != "" || q.dir != "" || p.last == nil {
+			return nil, errfmt.Errorf("unexpected %q", token)
+		}
+		q = *p.last // bare value: previous qualifiers
+		p.pos--
+	} else {
+		q.kind = token
+	}
+
+	switch q.kind {
+	case "host", "net":
+		if q.proto != "" && q.proto != "ip" && q.proto != "ip6" {
+			return nil, errfmt.Errorf("%s %s is not valid", q.proto, q.kind)
+		}
+	case "port", "portrange":
+		if q.proto == "ip" || q.proto == "ip6" {
+			return nil, errfmt.Errorf("%s %s is not valid", q.proto, q.kind)
+		}
+	}
+
+	node, err := p.value(q, p.next())
+	if err != nil {
+		return nil, err
+	}
+	p.last = &q
+
+	return node, nil
+}
+
+// value parses the value of a primitive with given qualifiers.
+func (p *filterParser) value(q filterQualifiers, value string) (filterNode, error) {
+	switch q.kind {
+	case "host":
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, errfmt.Errorf("invalid host address: %q", value)
+		}
+		return filterNet{q: q, net: &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}}, nil
+	case "net":
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errfmt.Errorf("invalid net: %q", value)
+			}
+			return filterNet{q: q, net: &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}}, nil
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errfmt.Errorf("invalid net: %q", value)
+		}
+		return filterNet{q: q, net: ipNet}, nil
+	case "port":
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, errfmt.Errorf("invalid port: %q", value)
+		}
+		return filterPort{q: q, first: uint16(port), last: uint16(port)}, nil
+	case "portrange":
+		first, last, ok := strings.Cut(value, "-")
+		start, err1 := strconv.ParseUint(first, 10, 16)
+		end, err2 := strconv.ParseUint(last, 10, 16)
+		if !ok || err1 != nil || err2 != nil || start > end {
+			return nil, errfmt.Errorf("invalid port range: %q", value)
+		}
+		return filterPort{q: q, first: uint16(start), last: uint16(end)}, nil
+	}
+
+	return nil, errfmt.Errorf("unexpected %q", value)
+}
+
+// isFilterDir returns true if the token is a direction qualifier.
+func isFilterDir(token string) bool {
+	return token == "src" || token == "dst"
+}
+
+// isFilterKind returns true if the token is a type qualifier.
+func isFilterKind(token string) bool {
+	switch token {
+	case "host", "net", "port", "portrange":
+		return true
+	}
+
+	return false
+}
+
+// filterAnd matches packets matching both nodes.
+type filterAnd [2]filterNode
+
+func (n filterAnd) match(packet gopacket.Packet) bool {
+	return n[0].match(packet) && n[1].match(packet)
+}
+
+// filterOr matches packets matching any of the nodes.
+type filterOr [2]filterNode
+
+func (n filterOr) match(packet gopacket.Packet) bool {
+	return n[0].match(packet) || n[1].match(packet)
+}
+
+// filterNot matches packets not matching the node.
+type filterNot [1]filterNode
+
+func (n filterNot) match(packet gopacket.Packet) bool {
+	return !n[0].match(packet)
+}
+
+// filterProto matches packets of a protocol.
+type filterProto struct {
+	proto string
+}
+
+func (n filterProto) match(packet gopacket.Packet) bool {
+	switch n.proto {
+	case "ip":
+		_, ok := packet.NetworkLayer().(*layers.IPv4)
+		return ok
+	case "ip6":
+		_, ok := packet.NetworkLayer().(*layers.IPv6)
+		return ok
+	case "tcp":
+		return packet.Layer(layers.LayerTypeTCP) != nil
+	case "udp":
+		return packet.Layer(layers.LayerTypeUDP) != nil
+	case "sctp":
+		return packet.Layer(layers.LayerTypeSCTP) != nil
+	case "icmp":
+		return packet.Layer(layers.LayerTypeICMPv4) != nil
+	case "icmp6":
+		return packet.Layer(layers.LayerTypeICMPv6) != nil
+	case "arp":
+		return packet.Layer(layers.LayerTypeARP) != nil
+	}
+
+	return false
+}
+
+// filterNet matches packets from or to a host or network.
+type filterNet struct {
+	q   filterQualifiers
+	net *net.IPNet
+}
+
+func (n filterNet) match(packet gopacket.Packet) bool {
+	var src, dst net.IP
+
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		if n.q.proto == "ip6" {
+			return false
+		}
+		src, dst = ip.SrcIP, ip.DstIP
+	case *layers.IPv6:
+		if n.q.proto == "ip" {
+			return false
+		}
+		src, dst = ip.SrcIP, ip.DstIP
+	default:
+		return false
+	}
+
+	return filterDirMatch(n.q.dir, n.net.Contains(src), n.net.Contains(dst))
+}
+
+// filterPort matches packets from or to a port (range).
+type filterPort struct {
+	q           filterQualifiers
+	first, last uint16
+}
+
+func (n filterPort) match(packet gopacket.Packet) bool {
+	var src, dst uint16
+
+	switch l4 := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		if n.q.proto != "" && n.q.proto != "tcp" {
+			return false
+		}
+		src, dst = uint16(l4.SrcPort), uint16(l4.DstPort)
+	case *layers.UDP:
+		if n.q.proto != "" && n.q.proto != "udp" {
+			return false
+		}
+		src, dst = uint16(l4.SrcPort), uint16(l4.DstPort)
+	case *layers.SCTP:
+		if n.q.proto != "" && n.q.proto != "sctp" {
+			return false
+		}
+		src, dst = uint16(l4.SrcPort), uint16(l4.DstPort)
+	default:
+		return false
+	}
+
+	in := func(port uint16) bool { return port >= n.first && port <= n.last }
+
+	return filterDirMatch(n.q.dir, in(src), in(dst))
+}
+
+// filterDirMatch returns the match of a primitive, given the direction
+// qualifier and the matches of the source and destination.
+func filterDirMatch(dir string, src, dst bool) bool {
+	switch dir {
+	case "src":
+		return src
+	case "dst":
+		return dst
+	}
+
+	return src || dst
+}
+
+// filterLength matches packets by length.
+type filterLength struct {
+	greater bool // greater or equal (less or equal otherwise)
+	length  int
+}
+
+func (n filterLength) match(packet gopacket.Packet) bool {
+	length := len(packet.Data())
+	switch ip := packet.NetworkLayer().(type) { // length before any truncation
+	case *layers.IPv4:
+		length = int(ip.Length)
+	case *layers.IPv6:
+		length = 40 + int(ip.Length)
+	}
+
+	if n.greater {
+		return length >= n.length
+	}
+
+	return length <= n.length
+}
@@ -0,0 +1,108 @@
+package pcaps
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileFilter(t *testing.T) {
+	t.Parallel()
+
+	decode := func(pkt []byte) gopacket.Packet {
+		return gopacket.NewPacket(pkt, layers.LayerTypeLoopback, gopacket.Default)
+	}
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "192.0.2.10",
+		clientPort: 40000,
+		serverPort: 443,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+	tls := decode(conn.packet(true, "PA", []byte("hello")))
+	dns := decode(newTestUDPPacket(t, "10.0.0.1", "10.0.0.53", 40001, 53, make([]byte, 30)))
+	icmp := decode(serializeTestPacket(t,
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolICMPv4, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}},
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0)},
+	))
+	ip6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: net.ParseIP("fd00::1"), DstIP: net.ParseIP("fd00::2")}
+	udp6 := &layers.UDP{SrcPort: 5000, DstPort: 5100}
+	require.NoError(t, udp6.SetNetworkLayerForChecksum(ip6))
+	ipv6 := decode(serializeTestPacket(t, ip6, udp6))
+	arp := gopacket.NewPacket(serializeTestPacket(t, &layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         layers.ARPRequest,
+		SourceHwAddress:   []byte{0x02, 0, 0, 0, 0, 1},
+		SourceProtAddress: []byte{10, 0, 0, 1},
+		DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
+		DstProtAddress:    []byte{10, 0, 0, 53},
+	})[4:], layers.LayerTypeARP, gopacket.Default) // no loopback family for ARP
+
+	packets := map[string]gopacket.Packet{"tls": tls, "dns": dns, "icmp": icmp, "ipv6": ipv6, "arp": arp}
+
+	testCases := []struct {
+		expression string
+		matches    []string
+	}{
+		{"udp port 53 or tcp port 443", []string{"dns", "tls"}},
+		{"port 53 or 443", []string{"dns", "tls"}},
+		{"tcp dst port 443", []string{"tls"}},
+		{"tcp src port 443", nil},
+		{"host 10.0.0.53", []string{"dns"}},
+		{"src net 10.0.0.0/8 and not icmp", []string{"dns", "tls"}},
+		{"dst host 10.0.0.1", nil},
+		{"ip6", []string{"ipv6"}},
+		{"ip6 net fd00::/8", []string{"ipv6"}},
+		{"ip net fd00::/8", nil},
+		{"udp portrange 5000-5100", []string{"ipv6"}},
+		{"icmp || (tcp && !port 80)", []string{"icmp", "tls"}},
+		{"not (udp or tcp)", []string{"arp", "icmp"}},
+		{"greater 50", []string{"dns"}},
+		{"less 50 and ip", []string{"icmp", "tls"}},
+		{"UDP Port 53", []string{"dns"}},
+		{"arp", []string{"arp"}},
+		{"arp or icmp", []string{"arp", "icmp"}},
+	}
+
+	for _, tc := range testCases {
+		filter, err := CompileFilter(tc.expression)
+		require.NoError(t, err, tc.expression)
+		require.Equal(t, tc.expression, filter.String())
+
+		var matches []string
+		for _, name := range []string{"arp", "dns", "icmp", "ipv6", "tls"} {
+			if filter.Match(packets[name]) {
+				matches = append(matches, name)
+			}
+		}
+		require.ElementsMatch(t, tc.matches, matches, tc.expression)
+	}
+
+	for _, expression := range []string{
+		"",
+		"port",
+		"port 70000",
+		"tcp host 10.0.0.1",
+		"ip port 53",
+		"host not-an-address",
+		"(udp",
+		"udp)",
+		"udp & tcp",
+		"portrange 10-5",
+		"53",
+		"src tcp",
+		"less",
+	} {
+		_, err := CompileFilter(expression)
+		require.Error(t, err, expression)
+	}
+}
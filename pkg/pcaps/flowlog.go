@@ -0,0 +1,112 @@
+package pcaps
+
+import (
+	"encoding/csv"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// The flow log is a single append-only CSV file, shared by all capture
+// sessions using the same output directory, holding one row per flow that is
+// over (fed by the flow table), for spreadsheet friendly triage. It might be
+// written in addition to the pcap files or instead of them (flow log only).
+//
+
+const pcapFlowLogFile string = pcapDir + "flows.csv"
+
+// flowLogHeader are the columns of the flow log.
+var flowLogHeader = []string{
+	"start_time", "end_time", "proto",
+	"src_ip", "src_port", "dst_ip", "dst_port",
+	"packets", "bytes",
+	"container_id", "container_name",
+	"l7", "closed",
+}
+
+// flowLog appends flow summaries to the flow log file.
+type flowLog struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newFlowLog(output *os.File) (*flowLog, error) {
+	err := utils.MkdirAtExist(output, pcapDir, os.ModePerm)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	file, err := utils.OpenAt(
+		output,
+		pcapFlowLogFile,
+		os.O_APPEND|os.O_WRONLY|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	l := &flowLog{
+		file:   file,
+		writer: csv.NewWriter(file),
+	}
+
+	// the header is only written once (the file is shared by all sessions)
+	stat, err := file.Stat()
+	if err == nil && stat.Size() == 0 {
+		err = l.write(flowLogHeader)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, errfmt.WrapError(err)
+	}
+
+	return l, nil
+}
+
+// add appends the row of a flow that is over.
+func (l *flowLog) add(s *flowSummary) error {
+	f := s.flow
+
+	srcIP, srcPort, _ := net.SplitHostPort(f.src)
+	dstIP, dstPort, _ := net.SplitHostPort(f.dst)
+
+	return l.write([]string{
+		flowLogTime(f.first),
+		flowLogTime(f.last),
+		protocolName(f.protocol),
+		srcIP,
+		srcPort,
+		dstIP,
+		dstPort,
+		strconv.FormatUint(f.packets, 10),
+		strconv.FormatUint(f.bytes, 10),
+		f.event.Container.ID,
+		f.event.Container.Name,
+		f.l7,
+		s.reason,
+	})
+}
+
+// write writes a row (CSV escaped) to the file right away.
+func (l *flowLog) write(row []string) error {
+	if err := l.writer.Write(row); err != nil {
+		return errfmt.WrapError(err)
+	}
+	l.writer.Flush()
+
+	return errfmt.WrapError(l.writer.Error())
+}
+
+func (l *flowLog) close() error {
+	return l.file.Close()
+}
+
+// flowLogTime formats a packet timestamp (nanoseconds since epoch).
+func flowLogTime(ts int64) string {
+	return time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+}
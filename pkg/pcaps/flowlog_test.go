@@ -0,0 +1,111 @@
+package pcaps
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// readTestFlowLog returns all the rows of the flow log.
+func readTestFlowLog(t *testing.T, dir string) [][]string {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(dir, pcapFlowLogFile))
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+
+	return rows
+}
+
+func TestPcapsFlowLog(t *testing.T) {
+	for _, only := range []bool{false, true} {
+		p, dir := newTestPcaps(t, config.PcapsConfig{
+			CaptureSingle: true,
+			Flows:         true,
+			FlowLog:       true,
+			FlowLogOnly:   only,
+		})
+
+		conn := &testTCPConn{
+			t:          t,
+			client:     "10.0.0.1",
+			server:     "10.0.0.80",
+			clientPort: 40000,
+			serverPort: 80,
+			clientSeq:  1000,
+			serverSeq:  5000,
+		}
+		container := trace.Container{ID: "abc123", Name: `web,"prod"`}
+		write := func(ts int, pkt []byte) {
+			event := newTestEvent(ts)
+			event.Container = container
+			require.NoError(t, p.Write(event, pkt))
+		}
+
+		http := [][]byte{
+			conn.packet(true, "S", nil),
+			conn.packet(false, "SA", nil),
+			conn.packet(true, "A", nil),
+			conn.packet(true, "PA", []byte("GET / HTTP/1.1\r\n\r\n")),
+			conn.packet(false, "PA", []byte("HTTP/1.1 204 No Content\r\n\r\n")),
+			conn.packet(true, "FA", nil),
+			conn.packet(false, "FA", nil),
+			conn.packet(true, "A", nil),
+		}
+		dns := newTestDNSQuery(t, "example.com", layers.DNSTypeA)
+
+		write(1000, dns)
+		for i, pkt := range http {
+			write(2000+i, pkt)
+		}
+		require.NoError(t, p.Destroy())
+
+		var httpBytes int
+		for _, pkt := range http {
+			httpBytes += len(pkt)
+		}
+
+		rows := readTestFlowLog(t, dir)
+		require.Equal(t, [][]string{
+			flowLogHeader,
+			{
+				"1970-01-01T00:00:00.000002Z", "1970-01-01T00:00:00.000002007Z", "tcp",
+				"10.0.0.1", "40000", "10.0.0.80", "80", "8", strconv.Itoa(httpBytes),
+				"abc123", `web,"prod"`, "http", "fin",
+			},
+			{
+				"1970-01-01T00:00:00.000001Z", "1970-01-01T00:00:00.000001Z", "udp",
+				"10.0.0.1", "4321", "10.0.0.53", "53", "1", strconv.Itoa(len(dns)),
+				"abc123", `web,"prod"`, "dns", "end",
+			},
+		}, rows)
+
+		_, err := os.Stat(filepath.Join(dir, pcapSingleDir, "single.pcap"))
+		if only {
+			require.ErrorIs(t, err, os.ErrNotExist)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestPcapsFlowLogRequiresFlows(t *testing.T) {
+	dir := t.TempDir()
+	outDir, err := os.Open(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+
+	_, err = New(config.PcapsConfig{CaptureSingle: true, FlowLog: true}, outDir)
+	require.ErrorContains(t, err, "requires flow tracking")
+}
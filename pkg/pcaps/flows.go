@@ -0,0 +1,751 @@
+package pcaps
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// The flow table tracks the (bidirectional) flows of captured packets. When a
+// flow is over (TCP FIN from both sides, RST, or idle for too long) a summary
+// is recorded in the pcap files of its first packet, as a "key=value" comment
+// of a pcapng interface statistics block.
+//
+// For TCP flows, the round-trip time is estimated passively: for each flow
+// direction, the time between a data segment and the first ACK covering it
+// (the time from the capture point to the receiver and back) is smoothed as an
+// EWMA (RFC 6298: srtt = 7/8 srtt + 1/8 sample). The flow RTT is the sum of
+// both directions estimates, which holds wherever the capture point is (at
+// one of the endpoints, one of the directions is close to zero). Samples are
+// not taken for retransmitted data (Karn's algorithm) and only one segment
+// per direction is timed at a time. The current estimate is also recorded in
+// the metadata of each TCP packet.
+//
+// TCP sequence gaps (data the capture never saw) are tracked as well, to tell
+// which flows were affected by a lossy capture. A gap is opened whenever a
+// segment starts beyond the highest data seen in its direction. It is then
+// either filled by a retransmission (the data was lost before reaching the
+// receiver: network loss) or ACKed by the receiver while still open (the
+// receiver got data the capture missed: capture loss). Gaps never filled nor
+// ACKed are still counted as missing (the loss cause is unknown).
+//
+// Segments reordered on their way to the capture point would look like
+// retransmissions filling gaps (network loss). With a reordering window, data
+// filling a gap within the window (of packet time) since the gap was opened
+// is taken as reordered instead: the segment carries new data (it is parsed
+// as such and does not void the RTT sample being taken) and a gap entirely filled that way is not counted as a
+// gap. The cost is that open gaps (up to flowMaxGaps per flow direction) are
+// kept in memory as before, and that a retransmission made within the window
+// is taken as reordering (the window should stay below the flows RTT).
+//
+// TCP handshakes are timed as well (see handshake.go).
+//
+// TLS flows are also tagged with the negotiated TLS version and cipher suite,
+// parsed from the ServerHello sent by the responder (see tls.go), and their
+// hellos fingerprints if enabled (see ja3.go). The L7
+// protocol of flows (dns, dhcp, http or tls) is recognized from their packets.
+//
+// The flow table is bounded (65536 flows by default). Once full, a flow is
+// evicted to make room for each new flow, according to the eviction policy:
+//
+// - idle (default): the least recently active flow, only if idle for longer
+//   than the idle timeout (otherwise the new flow is not tracked).
+// - lru: the least recently active flow, whatever its idle time.
+//
+// Evicted flows are over: their summaries are recorded as well.
+//
+// NOTE: Samples include the receivers ACK delay (delayed ACKs may add up to
+//       ~40ms-200ms). Selective ACKs (SACK) and lost ACKs (data covered by a
+//       later ACK) are not handled specially and make samples look bigger.
+//
+
+const (
+	flowIdleTimeout   = int64(120e9) // flows idle for longer are over (nanoseconds)
+	flowSweepInterval = int64(30e9)  // how often idle flows are looked for (nanoseconds)
+	flowTableMax      = 65536        // default max tracked flows
+	flowMaxGaps       = 32           // max open gaps per flow direction (older are given up)
+)
+
+// flow table eviction policies
+const (
+	FlowEvictionIdle = "idle"
+	FlowEvictionLRU  = "lru"
+)
+
+// ParseFlowEviction parses the eviction policy of a full flow table.
+func ParseFlowEviction(policy string) (string, error) {
+	switch policy = strings.ToLower(policy); policy {
+	case FlowEvictionIdle, FlowEvictionLRU:
+		return policy, nil
+	}
+
+	return "", errfmt.Errorf("invalid pcap flow eviction policy (idle or lru): %s", policy)
+}
+
+// L7 protocols of flows (besides DNS and DHCP)
+const (
+	protocolHTTP = "http"
+	protocolTLS  = "tls"
+)
+
+// flowKey identifies a flow regardless of the packet direction.
+type flowKey struct {
+	addrA, addrB [16]byte
+	portA, portB uint16
+	protocol     layers.IPProtocol
+}
+
+// newFlowKey returns the key of the flow of a packet and true if the packet
+// goes from endpoint A to endpoint B.
+func newFlowKey(info *packetInfo) (flowKey, bool) {
+	key := flowKey{protocol: info.protocol}
+
+	src, dst := info.srcIP.To16(), info.dstIP.To16()
+	forward := bytes.Compare(src, dst) < 0 || (bytes.Equal(src, dst) && info.srcPort <= info.dstPort)
+	if !forward {
+		src, dst = dst, src
+	}
+	copy(key.addrA[:], src)
+	copy(key.addrB[:], dst)
+
+	key.portA, key.portB = info.srcPort, info.dstPort
+	if !forward {
+		key.portA, key.portB = info.dstPort, info.srcPort
+	}
+
+	return key, forward
+}
+
+// flowHash returns a hash of the 5-tuple and start time of a flow: the same for
+// the same flow, whatever the capture (or tracee) instance tracking it.
+func flowHash(f *flow) []byte {
+	h := fnv.New128a()
+	_, _ = h.Write(f.key.addrA[:])
+	_, _ = h.Write(f.key.addrB[:])
+	_ = binary.Write(h, binary.BigEndian, []uint16{f.key.portA, f.key.portB, uint16(f.key.protocol)})
+	_ = binary.Write(h, binary.BigEndian, f.first)
+
+	return h.Sum(nil)
+}
+
+// flowID returns the flow ID of a flow (16 hex characters), derived from its
+// 5-tuple and start time (see flowHash).
+func flowID(f *flow) string {
+	return hex.EncodeToString(flowHash(f)[:8])
+}
+
+// flowDirection is the TCP state of one direction of a flow.
+type flowDirection struct {
+	started  bool
+	nextSeq  uint32 // end of the highest data sent
+	timing   bool   // a segment is being timed
+	timedAt  int64  // when the timed segment was seen
+	timedAck uint32 // ACK covering the timed segment
+	fin      bool
+	srtt     int64 // smoothed time from the capture point to the receiver and back
+	samples  uint64
+	gaps     []seqRange // open sequence gaps (data not seen yet)
+	gapCount uint64     // gaps ever opened
+	reorders uint64     // gaps entirely filled by reordered segments
+	lost     lostBytes
+	rst      bool   // a RST was sent
+	packets  uint64 // packets sent (any protocol)
+	ipBytes  uint64 // IP bytes sent (as captured)
+	payload  uint64 // L4 payload bytes sent (as captured)
+}
+
+// seqRange is a range [start, end) of TCP sequence numbers.
+type seqRange struct {
+	start, end uint32
+	opened     int64 // when the gap was opened (gaps only)
+}
+
+// lostBytes accounts the data of sequence gaps by loss cause.
+type lostBytes struct {
+	capture uint64 // ACKed by the receiver but never captured
+	network uint64 // captured once retransmitted
+	unknown uint64 // given up (too many open gaps)
+}
+
+// missing returns the number of bytes of the direction never captured.
+func (d *flowDirection) missing() uint64 {
+	missing := d.lost.capture + d.lost.unknown
+	for _, gap := range d.gaps {
+		missing += uint64(gap.end - gap.start)
+	}
+
+	return missing
+}
+
+// openGap accounts data never seen (at the given time), between the highest
+// data seen and a new segment.
+func (d *flowDirection) openGap(ts int64, start, end uint32) {
+	d.gapCount++
+	if len(d.gaps) == flowMaxGaps {
+		d.lost.unknown += uint64(d.gaps[0].end - d.gaps[0].start)
+		d.gaps = d.gaps[1:]
+	}
+	d.gaps = append(d.gaps, seqRange{start, end, ts})
+}
+
+// fillGaps removes the data of a (retransmitted or reordered) segment, seen at
+// the given time, from the open gaps. Data filling a gap within the reordering
+// window (nanoseconds, 0: none) since it was opened is taken as reordered, not
+// lost. It returns true if the segment filled gaps with reordered data.
+func (d *flowDirection) fillGaps(ts int64, start, end uint32, window int64) bool {
+	var reordered []int64 // opening time of the gaps filled by reordered data
+
+	gaps := make([]seqRange, 0, len(d.gaps)+1) // a gap might be split in two
+	for _, gap := range d.gaps {
+		if !seqAfter(end, gap.start) || !seqAfter(gap.end, start) {
+			gaps = append(gaps, gap) // no overlap
+			continue
+		}
+		filled := gap
+		if seqAfter(start, filled.start) {
+			gaps = append(gaps, seqRange{gap.start, start, gap.opened})
+			filled.start = start
+		}
+		if seqAfter(filled.end, end) {
+			gaps = append(gaps, seqRange{end, gap.end, gap.opened})
+			filled.end = end
+		}
+		if window > 0 && ts-gap.opened <= window {
+			if len(reordered) == 0 || reordered[len(reordered)-1] != gap.opened {
+				reordered = append(reordered, gap.opened) // pieces of a gap are adjacent
+			}
+			continue
+		}
+		d.lost.network += uint64(filled.end - filled.start)
+	}
+	for len(gaps) > flowMaxGaps {
+		d.lost.unknown += uint64(gaps[0].end - gaps[0].start)
+		gaps = gaps[1:]
+	}
+	d.gaps = gaps
+
+	// gaps (split or not) with no pieces left were entirely reordered
+	for _, opened := range reordered {
+		left := false
+		for _, gap := range gaps {
+			if gap.opened == opened {
+				left = true
+				break
+			}
+		}
+		if !left {
+			d.reorders++
+		}
+	}
+
+	return len(reordered) > 0
+}
+
+// ackGaps accounts the data of open gaps covered by an ACK of the receiver
+// as lost by the capture.
+func (d *flowDirection) ackGaps(ack uint32) {
+	gaps := d.gaps[:0]
+	for _, gap := range d.gaps {
+		switch {
+		case seqAfterOrEqual(ack, gap.end):
+			d.lost.capture += uint64(gap.end - gap.start)
+		case seqAfter(ack, gap.start):
+			d.lost.capture += uint64(ack - gap.start)
+			gaps = append(gaps, seqRange{ack, gap.end, gap.opened})
+		default:
+			gaps = append(gaps, gap)
+		}
+	}
+	d.gaps = gaps
+}
+
+// sample accounts a round-trip time sample of the direction.
+func (d *flowDirection) sample(rtt int64) {
+	if d.samples == 0 {
+		d.srtt = rtt
+	} else {
+		d.srtt += (rtt - d.srtt) / 8
+	}
+	d.samples++
+}
+
+// flow is a tracked flow.
+type flow struct {
+	src, dst   string // initiator and responder ("ip:port")
+	initiatorA bool   // initiator is endpoint A of the key
+	protocol   layers.IPProtocol
+	first      int64
+	last       int64
+	packets    uint64
+	bytes      uint64
+	dirs       [2]flowDirection // from initiator, from responder
+	handshake  flowHandshake    // TCP handshake (if the flow started with one)
+	history    []byte           // TCP history (Zeek conn.log letters)
+	tls        *tlsServerHello  // negotiated TLS parameters (if a TLS flow)
+	ja3, ja3s  string           // TLS client and server fingerprints (if enabled and a TLS flow)
+	http       *httpFlow        // HTTP metadata (if enabled and an HTTP flow)
+	withheld   bool             // packets withheld (below the byte threshold)
+	held       []*heldPacket    // withheld packets kept to backfill (the last ones)
+	l7         string           // L7 protocol (if recognized)
+	event      *trace.Event     // first packet event (locates the pcap files)
+	connection string           // name of the connection pcap file (if opened on its SYN)
+	id         string           // flow ID (if enabled)
+	caches     map[PcapType]*PcapCache
+	key        flowKey
+	element    *list.Element // in the flow table recency list
+}
+
+// missing returns the number of bytes of the flow never captured (estimated
+// from TCP sequence gaps).
+func (f *flow) missing() uint64 {
+	return f.dirs[0].missing() + f.dirs[1].missing()
+}
+
+// rtt returns the round-trip time estimate (nanoseconds) of the flow and the
+// number of samples it is based on.
+func (f *flow) rtt() (int64, uint64) {
+	return f.dirs[0].srtt + f.dirs[1].srtt, f.dirs[0].samples + f.dirs[1].samples
+}
+
+// tcp tracks a TCP segment sent in the given direction, and returns true if
+// the segment carries new data (not a retransmission). Segments filling gaps
+// within the reordering window (nanoseconds, 0: none) carry new data.
+func (f *flow) tcp(ts int64, tcp *layers.TCP, dir int, reorder int64) bool {
+	out, in := &f.dirs[dir], &f.dirs[1-dir]
+
+	if tcp.RST {
+		out.rst = true
+	}
+
+	// the ACK might cover the segment timed in the other direction
+	if tcp.ACK && in.timing && seqAfterOrEqual(tcp.Ack, in.timedAck) {
+		if rtt := ts - in.timedAt; rtt >= 0 {
+			in.sample(rtt)
+		}
+		in.timing = false
+	}
+	if tcp.ACK && len(in.gaps) > 0 {
+		in.ackGaps(tcp.Ack)
+	}
+
+	length := uint32(len(tcp.Payload))
+	if tcp.SYN || tcp.FIN {
+		length++
+	}
+	if length == 0 {
+		return false
+	}
+
+	fresh := !out.started || seqAfterOrEqual(tcp.Seq, out.nextSeq)
+
+	end := tcp.Seq + length
+	switch {
+	case fresh:
+		if out.started && seqAfter(tcp.Seq, out.nextSeq) {
+			out.openGap(ts, out.nextSeq, tcp.Seq)
+		}
+		// new data: time it (if not timing another segment already)
+		if !out.timing {
+			out.timing = true
+			out.timedAt = ts
+			out.timedAck = end
+		}
+	case len(out.gaps) > 0 && out.fillGaps(ts, tcp.Seq, end, reorder):
+		// reordered: new data arriving late (not timed, the data sent after
+		// it is ACKed along with it)
+		fresh = true
+	default:
+		// retransmission: samples would be ambiguous (Karn's algorithm)
+		out.timing = false
+	}
+	if !out.started || seqAfterOrEqual(end, out.nextSeq) {
+		out.nextSeq = end
+	}
+	out.started = true
+
+	if tcp.FIN {
+		out.fin = true
+	}
+
+	return fresh
+}
+
+// seqAfterOrEqual compares TCP sequence numbers (handling wrap around).
+func seqAfterOrEqual(a, b uint32) bool {
+	return int32(a-b) >= 0
+}
+
+// seqAfter compares TCP sequence numbers (handling wrap around).
+func seqAfter(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+// flowSummary describes a flow that is over.
+type flowSummary struct {
+	flow   *flow
+	reason string // why it is over: fin, rst, idle, evicted or end (of capture)
+}
+
+// FlowSummary describes a flow that is over (see Pcaps.OnFlowOver).
+type FlowSummary struct {
+	Event    *trace.Event // event of the first packet of the flow (its context)
+	Src      string       // initiator ("ip:port")
+	Dst      string       // responder ("ip:port")
+	Protocol string       // transport protocol (tcp, udp, icmp, sctp or its number)
+	Packets  uint64
+	Bytes    uint64
+	First    int64  // first packet time (nanoseconds since epoch)
+	Last     int64  // last packet time (nanoseconds since epoch)
+	Reason   string // why it is over: fin, rst, idle, evicted or end (of capture)
+}
+
+// export returns the summary handed to flow over callbacks.
+func (s *flowSummary) export() FlowSummary {
+	f := s.flow
+
+	return FlowSummary{
+		Event:    f.event,
+		Src:      f.src,
+		Dst:      f.dst,
+		Protocol: protocolName(f.protocol),
+		Packets:  f.packets,
+		Bytes:    f.bytes,
+		First:    f.first,
+		Last:     f.last,
+		Reason:   s.reason,
+	}
+}
+
+// comment returns the summary as a pcapng comment.
+func (s *flowSummary) comment() string {
+	f := s.flow
+	comment := fmt.Sprintf(
+		"flow_closed=%s proto=%s src=%s dst=%s packets=%d bytes=%d duration_us=%d",
+		s.reason, protocolName(f.protocol), f.src, f.dst, f.packets, f.bytes, (f.last-f.first)/1e3,
+	)
+	if f.id != "" {
+		comment += " flow_id=" + f.id
+	}
+	if rtt, samples := f.rtt(); samples > 0 {
+		comment += fmt.Sprintf(" rtt_us=%d rtt_samples=%d", rtt/1e3, samples)
+	}
+	reorders := f.dirs[0].reorders + f.dirs[1].reorders
+	if gaps := f.dirs[0].gapCount + f.dirs[1].gapCount - reorders; gaps > 0 {
+		lost := f.dirs[0].lost
+		lost.capture += f.dirs[1].lost.capture
+		lost.network += f.dirs[1].lost.network
+		comment += fmt.Sprintf(
+			" seq_gaps=%d missing_bytes=%d capture_loss_bytes=%d network_loss_bytes=%d",
+			gaps, f.missing(), lost.capture, lost.network,
+		)
+	}
+	if reorders > 0 {
+		comment += fmt.Sprintf(" reordered_gaps=%d", reorders)
+	}
+	comment += f.handshake.comment()
+	if f.tls != nil {
+		comment += fmt.Sprintf(" tls_version=%s tls_cipher=%s", f.tls.versionName(), f.tls.cipherName())
+	}
+	if f.ja3 != "" {
+		comment += " tls_ja3=" + f.ja3
+	}
+	if f.ja3s != "" {
+		comment += " tls_ja3s=" + f.ja3s
+	}
+
+	return comment
+}
+
+// comments returns the summary, followed by the HTTP exchanges of the flow
+// (if any), as pcapng comments.
+func (s *flowSummary) comments() []string {
+	comments := []string{s.comment()}
+	if s.flow.http != nil {
+		comments = append(comments, s.flow.http.comments()...)
+	}
+
+	return comments
+}
+
+// protocolName returns the name of an IP protocol.
+func protocolName(protocol layers.IPProtocol) string {
+	switch protocol {
+	case layers.IPProtocolTCP:
+		return protocolTCP
+	case layers.IPProtocolUDP:
+		return protocolUDP
+	case layers.IPProtocolICMPv4, layers.IPProtocolICMPv6:
+		return protocolICMP
+	case layers.IPProtocolSCTP:
+		return protocolSCTP
+	}
+
+	return strconv.Itoa(int(protocol))
+}
+
+// flowTable tracks the flows of captured packets.
+type flowTable struct {
+	flows      map[flowKey]*flow
+	recency    *list.List // flows, from the least to the most recently active
+	max        int
+	lru        bool               // evict the least recently active flow even if not idle
+	http       bool               // record HTTP metadata of flows
+	reorder    int64              // TCP reordering window (nanoseconds, 0: none)
+	withhold   bool               // withhold packets of new flows (until the byte threshold)
+	ja3        bool               // fingerprint TLS hellos (JA3 and JA3S) of flows
+	ids        bool               // derive flow IDs of flows
+	handshakes *metrics.Histogram // observes TCP handshake times (if exported)
+	lastSweep  int64
+	untracked  uint64 // packets of flows not tracked (table full)
+	evicted    uint64 // flows evicted (table full)
+}
+
+// newFlowTable creates a flow table of the given max size (default if zero)
+// and eviction policy (default if empty), recording HTTP metadata of flows if
+// requested, tolerating TCP segments reordered within the given window and
+// withholding packets of new flows if requested.
+func newFlowTable(size int, eviction string, http bool, reorder int64, withhold bool) *flowTable {
+	if size <= 0 {
+		size = flowTableMax
+	}
+
+	return &flowTable{
+		flows:    make(map[flowKey]*flow),
+		recency:  list.New(),
+		max:      size,
+		lru:      eviction == FlowEvictionLRU,
+		http:     http,
+		reorder:  reorder,
+		withhold: withhold,
+	}
+}
+
+// remove stops tracking a flow.
+func (t *flowTable) remove(f *flow) {
+	delete(t.flows, f.key)
+	t.recency.Remove(f.element)
+}
+
+// evict makes room for a new flow (if possible) and returns the summary of
+// the evicted flow.
+func (t *flowTable) evict(ts int64) *flowSummary {
+	oldest := t.recency.Front()
+	if oldest == nil {
+		return nil
+	}
+	f := oldest.Value.(*flow)
+	if !t.lru && ts-f.last < flowIdleTimeout {
+		return nil
+	}
+	t.remove(f)
+	t.evicted++
+
+	return &flowSummary{flow: f, reason: "evicted"}
+}
+
+// packet accounts a packet, written to the given caches, and returns its flow
+// (nil if not tracked) and the summaries of the flows that are over: the one
+// of a flow evicted to make room for the packet flow and, if the packet ends
+// its flow, the one of the packet flow.
+func (t *flowTable) packet(ts int64, info *packetInfo, event *trace.Event, caches map[PcapType]*PcapCache) (*flow, []*flowSummary) {
+	if info.srcIP == nil {
+		return nil, nil // not an IP packet
+	}
+
+	key, forward := newFlowKey(info)
+
+	var summaries []*flowSummary
+
+	f, ok := t.flows[key]
+	if !ok {
+		if len(t.flows) >= t.max {
+			evicted := t.evict(ts)
+			if evicted == nil {
+				t.untracked++
+				return nil, nil
+			}
+			summaries = append(summaries, evicted)
+		}
+		copied := *event
+		copied.Args = nil // only needed to locate the pcap files
+		f = &flow{
+			src:        net.JoinHostPort(info.srcIP.String(), strconv.Itoa(int(info.srcPort))),
+			dst:        net.JoinHostPort(info.dstIP.String(), strconv.Itoa(int(info.dstPort))),
+			initiatorA: forward,
+			protocol:   info.protocol,
+			first:      ts,
+			event:      &copied,
+			caches:     caches,
+			key:        key,
+			withheld:   t.withhold,
+		}
+		if t.ids {
+			f.id = flowID(f)
+		}
+		t.flows[key] = f
+		f.element = t.recency.PushBack(f)
+	} else {
+		t.recency.MoveToBack(f.element)
+	}
+
+	f.packets++
+	f.bytes += uint64(len(info.packet.Data()))
+	if ts > f.last {
+		f.last = ts
+	}
+
+	if f.l7 == "" {
+		f.l7 = flowL7(info)
+	}
+
+	dir := 0 // from initiator
+	if forward != f.initiatorA {
+		dir = 1
+	}
+	f.dirs[dir].packets++
+	if l3 := info.packet.NetworkLayer(); l3 != nil {
+		f.dirs[dir].ipBytes += uint64(len(l3.LayerContents()) + len(l3.LayerPayload()))
+	}
+	if l4 := info.packet.TransportLayer(); l4 != nil {
+		f.dirs[dir].payload += uint64(len(l4.LayerPayload()))
+	}
+
+	tcp, ok := info.packet.TransportLayer().(*layers.TCP)
+	if !ok {
+		return f, summaries
+	}
+
+	fresh := f.tcp(ts, tcp, dir, t.reorder)
+	f.history = zeekHistory(f.history, tcp, dir)
+
+	// handshakes are only timed from the first packet of the flow on
+	if f.packets == 1 || f.handshake.state != handshakeNone {
+		if f.handshake.segment(ts, tcp, dir) && t.handshakes != nil {
+			t.handshakes.Observe(time.Duration(f.handshake.duration()))
+		}
+	}
+
+	// HTTP metadata (retransmitted segments are not parsed again)
+	if t.http && fresh && len(tcp.Payload) > 0 {
+		if f.http == nil {
+			f.http = &httpFlow{}
+		}
+		if dir == 0 {
+			f.http.request(tcp.Payload)
+		} else {
+			f.http.response(tcp.Payload)
+		}
+	}
+
+	// the responder negotiates TLS parameters in its ServerHello
+	if f.tls == nil && dir == 1 && len(tcp.Payload) > 0 {
+		if hello, ok := parseTLSServerHello(tcp.Payload); ok {
+			f.tls = &hello
+			f.l7 = protocolTLS
+		}
+	}
+
+	// TLS hellos fingerprints (see ja3.go)
+	if t.ja3 && len(tcp.Payload) > 0 {
+		switch {
+		case dir == 0 && f.ja3 == "":
+			if s, ok := ja3String(tcp.Payload); ok {
+				f.ja3 = ja3Hash(s)
+			}
+		case dir == 1 && f.ja3s == "":
+			if s, ok := ja3sString(tcp.Payload); ok {
+				f.ja3s = ja3Hash(s)
+			}
+		}
+	}
+
+	switch {
+	case tcp.RST:
+		t.remove(f)
+		summaries = append(summaries, &flowSummary{flow: f, reason: "rst"})
+	case f.dirs[0].fin && f.dirs[1].fin && tcp.ACK && !tcp.FIN:
+		// last ACK (of the second FIN)
+		t.remove(f)
+		summaries = append(summaries, &flowSummary{flow: f, reason: "fin"})
+	}
+
+	return f, summaries
+}
+
+// flowL7 returns the L7 protocol of a packet, if recognized (TLS flows are
+// recognized by their ServerHello instead).
+func flowL7(info *packetInfo) string {
+	switch {
+	case info.packet.Layer(layers.LayerTypeDNS) != nil:
+		return protocolDNS
+	case info.packet.Layer(layers.LayerTypeDHCPv4) != nil || info.packet.Layer(layers.LayerTypeDHCPv6) != nil:
+		return protocolDHCP
+	}
+	if tcp, ok := info.packet.TransportLayer().(*layers.TCP); ok {
+		if isHTTPRequest(tcp.Payload) || bytes.HasPrefix(tcp.Payload, []byte("HTTP/1.")) {
+			return protocolHTTP
+		}
+	}
+
+	return ""
+}
+
+// sweep ends all flows idle by the given timestamp. Flows are swept at most
+// once per sweep interval.
+func (t *flowTable) sweep(ts int64) []*flowSummary {
+	if ts-t.lastSweep < flowSweepInterval {
+		return nil
+	}
+	t.lastSweep = ts
+
+	var summaries []*flowSummary
+	for _, f := range t.flows {
+		if ts-f.last < flowIdleTimeout {
+			continue
+		}
+		summaries = append(summaries, &flowSummary{flow: f, reason: "idle"})
+		t.remove(f)
+	}
+	sortFlowSummaries(summaries)
+
+	return summaries
+}
+
+// flush ends all flows.
+func (t *flowTable) flush() []*flowSummary {
+	summaries := make([]*flowSummary, 0, len(t.flows))
+	for _, f := range t.flows {
+		summaries = append(summaries, &flowSummary{flow: f, reason: "end"})
+	}
+	t.flows = make(map[flowKey]*flow)
+	t.recency.Init()
+	sortFlowSummaries(summaries)
+
+	return summaries
+}
+
+// sortFlowSummaries sorts summaries by flow start (so output is deterministic).
+func sortFlowSummaries(summaries []*flowSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i].flow, summaries[j].flow
+		if a.first != b.first {
+			return a.first < b.first
+		}
+		return a.src+a.dst < b.src+b.dst
+	})
+}
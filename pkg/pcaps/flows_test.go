@@ -0,0 +1,452 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsFlowRTT(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true})
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+
+	// captured at the client: the server is 10ms away, the client ACKs fast
+	const ms = 1000000
+	packets := []struct {
+		ts  int
+		pkt []byte
+	}{
+		{0, conn.packet(true, "S", nil)},
+		{10 * ms, conn.packet(false, "SA", nil)},
+		{10*ms + 100000, conn.packet(true, "A", nil)},
+		{11 * ms, conn.packet(true, "PA", []byte("GET / HTTP/1.1\r\n\r\n"))},
+		{21 * ms, conn.packet(false, "PA", []byte("HTTP/1.1 204 No Content\r\n\r\n"))},
+		{21*ms + 200000, conn.packet(true, "A", nil)},
+		{22 * ms, conn.packet(true, "FA", nil)},
+		{32 * ms, conn.packet(false, "FA", nil)},
+		{32*ms + 100000, conn.packet(true, "A", nil)},
+	}
+	for _, packet := range packets {
+		require.NoError(t, p.Write(newTestEvent(packet.ts), packet.pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+
+	// rtt estimate recorded in packets metadata (once there are samples)
+	comments := readTestPcapComments(t, path)
+	require.Len(t, comments, len(packets))
+	require.Nil(t, comments[0])
+	require.Equal(t, []string{"flow_rtt_us=10000"}, comments[1])
+	require.Equal(t, []string{"flow_rtt_us=10100"}, comments[2])
+
+	// rtt estimate recorded in the flow summary
+	summaries := readTestStatsComments(t, path)
+	require.Len(t, summaries, 1)
+	require.True(t, strings.HasPrefix(summaries[0],
+		"flow_closed=fin proto=tcp src=10.0.0.1:40000 dst=10.0.0.80:80 packets=9 "), summaries[0])
+
+	fields := make(map[string]int)
+	for _, field := range strings.Fields(summaries[0]) {
+		key, value, _ := strings.Cut(field, "=")
+		if n, err := strconv.Atoi(value); err == nil {
+			fields[key] = n
+		}
+	}
+	rtt, samples := fields["rtt_us"], fields["rtt_samples"]
+	require.Equal(t, 6, samples)
+	// ~10ms to the server plus ~0.1ms of client ACK latency
+	require.GreaterOrEqual(t, rtt, 10000)
+	require.LessOrEqual(t, rtt, 10300)
+}
+
+func TestPcapsFlowSeqGaps(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true})
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+	data := []byte("0123456789")
+
+	var written [][]byte
+	write := func(pkt []byte) {
+		require.NoError(t, p.Write(newTestEvent(len(written)), pkt))
+		written = append(written, pkt)
+	}
+
+	write(conn.packet(true, "S", nil))
+	write(conn.packet(false, "SA", nil))
+	write(conn.packet(true, "A", nil))
+	write(conn.packet(true, "PA", data))
+
+	// missed by the capture, but ACKed by the server: capture loss
+	conn.packet(true, "PA", data)
+	write(conn.packet(true, "PA", data))
+	write(conn.packet(false, "A", nil))
+
+	// lost on the way to the server, then retransmitted: network loss
+	lost := conn.packet(true, "PA", data)
+	write(conn.packet(true, "PA", data))
+	write(lost)
+	write(conn.packet(false, "A", nil))
+
+	write(conn.packet(true, "FA", nil))
+	write(conn.packet(false, "FA", nil))
+	write(conn.packet(true, "A", nil))
+	require.NoError(t, p.Destroy())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+	require.Equal(t, written, readTestPcap(t, path))
+
+	// missing bytes recorded in packets metadata (once there are gaps)
+	comments := readTestPcapComments(t, path)
+	require.Len(t, comments, len(written))
+	require.NotContains(t, comments[3], "flow_missing_bytes=10")
+	require.Contains(t, comments[4], "flow_missing_bytes=10")
+	require.Contains(t, comments[6], "flow_missing_bytes=20")
+	require.Contains(t, comments[7], "flow_missing_bytes=10") // gap filled
+
+	// gaps, by loss cause, recorded in the flow summary
+	summaries := readTestStatsComments(t, path)
+	require.Len(t, summaries, 1)
+	require.Contains(t, summaries[0], " seq_gaps=2 missing_bytes=10 capture_loss_bytes=10 network_loss_bytes=10")
+}
+
+func TestFlowDirectionGaps(t *testing.T) {
+	t.Parallel()
+
+	d := &flowDirection{}
+	d.openGap(0, 100, 200)
+	d.openGap(0, 300, 400)
+	require.Equal(t, uint64(200), d.missing())
+
+	// partial retransmission splits the gap
+	d.fillGaps(0, 120, 150, 0)
+	require.Equal(t, []seqRange{{100, 120, 0}, {150, 200, 0}, {300, 400, 0}}, d.gaps)
+	require.Equal(t, uint64(30), d.lost.network)
+
+	// partial ACK
+	d.ackGaps(350)
+	require.Equal(t, []seqRange{{350, 400, 0}}, d.gaps)
+	require.Equal(t, uint64(120), d.lost.capture)
+	require.Equal(t, uint64(170), d.missing())
+
+	// sequence numbers wrap around
+	w := &flowDirection{}
+	w.openGap(0, 0xfffffff0, 0x10)
+	require.Equal(t, uint64(0x20), w.missing())
+	w.ackGaps(0x10)
+	require.Empty(t, w.gaps)
+	require.Equal(t, uint64(0x20), w.lost.capture)
+}
+
+func TestFlowDirectionReorder(t *testing.T) {
+	t.Parallel()
+
+	const window = int64(10e6)
+
+	d := &flowDirection{}
+	d.openGap(0, 100, 400)
+	d.openGap(1, 500, 600)
+
+	// reordered within the window: split, then filled
+	require.True(t, d.fillGaps(window/2, 200, 300, window))
+	require.True(t, d.fillGaps(window, 100, 200, window))
+	require.Zero(t, d.reorders)
+	require.True(t, d.fillGaps(window, 300, 400, window))
+	require.Equal(t, uint64(1), d.reorders)
+	require.Zero(t, d.lost.network)
+
+	// filled once the window is over: retransmission
+	require.False(t, d.fillGaps(window+2, 500, 600, window))
+	require.Equal(t, uint64(1), d.reorders)
+	require.Equal(t, uint64(100), d.lost.network)
+	require.Empty(t, d.gaps)
+}
+
+func TestPcapsFlowReorder(t *testing.T) {
+	const ms = int(1e6)
+
+	testCases := []struct {
+		name    string
+		window  time.Duration
+		delay   int // reordered segment delay (milliseconds)
+		summary string
+	}{
+		{
+			name:    "within window",
+			window:  10 * time.Millisecond,
+			delay:   5,
+			summary: " reordered_gaps=1",
+		},
+		{
+			name:    "out of window",
+			window:  10 * time.Millisecond,
+			delay:   20,
+			summary: " seq_gaps=1 missing_bytes=0 capture_loss_bytes=0 network_loss_bytes=10",
+		},
+		{
+			name:    "no window",
+			delay:   5,
+			summary: " seq_gaps=1 missing_bytes=0 capture_loss_bytes=0 network_loss_bytes=10",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			p, dir := newTestPcaps(t, config.PcapsConfig{
+				CaptureSingle:     true,
+				Flows:             true,
+				FlowReorderWindow: tc.window,
+			})
+
+			conn := &testTCPConn{
+				t:          t,
+				client:     "10.0.0.1",
+				server:     "10.0.0.80",
+				clientPort: 40000,
+				serverPort: 80,
+				clientSeq:  1000,
+				serverSeq:  5000,
+			}
+			data := []byte("0123456789")
+
+			now := 0
+			write := func(pkt []byte, delay int) {
+				now += delay
+				require.NoError(t, p.Write(newTestEvent(now), pkt))
+			}
+
+			write(conn.packet(true, "S", nil), 0)
+			write(conn.packet(false, "SA", nil), ms)
+			write(conn.packet(true, "A", nil), ms)
+
+			// second segment seen before the first one
+			first := conn.packet(true, "PA", data)
+			write(conn.packet(true, "PA", data), ms)
+			write(first, tc.delay*ms)
+			write(conn.packet(false, "A", nil), ms)
+
+			write(conn.packet(true, "FA", nil), ms)
+			write(conn.packet(false, "FA", nil), ms)
+			write(conn.packet(true, "A", nil), ms)
+			require.NoError(t, p.Destroy())
+
+			summaries := readTestStatsComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+			require.Len(t, summaries, 1)
+			require.Contains(t, summaries[0], tc.summary)
+			if tc.window > 0 && tc.delay*ms <= int(tc.window) {
+				require.NotContains(t, summaries[0], "seq_gaps=")
+				require.NotContains(t, summaries[0], "network_loss_bytes=")
+			}
+		})
+	}
+}
+
+func TestPcapsFlowEviction(t *testing.T) {
+	const s = int(1e9)
+
+	testCases := []struct {
+		name      string
+		eviction  string
+		summaries []string // prefixes
+	}{
+		{
+			name:     "lru",
+			eviction: FlowEvictionLRU,
+			summaries: []string{
+				"flow_closed=evicted proto=udp src=10.0.0.1:1000 ",
+				"flow_closed=evicted proto=udp src=10.0.0.1:1002 ",
+				"flow_closed=end proto=udp src=10.0.0.1:1001 ",
+				"flow_closed=end proto=udp src=10.0.0.1:1003 ",
+				"flow_closed=end proto=udp src=10.0.0.1:1004 ",
+			},
+		},
+		{
+			name:     "idle",
+			eviction: FlowEvictionIdle,
+			summaries: []string{
+				"flow_closed=evicted proto=udp src=10.0.0.1:1000 ",
+				"flow_closed=end proto=udp src=10.0.0.1:1001 ",
+				"flow_closed=end proto=udp src=10.0.0.1:1002 ",
+				"flow_closed=end proto=udp src=10.0.0.1:1003 ",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, dir := newTestPcaps(t, config.PcapsConfig{
+				CaptureSingle: true,
+				Flows:         true,
+				MaxFlows:      3,
+				FlowEviction:  tc.eviction,
+			})
+
+			write := func(ts int, srcPort uint16) {
+				pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", srcPort, 53, nil)
+				require.NoError(t, p.Write(newTestEvent(ts), pkt))
+			}
+
+			// fills the table (flow 1000 is the least recently active)
+			write(0, 1000)
+			write(1, 1001)
+			write(2, 1002)
+			write(100*s, 1002)
+			write(100*s+1, 1001)
+
+			// flow 1000 is idle: evicted (by both policies)
+			write(125*s, 1003)
+			// no idle flows: the least recently active (1002) is only
+			// evicted by lru (idle doesn't track the new flow)
+			write(125*s+1, 1004)
+			write(125*s+2, 1004)
+			require.NoError(t, p.Destroy())
+
+			summaries := readTestStatsComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+			require.Len(t, summaries, len(tc.summaries))
+			for i, prefix := range tc.summaries {
+				require.True(t, strings.HasPrefix(summaries[i], prefix), summaries[i])
+			}
+		})
+	}
+}
+
+func TestPcapsFlowIDs(t *testing.T) {
+	cfg := config.PcapsConfig{CaptureSingle: true, Flows: true, FlowIDs: true}
+
+	request := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("request"))
+	response := newTestUDPPacket(t, "10.0.0.2", "10.0.0.1", 53, 1234, []byte("response"))
+	other := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1235, 53, []byte("request"))
+
+	// captures the given packets in a new capture instance, returning the flow
+	// ids recorded in the packets metadata and in the flow summaries
+	run := func(start int, pkts ...[]byte) ([]string, []string) {
+		p, dir := newTestPcaps(t, cfg)
+		for i, pkt := range pkts {
+			require.NoError(t, p.Write(newTestEvent(start+i*1000), pkt))
+		}
+		require.NoError(t, p.Destroy())
+
+		path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+		var ids, summaryIDs []string
+		for _, comments := range readTestPcapComments(t, path) {
+			require.Len(t, comments, 1)
+			id, ok := strings.CutPrefix(comments[0], "flow_id=")
+			require.True(t, ok, comments[0])
+			ids = append(ids, id)
+		}
+		for _, summary := range readTestStatsComments(t, path) {
+			_, id, ok := strings.Cut(summary, " flow_id=")
+			require.True(t, ok, summary)
+			summaryIDs = append(summaryIDs, strings.Fields(id)[0])
+		}
+		return ids, summaryIDs
+	}
+
+	ids, summaryIDs := run(1000000, request, response)
+	require.Len(t, ids[0], 16)
+	require.Equal(t, ids[0], ids[1]) // both directions
+	require.Equal(t, []string{ids[0]}, summaryIDs)
+
+	// stable for identical flow inputs across runs
+	rerunIDs, rerunSummaryIDs := run(1000000, request, response)
+	require.Equal(t, ids, rerunIDs)
+	require.Equal(t, summaryIDs, rerunSummaryIDs)
+
+	// different for another start time or 5-tuple
+	laterIDs, _ := run(2000000, request)
+	require.NotEqual(t, ids[0], laterIDs[0])
+	otherIDs, _ := run(1000000, other)
+	require.NotEqual(t, ids[0], otherIDs[0])
+}
+
+func TestPcapsOnFlowOver(t *testing.T) {
+	const s = int(1e9)
+
+	p, _ := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true})
+
+	var over []FlowSummary
+	p.OnFlowOver(func(s FlowSummary) {
+		over = append(over, s)
+	})
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+	tcp := [][]byte{
+		conn.packet(true, "S", nil),
+		conn.packet(false, "SA", nil),
+		conn.packet(true, "A", nil),
+		conn.packet(true, "FA", nil),
+		conn.packet(false, "FA", nil),
+		conn.packet(true, "A", nil),
+	}
+	var tcpBytes uint64
+	for i, pkt := range tcp {
+		require.NoError(t, p.Write(newTestEvent(1000+i), pkt))
+		tcpBytes += uint64(len(pkt))
+	}
+	require.Len(t, over, 1) // over on its FIN (without waiting for the end)
+
+	udp := newTestUDPPacket(t, "10.0.0.1", "10.0.0.53", 40001, 53, nil)
+	require.NoError(t, p.Write(newTestEvent(2*s), udp))
+	require.NoError(t, p.Write(newTestEvent(3*s), udp))
+	// idle for longer than the idle timeout: over once idle flows are swept
+	require.NoError(t, p.Write(newTestEvent(200*s), newTestUDPPacket(t, "10.0.0.1", "10.0.0.53", 40002, 53, nil)))
+	require.Len(t, over, 2)
+	require.NoError(t, p.Destroy())
+	require.Len(t, over, 3)
+
+	require.Equal(t, FlowSummary{
+		Event:    over[0].Event,
+		Src:      "10.0.0.1:40000",
+		Dst:      "10.0.0.80:80",
+		Protocol: "tcp",
+		Packets:  uint64(len(tcp)),
+		Bytes:    tcpBytes,
+		First:    1000,
+		Last:     1000 + int64(len(tcp)) - 1,
+		Reason:   "fin",
+	}, over[0])
+	require.Equal(t, 1000, over[0].Event.Timestamp) // first packet context
+
+	require.Equal(t, "10.0.0.1:40001", over[1].Src)
+	require.Equal(t, "udp", over[1].Protocol)
+	require.Equal(t, uint64(2), over[1].Packets)
+	require.Equal(t, int64(2*s), over[1].First)
+	require.Equal(t, int64(3*s), over[1].Last)
+	require.Equal(t, "idle", over[1].Reason)
+
+	require.Equal(t, "10.0.0.1:40002", over[2].Src)
+	require.Equal(t, "end", over[2].Reason)
+}
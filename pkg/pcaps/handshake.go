@@ -0,0 +1,105 @@
+package pcaps
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket/layers"
+)
+
+//
+// The TCP three-way handshake of flows is timed, for connection setup latency
+// SLOs: the time from the (first) SYN of the initiator to the SYN-ACK of the
+// responder, and from it to the ACK of the initiator completing the handshake.
+// Handshakes are recorded in flow summaries, either complete or as:
+//
+// - no_reply: SYN(s) with no reply (the flow was over before any SYN-ACK).
+// - refused: SYN(s) answered by a RST.
+// - half_open: SYN-ACK with no ACK completing the handshake.
+//
+// Flows whose first captured packet is not a SYN (e.g. started before the
+// capture) have no handshake. Retransmitted SYNs are counted, and the
+// handshake is timed from the first one (the setup latency the initiator
+// observes).
+//
+
+// handshake states
+const (
+	handshakeNone = iota // no SYN seen
+	handshakeSyn
+	handshakeSynAck
+	handshakeComplete
+	handshakeRefused
+)
+
+// flowHandshake is the TCP handshake of a flow.
+type flowHandshake struct {
+	state  int
+	syn    int64  // first SYN (from the initiator)
+	synAck int64  // first SYN-ACK (from the responder)
+	ack    int64  // ACK completing the handshake (from the initiator)
+	syns   uint64 // SYNs sent (retransmissions included)
+}
+
+// segment tracks a TCP segment sent in the given direction, and returns true
+// if it completes the handshake.
+func (h *flowHandshake) segment(ts int64, tcp *layers.TCP, dir int) bool {
+	switch {
+	case dir == 0 && tcp.SYN && !tcp.ACK:
+		if h.state == handshakeNone {
+			h.state = handshakeSyn
+			h.syn = ts
+		}
+		if h.state == handshakeSyn {
+			h.syns++
+		}
+	case dir == 1 && tcp.SYN && tcp.ACK:
+		if h.state == handshakeSyn {
+			h.state = handshakeSynAck
+			h.synAck = ts
+		}
+	case dir == 1 && tcp.RST:
+		if h.state == handshakeSyn {
+			h.state = handshakeRefused
+		}
+	case dir == 0 && tcp.ACK && !tcp.SYN && !tcp.RST:
+		if h.state == handshakeSynAck {
+			h.state = handshakeComplete
+			h.ack = ts
+			return true
+		}
+	}
+
+	return false
+}
+
+// duration returns the time (nanoseconds) the complete handshake took.
+func (h *flowHandshake) duration() int64 {
+	return h.ack - h.syn
+}
+
+// comment returns the handshake as "key=value" pairs of a flow summary (empty
+// if there was no handshake).
+func (h *flowHandshake) comment() string {
+	var comment string
+
+	switch h.state {
+	case handshakeNone:
+		return ""
+	case handshakeSyn:
+		comment = " handshake=no_reply"
+	case handshakeRefused:
+		comment = " handshake=refused"
+	case handshakeSynAck:
+		comment = fmt.Sprintf(" handshake=half_open syn_synack_us=%d", (h.synAck-h.syn)/1e3)
+	case handshakeComplete:
+		comment = fmt.Sprintf(
+			" handshake=complete handshake_us=%d syn_synack_us=%d synack_ack_us=%d",
+			h.duration()/1e3, (h.synAck-h.syn)/1e3, (h.ack-h.synAck)/1e3,
+		)
+	}
+	if h.syns > 1 {
+		comment += fmt.Sprintf(" syn_retries=%d", h.syns-1)
+	}
+
+	return comment
+}
@@ -0,0 +1,117 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+)
+
+func TestPcapsFlowHandshake(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true, FlowHandshakes: true})
+
+	var handshakes metrics.Histogram
+	p.ObserveHandshakes(&handshakes)
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+
+	const ms = 1000000
+	packets := []struct {
+		ts  int
+		pkt []byte
+	}{
+		{0, conn.packet(true, "S", nil)},
+		{10 * ms, conn.packet(false, "SA", nil)},
+		{12 * ms, conn.packet(true, "A", nil)},
+		{13 * ms, conn.packet(true, "PA", []byte("data"))},
+		{23 * ms, conn.packet(false, "A", nil)},
+		{24 * ms, conn.packet(true, "R", nil)},
+	}
+	for _, packet := range packets {
+		require.NoError(t, p.Write(newTestEvent(packet.ts), packet.pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	summaries := readTestStatsComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Len(t, summaries, 1)
+	require.True(t, strings.HasSuffix(summaries[0],
+		" handshake=complete handshake_us=12000 syn_synack_us=10000 synack_ack_us=2000"), summaries[0])
+
+	require.Equal(t, uint64(1), handshakes.Count())
+	require.Equal(t, 12*time.Millisecond, handshakes.Average())
+}
+
+func TestFlowHandshake(t *testing.T) {
+	t.Parallel()
+
+	syn := &layers.TCP{SYN: true}
+	synAck := &layers.TCP{SYN: true, ACK: true}
+	ack := &layers.TCP{ACK: true}
+	rst := &layers.TCP{RST: true, ACK: true}
+
+	type segment struct {
+		ts  int64
+		tcp *layers.TCP
+		dir int
+	}
+
+	testCases := []struct {
+		name     string
+		segments []segment
+		expected string
+	}{
+		{
+			name:     "no handshake",
+			segments: []segment{{0, ack, 0}, {1e6, ack, 1}},
+			expected: "",
+		},
+		{
+			name:     "no reply",
+			segments: []segment{{0, syn, 0}, {1e9, syn, 0}, {3e9, syn, 0}},
+			expected: " handshake=no_reply syn_retries=2",
+		},
+		{
+			name:     "refused",
+			segments: []segment{{0, syn, 0}, {1e6, rst, 1}},
+			expected: " handshake=refused",
+		},
+		{
+			name:     "half open",
+			segments: []segment{{0, syn, 0}, {5e6, synAck, 1}, {6e6, synAck, 1}},
+			expected: " handshake=half_open syn_synack_us=5000",
+		},
+		{
+			name:     "syn retransmitted",
+			segments: []segment{{0, syn, 0}, {1e9, syn, 0}, {1e9 + 5e6, synAck, 1}, {1e9 + 6e6, ack, 0}},
+			expected: " handshake=complete handshake_us=1006000 syn_synack_us=1005000 synack_ack_us=1000 syn_retries=1",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var h flowHandshake
+			for _, s := range tc.segments {
+				h.segment(s.ts, s.tcp, s.dir)
+			}
+			require.Equal(t, tc.expected, h.comment())
+		})
+	}
+}
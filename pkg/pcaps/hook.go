@@ -0,0 +1,111 @@
+package pcaps
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+//
+// Pcap files might be handed over to other tools (e.g. processing pipelines)
+// by hook commands: the open hook runs when a pcap file is opened for the first
+// time in a capture session (a new target, or a rotated file), and the close
+// hook runs once it is finalized (rotated, or when the session ends), after it
+// is compressed (if enabled). Files closed, and reopened, by the LRU cache do
+// not run hooks.
+//
+// Commands run asynchronously (capture never waits for them) by the shell, with
+// the pcap file path and its capture target as arguments ($1 and $2), also
+// given in the environment (TRACEE_PCAP_FILE and TRACEE_PCAP_TARGET, with
+// TRACEE_PCAP_HOOK set to open or close). Commands still running after the
+// hook timeout are killed, and failing ones are logged. Commands still running
+// when capture is destroyed are waited for.
+//
+
+const (
+	hookOpen  = "open"
+	hookClose = "close"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// fileHooks runs the hook commands of pcap files.
+type fileHooks struct {
+	open    string         // command run when a pcap file is opened (if set)
+	close   string         // command run when a pcap file is finalized (if set)
+	timeout time.Duration  // commands running longer are killed
+	running sync.WaitGroup // commands still running
+}
+
+func newFileHooks(open string, close string, timeout time.Duration) *fileHooks {
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	return &fileHooks{
+		open:    open,
+		close:   close,
+		timeout: timeout,
+	}
+}
+
+// opened runs the open hook (if any) of a pcap file of the given target.
+func (h *fileHooks) opened(path string, target string) {
+	if h.open != "" {
+		h.run(hookOpen, h.open, path, target)
+	}
+}
+
+// closed runs the close hook (if any) of a pcap file of the given target.
+func (h *fileHooks) closed(path string, target string) {
+	if h.close != "" {
+		h.run(hookClose, h.close, path, target)
+	}
+}
+
+// run runs a hook command, in the background, for a pcap file (relative to
+// output dir, unless absolute).
+func (h *fileHooks) run(hook string, command string, path string, target string) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(outputDirectory.Name(), path)
+	}
+
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command, "sh", path, target)
+		cmd.Env = append(os.Environ(),
+			"TRACEE_PCAP_HOOK="+hook,
+			"TRACEE_PCAP_FILE="+path,
+			"TRACEE_PCAP_TARGET="+target,
+		)
+		cmd.WaitDelay = time.Second // children of killed commands might hold the output
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			logger.Errorw("Running pcap hook",
+				"hook", hook,
+				"filename", path,
+				"output", strings.TrimSpace(string(output)),
+				"error", err,
+			)
+			return
+		}
+		logger.Debugw("pcap hook run", "hook", hook, "filename", path)
+	}()
+}
+
+// wait waits for all running hook commands.
+func (h *fileHooks) wait() {
+	h.running.Wait()
+}
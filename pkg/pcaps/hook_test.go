@@ -0,0 +1,73 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsHooks(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "hooks.log")
+	command := `echo "$TRACEE_PCAP_HOOK $1 $2" >> ` + log
+
+	cfg := config.PcapsConfig{
+		CaptureContainer: true,
+		RotateInterval:   time.Second,
+		OpenHook:         command,
+		CloseHook:        command,
+	}
+	p, dir := newTestPcaps(t, cfg)
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	write := func(ts time.Duration) {
+		event := newTestEvent(int(time.Unix(1700000000, 0).Add(ts).UnixNano()))
+		event.Container.ID = "abc"
+		require.NoError(t, p.Write(event, pkt))
+	}
+
+	write(0)
+	write(500 * time.Millisecond)
+	write(1200 * time.Millisecond)  // rotated: the first file is finalized
+	require.NoError(t, p.Destroy()) // waits for running hooks
+
+	contents, err := os.ReadFile(log)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	sort.Strings(lines) // hooks run concurrently
+
+	first := filepath.Join(dir, pcapContDir, "abc.pcap")
+	rotated := filepath.Join(dir, pcapContDir, "abc.20231114T221321.200000Z.pcap")
+	require.Equal(t, []string{
+		"close " + rotated + " container:abc",
+		"close " + first + " container:abc",
+		"open " + rotated + " container:abc",
+		"open " + first + " container:abc",
+	}, lines)
+}
+
+func TestPcapsHooksCompressed(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "hooks.log")
+
+	cfg := config.PcapsConfig{
+		CaptureSingle: true,
+		Compress:      CompressGzip,
+		CloseHook:     `echo "$TRACEE_PCAP_FILE" >> ` + log,
+	}
+	p, dir := newTestPcaps(t, cfg)
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	require.NoError(t, p.Write(newTestEvent(1700000000000000000), pkt))
+	require.NoError(t, p.Destroy())
+
+	// the close hook is given the compressed file
+	contents, err := os.ReadFile(log)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, pcapSingleDir, "single.pcap.gz")+"\n", string(contents))
+}
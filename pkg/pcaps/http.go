@@ -0,0 +1,202 @@
+package pcaps
+
+import (
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+//
+// HTTP metadata of flows (if enabled): the request line and headers of HTTP/1.x
+// requests, and the status line and headers of their responses, are parsed
+// from TCP segments starting them (lightweight: messages are not reassembled,
+// so only what the first segment of each message carries is recorded). With
+// keep-alive, a connection carries multiple requests: each response is paired
+// with the oldest request not answered yet. Exchanges are recorded in the flow
+// summary (one comment each).
+//
+
+const (
+	httpMaxExchanges = 16 // max HTTP exchanges recorded per flow (others are only counted)
+	httpMaxHeaders   = 8192
+)
+
+// httpExchange is the metadata of an HTTP request and its response.
+type httpExchange struct {
+	method        string
+	host          string
+	path          string
+	userAgent     string
+	status        int   // 0 if not answered (yet)
+	contentLength int64 // of the response (-1 if unknown)
+}
+
+// httpFlow is the HTTP metadata of a flow.
+type httpFlow struct {
+	exchanges []*httpExchange
+	requests  uint64
+	answered  uint64
+}
+
+// request accounts an HTTP request (a segment from the initiator).
+func (h *httpFlow) request(payload []byte) {
+	exchange, ok := parseHTTPRequest(payload)
+	if !ok {
+		return
+	}
+	h.requests++
+	if len(h.exchanges) < httpMaxExchanges {
+		h.exchanges = append(h.exchanges, exchange)
+	}
+}
+
+// response accounts an HTTP response (a segment from the responder).
+func (h *httpFlow) response(payload []byte) {
+	status, contentLength, ok := parseHTTPResponse(payload)
+	if !ok || h.answered >= h.requests {
+		return
+	}
+	if h.answered < uint64(len(h.exchanges)) {
+		exchange := h.exchanges[h.answered]
+		exchange.status = status
+		exchange.contentLength = contentLength
+	}
+	h.answered++
+}
+
+// comments returns the recorded exchanges as pcapng comments.
+func (h *httpFlow) comments() []string {
+	comments := make([]string, 0, len(h.exchanges))
+	for i, e := range h.exchanges {
+		comment := fmt.Sprintf(
+			"http_request=%d/%d method=%s host=%s path=%s user_agent=%s",
+			i+1, h.requests, e.method, e.host, e.path, strconv.Quote(e.userAgent),
+		)
+		if e.status != 0 {
+			comment += fmt.Sprintf(" status=%d", e.status)
+			if e.contentLength >= 0 {
+				comment += fmt.Sprintf(" content_length=%d", e.contentLength)
+			}
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments
+}
+
+// parseHTTPRequest parses the request line and headers of an HTTP/1.x request.
+func parseHTTPRequest(payload []byte) (*httpExchange, bool) {
+	if !isHTTPRequest(payload) {
+		return nil, false
+	}
+	line, headers := parseHTTPHead(payload)
+	parts := strings.Split(line, " ")
+
+	return &httpExchange{
+		method:        parts[0],
+		path:          parts[1],
+		host:          headers.Get("Host"),
+		userAgent:     headers.Get("User-Agent"),
+		contentLength: -1,
+	}, true
+}
+
+// httpMethods are the methods HTTP/1.x requests are told by.
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+
+// HTTPRequest holds what the head of an HTTP/1.x request tells about it.
+type HTTPRequest struct {
+	Method   string
+	Host     string // Host header (empty if none, or not carried yet)
+	Path     string
+	Complete bool // the whole head (request line and headers) was parsed
+}
+
+// MaybeHTTPRequest returns false if the given data can't start an HTTP/1.x
+// request (it does not start with a request method, or its request line is
+// not an HTTP/1.x one), so non-HTTP data is told without waiting for more.
+func MaybeHTTPRequest(data []byte) bool {
+	if bytes.IndexByte(data, '\n') >= 0 {
+		return isHTTPRequest(data)
+	}
+	for _, method := range httpMethods {
+		prefix := method + " "
+		if len(data) < len(prefix) {
+			if strings.HasPrefix(prefix, string(data)) {
+				return true
+			}
+			continue
+		}
+		if bytes.HasPrefix(data, []byte(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseHTTPRequest parses the head of an HTTP/1.x request at the start of the
+// given data (the payload of the TCP segments carrying it, in order). It
+// returns false until the request line is complete: headers are then parsed
+// as far as carried by the data.
+func ParseHTTPRequest(data []byte) (HTTPRequest, bool) {
+	exchange, ok := parseHTTPRequest(data)
+	if !ok {
+		return HTTPRequest{}, false
+	}
+
+	return HTTPRequest{
+		Method:   exchange.method,
+		Host:     exchange.host,
+		Path:     exchange.path,
+		Complete: bytes.Contains(data, []byte("\r\n\r\n")) || bytes.Contains(data, []byte("\n\n")),
+	}, true
+}
+
+// parseHTTPResponse parses the status line and headers of an HTTP/1.x response.
+func parseHTTPResponse(payload []byte) (int, int64, bool) {
+	if !bytes.HasPrefix(payload, []byte("HTTP/1.")) {
+		return 0, 0, false
+	}
+	line, headers := parseHTTPHead(payload)
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	status, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	contentLength, err := strconv.ParseInt(headers.Get("Content-Length"), 10, 64)
+	if err != nil {
+		contentLength = -1
+	}
+
+	return status, contentLength, true
+}
+
+// parseHTTPHead returns the first line and the headers (those fully carried
+// by the payload) of an HTTP message.
+func parseHTTPHead(payload []byte) (string, textproto.MIMEHeader) {
+	if len(payload) > httpMaxHeaders {
+		payload = payload[:httpMaxHeaders]
+	}
+	lines := strings.Split(string(payload), "\n")
+
+	headers := make(textproto.MIMEHeader)
+	for _, line := range lines[1 : len(lines)-1] { // the last one might be cut
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break // end of headers
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		headers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	return strings.TrimRight(lines[0], "\r"), headers
+}
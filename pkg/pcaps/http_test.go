@@ -0,0 +1,119 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsFlowHTTP(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true, FlowHTTP: true})
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+
+	request := "GET /index.html HTTP/1.1\r\nHost: example.com\r\nUser-Agent: curl/8.5.0 (x86_64)\r\n\r\n"
+	response := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 5\r\n\r\nhello"
+	// keep-alive: a second request on the same connection
+	post := "POST /api HTTP/1.1\r\nHost: example.com\r\nContent-Length: 2\r\n\r\n{}"
+	created := "HTTP/1.1 201 Created\r\n\r\n"
+
+	var packets [][]byte
+	packets = append(packets,
+		conn.packet(true, "S", nil),
+		conn.packet(false, "SA", nil),
+		conn.packet(true, "A", nil),
+		conn.packet(true, "PA", []byte(request)),
+		conn.packet(false, "PA", []byte(response)),
+	)
+	packets = append(packets, conn.packet(true, "PA", []byte(post)))
+	// retransmitted request (not parsed again)
+	conn.clientSeq -= uint32(len(post))
+	packets = append(packets, conn.packet(true, "PA", []byte(post)))
+	packets = append(packets,
+		conn.packet(false, "PA", []byte(created)),
+		conn.packet(true, "FA", nil),
+		conn.packet(false, "FA", nil),
+		conn.packet(true, "A", nil),
+	)
+	for i, pkt := range packets {
+		require.NoError(t, p.Write(newTestEvent(i), pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	comments := readTestStatsComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Len(t, comments, 3)
+	require.True(t, strings.HasPrefix(comments[0], "flow_closed=fin proto=tcp "), comments[0])
+	require.Equal(t, []string{
+		`http_request=1/2 method=GET host=example.com path=/index.html user_agent="curl/8.5.0 (x86_64)" status=200 content_length=5`,
+		`http_request=2/2 method=POST host=example.com path=/api user_agent="" status=201`,
+	}, comments[1:])
+}
+
+func TestParseHTTPHead(t *testing.T) {
+	t.Parallel()
+
+	// headers cut by the end of the segment are ignored
+	line, headers := parseHTTPHead([]byte("GET / HTTP/1.1\r\nHost: a.example\r\nUser-Agent: cu"))
+	require.Equal(t, "GET / HTTP/1.1", line)
+	require.Equal(t, "a.example", headers.Get("Host"))
+	require.Empty(t, headers.Get("User-Agent"))
+
+	_, _, ok := parseHTTPResponse([]byte("HTTP/1.1 abc\r\n\r\n"))
+	require.False(t, ok)
+	_, ok = parseHTTPRequest([]byte("\x16\x03\x01 not http"))
+	require.False(t, ok)
+}
+
+func TestParseHTTPRequest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		data    string
+		maybe   bool
+		ok      bool
+		request HTTPRequest
+	}{
+		{data: "GE", maybe: true},
+		{data: "POST /upl", maybe: true},
+		{data: "\x16\x03\x01\x02\x00", maybe: false},
+		{data: "SSH-2.0-OpenSSH_9.6\r\n", maybe: false},
+		{data: "GET / FTP/1.0\r\n", maybe: false},
+		{
+			data:    "GET /index.html HTTP/1.1\r\nUser-Agent: curl\r\nHo",
+			maybe:   true,
+			ok:      true,
+			request: HTTPRequest{Method: "GET", Path: "/index.html"},
+		},
+		{
+			data:    "GET /index.html HTTP/1.1\r\nHost: example.com\r\n",
+			maybe:   true,
+			ok:      true,
+			request: HTTPRequest{Method: "GET", Host: "example.com", Path: "/index.html"},
+		},
+		{
+			data:    "DELETE /items/1 HTTP/1.0\r\nHost: api.example\r\n\r\n",
+			maybe:   true,
+			ok:      true,
+			request: HTTPRequest{Method: "DELETE", Host: "api.example", Path: "/items/1", Complete: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.maybe, MaybeHTTPRequest([]byte(tc.data)), tc.data)
+		request, ok := ParseHTTPRequest([]byte(tc.data))
+		require.Equal(t, tc.ok, ok, tc.data)
+		require.Equal(t, tc.request, request, tc.data)
+	}
+}
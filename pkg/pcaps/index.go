@@ -0,0 +1,148 @@
+package pcaps
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// The index is a single append-only file, shared by all capture sessions using
+// the same output directory, holding one JSON line per written packet. Each
+// line locates the packet (pcap file and the offset of its block within the
+// file) and describes it (timestamp, 5-tuple and capture target), so finding
+// the files containing a given IP, port or time range only requires scanning
+// the (small) index instead of parsing all the pcap files.
+//
+
+const pcapIndexFile string = pcapDir + "index.jsonl"
+
+// IndexEntry locates a single packet written to a pcap file.
+type IndexEntry struct {
+	Timestamp int64  `json:"ts"` // packet timestamp (nanoseconds since epoch)
+	SrcIP     string `json:"src"`
+	DstIP     string `json:"dst"`
+	SrcPort   uint16 `json:"sport,omitempty"`
+	DstPort   uint16 `json:"dport,omitempty"`
+	Protocol  uint8  `json:"proto"`
+	Target    string `json:"target"` // capture target (e.g. "process:1234")
+	File      string `json:"file"`   // pcap file (relative to output dir, unless absolute)
+	Offset    int64  `json:"offset"` // offset of the packet block in the file
+}
+
+// IndexQuery selects entries from the index. Zero valued fields match all.
+type IndexQuery struct {
+	From     time.Time
+	To       time.Time
+	IP       net.IP // matches source or destination
+	Port     uint16 // matches source or destination
+	Protocol uint8
+	Target   string
+}
+
+func (q *IndexQuery) match(e *IndexEntry) bool {
+	if !q.From.IsZero() && e.Timestamp < q.From.UnixNano() {
+		return false
+	}
+	if !q.To.IsZero() && e.Timestamp > q.To.UnixNano() {
+		return false
+	}
+	if q.IP != nil {
+		ip := q.IP.String()
+		if e.SrcIP != ip && e.DstIP != ip {
+			return false
+		}
+	}
+	if q.Port != 0 && e.SrcPort != q.Port && e.DstPort != q.Port {
+		return false
+	}
+	if q.Protocol != 0 && e.Protocol != q.Protocol {
+		return false
+	}
+	if q.Target != "" && e.Target != q.Target {
+		return false
+	}
+
+	return true
+}
+
+// pcapIndex appends entries to the index file.
+type pcapIndex struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newPcapIndex(output *os.File) (*pcapIndex, error) {
+	err := utils.MkdirAtExist(output, pcapDir, os.ModePerm)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	file, err := utils.OpenAt(
+		output,
+		pcapIndexFile,
+		os.O_APPEND|os.O_WRONLY|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	return &pcapIndex{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// add indexes a packet written to the given pcap at the given offset.
+func (i *pcapIndex) add(ts int64, info *packetInfo, target string, item *Pcap, offset int64) error {
+	entry := IndexEntry{
+		Timestamp: ts,
+		SrcPort:   info.srcPort,
+		DstPort:   info.dstPort,
+		Protocol:  uint8(info.protocol),
+		Target:    target,
+		File:      item.pcapPath,
+		Offset:    offset,
+	}
+	if info.srcIP != nil {
+		entry.SrcIP = info.srcIP.String()
+		entry.DstIP = info.dstIP.String()
+	}
+
+	return errfmt.WrapError(i.encoder.Encode(entry))
+}
+
+func (i *pcapIndex) close() error {
+	return i.file.Close()
+}
+
+// QueryIndex returns all entries, from the given index file, matching query.
+func QueryIndex(indexPath string, query IndexQuery) ([]IndexEntry, error) {
+	file, err := os.Open(indexPath)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var entries []IndexEntry
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry IndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errfmt.Errorf("corrupted index entry: %v", err)
+		}
+		if query.match(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, errfmt.WrapError(scanner.Err())
+}
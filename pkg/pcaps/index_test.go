@@ -0,0 +1,61 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestQueryIndex(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:  true,
+		CaptureProcess: true,
+		Index:          true,
+	})
+
+	dns := newTestUDPPacket(t, "10.0.0.1", "8.8.8.8", 4000, 53, []byte("query"))
+	other := newTestUDPPacket(t, "10.0.0.1", "10.0.0.3", 4001, 9999, []byte("other"))
+
+	require.NoError(t, p.Write(newTestEvent(1000), other))
+	require.NoError(t, p.Write(newTestEvent(2000), dns))
+	require.NoError(t, p.Write(newTestEvent(3000), other))
+	require.NoError(t, p.Destroy())
+
+	indexPath := filepath.Join(dir, pcapIndexFile)
+
+	entries, err := QueryIndex(indexPath, IndexQuery{IP: net.ParseIP("8.8.8.8"), Port: 53})
+	require.NoError(t, err)
+	require.Len(t, entries, 2) // single and process files
+
+	targets := []string{entries[0].Target, entries[1].Target}
+	require.ElementsMatch(t, []string{"single", "process:1000"}, targets)
+
+	for _, entry := range entries {
+		require.Equal(t, int64(2000), entry.Timestamp)
+		require.Equal(t, "10.0.0.1", entry.SrcIP)
+		require.Equal(t, uint16(4000), entry.SrcPort)
+
+		// the offset points to the enhanced packet block holding the packet
+		data, err := os.ReadFile(filepath.Join(dir, entry.File))
+		require.NoError(t, err)
+		block := data[entry.Offset:]
+		require.Equal(t, uint32(0x00000006), binary.LittleEndian.Uint32(block[0:4]))
+		require.Equal(t, dns, block[28:28+len(dns)])
+	}
+
+	// time range queries
+	entries, err = QueryIndex(indexPath, IndexQuery{
+		From:   time.Unix(0, 2500),
+		Target: "single",
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, int64(3000), entries[0].Timestamp)
+}
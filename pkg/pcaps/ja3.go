@@ -0,0 +1,331 @@
+package pcaps
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+//
+// TLS hellos might be fingerprinted for threat intel matching: JA3 for the
+// ClientHello and JA3S for the ServerHello (MD5 hashes of the hello version,
+// cipher suites, extensions and, for JA3, supported groups and point formats,
+// GREASE values excluded). Fingerprints are recorded as metadata of the hello
+// packets (tls_ja3 and tls_ja3s comments) and, if flows are tracked, in the
+// flow summaries.
+//
+// TLS flows might be filtered by their fingerprints (needs flow tracking):
+//
+// - deny: packets of a flow are not captured from the hello with a denied
+//   fingerprint (JA3 or JA3S) on.
+// - allow: packets of a flow are withheld until its hellos tell whether the
+//   flow has an allowed fingerprint (JA3 or JA3S): if so, withheld packets are
+//   written (up to flowMaxBackfill of the last ones) and so are the next ones.
+//   Otherwise (including flows that are not TLS, or whose hellos can't be
+//   fingerprinted) nothing of the flow is captured.
+//
+// Packets not tracked in a flow (not IP, or the flow table is full) are
+// written as usual.
+//
+// NOTE: Hellos are only fingerprinted if the whole message is held by a
+//       single captured TCP segment (with a big enough snaplen). ClientHellos
+//       split over several segments (e.g. carrying big post-quantum key
+//       shares) are not fingerprinted.
+//
+
+const (
+	tlsHandshakeClientHello = 1
+	tlsExtSupportedGroups   = 10
+	tlsExtECPointFormats    = 11
+)
+
+// fingerprint filter verdicts of a flow
+const (
+	fingerprintPending = iota // hellos not seen yet
+	fingerprintAllowed
+	fingerprintDenied
+)
+
+// ParseFingerprints parses a comma separated list of JA3 (or JA3S) hashes.
+func ParseFingerprints(list string) ([]string, error) {
+	var fingerprints []string
+
+	for _, field := range strings.Split(list, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if decoded, err := hex.DecodeString(field); err != nil || len(decoded) != md5.Size {
+			return nil, errfmt.Errorf("invalid ja3 fingerprint (md5 hash): %s", field)
+		}
+		fingerprints = append(fingerprints, field)
+	}
+
+	return fingerprints, nil
+}
+
+// isTLSGrease returns true if the given value is a GREASE value (RFC 8701),
+// ignored by fingerprints.
+func isTLSGrease(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// tlsHandshakeMessage returns the body of the TLS handshake message of the
+// given type at the start of a TCP segment payload, if the whole message was
+// captured.
+func tlsHandshakeMessage(payload []byte, handshakeType byte) ([]byte, bool) {
+	// record header: type (1) | version (2) | length (2)
+	if len(payload) < 5 || payload[0] != tlsRecordHandshake || payload[1] != 3 {
+		return nil, false
+	}
+	record := payload[5:]
+	if length := int(binary.BigEndian.Uint16(payload[3:])); len(record) > length {
+		record = record[:length]
+	}
+
+	// handshake header: type (1) | length (3)
+	if len(record) < 4 || record[0] != handshakeType {
+		return nil, false
+	}
+	length := int(record[1])<<16 | int(record[2])<<8 | int(record[3])
+	if len(record[4:]) < length {
+		return nil, false
+	}
+
+	return record[4 : 4+length], true
+}
+
+// tlsExtensions parses the extensions block (length prefixed) at the start of
+// data, calling fn with the type and data of each extension. It returns false
+// if the block is malformed.
+func tlsExtensions(data []byte, fn func(extType uint16, extData []byte)) bool {
+	if len(data) == 0 {
+		return true // no extensions
+	}
+	if len(data) < 2 || len(data[2:]) != int(binary.BigEndian.Uint16(data)) {
+		return false
+	}
+
+	// extension: type (2) | length (2) | data
+	for extensions := data[2:]; len(extensions) > 0; {
+		if len(extensions) < 4 {
+			return false
+		}
+		extLength := int(binary.BigEndian.Uint16(extensions[2:]))
+		if len(extensions) < 4+extLength {
+			return false
+		}
+		fn(binary.BigEndian.Uint16(extensions), extensions[4:4+extLength])
+		extensions = extensions[4+extLength:]
+	}
+
+	return true
+}
+
+// formatJA3Values formats the (non GREASE) values of a fingerprint field.
+func formatJA3Values(values []uint16) string {
+	fields := make([]string, 0, len(values))
+	for _, value := range values {
+		if !isTLSGrease(value) {
+			fields = append(fields, strconv.Itoa(int(value)))
+		}
+	}
+
+	return strings.Join(fields, "-")
+}
+
+// parseUint16List parses a list of uint16 values (with a length prefix of the
+// given size).
+func parseUint16List(data []byte, prefix int) ([]uint16, []byte, bool) {
+	if len(data) < prefix {
+		return nil, nil, false
+	}
+	length := int(data[0])
+	if prefix == 2 {
+		length = int(binary.BigEndian.Uint16(data))
+	}
+	if length%2 != 0 || len(data) < prefix+length {
+		return nil, nil, false
+	}
+	values := make([]uint16, 0, length/2)
+	for i := prefix; i < prefix+length; i += 2 {
+		values = append(values, binary.BigEndian.Uint16(data[i:]))
+	}
+
+	return values, data[prefix+length:], true
+}
+
+// ja3String returns the JA3 string (before hashing) of the TLS ClientHello at
+// the start of a TCP segment payload.
+func ja3String(payload []byte) (string, bool) {
+	body, ok := tlsHandshakeMessage(payload, tlsHandshakeClientHello)
+
+	// body: version (2) | random (32) | session id (1 + n) |
+	// cipher suites (2 + n) | compression methods (1 + n) | extensions (2 + n)
+	if !ok || len(body) < 35 || len(body) < 35+int(body[34]) {
+		return "", false
+	}
+	version := binary.BigEndian.Uint16(body)
+	ciphers, rest, ok := parseUint16List(body[35+int(body[34]):], 2)
+	if !ok || len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return "", false
+	}
+
+	var extensions, groups []uint16
+	var pointFormats []string
+	ok = tlsExtensions(rest[1+int(rest[0]):], func(extType uint16, extData []byte) {
+		extensions = append(extensions, extType)
+		switch extType {
+		case tlsExtSupportedGroups:
+			groups, _, _ = parseUint16List(extData, 2)
+		case tlsExtECPointFormats:
+			if len(extData) > 0 && len(extData) >= 1+int(extData[0]) {
+				for _, format := range extData[1 : 1+int(extData[0])] {
+					pointFormats = append(pointFormats, strconv.Itoa(int(format)))
+				}
+			}
+		}
+	})
+	if !ok {
+		return "", false
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(version)),
+		formatJA3Values(ciphers),
+		formatJA3Values(extensions),
+		formatJA3Values(groups),
+		strings.Join(pointFormats, "-"),
+	}, ","), true
+}
+
+// ja3sString returns the JA3S string (before hashing) of the TLS ServerHello
+// at the start of a TCP segment payload (HelloRetryRequests excluded).
+func ja3sString(payload []byte) (string, bool) {
+	body, ok := tlsHandshakeMessage(payload, tlsHandshakeServerHello)
+
+	// body: version (2) | random (32) | session id (1 + n) | cipher (2) |
+	// compression (1) | extensions (2 + n)
+	if !ok || len(body) < 35 || bytes.Equal(body[2:34], tlsHelloRetryRandom[:]) {
+		return "", false
+	}
+	pos := 35 + int(body[34])
+	if len(body) < pos+3 {
+		return "", false
+	}
+	version := binary.BigEndian.Uint16(body)
+	cipher := binary.BigEndian.Uint16(body[pos:])
+
+	var extensions []uint16
+	ok = tlsExtensions(body[pos+3:], func(extType uint16, _ []byte) {
+		extensions = append(extensions, extType)
+	})
+	if !ok {
+		return "", false
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(version)),
+		strconv.Itoa(int(cipher)),
+		formatJA3Values(extensions),
+	}, ","), true
+}
+
+// ja3Hash returns the fingerprint (MD5 hash) of a JA3 or JA3S string.
+func ja3Hash(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// tlsFingerprints returns the JA3 and JA3S fingerprints of the TLS hello (if
+// any) carried by a packet.
+func tlsFingerprints(info *packetInfo) (ja3 string, ja3s string) {
+	tcp, ok := info.packet.TransportLayer().(*layers.TCP)
+	if !ok || len(tcp.Payload) == 0 {
+		return "", ""
+	}
+	if s, ok := ja3String(tcp.Payload); ok {
+		return ja3Hash(s), ""
+	}
+	if s, ok := ja3sString(tcp.Payload); ok {
+		return "", ja3Hash(s)
+	}
+
+	return "", ""
+}
+
+// tlsFingerprintMetadata returns the metadata describing the fingerprint of
+// the TLS hello (if any) carried by a packet.
+func tlsFingerprintMetadata(info *packetInfo) []string {
+	ja3, ja3s := tlsFingerprints(info)
+	switch {
+	case ja3 != "":
+		return []string{"tls_ja3=" + ja3}
+	case ja3s != "":
+		return []string{"tls_ja3s=" + ja3s}
+	}
+
+	return nil
+}
+
+// fingerprintFilter filters TLS flows by their JA3 and JA3S fingerprints.
+type fingerprintFilter struct {
+	allow map[string]struct{} // nil: all allowed (unless denied)
+	deny  map[string]struct{}
+}
+
+// newFingerprintFilter creates a filter of the given allowed and denied
+// fingerprints (nil if none).
+func newFingerprintFilter(allow []string, deny []string) *fingerprintFilter {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	set := func(fingerprints []string) map[string]struct{} {
+		if len(fingerprints) == 0 {
+			return nil
+		}
+		m := make(map[string]struct{}, len(fingerprints))
+		for _, fingerprint := range fingerprints {
+			m[fingerprint] = struct{}{}
+		}
+		return m
+	}
+
+	return &fingerprintFilter{allow: set(allow), deny: set(deny)}
+}
+
+// matches returns true if the flow has one of the given fingerprints.
+func (ff *fingerprintFilter) matches(f *flow, fingerprints map[string]struct{}) bool {
+	for _, fingerprint := range []string{f.ja3, f.ja3s} {
+		if _, ok := fingerprints[fingerprint]; ok && fingerprint != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verdict returns the filter verdict of a flow, given its hellos seen so far.
+func (ff *fingerprintFilter) verdict(f *flow) int {
+	if ff.matches(f, ff.deny) {
+		return fingerprintDenied
+	}
+	if ff.allow == nil || ff.matches(f, ff.allow) {
+		return fingerprintAllowed
+	}
+
+	// no allowed fingerprint yet: the flow is over (as far as hellos go) once
+	// the responder sent data, or the initiator sent data other than a
+	// fingerprinted ClientHello
+	if f.protocol != layers.IPProtocolTCP || f.dirs[1].payload > 0 ||
+		(f.dirs[0].payload > 0 && f.ja3 == "") {
+		return fingerprintDenied
+	}
+
+	return fingerprintPending
+}
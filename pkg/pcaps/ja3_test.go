@@ -0,0 +1,153 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// testTLSExtension is a TLS hello extension.
+type testTLSExtension struct {
+	extType uint16
+	data    []byte
+}
+
+// newTestTLSHello builds a TLS record holding a hello of the given handshake
+// type: body is the hello up to its extensions (excluded).
+func newTestTLSHello(handshakeType byte, body []byte, extensions []testTLSExtension) []byte {
+	var exts []byte
+	for _, ext := range extensions {
+		exts = binary.BigEndian.AppendUint16(exts, ext.extType)
+		exts = binary.BigEndian.AppendUint16(exts, uint16(len(ext.data)))
+		exts = append(exts, ext.data...)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(exts)))
+	body = append(body, exts...)
+
+	handshake := []byte{handshakeType, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+
+	record := []byte{tlsRecordHandshake, 3, 1}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+
+	return append(record, handshake...)
+}
+
+// newTestClientHello builds a TLS 1.2 (legacy version) ClientHello.
+func newTestClientHello(ciphers []uint16, extensions []testTLSExtension) []byte {
+	body := []byte{3, 3}
+	body = append(body, make([]byte, 32)...) // random
+	body = append(body, 0)                   // session id
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(ciphers)))
+	for _, cipher := range ciphers {
+		body = binary.BigEndian.AppendUint16(body, cipher)
+	}
+	body = append(body, 1, 0) // compression methods: null
+
+	return newTestTLSHello(tlsHandshakeClientHello, body, extensions)
+}
+
+// testClientHelloExtensions are the extensions of the test ClientHello:
+// GREASE, server_name, supported_groups (GREASE, x25519, secp256r1),
+// ec_point_formats (uncompressed) and supported_versions.
+var testClientHelloExtensions = []testTLSExtension{
+	{0x1a1a, nil},
+	{0, []byte{0, 14, 0, 0, 11, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm'}},
+	{tlsExtSupportedGroups, []byte{0, 6, 0x2a, 0x2a, 0, 29, 0, 23}},
+	{tlsExtECPointFormats, []byte{1, 0}},
+	{tlsExtSupportedVersions, []byte{2, 3, 4}},
+}
+
+func TestJA3(t *testing.T) {
+	t.Parallel()
+
+	clientHello := newTestClientHello([]uint16{0x0a0a, 0x1301, 0x1302, 0xc02b}, testClientHelloExtensions)
+
+	s, ok := ja3String(clientHello)
+	require.True(t, ok)
+	require.Equal(t, "771,4865-4866-49195,0-10-11-43,29-23,0", s)
+	require.Equal(t, "fd17f1f9b9c56d4bfda8900c8900ec06", ja3Hash(s))
+
+	// ServerHello: version, random, session id, cipher, compression
+	body := append([]byte{3, 3}, make([]byte, 32)...)
+	body = append(body, 0, 0xc0, 0x2b, 0)
+	serverHello := newTestTLSHello(tlsHandshakeServerHello, body, []testTLSExtension{
+		{0xff01, []byte{0}},
+		{tlsExtECPointFormats, []byte{1, 0}},
+	})
+
+	s, ok = ja3sString(serverHello)
+	require.True(t, ok)
+	require.Equal(t, "771,49195,65281-11", s)
+	require.Equal(t, "f9a66afdd1f499d415ca470974ec00c8", ja3Hash(s))
+
+	// hellos of the other side, truncated or not a hello
+	_, ok = ja3String(serverHello)
+	require.False(t, ok)
+	_, ok = ja3sString(clientHello)
+	require.False(t, ok)
+	_, ok = ja3String(clientHello[:len(clientHello)-1])
+	require.False(t, ok)
+	_, ok = ja3String([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.False(t, ok)
+}
+
+func TestPcapsJA3Filter(t *testing.T) {
+	const allowed = "fd17f1f9b9c56d4bfda8900c8900ec06"
+
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		Flows:         true,
+		JA3:           true,
+		JA3Allow:      []string{allowed},
+	})
+
+	matching := newTestClientHello([]uint16{0x0a0a, 0x1301, 0x1302, 0xc02b}, testClientHelloExtensions)
+	other := newTestClientHello([]uint16{0x1301}, testClientHelloExtensions[1:3])
+
+	var ts int
+	connect := func(clientPort uint16, clientHello []byte) {
+		conn := &testTCPConn{
+			t:          t,
+			client:     "10.0.0.1",
+			server:     "10.0.0.80",
+			clientPort: clientPort,
+			serverPort: 443,
+			clientSeq:  1000,
+			serverSeq:  5000,
+		}
+		for _, pkt := range [][]byte{
+			conn.packet(true, "S", nil),
+			conn.packet(false, "SA", nil),
+			conn.packet(true, "A", nil),
+			conn.packet(true, "PA", clientHello),
+			conn.packet(false, "PA", []byte{0x17, 0x03, 0x03, 0x00, 0x00}), // (fake) server flight
+			conn.packet(true, "A", nil),
+		} {
+			ts++
+			require.NoError(t, p.Write(newTestEvent(ts), pkt))
+		}
+	}
+	connect(40000, matching)
+	connect(40001, other)
+	require.NoError(t, p.Destroy())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+	packets := readTestPcap(t, path)
+	require.Len(t, packets, 6) // the whole matching flow (from its SYN on)
+	for _, packet := range packets {
+		info := newPacketInfo(packet)
+		require.Contains(t, []uint16{info.srcPort, info.dstPort}, uint16(40000))
+	}
+	comments := readTestPcapComments(t, path)
+	require.Contains(t, comments[3], "tls_ja3="+allowed)
+	require.Equal(t, uint64(6), p.Stats().Fingerprinted.Get())
+
+	summaries := readTestStatsComments(t, path)
+	require.Len(t, summaries, 1)
+	require.Contains(t, summaries[0], " tls_ja3="+allowed)
+}
@@ -0,0 +1,66 @@
+package pcaps
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Journal entries logged around the packets of a process might be embedded
+// into its pcap files, as pcapng systemd journal export blocks, so the capture
+// carries the surrounding system events. Each block holds a single entry, in
+// journal export format (KEY=value fields, one per line, followed by an empty
+// line). Readers (such as Wireshark) time the entries by their realtime
+// timestamp field, added when missing.
+//
+
+const journalRealtimeField = "__REALTIME_TIMESTAMP="
+
+// journalEntry returns the given journal entry (journal export format) as
+// embedded at the given time: ended by an empty line, with a realtime
+// timestamp (microseconds) field.
+func journalEntry(ts int64, entry []byte) []byte {
+	entry = bytes.TrimRight(entry, "\n")
+
+	var b []byte
+	if !bytes.HasPrefix(entry, []byte(journalRealtimeField)) &&
+		!bytes.Contains(entry, []byte("\n"+journalRealtimeField)) {
+		b = append(b, journalRealtimeField...)
+		b = strconv.AppendInt(b, ts/1e3, 10)
+		b = append(b, '\n')
+	}
+	b = append(b, entry...)
+
+	return append(b, '\n', '\n')
+}
+
+// WriteJournalEntry embeds a journal entry (journal export format), logged
+// around the packets of the process described by the given event, into all
+// pcap files the packets of that process are written to (as a pcapng systemd
+// journal export block).
+func (p *Pcaps) WriteJournalEntry(event *trace.Event, entry []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.journal || len(bytes.TrimSpace(entry)) == 0 || p.session == nil {
+		return nil
+	}
+
+	block := encodeNgSystemdJournalExport(journalEntry(int64(event.Timestamp), entry))
+
+	for k := range p.pcapCaches {
+		item, err := p.pcapCaches[k].get(event)
+		if err != nil {
+			return errfmt.WrapError(err)
+		}
+		if err := item.writeBlock(block); err != nil {
+			return errfmt.WrapError(err)
+		}
+		p.session.file(item.pcapPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package pcaps
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestJournalEntry(t *testing.T) {
+	t.Parallel()
+
+	// realtime timestamp added (microseconds)
+	entry := journalEntry(2_000_000_000, []byte("MESSAGE=hello\n_PID=1000\n"))
+	require.Equal(t, "__REALTIME_TIMESTAMP=2000000\nMESSAGE=hello\n_PID=1000\n\n", string(entry))
+
+	// realtime timestamp kept
+	entry = journalEntry(2_000_000_000, []byte("MESSAGE=hello\n__REALTIME_TIMESTAMP=42"))
+	require.Equal(t, "MESSAGE=hello\n__REALTIME_TIMESTAMP=42\n\n", string(entry))
+}
+
+func TestWriteJournalEntry(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		Journal:       true,
+	})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+
+	require.NoError(t, p.Write(newTestEvent(1), pkt))
+	require.NoError(t, p.WriteJournalEntry(newTestEvent(2000), []byte("MESSAGE=unit started\n_SYSTEMD_UNIT=app.service\n_PID=1000")))
+	require.NoError(t, p.Write(newTestEvent(3000), pkt))
+	require.NoError(t, p.Destroy())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+
+	var entries []map[string]string
+	var packets int
+	for _, block := range readTestNgBlocks(t, path) {
+		switch block.blockType {
+		case ngBlockTypeSystemdJournalExport:
+			require.Zero(t, len(block.body)%4)
+			entry := bytes.TrimRight(block.body, "\x00") // padding
+			require.True(t, bytes.HasSuffix(entry, []byte("\n\n")))
+
+			fields := make(map[string]string)
+			for _, line := range strings.Split(strings.TrimSuffix(string(entry), "\n\n"), "\n") {
+				key, value, ok := strings.Cut(line, "=")
+				require.True(t, ok)
+				fields[key] = value
+			}
+			entries = append(entries, fields)
+			require.Equal(t, 1, packets) // written in between packets
+		case ngBlockTypeEnhancedPacket:
+			packets++
+		}
+	}
+	require.Equal(t, []map[string]string{
+		{
+			"__REALTIME_TIMESTAMP": "2",
+			"MESSAGE":              "unit started",
+			"_SYSTEMD_UNIT":        "app.service",
+			"_PID":                 "1000",
+		},
+	}, entries)
+	require.Equal(t, 2, packets)
+
+	// files with journal blocks are still readable by regular pcapng readers
+	require.Len(t, readTestPcap(t, path), 2)
+}
@@ -0,0 +1,100 @@
+package pcaps
+
+import (
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// Capture targets producing traffic for a short while only (e.g. scan attempts)
+// might not be worth a file: with a minimum lifetime, the pcap file of a target
+// whose packets span less than it is removed once finalized, along with its
+// sidecar, hash chain and stats files (if any). The span of a target goes from
+// its first to its last packet in the capture session (written or not, e.g.
+// rate limited), across the LRU cache closing and reopening its file.
+//
+// Only files created during the session are removed: files appended to (a
+// target captured in earlier sessions) are always kept.
+//
+// With rotation, the span counts from the first packet of the target (in any
+// of its files): files finalized by rotation are always kept (their target was
+// still producing traffic), and so is the last file of a target outliving the
+// minimum lifetime, however short the file itself.
+//
+// Removed files are dropped from the session manifest, and their close hooks
+// (if any) don't run. The index (if enabled) keeps their entries (it is append
+// only).
+//
+
+// targetLifetime is the span of the packets of a capture target.
+type targetLifetime struct {
+	first int64 // timestamp of the first packet
+	last  int64 // timestamp of the last packet
+}
+
+// packet accounts a packet of the target.
+func (l *targetLifetime) packet(ts int64) {
+	if l.first == 0 || ts < l.first {
+		l.first = ts
+	}
+	if ts > l.last {
+		l.last = ts
+	}
+}
+
+// span returns the time between the first and the last packets of the target.
+func (l *targetLifetime) span() time.Duration {
+	return time.Duration(l.last - l.first)
+}
+
+// shortLived returns true if a finalized pcap file is to be removed: created
+// during the session, and its target packets spanning less than the minimum
+// lifetime (if enabled).
+func (p *PcapCache) shortLived(item *Pcap) bool {
+	return p.config.MinLifetime > 0 &&
+		item.created && !item.rotated && item.lifetime != nil &&
+		item.lifetime.span() < p.config.MinLifetime
+}
+
+// pcapRemoved accounts a pcap file removed (finalized) in the current session,
+// if any, and in the files index (if enabled).
+func (p *Pcaps) pcapRemoved(path string) {
+	if p.session != nil {
+		p.session.removed(path)
+	}
+	if p.filesIndex != nil {
+		if err := p.filesIndex.removed(path); err != nil {
+			logger.Errorw("Writing pcap files index", "error", err)
+		}
+	}
+}
+
+// removePcap removes a finalized pcap file and the files next to it.
+func removePcap(item *Pcap) error {
+	if err := utils.RemoveAt(outputDirectory, item.pcapPath, 0); err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	var companions []string
+	if item.sidecar != nil {
+		companions = append(companions, item.pcapPath+sidecarSuffix)
+	}
+	if item.chain != nil {
+		companions = append(companions, item.pcapPath+chainSuffix)
+	}
+	if item.stats != nil {
+		companions = append(companions, item.pcapPath+statsSuffix)
+	}
+	for _, path := range companions {
+		if err := utils.RemoveAt(outputDirectory, path, 0); err != nil {
+			logger.Debugw("Removing pcap companion file", "filename", path, "error", err)
+		}
+	}
+
+	logger.Debugw("short-lived target pcap file removed", "filename", item.pcapPath)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+func TestPcapsMinLifetime(t *testing.T) {
+	cfg := config.PcapsConfig{CaptureContainer: true, MinLifetime: 2 * time.Second, Sidecar: true}
+	p, dir := newTestPcaps(t, cfg)
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 80, []byte("payload"))
+	write := func(container string, ts time.Duration) {
+		event := newTestEvent(int(time.Unix(1700000000, 0).Add(ts).UnixNano()))
+		event.Container.ID = container
+		require.NoError(t, p.Write(event, pkt))
+	}
+
+	write("scan", 0)
+	write("scan", 500*time.Millisecond) // short-lived target
+	write("long", 0)
+	write("long", 3*time.Second)
+	require.NoError(t, p.Destroy())
+
+	containers := filepath.Join(dir, pcapContDir)
+	require.NoFileExists(t, filepath.Join(containers, "scan.pcap"))
+	require.NoFileExists(t, filepath.Join(containers, "scan.pcap"+sidecarSuffix))
+	require.Len(t, readTestPcap(t, filepath.Join(containers, "long.pcap")), 2)
+
+	// the manifest only lists kept files
+	manifests, err := filepath.Glob(filepath.Join(dir, pcapDir, "manifest-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	manifest, err := os.ReadFile(manifests[0])
+	require.NoError(t, err)
+	require.Contains(t, string(manifest), " "+pcapContDir+"long.pcap\n")
+	require.NotContains(t, string(manifest), "scan.pcap")
+
+	// files appended to (captured in earlier sessions) are kept
+	outDir, err := utils.OpenExistingDir(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+	p, err = New(cfg, outDir)
+	require.NoError(t, err)
+	write("long", 10*time.Second)
+	require.NoError(t, p.Destroy())
+	require.Len(t, readTestPcap(t, filepath.Join(containers, "long.pcap")), 3)
+}
+
+func TestPcapsMinLifetimeRotation(t *testing.T) {
+	cfg := config.PcapsConfig{CaptureContainer: true, MinLifetime: 2 * time.Second, RotateInterval: time.Second}
+	p, dir := newTestPcaps(t, cfg)
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 80, []byte("payload"))
+	write := func(ts time.Duration) {
+		event := newTestEvent(int(time.Unix(1700000000, 0).Add(ts).UnixNano()))
+		event.Container.ID = "abc"
+		require.NoError(t, p.Write(event, pkt))
+	}
+
+	write(0)
+	write(1500 * time.Millisecond) // rotated: the first file is kept
+	write(2200 * time.Millisecond) // the target lived long enough
+	require.NoError(t, p.Destroy())
+
+	containers := filepath.Join(dir, pcapContDir)
+	require.Len(t, readTestPcap(t, filepath.Join(containers, "abc.pcap")), 1)
+	require.Len(t, readTestPcap(t, filepath.Join(containers, "abc.20231114T221321.500000Z.pcap")), 2)
+}
@@ -0,0 +1,74 @@
+package pcaps
+
+import (
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Each container might be limited to a total of packets and/or bytes written
+// to its pcap files, so a single misbehaving container does not fill the
+// capture disk. Once a container reaches its cap, its next packets are dropped
+// (and counted) for the rest of the capture, and a line is logged (once).
+//
+// Limits are tracked by the container cache (the per container files), by
+// container, across its files (split by direction, rotated, or closed and
+// reopened), so other pcap types (e.g. the single file) are unaffected. Host
+// packets (no container) are limited as well, as the "host" container.
+//
+
+// containerLimit counts what was written to the pcap files of a container.
+type containerLimit struct {
+	packets uint64
+	bytes   uint64
+	reached bool // packets are dropped (cap reached)
+}
+
+// containerLimits tracks the packets and bytes written per container.
+type containerLimits struct {
+	maxPackets uint64 // 0: unlimited
+	maxBytes   uint64 // 0: unlimited
+	containers map[string]*containerLimit
+}
+
+// newContainerLimits returns the per container limits, or nil if there are no
+// limits.
+func newContainerLimits(maxPackets, maxBytes uint64) *containerLimits {
+	if maxPackets == 0 && maxBytes == 0 {
+		return nil
+	}
+
+	return &containerLimits{
+		maxPackets: maxPackets,
+		maxBytes:   maxBytes,
+		containers: make(map[string]*containerLimit),
+	}
+}
+
+// reached returns true if the container of the given event reached its cap.
+func (l *containerLimits) reached(event *trace.Event) bool {
+	c, ok := l.containers[event.Container.ID]
+
+	return ok && c.reached
+}
+
+// written accounts a packet written to the pcap files of the container of
+// the given event, logging (once) when the container reaches its cap.
+func (l *containerLimits) written(event *trace.Event, length int) {
+	c, ok := l.containers[event.Container.ID]
+	if !ok {
+		c = &containerLimit{}
+		l.containers[event.Container.ID] = c
+	}
+	c.packets++
+	c.bytes += uint64(length)
+
+	if (l.maxPackets > 0 && c.packets >= l.maxPackets) || (l.maxBytes > 0 && c.bytes >= l.maxBytes) {
+		c.reached = true
+		logger.Warnw("Network capture: container pcap cap reached (next packets dropped)",
+			"container", getContainerID(event.Container.ID),
+			"packets", c.packets,
+			"bytes", c.bytes,
+		)
+	}
+}
@@ -0,0 +1,54 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestContainerLimits(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, newContainerLimits(0, 0))
+
+	l := newContainerLimits(0, 1000)
+	noisy := newTestEvent(int(time.Second))
+	noisy.Container.ID = "0123456789abcdef"
+	quiet := newTestEvent(int(time.Second))
+	quiet.Container.ID = "fedcba9876543210"
+
+	l.written(noisy, 600)
+	require.False(t, l.reached(noisy))
+	l.written(noisy, 600) // cap reached (the packet reaching it is written)
+	require.True(t, l.reached(noisy))
+	require.False(t, l.reached(quiet))
+}
+
+func TestPcapsPerContainerPacketLimit(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:           true,
+		CaptureContainer:        true,
+		PerContainerPacketLimit: 3,
+	})
+
+	packet := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("query"))
+	for i := 0; i < 5; i++ {
+		noisy := newTestEvent(int(time.Second) + i)
+		noisy.Container.ID = "0123456789abcdef"
+		require.NoError(t, p.Write(noisy, packet))
+	}
+	quiet := newTestEvent(int(2 * time.Second))
+	quiet.Container.ID = "fedcba9876543210"
+	require.NoError(t, p.Write(quiet, packet))
+	require.NoError(t, p.Destroy())
+
+	// the single file is not capped
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")), 6)
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapContDir, "0123456789a.pcap")), 3)
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapContDir, "fedcba98765.pcap")), 1)
+	require.Equal(t, uint64(2), p.Stats().CapReached.Get())
+}
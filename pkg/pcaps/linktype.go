@@ -0,0 +1,88 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+//
+// Captured packets carry a fake layer 2 header (BSD loopback encapsulation, 4
+// bytes holding the address family: 2 for IPv4, 28 for IPv6, see LoopbackARP
+// for ARP), and pcap files have the null link type by default. Some tools only
+// read Ethernet captures (DLT_EN10MB), so pcap files might have the Ethernet
+// link type instead: the loopback header of each packet is then replaced, when
+// written, by a synthetic Ethernet header (zeroed MAC addresses, EtherType of
+// the packet address family). Packets are processed with the loopback header
+// either way.
+//
+
+const (
+	LinkTypeNull     = "null"
+	LinkTypeEthernet = "ethernet"
+)
+
+// ethernetHeaderSize is the size of the synthetic Ethernet header.
+const ethernetHeaderSize = 14
+
+// LoopbackARP is held by the fake (null) L2 header of ARP packets: ARP has no
+// address family, so its EtherType is held instead. Readers of null link type
+// pcap files show it as an unknown family: ARP packets are only decoded with
+// the Ethernet link type (EtherType 0x0806).
+const LoopbackARP = uint32(layers.EthernetTypeARP)
+
+// ParseLinkType parses the name of a pcap files link type.
+func ParseLinkType(linkType string) (string, error) {
+	switch linkType = strings.ToLower(linkType); linkType {
+	case LinkTypeNull, LinkTypeEthernet:
+		return linkType, nil
+	}
+
+	return "", errfmt.Errorf(
+		"invalid pcap link type (%s or %s): %s",
+		LinkTypeNull, LinkTypeEthernet, linkType,
+	)
+}
+
+// pcapLinkType returns the pcap link type of given name (null if empty).
+func pcapLinkType(linkType string) layers.LinkType {
+	if linkType == LinkTypeEthernet {
+		return layers.LinkTypeEthernet
+	}
+
+	return layers.LinkTypeNull
+}
+
+// ethernetFrame returns the given packet, prefixed by the fake (null) L2
+// header, with a synthetic Ethernet header instead.
+func ethernetFrame(payload []byte) []byte {
+	if len(payload) < l2PrefixSize {
+		return payload
+	}
+
+	frame := make([]byte, ethernetHeaderSize+len(payload)-l2PrefixSize)
+	binary.BigEndian.PutUint16(frame[12:], uint16(loopbackEthernetType(payload)))
+	copy(frame[ethernetHeaderSize:], payload[l2PrefixSize:])
+
+	return frame
+}
+
+// loopbackEthernetType returns the EtherType of the address family given by
+// the fake (null) L2 header of a packet.
+func loopbackEthernetType(payload []byte) layers.EthernetType {
+	family := binary.BigEndian.Uint32(payload)
+	if family == LoopbackARP {
+		return layers.EthernetTypeARP
+	}
+	if family > 0xff {
+		family = binary.LittleEndian.Uint32(payload) // host byte order
+	}
+	if family == AF_INET {
+		return layers.EthernetTypeIPv4
+	}
+
+	return layers.EthernetTypeIPv6 // 10, 24, 28 or 30 depending on the OS
+}
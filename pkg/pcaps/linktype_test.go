@@ -0,0 +1,72 @@
+package pcaps
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsEthernetLinkType(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, LinkType: LinkTypeEthernet})
+
+	ipv4 := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 53, []byte("query"))
+	ip6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: net.ParseIP("fd00::1"), DstIP: net.ParseIP("fd00::2")}
+	udp6 := &layers.UDP{SrcPort: 5000, DstPort: 5100}
+	require.NoError(t, udp6.SetNetworkLayerForChecksum(ip6))
+	ipv6 := serializeTestPacket(t, ip6, udp6)
+
+	require.NoError(t, p.Write(newTestEvent(1), ipv4))
+	require.NoError(t, p.Write(newTestEvent(2), ipv6))
+	require.NoError(t, p.Destroy())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+	require.Equal(t, layers.LinkTypeEthernet, r.LinkType())
+
+	packets := readTestPcap(t, path)
+	require.Len(t, packets, 2)
+	for i, expected := range []struct {
+		etherType layers.EthernetType
+		l3        []byte
+	}{
+		{layers.EthernetTypeIPv4, ipv4[l2PrefixSize:]},
+		{layers.EthernetTypeIPv6, ipv6[l2PrefixSize:]},
+	} {
+		packet := gopacket.NewPacket(packets[i], layers.LayerTypeEthernet, gopacket.Default)
+		eth, ok := packet.LinkLayer().(*layers.Ethernet)
+		require.True(t, ok)
+		require.Equal(t, expected.etherType, eth.EthernetType)
+		require.Equal(t, expected.l3, eth.Payload)
+		require.NotNil(t, packet.TransportLayer())
+	}
+}
+
+func TestLoopbackEthernetType(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		header   []byte
+		expected layers.EthernetType
+	}{
+		{[]byte{0, 0, 0, 2}, layers.EthernetTypeIPv4},
+		{[]byte{0, 0, 0, 28}, layers.EthernetTypeIPv6},
+		{[]byte{2, 0, 0, 0}, layers.EthernetTypeIPv4}, // host (little endian) byte order
+		{[]byte{30, 0, 0, 0}, layers.EthernetTypeIPv6},
+		{[]byte{0, 0, 8, 6}, layers.EthernetTypeARP}, // LoopbackARP
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, loopbackEthernetType(tc.header), tc.header)
+	}
+}
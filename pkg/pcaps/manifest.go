@@ -0,0 +1,502 @@
+package pcaps
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// At the end of each capture session (the lifetime of a Pcaps instance) a
+// human readable manifest is written to the pcap directory, so captures can be
+// handed off to other analysts: it describes the configuration and filters in
+// use, the captured time range, the capture targets and all the files written
+// during the session (with their sizes and SHA-256 hashes).
+//
+
+const manifestTimeFormat = "20060102T150405.000Z"
+
+// captureSession tracks what was captured during a capture session.
+type captureSession struct {
+	start    time.Time
+	packets  uint64
+	first    int64 // timestamp of the first written packet
+	last     int64 // timestamp of the last written packet
+	targets  map[string]struct{}
+	files    map[string]struct{} // written files (relative to output dir, unless absolute)
+	timeline *flowTimeline       // flows drawn at the end of the session (if enabled)
+}
+
+func newCaptureSession(timeline bool) *captureSession {
+	s := &captureSession{
+		start:   time.Now().UTC(),
+		targets: make(map[string]struct{}),
+		files:   make(map[string]struct{}),
+	}
+	if timeline {
+		s.timeline = newFlowTimeline()
+	}
+
+	return s
+}
+
+// packet accounts a written packet.
+func (s *captureSession) packet(ts int64) {
+	if s.packets == 0 || ts < s.first {
+		s.first = ts
+	}
+	if ts > s.last {
+		s.last = ts
+	}
+	s.packets++
+}
+
+// target accounts a capture target (and its pcap file) packets were written to.
+func (s *captureSession) target(target string, item *Pcap) {
+	s.targets[target] = struct{}{}
+	s.files[item.pcapPath] = struct{}{}
+	if item.chain != nil {
+		s.files[item.pcapPath+chainSuffix] = struct{}{}
+	}
+}
+
+// file accounts a file written during the session.
+func (s *captureSession) file(path string) {
+	s.files[path] = struct{}{}
+}
+
+// removed accounts a session file that was removed (e.g. of a short-lived
+// target), along with its hash chain.
+func (s *captureSession) removed(path string) {
+	delete(s.files, path)
+	delete(s.files, path+chainSuffix)
+}
+
+// renamed accounts a session file that was renamed (e.g. compressed).
+func (s *captureSession) renamed(path string, newPath string) {
+	if _, ok := s.files[path]; ok {
+		delete(s.files, path)
+		s.files[newPath] = struct{}{}
+	}
+}
+
+// manifestPath returns the path of the session manifest (relative to output dir).
+func (s *captureSession) manifestPath() string {
+	return pcapDir + "manifest-" + s.start.Format(manifestTimeFormat) + ".txt"
+}
+
+// writeManifest writes the session manifest. All session files must have been
+// closed already.
+func (s *captureSession) writeManifest(output *os.File, cfg config.PcapsConfig) error {
+	end := time.Now().UTC()
+
+	err := utils.MkdirAtExist(output, pcapDir, os.ModePerm)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	file, err := utils.OpenAt(output, s.manifestPath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	w := bufio.NewWriter(file)
+
+	fmt.Fprintf(w, "Network capture session\n\n")
+	fmt.Fprintf(w, "Started:  %s\n", s.start.Format(time.RFC3339))
+	fmt.Fprintf(w, "Finished: %s\n", end.Format(time.RFC3339))
+	fmt.Fprintf(w, "Packets:  %d\n", s.packets)
+	if s.packets > 0 {
+		fmt.Fprintf(w, "First:    %s\n", time.Unix(0, s.first).UTC().Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "Last:     %s\n", time.Unix(0, s.last).UTC().Format(time.RFC3339Nano))
+	}
+
+	fmt.Fprintf(w, "\nConfiguration:\n")
+	for _, line := range describeConfig(cfg) {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+
+	fmt.Fprintf(w, "\nFilters:\n")
+	filters := describeFilters(cfg)
+	if len(filters) == 0 {
+		fmt.Fprintf(w, "  none\n")
+	}
+	for _, line := range filters {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+
+	fmt.Fprintf(w, "\nTargets (%d):\n", len(s.targets))
+	for _, target := range sortedKeys(s.targets) {
+		fmt.Fprintf(w, "  %s\n", target)
+	}
+
+	fmt.Fprintf(w, "\nFiles (%d): size (bytes), sha256, path (relative to output dir, unless absolute)\n", len(s.files))
+	for _, path := range sortedKeys(s.files) {
+		size, hash, err := hashFile(output, path)
+		if err != nil {
+			return errfmt.WrapError(err)
+		}
+		fmt.Fprintf(w, "  %d %s %s\n", size, hash, path)
+	}
+
+	return errfmt.WrapError(w.Flush())
+}
+
+// describeConfig returns a human readable description of the configuration.
+func describeConfig(cfg config.PcapsConfig) []string {
+	var types []string
+	for _, t := range []PcapType{Single, Process, Container, Command, User, Connection} {
+		if configToPcapType(cfg)&t == t {
+			types = append(types, strings.ToLower(t.String()))
+		}
+	}
+
+	lines := []string{
+		"pcap files: " + strings.Join(types, ", "),
+		fmt.Sprintf("snaplen: %d bytes", cfg.CaptureLength),
+	}
+	for _, protocol := range sortedKeys(cfg.CaptureLengths) {
+		lines = append(lines, fmt.Sprintf("%s snaplen: %d bytes", protocol, cfg.CaptureLengths[protocol]))
+	}
+	if cfg.PayloadCeiling > 0 {
+		lines = append(lines, fmt.Sprintf("max payload: %d bytes", cfg.PayloadCeiling))
+	}
+	if cfg.PayloadWindowEnd > 0 {
+		lines = append(lines, fmt.Sprintf("payload window: bytes %d to %d (offsets lost)", cfg.PayloadWindowStart, cfg.PayloadWindowEnd))
+	}
+	if cfg.RotateSize > 0 {
+		lines = append(lines, fmt.Sprintf("rotation: pcap files bigger than %d bytes", cfg.RotateSize))
+	}
+	if cfg.RotateInterval > 0 {
+		lines = append(lines, "rotation: pcap files every "+cfg.RotateInterval.String())
+	}
+	if cfg.Compress == CompressGzip {
+		lines = append(lines, "compression: gzip (finalized pcap files)")
+	}
+	if cfg.MinLifetime > 0 {
+		lines = append(lines, "pcap files of targets living less than "+cfg.MinLifetime.String()+" removed")
+	}
+	if cfg.OpenHook != "" {
+		lines = append(lines, "open hook: "+cfg.OpenHook)
+	}
+	if cfg.CloseHook != "" {
+		lines = append(lines, "close hook: "+cfg.CloseHook)
+	}
+	for _, protocol := range sortedKeys(protocolSet(cfg.ProtocolDirs)) {
+		lines = append(lines, fmt.Sprintf("%s output dir: %s", protocol, cfg.ProtocolDirs[protocol]))
+	}
+	if cfg.NoiseFile {
+		line := "noise file: " + pcapNoiseDir + "noise.pcap (netbios, ssdp, mdns, llmnr)"
+		if cfg.NoiseFileSize > 0 {
+			line += fmt.Sprintf(", ring of %d bytes", cfg.NoiseFileSize)
+		}
+		lines = append(lines, line)
+	}
+	if cfg.Index {
+		lines = append(lines, "index: "+pcapIndexFile)
+	}
+	if cfg.FilesIndex {
+		lines = append(lines, "files index: "+pcapFilesIndexFile)
+	}
+	if cfg.Chain {
+		lines = append(lines, "hash chain: every pcap file (checkpoints in FILE"+chainSuffix+")")
+	}
+	if cfg.SplitDirection {
+		unknown := cfg.UnknownDirection
+		if unknown == "" {
+			unknown = DirectionUnknown
+		}
+		lines = append(lines, "split by direction: inbound, outbound (unknown direction: "+unknown+")")
+	}
+	if cfg.Flows {
+		lines = append(lines, "flow table: close summaries and tcp rtt estimates")
+		if cfg.MaxFlows > 0 || cfg.FlowEviction != "" {
+			eviction := cfg.FlowEviction
+			if eviction == "" {
+				eviction = FlowEvictionIdle
+			}
+			size := fmt.Sprint(cfg.MaxFlows)
+			if cfg.MaxFlows == 0 {
+				size = fmt.Sprint(flowTableMax)
+			}
+			lines = append(lines, "flow table size: "+size+" flows (eviction: "+eviction+")")
+		}
+		if cfg.FlowReorderWindow > 0 {
+			lines = append(lines, "flow reordering window: "+cfg.FlowReorderWindow.String())
+		}
+		if cfg.FlowHandshakes {
+			lines = append(lines, "flow tcp handshake times exported as a metric")
+		}
+		if cfg.FlowByteThreshold > 0 {
+			lines = append(lines, fmt.Sprintf("flow byte threshold: %d bytes (backfill: %d packets)", cfg.FlowByteThreshold, cfg.FlowBackfill))
+		}
+	}
+	if cfg.FlowHTTP {
+		lines = append(lines, "flow http metadata: method, host, path, user agent, status and content length")
+	}
+	if cfg.FlowIDs {
+		lines = append(lines, "flow ids: 5-tuple and start time (stable across restarts)")
+	}
+	if cfg.FlowLog {
+		line := "flow log: " + pcapFlowLogFile
+		if cfg.FlowLogOnly {
+			line += " (only, no pcap files)"
+		}
+		lines = append(lines, line)
+	}
+	if cfg.FlowZeekLog {
+		lines = append(lines, "flow zeek conn log: "+pcapZeekConnLogFile)
+	}
+	if cfg.FlowTimeline {
+		lines = append(lines, "flow timeline: "+pcapDir+"timeline-TIMESTAMP.svg (per session)")
+	}
+	if cfg.FlowEvents {
+		lines = append(lines, "events stream: net_flow_summary (flows that are over)")
+	}
+	if cfg.Events || cfg.EventsOnly {
+		line := "events stream: net_packet_captured"
+		if cfg.EventPayloadSize > 0 {
+			line += fmt.Sprintf(" (up to %d bytes of each packet)", cfg.EventPayloadSize)
+		}
+		if cfg.EventsOnly {
+			line += " (only, no pcap files)"
+		}
+		lines = append(lines, line)
+	}
+	if cfg.DryRun {
+		lines = append(lines, "dry run: packets counted per target (no pcap files)")
+	} else if cfg.SinkNetwork != "" {
+		lines = append(lines, fmt.Sprintf("sink: %s:%s (streamed, no pcap files)", cfg.SinkNetwork, cfg.SinkAddress))
+	}
+	if cfg.DNSEvents {
+		line := "events stream: net_packet_captured_dns (parsed dns messages)"
+		if len(cfg.DNSPorts) > 0 {
+			ports := make([]string, 0, len(cfg.DNSPorts))
+			for _, port := range cfg.DNSPorts {
+				ports = append(ports, fmt.Sprint(port))
+			}
+			line += " (from or to ports: " + strings.Join(ports, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	if cfg.TLSEvents {
+		line := "events stream: net_packet_tls (parsed tls hellos)"
+		if len(cfg.TLSPorts) > 0 {
+			ports := make([]string, 0, len(cfg.TLSPorts))
+			for _, port := range cfg.TLSPorts {
+				ports = append(ports, fmt.Sprint(port))
+			}
+			line += " (from or to ports: " + strings.Join(ports, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	if cfg.HTTPEvents {
+		line := "events stream: net_packet_captured_http (parsed http requests)"
+		if len(cfg.HTTPPorts) > 0 {
+			ports := make([]string, 0, len(cfg.HTTPPorts))
+			for _, port := range cfg.HTTPPorts {
+				ports = append(ports, fmt.Sprint(port))
+			}
+			line += " (to ports: " + strings.Join(ports, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	if cfg.ExtractMaxStream > 0 {
+		lines = append(lines, fmt.Sprintf("file extraction: %s (streams up to %d bytes)", pcapExtractDir, cfg.ExtractMaxStream))
+		if len(cfg.ExtractProtocols) > 0 {
+			lines = append(lines, "file extraction protocols: "+strings.Join(cfg.ExtractProtocols, ", "))
+		}
+	}
+	if cfg.JA3 {
+		lines = append(lines, "tls hello fingerprints (ja3, ja3s) in packet metadata")
+	}
+	if cfg.TLSKeyLog {
+		lines = append(lines, "tls key log: embedded")
+	}
+	if cfg.PacketContext {
+		lines = append(lines, "packet context: pid, process and container of every packet")
+	}
+	if cfg.Journal {
+		lines = append(lines, "journal context: embedded")
+	}
+	if cfg.MemoryThreshold > 0 {
+		lines = append(lines, fmt.Sprintf("memory limit: %d bytes", cfg.MemoryThreshold))
+	}
+
+	return lines
+}
+
+// describeFilters returns a human readable description of the filters (things
+// that may prevent packets from being captured).
+func describeFilters(cfg config.PcapsConfig) []string {
+	var lines []string
+
+	if cfg.CaptureFiltered {
+		lines = append(lines, "only packets matching the event filters")
+	}
+	if len(cfg.UidFilter) > 0 {
+		uids := make([]string, 0, len(cfg.UidFilter))
+		for _, uid := range cfg.UidFilter {
+			uids = append(uids, fmt.Sprint(uid))
+		}
+		lines = append(lines, "only packets from uids: "+strings.Join(uids, ", "))
+	}
+	if len(cfg.PortFilter) > 0 {
+		ports := make([]string, 0, len(cfg.PortFilter))
+		for _, port := range cfg.PortFilter {
+			ports = append(ports, fmt.Sprint(port))
+		}
+		lines = append(lines, "only packets from or to ports: "+strings.Join(ports, ", "))
+	}
+	if cfg.Filter != "" {
+		lines = append(lines, "only packets matching filter: "+cfg.Filter)
+	}
+	if len(cfg.AlwaysPorts) > 0 {
+		ports := make([]string, 0, len(cfg.AlwaysPorts))
+		for _, port := range cfg.AlwaysPorts {
+			ports = append(ports, fmt.Sprint(port))
+		}
+		lines = append(lines, "always packets from or to ports: "+strings.Join(ports, ", "))
+	}
+	if cfg.SampleRate > 0 {
+		lines = append(lines, fmt.Sprintf("only a sample of flows: %g of them (by 5-tuple hash)", cfg.SampleRate))
+	}
+	if cfg.MinEntropy > 0 {
+		line := fmt.Sprintf("only packets with payload entropy above %g bits/byte", cfg.MinEntropy)
+		if len(cfg.EntropyPorts) > 0 {
+			ports := make([]string, 0, len(cfg.EntropyPorts))
+			for _, port := range cfg.EntropyPorts {
+				ports = append(ports, fmt.Sprint(port))
+			}
+			line += " (ports: " + strings.Join(ports, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	if cfg.ICMPAnomalous {
+		size := "the default ping size"
+		if cfg.ICMPMaxPayload > 0 {
+			size = fmt.Sprintf("%d bytes", cfg.ICMPMaxPayload)
+		}
+		lines = append(lines, "only anomalous icmp echoes (payload above "+size+" or not filled like a ping)")
+	}
+	if cfg.TCPUrgentOnly {
+		lines = append(lines, "only tcp segments with the urg flag set")
+	}
+	if len(cfg.JA3Allow) > 0 {
+		lines = append(lines, "only tls flows with ja3/ja3s fingerprints: "+strings.Join(cfg.JA3Allow, ", "))
+	}
+	if len(cfg.JA3Deny) > 0 {
+		lines = append(lines, "never tls flows with ja3/ja3s fingerprints: "+strings.Join(cfg.JA3Deny, ", "))
+	}
+	if cfg.BadChecksumOnly {
+		lines = append(lines, "only packets with an invalid checksum")
+	}
+	if cfg.FixChecksums {
+		lines = append(lines, "ipv4 header, tcp and udp checksums recomputed (as captured)")
+	}
+	if len(cfg.Sources) > 0 {
+		lines = append(lines, "only packets from sources: "+strings.Join(cfg.Sources, ", "))
+	}
+	if len(cfg.Syscalls) > 0 {
+		lines = append(lines, "only packets originated by syscalls: "+strings.Join(cfg.Syscalls, ", "))
+	}
+	if cfg.Family != "" && cfg.Family != FamilyBoth {
+		lines = append(lines, "only packets of address family: "+cfg.Family)
+	}
+	if cfg.Decap {
+		line := "gre and vxlan tunnels decapsulated (inner packets)"
+		if len(cfg.VXLANPorts) > 0 {
+			ports := make([]string, 0, len(cfg.VXLANPorts))
+			for _, port := range cfg.VXLANPorts {
+				ports = append(ports, fmt.Sprint(port))
+			}
+			line += " (vxlan ports: " + strings.Join(ports, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	if cfg.Defrag {
+		lines = append(lines, "ipv4 fragments reassembled (datagrams)")
+	}
+	if cfg.L2Mode != "" && cfg.L2Mode != L2ModeAuto {
+		lines = append(lines, "fake l2 header: "+cfg.L2Mode)
+	}
+	if cfg.LinkType != "" && cfg.LinkType != LinkTypeNull {
+		lines = append(lines, "link type: "+cfg.LinkType)
+	}
+	if cfg.FileNameTemplate != "" {
+		lines = append(lines, "file name template (process and command files): "+cfg.FileNameTemplate)
+	}
+	if cfg.RateLimitPackets > 0 {
+		lines = append(lines, fmt.Sprintf("rate limit: %d packets/sec per file", cfg.RateLimitPackets))
+	}
+	if cfg.RateLimitBytes > 0 {
+		lines = append(lines, fmt.Sprintf("rate limit: %d bytes/sec per file", cfg.RateLimitBytes))
+	}
+	if cfg.PerContainerPacketLimit > 0 {
+		lines = append(lines, fmt.Sprintf("container cap: %d packets per container", cfg.PerContainerPacketLimit))
+	}
+	if cfg.PerContainerByteLimit > 0 {
+		lines = append(lines, fmt.Sprintf("container cap: %d bytes per container", cfg.PerContainerByteLimit))
+	}
+	if cfg.StatsInterval > 0 {
+		lines = append(lines, fmt.Sprintf("target stats: every pcap file (FILE%s, every %v)", statsSuffix, cfg.StatsInterval))
+	}
+	if cfg.DetectionWindow > 0 {
+		lines = append(lines, fmt.Sprintf("only targets with a detection within the last %v", cfg.DetectionWindow))
+	}
+	if cfg.ShortLived > 0 {
+		lines = append(lines, fmt.Sprintf("only processes living less than %v", cfg.ShortLived))
+	}
+	if cfg.RingBuffer > 0 {
+		lines = append(lines, fmt.Sprintf("ring buffer: last %d bytes held in memory, dumped on triggers (written for %v after)", cfg.RingBuffer, cfg.RingBufferAfter))
+	}
+	if cfg.DNSDedupWindow > 0 {
+		lines = append(lines, fmt.Sprintf("identical dns queries suppressed within %v", cfg.DNSDedupWindow))
+	}
+	if cfg.ProtocolSample {
+		lines = append(lines, "only the first packet of each protocol of each target")
+	}
+
+	return lines
+}
+
+// hashFile returns the size and the SHA-256 hash of the given file.
+func hashFile(output *os.File, path string) (int64, string, error) {
+	file, err := utils.OpenAt(output, path, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, "", errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return 0, "", errfmt.WrapError(err)
+	}
+
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,57 @@
+package pcaps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestCaptureSessionManifest(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:  true,
+		CaptureProcess: true,
+		Index:          true,
+		UidFilter:      []uint32{0},
+	})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	require.NoError(t, p.Write(newTestEvent(1), pkt))
+	require.NoError(t, p.Write(newTestEvent(2), pkt))
+	require.NoError(t, p.Destroy())
+
+	manifests, err := filepath.Glob(filepath.Join(dir, pcapDir, "manifest-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+
+	data, err := os.ReadFile(manifests[0])
+	require.NoError(t, err)
+	manifest := string(data)
+
+	require.Contains(t, manifest, "Packets:  2\n")
+	require.Contains(t, manifest, "pcap files: single, process\n")
+	require.Contains(t, manifest, "only packets from uids: 0\n")
+	require.Contains(t, manifest, "  single\n")
+	require.Contains(t, manifest, "  process:1000\n")
+
+	files := []string{
+		pcapIndexFile,
+		filepath.Join(pcapSingleDir, "single.pcap"),
+		filepath.Join(pcapProcDir, "host", "proc_1000_0.pcap"),
+	}
+	require.Contains(t, manifest, fmt.Sprintf("Files (%d)", len(files)))
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		require.NoError(t, err)
+		sum := sha256.Sum256(content)
+		line := fmt.Sprintf("  %d %s %s\n", len(content), hex.EncodeToString(sum[:]), file)
+		require.True(t, strings.Contains(manifest, line), "missing %q in:\n%s", line, manifest)
+	}
+}
@@ -0,0 +1,245 @@
+package pcaps
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Per-packet metadata is recorded, as "key=value" comments, in the pcapng
+// enhanced packet block holding the packet (Wireshark shows them as packet
+// comments and they can be filtered with "frame.comment contains ...").
+//
+// Most of the metadata comes from optional arguments of the network capture
+// event: they are only recorded if the event carries them.
+//
+// Per-file metadata (describing the capture target) is recorded, the same way,
+// in the pcapng section header block of the file (Wireshark shows it in the
+// capture file properties). It is taken from the event that creates (or
+// reopens) the file.
+//
+
+const fileMetadataMaxLength = 4096 // max length of a single metadata value (bytes)
+
+// packetMetadata returns the metadata describing the captured packet. The
+// decoded packet is only needed (and might be nil otherwise) if the event
+// carries a NAT original tuple (see hasNATTuple).
+func packetMetadata(event *trace.Event, info *packetInfo) []string {
+	var metadata []string
+
+	// netfilter mark of the packet (skb mark)
+	if mark, ok := getUint32Arg(event, "mark"); ok {
+		metadata = append(metadata, fmt.Sprintf("mark=0x%x", mark))
+	}
+
+	// autonomous system of the destination address
+	if asn, ok := getUint32Arg(event, "dst_asn"); ok {
+		metadata = append(metadata, fmt.Sprintf("dst_asn=AS%d", asn))
+		if org, ok := getStringArg(event, "dst_as_org"); ok && org != "" {
+			metadata = append(metadata, "dst_as_org="+org)
+		}
+	}
+
+	// geolocation of the destination address
+	if country, ok := getStringArg(event, "dst_country"); ok && country != "" {
+		metadata = append(metadata, "dst_country="+country)
+		if city, ok := getStringArg(event, "dst_city"); ok && city != "" {
+			metadata = append(metadata, "dst_city="+city)
+		}
+	}
+
+	// verdict made by the kernel on the packet (allow or drop)
+	if verdict, ok := getStringArg(event, "verdict"); ok && verdict != "" {
+		metadata = append(metadata, "verdict="+verdict)
+	}
+
+	// layer of the packet holding an invalid checksum (corruption, injection)
+	if layer, ok := getStringArg(event, "bad_checksum"); ok && layer != "" {
+		metadata = append(metadata, "bad_checksum="+layer)
+	}
+
+	// urgent pointer of TCP segments with the URG flag set (rare, evasion)
+	if urgent, ok := getUint16Arg(event, "tcp_urgent_ptr"); ok {
+		metadata = append(metadata, fmt.Sprintf("tcp_urgent_ptr=%d", urgent))
+	}
+
+	// connection tuple before NAT (conntrack original tuple)
+	if info != nil && hasNATTuple(event) {
+		metadata = append(metadata, natMetadata(event, info)...)
+	}
+
+	return metadata
+}
+
+// contextMetadata returns the metadata describing the process that sent or
+// received the captured packet (so packets of shared pcap files, such as the
+// single one, can be told apart by process).
+func contextMetadata(event *trace.Event) []string {
+	metadata := []string{
+		"pid=" + strconv.Itoa(event.HostProcessID),
+		"process=" + event.ProcessName,
+	}
+	if event.Container.ID != "" {
+		metadata = append(metadata, "container_id="+event.Container.ID)
+	}
+
+	return metadata
+}
+
+// itemMetadata returns the metadata describing the captured packet that is
+// only recorded in pcap files of the given type.
+func itemMetadata(event *trace.Event, itemType PcapType) []string {
+	var metadata []string
+
+	switch itemType {
+	case Command:
+		// hash of the executing binary: commands with the same name might
+		// come from different binaries, so it is recorded for every packet
+		if hash, ok := getStringArg(event, "sha256"); ok && hash != "" {
+			metadata = append(metadata, "binary_sha256="+hash)
+		}
+	}
+
+	return metadata
+}
+
+// fileMetadata returns the metadata describing the capture target of pcap
+// files of the given type.
+func fileMetadata(event *trace.Event, itemType PcapType) []string {
+	var metadata []string
+
+	switch itemType {
+	case Process:
+		// command line and working directory of the process
+		if argv, ok := getStringSliceArg(event, "argv"); ok && len(argv) > 0 {
+			metadata = append(metadata, "argv="+boundMetadata(formatArgv(argv)))
+		}
+		if cwd, ok := getStringArg(event, "cwd"); ok && cwd != "" {
+			metadata = append(metadata, "cwd="+boundMetadata(cwd))
+		}
+	}
+
+	return metadata
+}
+
+// formatArgv formats a command line, quoting arguments that would be ambiguous
+// otherwise (empty or holding spaces, quotes or non printable characters).
+func formatArgv(argv []string) string {
+	args := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		if arg == "" || strings.ContainsAny(arg, " '") || strconv.Quote(arg) != `"`+arg+`"` {
+			arg = strconv.Quote(arg)
+		}
+		args = append(args, arg)
+	}
+
+	return strings.Join(args, " ")
+}
+
+// boundMetadata truncates a metadata value longer than the max length (marking
+// it with a trailing "...").
+func boundMetadata(value string) string {
+	if len(value) <= fileMetadataMaxLength {
+		return value
+	}
+	value = value[:fileMetadataMaxLength-3]
+	for !utf8.ValidString(value) {
+		value = value[:len(value)-1] // do not split a multi-byte character
+	}
+
+	return value + "..."
+}
+
+// hasNATTuple returns true if the event carries the conntrack original tuple
+// of the packet connection.
+func hasNATTuple(event *trace.Event) bool {
+	src, ok := getStringArg(event, "orig_src_ip")
+	if !ok || src == "" {
+		return false
+	}
+	dst, ok := getStringArg(event, "orig_dst_ip")
+
+	return ok && dst != ""
+}
+
+// natMetadata returns both the original (pre NAT) and the observed tuples of
+// a packet, if the packet was NAT translated. The original tuple describes the
+// connection initiator direction, so packets in the reply direction match it
+// reversed.
+func natMetadata(event *trace.Event, info *packetInfo) []string {
+	if info.srcIP == nil {
+		return nil // not an IP packet
+	}
+
+	origSrcIP, _ := getStringArg(event, "orig_src_ip")
+	origDstIP, _ := getStringArg(event, "orig_dst_ip")
+	origSrcPort, _ := getUint16Arg(event, "orig_src_port")
+	origDstPort, _ := getUint16Arg(event, "orig_dst_port")
+
+	original := formatTuple(origSrcIP, origSrcPort, origDstIP, origDstPort)
+	observed := formatTuple(info.srcIP.String(), info.srcPort, info.dstIP.String(), info.dstPort)
+	reversed := formatTuple(info.dstIP.String(), info.dstPort, info.srcIP.String(), info.srcPort)
+
+	if original == observed || original == reversed {
+		return nil // not translated
+	}
+
+	return []string{"nat_orig=" + original, "nat_observed=" + observed}
+}
+
+// formatTuple formats a connection tuple as "src:port->dst:port".
+func formatTuple(srcIP string, srcPort uint16, dstIP string, dstPort uint16) string {
+	return net.JoinHostPort(srcIP, strconv.Itoa(int(srcPort))) + "->" +
+		net.JoinHostPort(dstIP, strconv.Itoa(int(dstPort)))
+}
+
+// getUint16Arg returns the value of an optional uint16 event argument.
+func getUint16Arg(event *trace.Event, name string) (uint16, bool) {
+	arg := events.GetArg(event, name)
+	if arg == nil {
+		return 0, false
+	}
+	value, ok := arg.Value.(uint16)
+
+	return value, ok
+}
+
+// getUint32Arg returns the value of an optional uint32 event argument.
+func getUint32Arg(event *trace.Event, name string) (uint32, bool) {
+	arg := events.GetArg(event, name)
+	if arg == nil {
+		return 0, false
+	}
+	value, ok := arg.Value.(uint32)
+
+	return value, ok
+}
+
+// getStringSliceArg returns the value of an optional string slice event
+// argument.
+func getStringSliceArg(event *trace.Event, name string) ([]string, bool) {
+	arg := events.GetArg(event, name)
+	if arg == nil {
+		return nil, false
+	}
+	value, ok := arg.Value.([]string)
+
+	return value, ok
+}
+
+// getStringArg returns the value of an optional string event argument.
+func getStringArg(event *trace.Event, name string) (string, bool) {
+	arg := events.GetArg(event, name)
+	if arg == nil {
+		return "", false
+	}
+	value, ok := arg.Value.(string)
+
+	return value, ok
+}
@@ -0,0 +1,228 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+func TestPacketMetadataMark(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+
+	marked := newTestEvent(1)
+	marked.Args = []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "payload"}, Value: pkt},
+		{ArgMeta: trace.ArgMeta{Name: "mark"}, Value: uint32(0x2a)},
+	}
+	unmarked := newTestEvent(2)
+
+	require.NoError(t, p.Write(marked, pkt))
+	require.NoError(t, p.Write(unmarked, pkt))
+	require.NoError(t, p.Destroy())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+
+	comments := readTestPcapComments(t, path)
+	require.Equal(t, [][]string{{"mark=0x2a"}, nil}, comments)
+
+	// packets with options are still readable by regular pcapng readers
+	pkts := readTestPcap(t, path)
+	require.Equal(t, [][]byte{pkt, pkt}, pkts)
+}
+
+func TestItemMetadataBinaryHash(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, CaptureCommand: true})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	event := newTestEvent(1)
+	event.Args = []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "payload"}, Value: pkt},
+		{ArgMeta: trace.ArgMeta{Name: "sha256"}, Value: hash},
+	}
+
+	require.NoError(t, p.Write(event, pkt))
+	require.NoError(t, p.Write(newTestEvent(2), pkt))
+	require.NoError(t, p.Destroy())
+
+	// the hash is recorded in per-command captures only
+	comments := readTestPcapComments(t, filepath.Join(dir, pcapCommDir, "host", "proc.pcap"))
+	require.Equal(t, [][]string{{"binary_sha256=" + hash}, nil}, comments)
+
+	comments = readTestPcapComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Equal(t, [][]string{nil, nil}, comments)
+}
+
+// readTestPcapSectionComment reads the section header comment of a pcap file.
+func readTestPcapSectionComment(t *testing.T, path string) string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	r, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+
+	return r.SectionInfo().Comment
+}
+
+func TestFileMetadataProcess(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, CaptureProcess: true})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+
+	event := newTestEvent(1)
+	event.Args = []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "payload"}, Value: pkt},
+		{ArgMeta: trace.ArgMeta{Name: "argv"}, Value: []string{"curl", "-H", "User-Agent: test", "https://example.com"}},
+		{ArgMeta: trace.ArgMeta{Name: "cwd"}, Value: "/home/user"},
+	}
+
+	require.NoError(t, p.Write(event, pkt))
+	require.NoError(t, p.Destroy())
+
+	// argv and cwd are recorded in per-process captures only
+	comment := readTestPcapSectionComment(t, filepath.Join(dir, pcapProcDir, "host", "proc_1000_0.pcap"))
+	require.Equal(t, "argv=curl -H \"User-Agent: test\" https://example.com\ncwd=/home/user", comment)
+
+	comment = readTestPcapSectionComment(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Empty(t, comment)
+}
+
+func TestFileMetadataBounded(t *testing.T) {
+	t.Parallel()
+
+	event := newTestEvent(1)
+	event.Args = []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "argv"}, Value: []string{"sh", "-c", strings.Repeat("é", 4096)}},
+		{ArgMeta: trace.ArgMeta{Name: "cwd"}, Value: "/tmp"},
+	}
+
+	metadata := fileMetadata(event, Process)
+	require.Len(t, metadata, 2)
+	require.LessOrEqual(t, len(metadata[0]), len("argv=")+fileMetadataMaxLength)
+	require.True(t, strings.HasPrefix(metadata[0], "argv=sh -c éé"))
+	require.True(t, strings.HasSuffix(metadata[0], "é..."))
+	require.Equal(t, "cwd=/tmp", metadata[1])
+
+	// no metadata for events without argv and cwd (or other pcap types)
+	require.Empty(t, fileMetadata(newTestEvent(2), Process))
+	require.Empty(t, fileMetadata(event, Container))
+}
+
+func TestPacketMetadataASN(t *testing.T) {
+	t.Parallel()
+
+	event := newTestEvent(1)
+	event.Args = []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "dst_asn"}, Value: uint32(13335)},
+		{ArgMeta: trace.ArgMeta{Name: "dst_as_org"}, Value: "CLOUDFLARENET"},
+	}
+	require.Equal(t, []string{"dst_asn=AS13335", "dst_as_org=CLOUDFLARENET"}, packetMetadata(event, nil))
+
+	event.Args = []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "dst_asn"}, Value: nil}}
+	require.Empty(t, packetMetadata(event, nil))
+}
+
+func TestPacketMetadataGeo(t *testing.T) {
+	t.Parallel()
+
+	event := newTestEvent(1)
+	event.Args = []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "dst_country"}, Value: "US"},
+		{ArgMeta: trace.ArgMeta{Name: "dst_city"}, Value: "Mountain View"},
+	}
+	require.Equal(t, []string{"dst_country=US", "dst_city=Mountain View"}, packetMetadata(event, nil))
+}
+
+func TestPacketMetadataTCPUrgent(t *testing.T) {
+	t.Parallel()
+
+	event := newTestEvent(1)
+	event.Args = []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "tcp_urgent_ptr"}, Value: uint16(1)}}
+	require.Equal(t, []string{"tcp_urgent_ptr=1"}, packetMetadata(event, nil))
+}
+
+func TestPacketMetadataVerdict(t *testing.T) {
+	t.Parallel()
+
+	event := newTestEvent(1)
+	event.Args = []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "verdict"}, Value: VerdictDrop}}
+	require.Equal(t, []string{"verdict=drop"}, packetMetadata(event, nil))
+}
+
+func TestPacketMetadataContext(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, PacketContext: true})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("query"))
+
+	host := newTestEvent(1)
+	host.HostProcessID = 1234
+	contained := newTestEvent(2)
+	contained.HostProcessID = 5678
+	contained.ProcessName = "nginx"
+	contained.Container.ID = "abcdef0123456789"
+
+	require.NoError(t, p.Write(host, pkt))
+	require.NoError(t, p.Write(contained, pkt))
+	require.NoError(t, p.Destroy())
+
+	// both processes packets in the single file, told apart by their context
+	comments := readTestPcapComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Equal(t, [][]string{
+		{"pid=1234", "process=proc"},
+		{"pid=5678", "process=nginx", "container_id=abcdef0123456789"},
+	}, comments)
+}
+
+func TestPacketMetadataNAT(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true})
+
+	// outgoing packet, source NAT translated (10.0.0.1:1234 -> 192.0.2.1:40000)
+	outgoing := newTestUDPPacket(t, "192.0.2.1", "198.51.100.1", 40000, 53, []byte("query"))
+	// reply packet, before de-NAT (same connection, reply direction)
+	reply := newTestUDPPacket(t, "198.51.100.1", "192.0.2.1", 53, 40000, []byte("answer"))
+	// packet of a connection that was not translated
+	plain := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("query"))
+
+	natArgs := func(event *trace.Event, srcIP, dstIP string, srcPort, dstPort uint16) {
+		event.Args = []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "orig_src_ip"}, Value: srcIP},
+			{ArgMeta: trace.ArgMeta{Name: "orig_dst_ip"}, Value: dstIP},
+			{ArgMeta: trace.ArgMeta{Name: "orig_src_port"}, Value: srcPort},
+			{ArgMeta: trace.ArgMeta{Name: "orig_dst_port"}, Value: dstPort},
+		}
+	}
+
+	event1 := newTestEvent(1)
+	natArgs(event1, "10.0.0.1", "198.51.100.1", 1234, 53)
+	event2 := newTestEvent(2)
+	natArgs(event2, "10.0.0.1", "198.51.100.1", 1234, 53)
+	event3 := newTestEvent(3)
+	natArgs(event3, "10.0.0.1", "10.0.0.2", 1234, 53)
+
+	require.NoError(t, p.Write(event1, outgoing))
+	require.NoError(t, p.Write(event2, reply))
+	require.NoError(t, p.Write(event3, plain))
+	require.NoError(t, p.Write(newTestEvent(4), outgoing)) // no NAT info
+	require.NoError(t, p.Destroy())
+
+	comments := readTestPcapComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Equal(t, [][]string{
+		{"nat_orig=10.0.0.1:1234->198.51.100.1:53", "nat_observed=192.0.2.1:40000->198.51.100.1:53"},
+		{"nat_orig=10.0.0.1:1234->198.51.100.1:53", "nat_observed=198.51.100.1:53->192.0.2.1:40000"},
+		nil,
+		nil,
+	}, comments)
+}
@@ -0,0 +1,123 @@
+package pcaps
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Per process and per command pcap files might be named after a template (Go
+// text/template), instead of the default scheme, to fit existing tooling:
+//
+// {{.ContainerName}}_{{.HostProcessID}}_{{.ProcessName}}_{{.Timestamp}}.pcap
+//
+// The template only names the file: files are still written to the directory
+// of their pcap type and container (e.g. pcap/processes/abc123/), and the
+// ".pcap" extension is added if missing. Slashes in the name are replaced by
+// underscores. Fields are those of the packet the file is (re)opened for, so
+// a timestamp in the name makes files closed by the LRU cache, and reopened,
+// new files (instead of appended to).
+//
+// The template is validated when capture starts (parsed, and executed against
+// a sample packet): if it fails afterwards, the default scheme is used.
+//
+
+// fileNameTemplate is the template of per process and per command pcap file
+// names (nil: default scheme).
+var fileNameTemplate *template.Template
+
+// fileNameFields are the fields available to pcap file name templates.
+type fileNameFields struct {
+	ProcessName   string
+	HostProcessID int
+	ProcessID     int
+	HostThreadID  int
+	ContainerID   string // short (12 characters) container id ("host" if none)
+	ContainerName string // container name ("host" if none)
+	UserID        int
+	Timestamp     string    // packet timestamp (e.g. 20261015T120000.000000Z)
+	Time          time.Time // packet timestamp (UTC), for custom formats (e.g. {{.Time.Format "20060102"}})
+}
+
+// newFileNameFields returns the file name fields of the given event.
+func newFileNameFields(e *trace.Event) fileNameFields {
+	containerName := e.Container.Name
+	if containerName == "" {
+		containerName = "host"
+	}
+
+	return fileNameFields{
+		ProcessName:   e.ProcessName,
+		HostProcessID: e.HostProcessID,
+		ProcessID:     e.ProcessID,
+		HostThreadID:  e.HostThreadID,
+		ContainerID:   getContainerID(e.Container.ID),
+		ContainerName: containerName,
+		UserID:        e.UserID,
+		Timestamp:     rotationSuffix(int64(e.Timestamp)),
+		Time:          time.Unix(0, int64(e.Timestamp)).UTC(),
+	}
+}
+
+// ParseFileNameTemplate parses a pcap file name template, and checks it names
+// the file of a sample packet.
+func ParseFileNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("pcap-name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errfmt.Errorf("invalid pcap file name template: %v", err)
+	}
+
+	sample := &trace.Event{
+		Timestamp:     int(time.Now().UnixNano()),
+		ProcessName:   "comm",
+		HostProcessID: 1,
+		ProcessID:     1,
+		HostThreadID:  1,
+	}
+	if _, err := executeFileNameTemplate(tmpl, sample); err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	return tmpl, nil
+}
+
+// executeFileNameTemplate returns the file name the given template gives to
+// the pcap file of the given event.
+func executeFileNameTemplate(tmpl *template.Template, e *trace.Event) (string, error) {
+	var name bytes.Buffer
+	if err := tmpl.Execute(&name, newFileNameFields(e)); err != nil {
+		return "", errfmt.Errorf("invalid pcap file name template: %v", err)
+	}
+
+	fileName := strings.ReplaceAll(strings.TrimSpace(name.String()), "/", "_")
+	if base := strings.TrimSuffix(fileName, ".pcap"); base == "" || base == "." || base == ".." {
+		return "", errfmt.Errorf("invalid pcap file name template: empty file name")
+	}
+	if !strings.HasSuffix(fileName, ".pcap") {
+		fileName += ".pcap"
+	}
+
+	return fileName, nil
+}
+
+// templateFileName returns the file name of the pcap file of the given event,
+// as given by the file name template (false if none, or if it failed).
+func templateFileName(e *trace.Event) (string, bool) {
+	if fileNameTemplate == nil {
+		return "", false
+	}
+
+	name, err := executeFileNameTemplate(fileNameTemplate, e)
+	if err != nil {
+		logger.Warnw("Naming pcap file (default name used)", "error", err)
+		return "", false
+	}
+
+	return name, true
+}
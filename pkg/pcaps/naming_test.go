@@ -0,0 +1,69 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsFileNameTemplate(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureProcess:   true,
+		CaptureCommand:   true,
+		CaptureContainer: true,
+		FileNameTemplate: `{{.ContainerName}}_{{.HostProcessID}}_{{.ProcessName}}_{{.Time.Format "20060102"}}`,
+	})
+
+	event := newTestEvent(int(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC).UnixNano()))
+	event.HostProcessID = 42
+	event.ProcessName = "curl/x"
+	event.Container.ID = "0123456789abcdef"
+	event.Container.Name = "web"
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("query"))
+	require.NoError(t, p.Write(event, pkt))
+	require.NoError(t, p.Destroy())
+
+	// process and command files named after the template, container ones not
+	require.FileExists(t, filepath.Join(dir, pcapProcDir, "0123456789a", "web_42_curl_x_20261015.pcap"))
+	require.FileExists(t, filepath.Join(dir, pcapCommDir, "0123456789a", "web_42_curl_x_20261015.pcap"))
+	require.FileExists(t, filepath.Join(dir, pcapContDir, "0123456789a.pcap"))
+
+	// default scheme once unset
+	p, dir = newTestPcaps(t, config.PcapsConfig{CaptureCommand: true})
+	require.NoError(t, p.Write(newTestEvent(1), pkt))
+	require.NoError(t, p.Destroy())
+	require.FileExists(t, filepath.Join(dir, pcapCommDir, "host", "proc.pcap"))
+}
+
+func TestParseFileNameTemplate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		template      string
+		expectedName  string
+		expectedError string
+	}{
+		{template: "{{.ProcessName}}_{{.UserID}}.pcap", expectedName: "proc_0.pcap"},
+		{template: "{{.ContainerID}}-{{.HostThreadID}}", expectedName: "host-1000.pcap"},
+		{template: "{{.ProcessName", expectedError: "invalid pcap file name template"},
+		{template: "{{.Unknown}}", expectedError: "invalid pcap file name template"},
+		{template: " {{/* nothing */}} ", expectedError: "empty file name"},
+		{template: "..", expectedError: "empty file name"},
+	}
+
+	for _, tc := range testCases {
+		tmpl, err := ParseFileNameTemplate(tc.template)
+		if tc.expectedError != "" {
+			require.ErrorContains(t, err, tc.expectedError, tc.template)
+			continue
+		}
+		require.NoError(t, err, tc.template)
+		name, err := executeFileNameTemplate(tmpl, newTestEvent(1))
+		require.NoError(t, err)
+		require.Equal(t, tc.expectedName, name)
+	}
+}
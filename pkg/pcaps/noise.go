@@ -0,0 +1,58 @@
+package pcaps
+
+import (
+	"github.com/google/gopacket/layers"
+)
+
+//
+// Broadcast-heavy (local network discovery) protocols generate constant noise
+// that crowds out interesting (unicast) traffic. When enabled, their packets
+// are written to a single dedicated low-priority pcap file (pcap/noise.pcap),
+// with its own size limit (a ring file), instead of the regular pcap files.
+//
+// Noise protocols are recognized by their UDP ports or multicast groups:
+//
+//	NetBIOS  137 (name service), 138 (datagram service)
+//	SSDP     1900, 239.255.255.250, ff02::c, ff05::c
+//	mDNS     5353, 224.0.0.251, ff02::fb
+//	LLMNR    5355, 224.0.0.252, ff02::1:3
+//
+
+// noisePorts are the UDP ports of broadcast-heavy protocols.
+var noisePorts = map[uint16]struct{}{
+	137:  {}, // netbios name service
+	138:  {}, // netbios datagram service
+	1900: {}, // ssdp
+	5353: {}, // mdns
+	5355: {}, // llmnr
+}
+
+// noiseGroups are the multicast groups of broadcast-heavy protocols.
+var noiseGroups = map[string]struct{}{
+	"239.255.255.250": {}, // ssdp
+	"ff02::c":         {}, // ssdp (link-local)
+	"ff05::c":         {}, // ssdp (site-local)
+	"224.0.0.251":     {}, // mdns
+	"ff02::fb":        {}, // mdns
+	"224.0.0.252":     {}, // llmnr
+	"ff02::1:3":       {}, // llmnr
+}
+
+// isNoisePacket returns true if the packet belongs to a broadcast-heavy protocol.
+func isNoisePacket(info *packetInfo) bool {
+	if info.protocol != layers.IPProtocolUDP {
+		return false
+	}
+	if _, ok := noisePorts[info.dstPort]; ok {
+		return true
+	}
+	if _, ok := noisePorts[info.srcPort]; ok {
+		return true
+	}
+	if info.dstIP != nil && info.dstIP.IsMulticast() {
+		_, ok := noiseGroups[info.dstIP.String()]
+		return ok
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsNoiseFile(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, NoiseFile: true})
+
+	mdns := newTestUDPPacket(t, "10.0.0.1", "224.0.0.251", 5353, 5353, []byte("mdns"))
+	ssdp := newTestUDPPacket(t, "10.0.0.1", "239.255.255.250", 40000, 1900, []byte("M-SEARCH"))
+	unicast := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 5000, []byte("other"))
+
+	require.NoError(t, p.Write(newTestEvent(1), mdns))
+	require.NoError(t, p.Write(newTestEvent(2), unicast))
+	require.NoError(t, p.Write(newTestEvent(3), ssdp))
+	require.NoError(t, p.Destroy())
+
+	require.Equal(t, [][]byte{mdns, ssdp}, readTestPcap(t, filepath.Join(dir, pcapNoiseDir, "noise.pcap")))
+	require.Equal(t, [][]byte{unicast}, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")))
+}
+
+func TestPcapsNoiseFileValidation(t *testing.T) {
+	dir := t.TempDir()
+	outDir, err := os.Open(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+
+	_, err = New(config.PcapsConfig{CaptureSingle: true, NoiseFile: true, NoiseFileSize: 1 << 20, Chain: true}, outDir)
+	require.ErrorContains(t, err, "can't be used with sidecar files or hash chains")
+}
+
+func TestIsNoisePacket(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		src, dst string
+		srcPort  uint16
+		dstPort  uint16
+		expected bool
+	}{
+		{"netbios name service", "10.0.0.1", "10.0.0.255", 137, 137, true},
+		{"netbios datagram", "10.0.0.1", "10.0.0.255", 138, 138, true},
+		{"ssdp reply", "10.0.0.2", "10.0.0.1", 1900, 40000, true},
+		{"mdns", "10.0.0.1", "224.0.0.251", 5353, 5353, true},
+		{"llmnr query", "10.0.0.1", "224.0.0.252", 50000, 5355, true},
+		{"ssdp group other port", "10.0.0.1", "239.255.255.250", 40000, 40001, true},
+		{"other multicast group", "10.0.0.1", "239.1.2.3", 40000, 40001, false},
+		{"unicast", "10.0.0.1", "10.0.0.2", 40000, 53, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			packet := newTestUDPPacket(t, tc.src, tc.dst, tc.srcPort, tc.dstPort, nil)
+			require.Equal(t, tc.expected, isNoisePacket(newPacketInfo(packet)))
+		})
+	}
+
+	// noise protocols are udp only
+	tcp := newTestTCPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 5353, nil)
+	require.False(t, isNoisePacket(newPacketInfo(tcp)))
+}
@@ -0,0 +1,54 @@
+package pcaps
+
+import (
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// packetInfo holds the decoded information of a packet being written. It is
+// decoded once, from the payload given to Pcaps.Write(), and shared by all the
+// features depending on it.
+type packetInfo struct {
+	packet   gopacket.Packet
+	srcIP    net.IP
+	dstIP    net.IP
+	srcPort  uint16
+	dstPort  uint16
+	protocol layers.IPProtocol
+}
+
+// newPacketInfo decodes a packet prefixed by the fake (null) L2 header.
+func newPacketInfo(payload []byte) *packetInfo {
+	info := &packetInfo{
+		packet: gopacket.NewPacket(
+			payload,
+			layers.LayerTypeLoopback,
+			gopacket.DecodeOptions{Lazy: true, NoCopy: true},
+		),
+	}
+
+	switch l3 := info.packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		info.srcIP, info.dstIP = l3.SrcIP, l3.DstIP
+		info.protocol = l3.Protocol
+	case *layers.IPv6:
+		info.srcIP, info.dstIP = l3.SrcIP, l3.DstIP
+		info.protocol = l3.NextHeader
+	}
+
+	switch l4 := info.packet.TransportLayer().(type) {
+	case *layers.TCP:
+		info.srcPort, info.dstPort = uint16(l4.SrcPort), uint16(l4.DstPort)
+		info.protocol = layers.IPProtocolTCP
+	case *layers.UDP:
+		info.srcPort, info.dstPort = uint16(l4.SrcPort), uint16(l4.DstPort)
+		info.protocol = layers.IPProtocolUDP
+	case *layers.SCTP:
+		info.srcPort, info.dstPort = uint16(l4.SrcPort), uint16(l4.DstPort)
+		info.protocol = layers.IPProtocolSCTP
+	}
+
+	return info
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcapgo"
 
 	"github.com/aquasecurity/tracee/pkg/errfmt"
@@ -26,6 +27,12 @@ func (t *PcapType) String() string {
 		return "Command"
 	case Single:
 		return "Single"
+	case User:
+		return "User"
+	case Noise:
+		return "Noise"
+	case Connection:
+		return "Connection"
 	}
 
 	return "None"
@@ -37,6 +44,9 @@ const (
 	// 2 (0010): container: 1 pcap file per container
 	// 4 (0011): command:   1 pcap file per command
 	// 8 (1000): single:    1 single pcap file for all
+	// 16 (10000): user:    1 pcap file per user (UID)
+	// 32 (100000): noise:  1 pcap file for broadcast-heavy protocols (see noise.go)
+	// 64 (1000000): connection: 1 pcap file per TCP connection (see connection.go)
 	//
 	// or a combination:
 	//
@@ -45,48 +55,106 @@ const (
 	// 6 (0110): container + command
 	// 7 (0111): process + container + command
 	//
-	None      PcapType = 0x0
-	Process   PcapType = 0x1
-	Container PcapType = 0x2
-	Command   PcapType = 0x4
-	Single    PcapType = 0x8
+	None       PcapType = 0x0
+	Process    PcapType = 0x1
+	Container  PcapType = 0x2
+	Command    PcapType = 0x4
+	Single     PcapType = 0x8
+	User       PcapType = 0x10
+	Noise      PcapType = 0x20
+	Connection PcapType = 0x40
 )
 
 type PcapOption uint32
 
 const (
 	Filtered PcapOption = 0x1
+	Stopped  PcapOption = 0x2 // no packets are submitted (capture stopped at runtime)
 )
 
 // Pcap is a representation of a pcap file
 type Pcap struct {
 	writtenPkts int              // packets written before next sync
 	pcapType    PcapType         // Process, Container or Command
+	pcapPath    string           // pcap file path (relative to output dir, unless absolute)
 	pcapFile    *os.File         // pcap file descriptor
 	pcapWriter  *pcapgo.NgWriter // pcap writer descriptor
+	offset      int64            // file offset of the next packet block
+	opened      int64            // timestamp of the first packet since the file was (re)opened
+	finalized   bool             // closed for good (rotated or capture ended)
+	rotated     bool             // finalized by rotation
+	created     bool             // file created (not appended to) in the capture session
+	target      string           // capture target (e.g. container:ID)
+	lifetime    *targetLifetime  // span of the target packets (if a min lifetime is set)
+	limiter     *rateLimiter     // packets and bytes per second limits (if any)
+	ring        *ringFile        // fixed size file overwriting oldest packets (if enabled)
+	sidecar     *os.File         // packets 5-tuple sidecar file (if enabled)
+	chain       *pcapChain       // hash chain of written blocks (if enabled)
+	stats       *targetStats     // capture target statistics (if enabled)
 }
 
 func NewPcap(e *trace.Event, t PcapType) (*Pcap, error) {
+	return newPcap(defaultOutput(), e, t, "", "")
+}
+
+// newPcap creates (or reopens) a pcap file, of the given packet direction (if
+// split by direction) and rotation suffix (if rotated), under the given output
+// directory.
+func newPcap(output *pcapOutput, e *trace.Event, t PcapType, direction string, rotation string) (*Pcap, error) {
 	var err error
 
 	p := &Pcap{
 		pcapType: t,
 	}
 
-	p.pcapFile, p.pcapWriter, err = getPcapFileAndWriter(e, t)
+	p.pcapPath, p.pcapFile, p.pcapWriter, p.created, err = getPcapFileAndWriter(output, e, t, direction, rotation)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	// file is opened in append mode: next block goes to its end
+	stat, err := p.pcapFile.Stat()
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	p.offset = stat.Size()
 
-	return p, errfmt.WrapError(err)
+	return p, nil
 }
 
-func (p *Pcap) write(event *trace.Event, payload []byte) error {
+// write writes a packet, and its (pcapng block) options, to the pcap file and
+// returns the file offset of the packet block.
+func (p *Pcap) write(event *trace.Event, payload []byte, options []ngOption) (int64, error) {
+	if fake.LinkType == layers.LinkTypeEthernet {
+		payload = ethernetFrame(payload)
+	}
+
 	info := gopacket.CaptureInfo{
 		Timestamp:     time.Unix(0, int64(event.Timestamp)),
 		CaptureLength: int(len(payload)),
 		Length:        int(len(payload)),
 	}
 
-	if err := p.pcapWriter.WritePacket(info, payload); err != nil {
-		return errfmt.WrapError(err)
+	offset := p.offset
+
+	switch {
+	case p.ring != nil:
+		var err error
+		offset, err = p.ring.write(encodeNgEnhancedPacket(info, payload, options))
+		if err != nil {
+			return 0, errfmt.WrapError(err)
+		}
+	case len(options) == 0 && p.chain == nil:
+		if err := p.pcapWriter.WritePacket(info, payload); err != nil {
+			return 0, errfmt.WrapError(err)
+		}
+		p.offset += ngPacketBlockLength(len(payload))
+	default:
+		// gopacket writer does not support packet options
+		err := p.writeBlock(encodeNgEnhancedPacket(info, payload, options))
+		if err != nil {
+			return 0, errfmt.WrapError(err)
+		}
 	}
 	p.writtenPkts++
 
@@ -96,17 +164,75 @@ func (p *Pcap) write(event *trace.Event, payload []byte) error {
 		}
 	}
 
+	return offset, nil
+}
+
+// writeBlock writes an already encoded pcapng block directly to the file
+// (after anything buffered in the gopacket writer).
+func (p *Pcap) writeBlock(block []byte) error {
+	if p.ring != nil {
+		_, err := p.ring.write(block)
+		return errfmt.WrapError(err)
+	}
+	if err := p.pcapWriter.Flush(); err != nil {
+		return errfmt.WrapError(err)
+	}
+	if _, err := p.pcapFile.Write(block); err != nil {
+		return errfmt.WrapError(err)
+	}
+	p.offset += int64(len(block))
+
+	if p.chain != nil {
+		return errfmt.WrapError(p.chain.add(block, p.offset))
+	}
+
 	return nil
 }
 
+// ngPacketBlockLength returns the size of the pcapng enhanced packet block
+// holding a packet of the given length.
+func ngPacketBlockLength(length int) int64 {
+	blockLength := int64(length) + 32
+	return blockLength + (4-blockLength&3)&3 // blocks are 32-bit aligned
+}
+
 func (p *Pcap) flush() error {
 	p.writtenPkts = 0
 	return p.pcapWriter.Flush()
 }
 
+// sync flushes the pcap file and commits it to disk.
+func (p *Pcap) sync() error {
+	if err := p.flush(); err != nil {
+		return errfmt.WrapError(err)
+	}
+	return errfmt.WrapError(p.pcapFile.Sync())
+}
+
+// writeSidecar records a packet, written at the given offset, in the sidecar.
+func (p *Pcap) writeSidecar(ts int64, offset int64, info *packetInfo) error {
+	_, err := p.sidecar.Write(encodeSidecarRecord(ts, offset, info))
+	return errfmt.WrapError(err)
+}
+
 func (p *Pcap) close() error {
 	if err := p.flush(); err != nil {
 		logger.Errorw("Flushing pcap", "error", err)
 	}
+	if p.sidecar != nil {
+		if err := p.sidecar.Close(); err != nil {
+			logger.Errorw("Closing pcap sidecar", "error", err)
+		}
+	}
+	if p.chain != nil {
+		if err := p.chain.close(); err != nil {
+			logger.Errorw("Closing pcap chain", "error", err)
+		}
+	}
+	if p.stats != nil {
+		if _, err := p.stats.write(); err != nil {
+			logger.Errorw("Writing pcap stats", "error", err)
+		}
+	}
 	return p.pcapFile.Close()
 }
@@ -0,0 +1,188 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// gopacket pcapng writer does not support block options other than the ones
+// in the section header and interface description blocks. Blocks carrying
+// other options (such as per-packet comments) are encoded here and written
+// directly to the pcap file, after flushing the gopacket writer (pcapng files
+// are just a sequence of self-contained blocks).
+//
+// All blocks are encoded in little endian (as gopacket does for the section).
+//
+
+const (
+	ngBlockTypeSectionHeader        uint32 = 0x0A0D0D0A
+	ngBlockTypeInterfaceDescription uint32 = 0x00000001
+	ngBlockTypeInterfaceStats       uint32 = 0x00000005
+	ngBlockTypeEnhancedPacket       uint32 = 0x00000006
+	ngBlockTypeSystemdJournalExport uint32 = 0x00000009
+	ngBlockTypeDecryptionSecrets    uint32 = 0x0000000A
+)
+
+const (
+	ngSecretsTypeTLSKeyLog uint32 = 0x544c534b // NSS key log format
+)
+
+const (
+	ngOptionCodeEndOfOptions uint16 = 0
+	ngOptionCodeComment      uint16 = 1
+	ngOptionCodePacketFlags  uint16 = 2 // epb_flags (enhanced packet blocks only)
+)
+
+// epb_flags direction bits (bits 0-1)
+const (
+	ngPacketFlagsInbound  uint32 = 1
+	ngPacketFlagsOutbound uint32 = 2
+)
+
+// ngOption is a pcapng block option.
+type ngOption struct {
+	code  uint16
+	value []byte
+}
+
+// ngCommentOption returns an opt_comment option holding given comment.
+func ngCommentOption(comment string) ngOption {
+	return ngOption{code: ngOptionCodeComment, value: []byte(comment)}
+}
+
+// commentOptions returns opt_comment options holding given comments.
+func commentOptions(comments []string) []ngOption {
+	var options []ngOption
+	for _, c := range comments {
+		options = append(options, ngCommentOption(c))
+	}
+
+	return options
+}
+
+// ngPadding returns the amount of bytes needed to 32-bit align given length.
+func ngPadding(length int) int {
+	return (4 - length&3) & 3
+}
+
+// ngOptionsLength returns the encoded length of given options (including the
+// end of options option).
+func ngOptionsLength(options []ngOption) int {
+	if len(options) == 0 {
+		return 0
+	}
+
+	length := 4 // opt_endofopt
+	for _, o := range options {
+		length += 4 + len(o.value) + ngPadding(len(o.value))
+	}
+
+	return length
+}
+
+// appendNgOptions encodes given options (and the end of options option).
+func appendNgOptions(b []byte, options []ngOption) []byte {
+	if len(options) == 0 {
+		return b
+	}
+
+	for _, o := range options {
+		b = binary.LittleEndian.AppendUint16(b, o.code)
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(o.value)))
+		b = append(b, o.value...)
+		b = append(b, make([]byte, ngPadding(len(o.value)))...)
+	}
+	b = binary.LittleEndian.AppendUint16(b, ngOptionCodeEndOfOptions)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+
+	return b
+}
+
+// encodeNgEnhancedPacket encodes an enhanced packet block (for interface 0)
+// holding given packet data and options.
+func encodeNgEnhancedPacket(ci gopacket.CaptureInfo, data []byte, options []ngOption) []byte {
+	length := 32 + len(data) + ngPadding(len(data)) + ngOptionsLength(options)
+	ts := ci.Timestamp.UnixNano()
+
+	b := make([]byte, 0, length)
+	b = binary.LittleEndian.AppendUint32(b, ngBlockTypeEnhancedPacket)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+	b = binary.LittleEndian.AppendUint32(b, 0) // interface id
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts>>32))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ci.CaptureLength))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ci.Length))
+	b = append(b, data...)
+	b = append(b, make([]byte, ngPadding(len(data)))...)
+	b = appendNgOptions(b, options)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+
+	return b
+}
+
+// encodeNgDecryptionSecrets encodes a decryption secrets block holding given
+// secrets of given type.
+func encodeNgDecryptionSecrets(secretsType uint32, secrets []byte) []byte {
+	length := 20 + len(secrets) + ngPadding(len(secrets))
+
+	b := make([]byte, 0, length)
+	b = binary.LittleEndian.AppendUint32(b, ngBlockTypeDecryptionSecrets)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+	b = binary.LittleEndian.AppendUint32(b, secretsType)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(secrets)))
+	b = append(b, secrets...)
+	b = append(b, make([]byte, ngPadding(len(secrets)))...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+
+	return b
+}
+
+// encodeNgSystemdJournalExport encodes a systemd journal export block holding
+// given journal entry (journal export format).
+func encodeNgSystemdJournalExport(entry []byte) []byte {
+	length := 12 + len(entry) + ngPadding(len(entry))
+
+	b := make([]byte, 0, length)
+	b = binary.LittleEndian.AppendUint32(b, ngBlockTypeSystemdJournalExport)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+	b = append(b, entry...)
+	b = append(b, make([]byte, ngPadding(len(entry)))...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+
+	return b
+}
+
+// encodeNgInterfaceStatistics encodes an interface statistics block (for
+// interface 0) holding given timestamp and options.
+func encodeNgInterfaceStatistics(ts int64, options []ngOption) []byte {
+	length := 24 + ngOptionsLength(options)
+
+	b := make([]byte, 0, length)
+	b = binary.LittleEndian.AppendUint32(b, ngBlockTypeInterfaceStats)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+	b = binary.LittleEndian.AppendUint32(b, 0) // interface id
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts>>32))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts))
+	b = appendNgOptions(b, options)
+	b = binary.LittleEndian.AppendUint32(b, uint32(length))
+
+	return b
+}
+
+// ngWriterOptions returns the options of the pcapng writer of a pcap file of
+// the given type: the file metadata (if any) goes to the section header block
+// comment.
+func ngWriterOptions(event *trace.Event, t PcapType) pcapgo.NgWriterOptions {
+	options := pcapgo.DefaultNgWriterOptions
+	if metadata := fileMetadata(event, t); len(metadata) > 0 {
+		options.SectionInfo.Comment = strings.Join(metadata, "\n")
+	}
+
+	return options
+}
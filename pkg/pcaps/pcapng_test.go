@@ -0,0 +1,151 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// ngTestBlock is a raw pcapng block read back from a pcap file.
+type ngTestBlock struct {
+	blockType uint32
+	body      []byte // block body (without type, lengths and options)
+	options   []ngOption
+}
+
+// readTestNgBlocks returns all blocks from the given pcapng file, decoding the
+// options of the enhanced packet blocks.
+func readTestNgBlocks(t *testing.T, path string) []ngTestBlock {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var blocks []ngTestBlock
+	for len(data) > 0 {
+		require.GreaterOrEqual(t, len(data), 12)
+		blockType := binary.LittleEndian.Uint32(data[0:4])
+		length := int(binary.LittleEndian.Uint32(data[4:8]))
+		require.GreaterOrEqual(t, len(data), length)
+		require.Equal(t, uint32(length), binary.LittleEndian.Uint32(data[length-4:length]))
+
+		block := ngTestBlock{blockType: blockType, body: data[8 : length-4]}
+		if blockType == ngBlockTypeEnhancedPacket {
+			capLen := int(binary.LittleEndian.Uint32(block.body[12:16]))
+			optStart := 20 + capLen + ngPadding(capLen)
+			block.options = parseTestNgOptions(t, block.body[optStart:])
+			block.body = block.body[:20+capLen]
+		}
+		blocks = append(blocks, block)
+		data = data[length:]
+	}
+
+	return blocks
+}
+
+func parseTestNgOptions(t *testing.T, data []byte) []ngOption {
+	t.Helper()
+
+	var options []ngOption
+	for len(data) >= 4 {
+		code := binary.LittleEndian.Uint16(data[0:2])
+		length := int(binary.LittleEndian.Uint16(data[2:4]))
+		if code == ngOptionCodeEndOfOptions {
+			break
+		}
+		options = append(options, ngOption{code: code, value: data[4 : 4+length]})
+		data = data[4+length+ngPadding(length):]
+	}
+
+	return options
+}
+
+// readTestPcapComments returns the comments of each packet in the pcap file.
+func readTestPcapComments(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	var comments [][]string
+	for _, block := range readTestNgBlocks(t, path) {
+		if block.blockType != ngBlockTypeEnhancedPacket {
+			continue
+		}
+		var packetComments []string
+		for _, o := range block.options {
+			if o.code == ngOptionCodeComment {
+				packetComments = append(packetComments, string(o.value))
+			}
+		}
+		comments = append(comments, packetComments)
+	}
+
+	return comments
+}
+
+func TestNgOptionsLength(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 0, ngOptionsLength(nil))
+
+	options := []ngOption{ngCommentOption("a"), ngCommentOption("abcd")}
+	require.Equal(t, 4+8+8, ngOptionsLength(options))
+	require.Len(t, appendNgOptions(nil, options), ngOptionsLength(options))
+}
+
+func TestWriteTLSKeyLog(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		TLSKeyLog:     true,
+	})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 443, []byte("payload"))
+	keyLog := "CLIENT_RANDOM 0102 0304"
+
+	require.NoError(t, p.Write(newTestEvent(1), pkt))
+	require.NoError(t, p.WriteTLSKeyLog(newTestEvent(2), []byte(keyLog)))
+	require.NoError(t, p.Write(newTestEvent(3), pkt))
+	require.NoError(t, p.Destroy())
+
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+
+	var secrets [][]byte
+	var packets int
+	for _, block := range readTestNgBlocks(t, path) {
+		switch block.blockType {
+		case ngBlockTypeDecryptionSecrets:
+			require.Equal(t, ngSecretsTypeTLSKeyLog, binary.LittleEndian.Uint32(block.body[0:4]))
+			length := binary.LittleEndian.Uint32(block.body[4:8])
+			secrets = append(secrets, block.body[8:8+length])
+			require.Equal(t, 1, packets) // written in between packets
+		case ngBlockTypeEnhancedPacket:
+			packets++
+		}
+	}
+	require.Equal(t, [][]byte{[]byte(keyLog + "\n")}, secrets)
+	require.Equal(t, 2, packets)
+
+	// files with secrets blocks are still readable by regular pcapng readers
+	require.Len(t, readTestPcap(t, path), 2)
+}
+
+// readTestStatsComments returns the comments of all interface statistics
+// blocks in the pcap file.
+func readTestStatsComments(t *testing.T, path string) []string {
+	t.Helper()
+
+	var comments []string
+	for _, block := range readTestNgBlocks(t, path) {
+		if block.blockType != ngBlockTypeInterfaceStats {
+			continue
+		}
+		for _, o := range parseTestNgOptions(t, block.body[12:]) {
+			comments = append(comments, string(o.value))
+		}
+	}
+
+	return comments
+}
@@ -1,12 +1,16 @@
 package pcaps
 
 import (
+	"fmt"
 	"os"
+	"sync"
 
 	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/counter"
 	"github.com/aquasecurity/tracee/pkg/errfmt"
 	"github.com/aquasecurity/tracee/pkg/events"
 	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/metrics"
 	"github.com/aquasecurity/tracee/types/trace"
 )
 
@@ -22,12 +26,101 @@ import (
 // At the end we have the Pcap struct itself. It describes a pcap file being
 // kept opened on behalf of a process, a container or a command.
 //
-// NOTE: Pcaps is not thread safe, should be called from a single routine.
+// Packets are captured within capture sessions: a session starts when Pcaps
+// is created and ends when it is destroyed, but sessions might also be ended
+// and started (and capture paused and resumed) on demand (see control.go).
+//
+// Packets arriving once a session has ended (e.g. still queued when the
+// session was ended, or when tracee is shutting down and capture was
+// destroyed) are late: they are dropped and counted, never written to the
+// (already closed) pcap files of the ended session. After Destroy, no new
+// sessions can be started.
+//
+// NOTE: Pcaps methods are serialized by a mutex: packets are written from a
+//       single routine, but capture might be controlled from other routines.
 //
 
 // Pcaps holds all Pcap for different PcapTypes
 type Pcaps struct {
-	pcapCaches map[PcapType]*PcapCache
+	mutex        sync.Mutex
+	config       config.PcapsConfig
+	output       *os.File
+	session      *captureSession // current capture session (nil if none)
+	paused       bool
+	destroyed    bool // all files closed for good (no more sessions)
+	pcapCaches   map[PcapType]*PcapCache
+	connections  *PcapCache           // pcap files per TCP connection (if enabled)
+	uidFilter    map[int]struct{}     // capture only packets from these UIDs (if set)
+	portFilter   map[uint16]struct{}  // capture only packets from or to these ports (if set)
+	index        *pcapIndex           // index of all written packets (if enabled)
+	filesIndex   *filesIndex          // index of all created pcap files (if enabled)
+	memory       *memoryMonitor       // disables features under memory pressure (if enabled)
+	tlsKeyLog    bool                 // embed TLS key log secrets into pcap files
+	journal      bool                 // embed journal entries into pcap files
+	dnsDedup     *dnsDedup            // suppresses identical DNS queries (if enabled)
+	extractor    *objectExtractor     // extracts transferred files (if enabled)
+	flows        *flowTable           // tracks flows of captured packets (if enabled)
+	flowLog      *flowLog             // CSV log of flows that are over (if enabled)
+	zeekLog      *zeekConnLog         // Zeek conn.log of flows that are over (if enabled)
+	windows      *detectionWindows    // targets are only captured during detection windows (if enabled)
+	shortLived   *shortLivedProcesses // packets are buffered until their processes are known to be short-lived (if enabled)
+	ringBuffer   *ringBuffer          // packets are held in memory until a trigger dumps them (if enabled)
+	fingerprints *fingerprintFilter   // TLS flows are filtered by their JA3/JA3S fingerprints (if enabled)
+	hooks        *fileHooks           // hook commands run when pcap files are opened or finalized (if any)
+	sampler      *protocolSampler     // only the first packet of each L7 protocol of each target is written (if enabled)
+	flowOver     func(FlowSummary)    // called with the summary of each flow that is over (if set)
+	statsAt      int64                // last time target stats files were written
+	stats        Stats
+	// protocols written to their own output directories (if any)
+	protocolCaches  map[string]map[PcapType]*PcapCache
+	protocolOutputs map[string]*pcapOutput
+	// broadcast-heavy protocols written to their own file (if enabled)
+	noiseCaches map[PcapType]*PcapCache
+}
+
+// Stats holds the network capture statistics.
+type Stats struct {
+	RateLimited     counter.Counter // packets dropped by per target rate limits
+	CapReached      counter.Counter // packets dropped by per container caps
+	DNSDeduplicated counter.Counter // identical DNS queries suppressed
+	NotCaptured     counter.Counter // packets seen while paused or out of session
+	Late            counter.Counter // packets arriving after a session ended (dropped)
+	Extracted       counter.Counter // files extracted from reassembled streams
+	NotReassembled  counter.Counter // TCP connections not reassembled (protocol not selected)
+	OutOfWindow     counter.Counter // packets of targets without an open detection window (not written)
+	BelowThreshold  counter.Counter // packets of flows below the byte threshold (withheld)
+	Fingerprinted   counter.Counter // packets of TLS flows filtered out by their JA3/JA3S fingerprints
+	LongLived       counter.Counter // packets of processes not known to be short-lived (discarded)
+	Untriggered     counter.Counter // packets dropped from the ring buffer (never dumped by a trigger)
+	Unsampled       counter.Counter // packets of protocols already sampled for their target (not written)
+	Written         counter.Counter // packets written to pcap files (once per file)
+	WrittenBytes    counter.Counter // packet bytes written to pcap files (once per file)
+}
+
+// Stats returns the network capture statistics.
+func (p *Pcaps) Stats() *Stats {
+	return &p.stats
+}
+
+// ObserveHandshakes records the TCP handshake times of tracked flows in the
+// given histogram (exported as a metric). It does nothing if flows are not
+// tracked.
+func (p *Pcaps) ObserveHandshakes(h *metrics.Histogram) {
+	if p.flows != nil {
+		p.flows.handshakes = h
+	}
+}
+
+// OnFlowOver sets a function called with the summary of each tracked flow
+// that is over (with the pcaps lock held: it must not call back into Pcaps).
+// It does nothing if flows are not tracked.
+func (p *Pcaps) OnFlowOver(fn func(FlowSummary)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.flows != nil {
+		p.flowOver = fn
+	}
 }
 
 func New(simple config.PcapsConfig, output *os.File) (*Pcaps, error) {
@@ -35,30 +128,439 @@ func New(simple config.PcapsConfig, output *os.File) (*Pcaps, error) {
 
 	cfg := configToPcapType(simple)
 
-	// initialize all keys first
-	caches := map[PcapType]*PcapCache{
-		Single:    nil,
-		Process:   nil,
-		Container: nil,
-		Command:   nil,
+	if simple.Sidecar && simple.RingFileSize > 0 {
+		return nil, errfmt.Errorf("pcap sidecar files can't be used with ring files")
+	}
+	if simple.Chain && simple.RingFileSize > 0 {
+		return nil, errfmt.Errorf("pcap hash chains can't be used with ring files")
+	}
+	if (simple.RotateSize > 0 || simple.RotateInterval > 0) && simple.RingFileSize > 0 {
+		return nil, errfmt.Errorf("pcap rotation can't be used with ring files")
+	}
+	if simple.Compress != "" {
+		if _, err := ParseCompression(simple.Compress); err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		if simple.Compress == CompressGzip && simple.RingFileSize > 0 {
+			return nil, errfmt.Errorf("pcap compression can't be used with ring files")
+		}
+	}
+	if simple.NoiseFileSize > 0 && (simple.Sidecar || simple.Chain) {
+		return nil, errfmt.Errorf("pcap noise file size can't be used with sidecar files or hash chains")
+	}
+	if simple.FlowEviction != "" {
+		if _, err := ParseFlowEviction(simple.FlowEviction); err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+	if simple.UnknownDirection != "" {
+		if _, err := ParseDirection(simple.UnknownDirection); err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	if simple.LinkType != "" {
+		if _, err := ParseLinkType(simple.LinkType); err != nil {
+			return nil, errfmt.WrapError(err)
+		}
 	}
 
-	initializeGlobalVars(output)
+	fileNameTemplate = nil
+	if simple.FileNameTemplate != "" {
+		fileNameTemplate, err = ParseFileNameTemplate(simple.FileNameTemplate)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	initializeGlobalVars(output, pcapLinkType(simple.LinkType))
 
+	caches, err := newPcapCaches(cfg, simple, defaultOutput())
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
 	for t := range caches {
-		if cfg&t == t { // if type was requested, init its cache
-			logger.Debugw("pcap enabled: " + t.String())
-			caches[t], err = newPcapCache(t)
+		logger.Debugw("pcap enabled: " + t.String())
+	}
+
+	// protocols written to their own output directories (if any)
+	var protocolCaches map[string]map[PcapType]*PcapCache
+	var protocolOutputs map[string]*pcapOutput
+	if len(simple.ProtocolDirs) > 0 {
+		protocolOutputs, err = openProtocolOutputs(simple.ProtocolDirs)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		protocolCaches = make(map[string]map[PcapType]*PcapCache, len(protocolOutputs))
+		for protocol, o := range protocolOutputs {
+			protocolCaches[protocol], err = newPcapCaches(cfg, simple, o)
 			if err != nil {
 				return nil, errfmt.WrapError(err)
 			}
-		} else {
-			// remove keys that were not requested
-			delete(caches, t)
 		}
 	}
 
-	return &Pcaps{pcapCaches: caches}, nil
+	// broadcast-heavy protocols written to their own file (if enabled)
+	var noiseCaches map[PcapType]*PcapCache
+	if simple.NoiseFile {
+		noiseConfig := simple
+		noiseConfig.RingFileSize = simple.NoiseFileSize
+		noiseCaches, err = newPcapCaches(Noise, noiseConfig, defaultOutput())
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	// TCP connections written to their own files (if enabled)
+	var connections *PcapCache
+	if simple.PerConnection {
+		if !simple.Flows {
+			return nil, errfmt.Errorf("pcap per connection files require flow tracking")
+		}
+		if simple.DetectionWindow > 0 {
+			return nil, errfmt.Errorf("pcap per connection files can't be used with detection windows")
+		}
+		connections, err = newPcapCache(Connection, simple, defaultOutput())
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	var uidFilter map[int]struct{}
+	if len(simple.UidFilter) > 0 {
+		uidFilter = make(map[int]struct{}, len(simple.UidFilter))
+		for _, uid := range simple.UidFilter {
+			uidFilter[int(uid)] = struct{}{}
+		}
+	}
+
+	var portFilter map[uint16]struct{}
+	if len(simple.PortFilter) > 0 {
+		portFilter = make(map[uint16]struct{}, len(simple.PortFilter))
+		for _, port := range simple.PortFilter {
+			portFilter[port] = struct{}{}
+		}
+		for _, port := range simple.AlwaysPorts {
+			portFilter[port] = struct{}{} // always captured
+		}
+	}
+
+	var index *pcapIndex
+	if simple.Index {
+		index, err = newPcapIndex(output)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	p := &Pcaps{
+		config:      simple,
+		output:      output,
+		session:     newCaptureSession(simple.FlowTimeline),
+		pcapCaches:  caches,
+		connections: connections,
+		uidFilter:   uidFilter,
+		portFilter:  portFilter,
+		index:       index,
+		tlsKeyLog:   simple.TLSKeyLog,
+		journal:     simple.Journal,
+
+		protocolCaches:  protocolCaches,
+		protocolOutputs: protocolOutputs,
+		noiseCaches:     noiseCaches,
+	}
+
+	if index != nil {
+		p.session.file(pcapIndexFile)
+	}
+
+	if simple.FilesIndex {
+		p.filesIndex, err = newFilesIndex(output)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		p.session.file(pcapFilesIndexFile)
+	}
+
+	if simple.OpenHook != "" || simple.CloseHook != "" {
+		p.hooks = newFileHooks(simple.OpenHook, simple.CloseHook, simple.HookTimeout)
+	}
+
+	// compressed pcap files replace plain ones in session manifests (and the
+	// files index), and removed ones are dropped from them
+	for _, caches := range p.allCaches() {
+		for _, cache := range caches {
+			cache.created = p.pcapCreated
+			cache.compressed = p.pcapCompressed
+			cache.removed = p.pcapRemoved
+			cache.hooks = p.hooks
+		}
+	}
+
+	if simple.DNSDedupWindow > 0 {
+		p.dnsDedup = newDNSDedup(int64(simple.DNSDedupWindow))
+	}
+
+	if simple.DetectionWindow > 0 {
+		p.windows = newDetectionWindows(int64(simple.DetectionWindow))
+	}
+
+	if simple.ProtocolSample {
+		p.sampler = newProtocolSampler()
+	}
+
+	if simple.ShortLived > 0 {
+		if simple.FlowByteThreshold > 0 {
+			return nil, errfmt.Errorf("pcap short-lived processes can't be used with a flow byte threshold")
+		}
+		p.shortLived = newShortLivedProcesses(int64(simple.ShortLived), func(count int) {
+			_ = p.stats.LongLived.Increment(uint64(count))
+		})
+	}
+
+	if simple.RingBuffer > 0 {
+		if simple.ShortLived > 0 {
+			return nil, errfmt.Errorf("pcap ring buffer can't be used with short-lived processes")
+		}
+		p.ringBuffer = newRingBuffer(simple.RingBuffer, int64(simple.RingBufferAfter), func(count int) {
+			_ = p.stats.Untriggered.Increment(uint64(count))
+		})
+	}
+
+	if simple.Flows {
+		withhold := simple.FlowByteThreshold > 0 || len(simple.JA3Allow) > 0
+		p.flows = newFlowTable(int(simple.MaxFlows), simple.FlowEviction, simple.FlowHTTP, int64(simple.FlowReorderWindow), withhold)
+		p.flows.ja3 = simple.JA3 || len(simple.JA3Allow) > 0 || len(simple.JA3Deny) > 0
+		p.flows.ids = simple.FlowIDs
+	}
+
+	if simple.FlowHTTP && !simple.Flows {
+		return nil, errfmt.Errorf("pcap flow http metadata requires flow tracking")
+	}
+	if simple.FlowIDs && !simple.Flows {
+		return nil, errfmt.Errorf("pcap flow ids require flow tracking")
+	}
+	if simple.FlowReorderWindow > 0 && !simple.Flows {
+		return nil, errfmt.Errorf("pcap flow reordering window requires flow tracking")
+	}
+	if simple.FlowByteThreshold > 0 && !simple.Flows {
+		return nil, errfmt.Errorf("pcap flow byte threshold requires flow tracking")
+	}
+	if len(simple.JA3Allow) > 0 || len(simple.JA3Deny) > 0 {
+		if !simple.Flows {
+			return nil, errfmt.Errorf("pcap ja3 filters require flow tracking")
+		}
+		if len(simple.JA3Allow) > 0 && simple.FlowByteThreshold > 0 {
+			return nil, errfmt.Errorf("pcap ja3 allow filter can't be used with a flow byte threshold")
+		}
+		p.fingerprints = newFingerprintFilter(simple.JA3Allow, simple.JA3Deny)
+	}
+	if simple.FlowHandshakes && !simple.Flows {
+		return nil, errfmt.Errorf("pcap flow handshake metric requires flow tracking")
+	}
+	if simple.FlowBackfill > flowMaxBackfill {
+		return nil, errfmt.Errorf("pcap flow backfill can't be bigger than %d packets", flowMaxBackfill)
+	}
+
+	if simple.FlowLog {
+		if !simple.Flows {
+			return nil, errfmt.Errorf("pcap flow log requires flow tracking")
+		}
+		p.flowLog, err = newFlowLog(output)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+	if simple.FlowZeekLog {
+		if !simple.Flows {
+			return nil, errfmt.Errorf("pcap flow zeek log requires flow tracking")
+		}
+		p.zeekLog, err = newZeekConnLog(output)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+	if simple.FlowTimeline && !simple.Flows {
+		return nil, errfmt.Errorf("pcap flow timeline requires flow tracking")
+	}
+
+	if len(simple.ExtractProtocols) > 0 && simple.ExtractMaxStream == 0 {
+		return nil, errfmt.Errorf("pcap extract protocols require file extraction")
+	}
+	if simple.ExtractMaxStream > 0 {
+		p.extractor, err = newObjectExtractor(output, simple.ExtractMaxStream, simple.ExtractProtocols, p.objectExtracted, p.streamSkipped)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	if simple.MemoryThreshold > 0 {
+		p.memory, err = newMemoryMonitor(simple.MemoryThreshold, simple.DegradeOrder, p.degradables())
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	return p, nil
+}
+
+// degradables returns the features that can be disabled under memory pressure.
+func (p *Pcaps) degradables() map[string]func() bool {
+	return map[string]func() bool{
+		"extract":   p.disableExtract,
+		"flows":     p.disableFlows,
+		"index":     p.disableIndex,
+		"dns-dedup": p.disableDNSDedup,
+	}
+}
+
+func (p *Pcaps) disableExtract() bool {
+	if p.extractor == nil {
+		return false
+	}
+	if err := p.extractor.close(); err != nil {
+		logger.Errorw("Closing pcap object extractor", "error", err)
+	}
+	p.extractor = nil
+
+	return true
+}
+
+func (p *Pcaps) disableFlows() bool {
+	if p.flows == nil {
+		return false
+	}
+	p.writeFlowSummaries(p.flows.flush())
+	p.flows = nil
+
+	return true
+}
+
+// streamSkipped accounts a TCP connection not reassembled (protocol not
+// selected).
+func (p *Pcaps) streamSkipped() {
+	_ = p.stats.NotReassembled.Increment()
+}
+
+// objectExtracted accounts a file extracted from a reassembled stream.
+func (p *Pcaps) objectExtracted(path string) {
+	_ = p.stats.Extracted.Increment()
+	if p.session != nil {
+		p.session.file(path)
+		p.session.file(pcapExtractObjectFile)
+	}
+}
+
+func (p *Pcaps) disableIndex() bool {
+	if p.index == nil {
+		return false
+	}
+	if err := p.index.close(); err != nil {
+		logger.Errorw("Closing pcap index", "error", err)
+	}
+	p.index = nil
+
+	return true
+}
+
+func (p *Pcaps) disableDNSDedup() bool {
+	if p.dnsDedup == nil {
+		return false
+	}
+	p.writeDNSDedupSummaries(p.dnsDedup.flush())
+	p.dnsDedup = nil
+
+	return true
+}
+
+// writeFlowSummaries records the summaries of flows that are over in the pcap
+// files of their first packets (and hands them to the flow over function).
+func (p *Pcaps) writeFlowSummaries(summaries []*flowSummary) {
+	for _, s := range summaries {
+		if p.flowOver != nil {
+			p.flowOver(s.export())
+		}
+		if p.flowLog != nil {
+			if err := p.flowLog.add(s); err != nil {
+				logger.Errorw("Writing pcap flow log", "error", err)
+			}
+			if p.session != nil {
+				p.session.file(pcapFlowLogFile)
+			}
+		}
+		if p.zeekLog != nil {
+			if err := p.zeekLog.add(s); err != nil {
+				logger.Errorw("Writing pcap zeek conn log", "error", err)
+			}
+			if p.session != nil {
+				p.session.file(pcapZeekConnLogFile)
+			}
+		}
+		if p.session != nil && p.session.timeline != nil {
+			p.session.timeline.add(s)
+		}
+		if s.flow.connection != "" && p.connections != nil {
+			p.finishConnection(s)
+		}
+		if s.flow.withheld {
+			continue // nothing of the flow was written (below the byte threshold)
+		}
+		block := encodeNgInterfaceStatistics(s.flow.last, commentOptions(s.comments()))
+		for k := range s.flow.caches {
+			item, err := s.flow.caches[k].get(s.flow.event)
+			if err == nil {
+				err = item.writeBlock(block)
+				p.session.file(item.pcapPath)
+			}
+			if err != nil {
+				logger.Errorw("Writing pcap flow summary", "error", err)
+			}
+		}
+	}
+}
+
+// cachesFor returns the caches of the pcap files packets of the given protocols
+// (from the most to the least specific one) are written to.
+func (p *Pcaps) cachesFor(protocols ...string) map[PcapType]*PcapCache {
+	for _, protocol := range protocols {
+		if caches, ok := p.protocolCaches[protocol]; ok {
+			return caches
+		}
+	}
+
+	return p.pcapCaches
+}
+
+// allCaches returns the caches of all output directories.
+func (p *Pcaps) allCaches() []map[PcapType]*PcapCache {
+	all := []map[PcapType]*PcapCache{p.pcapCaches}
+	for _, caches := range p.protocolCaches {
+		all = append(all, caches)
+	}
+	if p.noiseCaches != nil {
+		all = append(all, p.noiseCaches)
+	}
+	if p.connections != nil {
+		all = append(all, map[PcapType]*PcapCache{Connection: p.connections})
+	}
+
+	return all
+}
+
+// writeDNSDedupSummaries records the suppressed DNS queries of closed dedup
+// windows in the pcap files of their capture targets.
+func (p *Pcaps) writeDNSDedupSummaries(summaries []*dnsDedupSummary) {
+	for _, s := range summaries {
+		item, err := s.entry.cache.get(s.entry.event)
+		if err == nil {
+			block := encodeNgInterfaceStatistics(s.entry.last, commentOptions([]string{s.comment()}))
+			err = item.writeBlock(block)
+			p.session.file(item.pcapPath)
+		}
+		if err != nil {
+			logger.Errorw("Writing pcap dns dedup summary", "error", err)
+		}
+	}
 }
 
 // Write writes a packet to all opened pcap files from all supported pcap types
@@ -68,27 +570,454 @@ func (p *Pcaps) Write(event *trace.Event, payload []byte) error {
 		return errfmt.Errorf("wrong event type given to pcap")
 	}
 
-	for k := range p.pcapCaches {
-		item, err := p.pcapCaches[k].get(event)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.session == nil {
+		// late packet: its session ended, and its files were closed
+		_ = p.stats.NotCaptured.Increment()
+		_ = p.stats.Late.Increment()
+		return nil
+	}
+	if p.paused {
+		_ = p.stats.NotCaptured.Increment()
+		return nil
+	}
+
+	// only capture packets from processes owned by the given UIDs
+	if p.uidFilter != nil {
+		if _, ok := p.uidFilter[event.UserID]; !ok {
+			return nil
+		}
+	}
+
+	var info *packetInfo
+	if p.index != nil || p.dnsDedup != nil || p.config.Sidecar || p.protocolCaches != nil ||
+		p.noiseCaches != nil || p.extractor != nil || p.portFilter != nil || p.flows != nil ||
+		p.config.StatsInterval > 0 || p.config.JA3 || p.sampler != nil || hasNATTuple(event) {
+		info = newPacketInfo(payload)
+	}
+
+	// only capture packets from or to the given ports
+	if p.portFilter != nil {
+		_, src := p.portFilter[info.srcPort]
+		_, dst := p.portFilter[info.dstPort]
+		if !src && !dst {
+			return nil
+		}
+	}
+
+	caches := p.pcapCaches
+	if p.config.FlowLogOnly {
+		caches = nil // flows are only logged (no pcap files)
+	} else if p.noiseCaches != nil && isNoisePacket(info) {
+		caches = p.noiseCaches
+	} else if p.protocolCaches != nil {
+		caches = p.cachesFor(packetProtocols(info)...)
+	}
+
+	if p.dnsDedup != nil {
+		p.writeDNSDedupSummaries(p.dnsDedup.sweep(int64(event.Timestamp)))
+	}
+
+	options := append(directionOptions(event), commentOptions(packetMetadata(event, info))...)
+	if p.config.JA3 {
+		options = append(options, commentOptions(tlsFingerprintMetadata(info))...)
+	}
+	if p.config.PacketContext {
+		options = append(options, commentOptions(contextMetadata(event))...)
+	}
+
+	var closed []*flowSummary  // flows ended by this packet (or evicted for its flow)
+	var backfill []*heldPacket // withheld packets of a flow crossing the byte threshold
+	if p.flows != nil {
+		p.writeFlowSummaries(p.flows.sweep(int64(event.Timestamp)))
+		var f *flow
+		f, closed = p.flows.packet(int64(event.Timestamp), info, event, caches)
+		if f != nil && p.connections != nil && !p.config.FlowLogOnly {
+			event, caches = p.connection(f, event, info, caches)
+		}
+		if f != nil {
+			if f.id != "" {
+				options = append(options, ngCommentOption("flow_id="+f.id))
+			}
+			if rtt, samples := f.rtt(); samples > 0 {
+				options = append(options, ngCommentOption(fmt.Sprintf("flow_rtt_us=%d", rtt/1e3)))
+			}
+			if missing := f.missing(); missing > 0 {
+				options = append(options, ngCommentOption(fmt.Sprintf("flow_missing_bytes=%d", missing)))
+			}
+		}
+		if f != nil && p.fingerprints != nil {
+			switch p.fingerprints.verdict(f) {
+			case fingerprintPending:
+				f.hold(newHeldPacket(event, payload, caches, options), flowMaxBackfill)
+				caches = nil
+			case fingerprintAllowed:
+				if f.withheld {
+					backfill = f.release()
+				}
+			case fingerprintDenied:
+				_ = p.stats.Fingerprinted.Increment(uint64(len(f.held)) + 1)
+				f.held = nil
+				caches = nil
+			}
+		} else if f != nil && f.withheld {
+			if f.bytes < p.config.FlowByteThreshold {
+				if p.config.FlowBackfill > 0 {
+					f.hold(newHeldPacket(event, payload, caches, options), int(p.config.FlowBackfill))
+				}
+				_ = p.stats.BelowThreshold.Increment()
+				caches = nil
+			} else {
+				backfill = f.release()
+			}
+		}
+	}
+
+	// packets are buffered until their process is known to be short-lived
+	if p.shortLived != nil && len(caches) > 0 {
+		p.shortLived.packet(newHeldPacket(event, payload, caches, options))
+		caches = nil
+	}
+
+	// packets are held in memory until a trigger dumps them (but written
+	// right away after a trigger)
+	if p.ringBuffer != nil && len(caches) > 0 && !p.ringBuffer.triggered(int64(event.Timestamp)) {
+		p.ringBuffer.packet(newHeldPacket(event, payload, caches, options))
+		caches = nil
+	}
+
+	for _, held := range backfill {
+		if err := p.writePacket(held.event, held.payload, held.info(), held.caches, held.options); err != nil {
+			return errfmt.WrapError(err)
+		}
+	}
+	if err := p.writePacket(event, payload, info, caches, options); err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	if len(closed) > 0 {
+		p.writeFlowSummaries(closed)
+	}
+	if interval := int64(p.config.StatsInterval); interval > 0 && int64(event.Timestamp)-p.statsAt >= interval {
+		if err := p.flushTargetStats(); err != nil {
+			logger.Errorw("Writing pcap stats", "error", err)
+		}
+		p.statsAt = int64(event.Timestamp)
+	}
+	if p.memory != nil {
+		p.memory.packet()
+	}
+
+	return nil
+}
+
+// writePacket writes a packet (with its metadata options) to the pcap files
+// of the given caches.
+func (p *Pcaps) writePacket(event *trace.Event, payload []byte, info *packetInfo, caches map[PcapType]*PcapCache, options []ngOption) error {
+	written := false
+
+	// packets are sampled by their L7 (or transport) protocol (if enabled)
+	var protocol string
+	if p.sampler != nil {
+		protocol = sampleProtocol(info)
+		options = append(options[:len(options):len(options)], ngCommentOption("sampled_protocol="+protocol))
+	}
+
+	for k := range caches {
+		if p.windows != nil && !p.windows.active(int64(event.Timestamp), getItemTarget(event, k)) {
+			_ = p.stats.OutOfWindow.Increment()
+			continue
+		}
+		limits := caches[k].limits
+		if limits != nil && limits.reached(event) {
+			_ = p.stats.CapReached.Increment()
+			continue
+		}
+		item, err := caches[k].get(event)
 		if err != nil {
 			return errfmt.WrapError(err)
 		}
-		err = item.write(event, payload)
+		if p.dnsDedup != nil {
+			if key, ok := dnsQueryKey(info, getItemTarget(event, k)); ok {
+				summary, duplicate := p.dnsDedup.check(int64(event.Timestamp), key, event, caches[k])
+				if summary != nil {
+					p.writeDNSDedupSummaries([]*dnsDedupSummary{summary})
+				}
+				if duplicate {
+					_ = p.stats.DNSDeduplicated.Increment()
+					continue
+				}
+			}
+		}
+		if item.limiter != nil && !item.limiter.allow(int64(event.Timestamp), len(payload)) {
+			_ = p.stats.RateLimited.Increment()
+			continue
+		}
+		if p.sampler != nil && !p.sampler.sample(getItemTarget(event, k), protocol) {
+			_ = p.stats.Unsampled.Increment()
+			continue
+		}
+		itemOptions := options
+		if metadata := itemMetadata(event, k); len(metadata) > 0 {
+			itemOptions = append(itemOptions[:len(itemOptions):len(itemOptions)], commentOptions(metadata)...)
+		}
+		offset, err := item.write(event, payload, itemOptions)
 		if err != nil {
 			return errfmt.WrapError(err)
 		}
+		_ = p.stats.Written.Increment()
+		_ = p.stats.WrittenBytes.Increment(uint64(len(payload)))
+		if limits != nil {
+			limits.written(event, len(payload))
+		}
+		if item.sidecar != nil {
+			err = item.writeSidecar(int64(event.Timestamp), offset, info)
+			if err != nil {
+				return errfmt.WrapError(err)
+			}
+		}
+		if item.stats != nil {
+			item.stats.packet(int64(event.Timestamp), info, len(payload))
+		}
+		target := getItemTarget(event, k)
+		p.session.target(target, item)
+		written = true
+		if p.index != nil {
+			err = p.index.add(int64(event.Timestamp), info, target, item, offset)
+			if err != nil {
+				return errfmt.WrapError(err)
+			}
+		}
+	}
+
+	if written {
+		p.session.packet(int64(event.Timestamp))
+		if p.extractor != nil {
+			p.extractor.packet(int64(event.Timestamp), info)
+		}
 	}
 
 	return nil
 }
 
-// Destroy destroys all opened pcap files from all supported pcap types
-func (p *Pcaps) Destroy() error {
-	for k := range p.pcapCaches {
-		err := p.pcapCaches[k].destroy()
+// WriteTLSKeyLog embeds TLS key log lines (NSS key log format), obtained from
+// the process described by the given event, into all pcap files the packets of
+// that process are written to (as a pcapng decryption secrets block), so
+// Wireshark is able to decrypt its TLS traffic without an external key log.
+func (p *Pcaps) WriteTLSKeyLog(event *trace.Event, keyLog []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.tlsKeyLog || len(keyLog) == 0 || p.session == nil {
+		return nil
+	}
+	if keyLog[len(keyLog)-1] != '\n' {
+		keyLog = append(keyLog, '\n')
+	}
+
+	block := encodeNgDecryptionSecrets(ngSecretsTypeTLSKeyLog, keyLog)
+
+	// TLS runs over TCP
+	caches := p.cachesFor(protocolTCP)
+
+	for k := range caches {
+		item, err := caches[k].get(event)
 		if err != nil {
 			return errfmt.WrapError(err)
 		}
+		if err := item.writeBlock(block); err != nil {
+			return errfmt.WrapError(err)
+		}
+		p.session.file(item.pcapPath)
+	}
+
+	return nil
+}
+
+// Pause stops capturing packets (until resumed).
+func (p *Pcaps) Pause() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.paused = true
+}
+
+// Resume resumes capturing packets.
+func (p *Pcaps) Resume() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.paused = false
+}
+
+// Paused returns true if capture is paused.
+func (p *Pcaps) Paused() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.paused
+}
+
+// StartSession starts a new capture session.
+func (p *Pcaps) StartSession() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.destroyed {
+		return errfmt.Errorf("capture destroyed")
+	}
+	if p.session != nil {
+		return errfmt.Errorf("capture session already started")
+	}
+	p.session = newCaptureSession(p.config.FlowTimeline)
+
+	return nil
+}
+
+// EndSession ends the current capture session: all its pcap files are closed
+// and its manifest is written. No packets are captured until a new session is
+// started.
+func (p *Pcaps) EndSession() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.session == nil {
+		return errfmt.Errorf("no capture session started")
+	}
+
+	return p.endSession()
+}
+
+// InSession returns true if a capture session is started.
+func (p *Pcaps) InSession() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.session != nil
+}
+
+// ActiveTargets returns the number of capture targets currently active (pcap
+// files kept open). Targets stop being active once evicted from their cache
+// (the least recently used ones, when too many are open) or when the capture
+// session ends.
+func (p *Pcaps) ActiveTargets() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	active := 0
+	for _, caches := range p.allCaches() {
+		for k := range caches {
+			active += caches[k].itemCache.Len()
+		}
+	}
+
+	return active
+}
+
+// Flush flushes all open pcap files (of the current session, if any) and
+// commits them to disk.
+func (p *Pcaps) Flush() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, caches := range p.allCaches() {
+		for k := range caches {
+			if err := caches[k].sync(); err != nil {
+				return errfmt.WrapError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Pcaps) endSession() error {
+	if p.dnsDedup != nil {
+		p.writeDNSDedupSummaries(p.dnsDedup.flush())
+	}
+	if p.sampler != nil {
+		p.sampler.reset()
+	}
+	if p.ringBuffer != nil {
+		p.ringBuffer.reset() // never triggered
+	}
+	if p.extractor != nil {
+		p.extractor.flush()
+	}
+	if p.flows != nil {
+		p.writeFlowSummaries(p.flows.flush())
+	}
+	if err := p.flushTargetStats(); err != nil {
+		logger.Errorw("Writing pcap stats", "error", err)
+	}
+	for _, caches := range p.allCaches() {
+		for k := range caches {
+			err := caches[k].destroy()
+			if err != nil {
+				return errfmt.WrapError(err)
+			}
+		}
+	}
+
+	session := p.session
+	p.session = nil
+
+	if session.timeline != nil {
+		if err := session.writeTimeline(p.output); err != nil {
+			logger.Errorw("Writing pcap flow timeline", "error", err)
+		}
+	}
+
+	return errfmt.WrapError(session.writeManifest(p.output, p.config))
+}
+
+// Destroy destroys all opened pcap files from all supported pcap types. Packets
+// written afterwards are dropped (as late packets).
+func (p *Pcaps) Destroy() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.destroyed = true
+
+	if p.session != nil {
+		if err := p.endSession(); err != nil {
+			return errfmt.WrapError(err)
+		}
+	}
+	if p.index != nil {
+		if err := p.index.close(); err != nil {
+			return errfmt.WrapError(err)
+		}
+		p.index = nil
+	}
+	if p.extractor != nil {
+		if err := p.extractor.close(); err != nil {
+			return errfmt.WrapError(err)
+		}
+		p.extractor = nil
+	}
+	if p.flowLog != nil {
+		if err := p.flowLog.close(); err != nil {
+			return errfmt.WrapError(err)
+		}
+		p.flowLog = nil
+	}
+	if p.zeekLog != nil {
+		if err := p.zeekLog.close(); err != nil {
+			return errfmt.WrapError(err)
+		}
+		p.zeekLog = nil
+	}
+	for protocol, o := range p.protocolOutputs {
+		if err := o.dir.Close(); err != nil {
+			return errfmt.WrapError(err)
+		}
+		delete(p.protocolOutputs, protocol)
+	}
+	if p.hooks != nil {
+		p.hooks.wait() // for hooks of files just finalized
 	}
 
 	return nil
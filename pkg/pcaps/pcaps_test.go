@@ -0,0 +1,167 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// newTestPcaps creates a Pcaps instance writing into a temporary directory.
+// NOTE: the output directory is a package global: tests using it must not run
+// in parallel.
+func newTestPcaps(t *testing.T, cfg config.PcapsConfig) (*Pcaps, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	outDir, err := utils.OpenExistingDir(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = outDir.Close() })
+
+	p, err := New(cfg, outDir)
+	require.NoError(t, err)
+
+	return p, dir
+}
+
+// newTestEvent creates a network capture event.
+func newTestEvent(ts int) *trace.Event {
+	return &trace.Event{
+		Timestamp:    ts,
+		EventID:      int(events.NetPacketCapture),
+		HostThreadID: 1000,
+		ProcessName:  "proc",
+	}
+}
+
+// newTestUDPPacket builds an IPv4 UDP packet prefixed by the fake (null) L2
+// header, as given to Pcaps.Write().
+func newTestUDPPacket(t *testing.T, src, dst string, srcPort, dstPort uint16, payload []byte) []byte {
+	t.Helper()
+
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP(src).To4(),
+		DstIP:    net.ParseIP(dst).To4(),
+	}
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+
+	return serializeTestPacket(t, ip, udp, gopacket.Payload(payload))
+}
+
+// serializeTestPacket serializes the given L3+ layers and prefixes them with
+// the fake (null) L2 header.
+func serializeTestPacket(t *testing.T, l ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, l...))
+
+	family := uint32(2)
+	if _, ok := l[0].(*layers.IPv6); ok {
+		family = 28
+	}
+	l2 := make([]byte, 4)
+	binary.BigEndian.PutUint32(l2, family)
+
+	return append(l2, buf.Bytes()...)
+}
+
+// readTestPcap returns all packets written to the given pcap file.
+func readTestPcap(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	r, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+
+	var pkts [][]byte
+	for {
+		data, _, err := r.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		pkts = append(pkts, data)
+	}
+
+	return pkts
+}
+
+func TestPcapsUidFilter(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		CaptureUser:   true,
+		UidFilter:     []uint32{1000},
+	})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+
+	for i, uid := range []int{1000, 0, 1001, 1000} {
+		e := newTestEvent(i + 1)
+		e.UserID = uid
+		require.NoError(t, p.Write(e, pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")), 2)
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapUserDir, "1000.pcap")), 2)
+	require.NoFileExists(t, filepath.Join(dir, pcapUserDir, "0.pcap"))
+	require.NoFileExists(t, filepath.Join(dir, pcapUserDir, "1001.pcap"))
+}
+
+func TestPcapsActiveTargets(t *testing.T) {
+	p, _ := newTestPcaps(t, config.PcapsConfig{CaptureProcess: true})
+
+	packet := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 5678, nil)
+	write := func(tid int) {
+		event := newTestEvent(tid)
+		event.HostThreadID = tid
+		require.NoError(t, p.Write(event, packet))
+	}
+
+	require.Equal(t, 0, p.ActiveTargets())
+
+	write(1)
+	write(2)
+	write(2)
+	require.Equal(t, 2, p.ActiveTargets())
+
+	// least recently used targets are evicted once too many are open
+	for tid := 3; tid <= pcapsToCache+10; tid++ {
+		write(tid)
+	}
+	require.Equal(t, pcapsToCache, p.ActiveTargets())
+
+	// all targets are closed when the session ends
+	require.NoError(t, p.EndSession())
+	require.Equal(t, 0, p.ActiveTargets())
+
+	require.NoError(t, p.StartSession())
+	write(1)
+	require.Equal(t, 1, p.ActiveTargets())
+	require.NoError(t, p.Destroy())
+	require.Equal(t, 0, p.ActiveTargets())
+}
@@ -0,0 +1,81 @@
+package pcaps
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+//
+// Port presets are named groups of ports that can be given, instead of the
+// ports themselves, to the capture port filter (e.g. "k8s-control-plane").
+// New presets can be registered with RegisterPortPreset().
+//
+
+var portPresets = map[string][]uint16{
+	// kubernetes api server, etcd (client and peer) and kubelet
+	"k8s-control-plane": {6443, 2379, 2380, 10250},
+}
+
+// RegisterPortPreset registers a new port preset. It must be called before
+// capture flags are parsed (e.g. from an init function).
+func RegisterPortPreset(name string, ports ...uint16) error {
+	name = strings.ToLower(name)
+
+	if name == "" || len(ports) == 0 {
+		return errfmt.Errorf("port preset needs a name and at least one port")
+	}
+	if _, err := strconv.ParseUint(name, 10, 16); err == nil {
+		return errfmt.Errorf("port preset name can't be a port number: %s", name)
+	}
+	if _, ok := portPresets[name]; ok {
+		return errfmt.Errorf("port preset already registered: %s", name)
+	}
+	portPresets[name] = append([]uint16(nil), ports...)
+
+	return nil
+}
+
+// PortPresets returns the names of all registered port presets.
+func PortPresets() []string {
+	names := make([]string, 0, len(portPresets))
+	for name := range portPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParsePorts parses a comma separated list of ports and port preset names,
+// returning all the (distinct) ports.
+func ParsePorts(list string) ([]uint16, error) {
+	var ports []uint16
+	seen := make(map[uint16]struct{})
+
+	add := func(port uint16) {
+		if _, ok := seen[port]; !ok {
+			seen[port] = struct{}{}
+			ports = append(ports, port)
+		}
+	}
+
+	for _, field := range strings.Split(list, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if preset, ok := portPresets[field]; ok {
+			for _, port := range preset {
+				add(port)
+			}
+			continue
+		}
+		port, err := strconv.ParseUint(field, 10, 16)
+		if err != nil || port == 0 {
+			return nil, errfmt.Errorf("invalid port or unknown port preset: %s", field)
+		}
+		add(uint16(port))
+	}
+
+	return ports, nil
+}
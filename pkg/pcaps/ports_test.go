@@ -0,0 +1,67 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestParsePorts(t *testing.T) {
+	t.Parallel()
+
+	ports, err := ParsePorts("k8s-control-plane")
+	require.NoError(t, err)
+	require.Equal(t, []uint16{6443, 2379, 2380, 10250}, ports)
+
+	ports, err = ParsePorts("53,K8S-Control-Plane,6443")
+	require.NoError(t, err)
+	require.Equal(t, []uint16{53, 6443, 2379, 2380, 10250}, ports)
+
+	_, err = ParsePorts("k8s")
+	require.Error(t, err)
+	_, err = ParsePorts("0")
+	require.Error(t, err)
+	_, err = ParsePorts("65536")
+	require.Error(t, err)
+}
+
+func TestRegisterPortPreset(t *testing.T) {
+	// registers into the global presets: not parallel
+	t.Cleanup(func() { delete(portPresets, "test-preset") })
+
+	require.NoError(t, RegisterPortPreset("Test-Preset", 8080, 8443))
+	require.Contains(t, PortPresets(), "test-preset")
+
+	ports, err := ParsePorts("test-preset,k8s-control-plane")
+	require.NoError(t, err)
+	require.Equal(t, []uint16{8080, 8443, 6443, 2379, 2380, 10250}, ports)
+
+	require.Error(t, RegisterPortPreset("test-preset", 1))       // already registered
+	require.Error(t, RegisterPortPreset("k8s-control-plane", 1)) // already registered
+	require.Error(t, RegisterPortPreset("8080", 8080))           // ambiguous name
+	require.Error(t, RegisterPortPreset("empty"))                // no ports
+}
+
+func TestPcapsPortFilterPreset(t *testing.T) {
+	ports, err := ParsePorts("k8s-control-plane")
+	require.NoError(t, err)
+
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, PortFilter: ports})
+
+	apiServer := newTestTCPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 6443, []byte("api"))
+	etcdPeer := newTestTCPPacket(t, "10.0.0.2", "10.0.0.3", 2380, 40001, []byte("etcd")) // reply
+	kubelet := newTestTCPPacket(t, "10.0.0.1", "10.0.0.4", 40002, 10250, []byte("kubelet"))
+	web := newTestTCPPacket(t, "10.0.0.1", "10.0.0.5", 40003, 443, []byte("web"))
+	dns := newTestUDPPacket(t, "10.0.0.1", "10.0.0.53", 40004, 53, []byte("dns"))
+
+	for i, pkt := range [][]byte{apiServer, web, etcdPeer, dns, kubelet} {
+		require.NoError(t, p.Write(newTestEvent(i), pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	pkts := readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Equal(t, [][]byte{apiServer, etcdPeer, kubelet}, pkts)
+}
@@ -0,0 +1,131 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/google/gopacket/layers"
+	"golang.org/x/sys/unix"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// Packets of given protocols might be written to their own output directories
+// (e.g. DNS to a high retention volume and bulk TCP to a scratch volume). Each
+// protocol output directory holds the same pcap directory structure as the
+// capture output directory, with pcap files for all enabled pcap types.
+//
+// DNS and DHCP take precedence over their transport protocol: DNS packets only
+// go to the TCP or UDP output directories if no DNS output directory was given
+// (the same for DHCP, v4 and v6, and UDP).
+//
+
+const (
+	protocolDNS  = "dns"
+	protocolDHCP = "dhcp"
+	protocolTCP  = "tcp"
+	protocolUDP  = "udp"
+	protocolICMP = "icmp"
+	protocolSCTP = "sctp"
+)
+
+// captureProtocols are the protocols that can have their own output directory.
+var captureProtocols = map[string]struct{}{
+	protocolDNS:  {},
+	protocolDHCP: {},
+	protocolTCP:  {},
+	protocolUDP:  {},
+	protocolICMP: {},
+	protocolSCTP: {},
+}
+
+// packetProtocols returns the protocols of a packet, from the most specific to
+// the least specific one.
+func packetProtocols(info *packetInfo) []string {
+	var protocols []string
+
+	if info.packet.Layer(layers.LayerTypeDNS) != nil {
+		protocols = append(protocols, protocolDNS)
+	}
+	if info.packet.Layer(layers.LayerTypeDHCPv4) != nil || info.packet.Layer(layers.LayerTypeDHCPv6) != nil {
+		protocols = append(protocols, protocolDHCP)
+	}
+
+	switch info.protocol {
+	case layers.IPProtocolTCP:
+		protocols = append(protocols, protocolTCP)
+	case layers.IPProtocolUDP:
+		protocols = append(protocols, protocolUDP)
+	case layers.IPProtocolICMPv4, layers.IPProtocolICMPv6:
+		protocols = append(protocols, protocolICMP)
+	case layers.IPProtocolSCTP:
+		protocols = append(protocols, protocolSCTP)
+	}
+
+	return protocols
+}
+
+// openProtocolOutputs validates (creating them if needed) and opens all the
+// protocol output directories.
+func openProtocolOutputs(dirs map[string]string) (map[string]*pcapOutput, error) {
+	outputs := make(map[string]*pcapOutput, len(dirs))
+
+	closeAll := func() {
+		for _, o := range outputs {
+			_ = o.dir.Close()
+		}
+	}
+
+	for _, protocol := range sortedKeys(protocolSet(dirs)) { // deterministic errors
+		if _, ok := captureProtocols[protocol]; !ok {
+			closeAll()
+			return nil, errfmt.Errorf("unsupported pcap output protocol: %s", protocol)
+		}
+		output, err := openProtocolOutput(dirs[protocol])
+		if err != nil {
+			closeAll()
+			return nil, errfmt.Errorf("invalid %s pcap output dir: %v", protocol, err)
+		}
+		outputs[protocol] = output
+	}
+
+	return outputs, nil
+}
+
+// openProtocolOutput validates (creating it if needed) and opens a protocol
+// output directory.
+func openProtocolOutput(dir string) (*pcapOutput, error) {
+	if dir == "" {
+		return nil, errfmt.Errorf("empty path")
+	}
+
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	if err := unix.Access(path, unix.W_OK|unix.X_OK); err != nil {
+		return nil, errfmt.Errorf("%s is not writable: %v", path, err)
+	}
+
+	file, err := utils.OpenExistingDir(path)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	return &pcapOutput{dir: file, path: path}, nil
+}
+
+// protocolSet returns the protocols of the given protocol output directories.
+func protocolSet(dirs map[string]string) map[string]struct{} {
+	set := make(map[string]struct{}, len(dirs))
+	for protocol := range dirs {
+		set[protocol] = struct{}{}
+	}
+
+	return set
+}
@@ -0,0 +1,161 @@
+package pcaps
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+// newTestTCPPacket builds an IPv4 TCP packet, as given to Pcaps.Write().
+func newTestTCPPacket(t *testing.T, src, dst string, srcPort, dstPort uint16, payload []byte) []byte {
+	t.Helper()
+
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.ParseIP(src).To4(),
+		DstIP:    net.ParseIP(dst).To4(),
+	}
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		ACK:     true,
+		Window:  1024,
+	}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+
+	return serializeTestPacket(t, ip, tcp, gopacket.Payload(payload))
+}
+
+func TestPcapsProtocolDirs(t *testing.T) {
+	dnsDir := filepath.Join(t.TempDir(), "retention")
+	tcpDir := filepath.Join(t.TempDir(), "scratch")
+
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		ProtocolDirs: map[string]string{
+			"dns": dnsDir,
+			"tcp": tcpDir,
+		},
+	})
+
+	dns := newTestDNSQuery(t, "example.com", layers.DNSTypeA)
+	tcp := newTestTCPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 443, []byte("bulk"))
+	udp := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 5000, []byte("other"))
+
+	require.NoError(t, p.Write(newTestEvent(1), dns))
+	require.NoError(t, p.Write(newTestEvent(2), tcp))
+	require.NoError(t, p.Write(newTestEvent(3), udp))
+	require.NoError(t, p.Write(newTestEvent(4), tcp))
+	require.NoError(t, p.Destroy())
+
+	single := filepath.Join(pcapSingleDir, "single.pcap")
+
+	require.Equal(t, [][]byte{dns}, readTestPcap(t, filepath.Join(dnsDir, single)))
+	require.Equal(t, [][]byte{tcp, tcp}, readTestPcap(t, filepath.Join(tcpDir, single)))
+	require.Equal(t, [][]byte{udp}, readTestPcap(t, filepath.Join(dir, single)))
+
+	// files written to protocol dirs are listed (by absolute path) in the manifest
+	manifests, err := filepath.Glob(filepath.Join(dir, pcapDir, "manifest-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	manifest, err := os.ReadFile(manifests[0])
+	require.NoError(t, err)
+	require.Contains(t, string(manifest), filepath.Join(dnsDir, single))
+	require.Contains(t, string(manifest), filepath.Join(tcpDir, single))
+}
+
+func TestPcapsProtocolDirsValidation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	testCases := []struct {
+		name string
+		dirs map[string]string
+		err  string
+	}{
+		{
+			name: "unsupported protocol",
+			dirs: map[string]string{"http": t.TempDir()},
+			err:  "unsupported pcap output protocol: http",
+		},
+		{
+			name: "not a directory",
+			dirs: map[string]string{"dns": file},
+			err:  "invalid dns pcap output dir",
+		},
+		{
+			name: "not writable",
+			dirs: map[string]string{"tcp": "/proc/tracee-pcaps"},
+			err:  "invalid tcp pcap output dir",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			outDir, err := utils.OpenExistingDir(t.TempDir())
+			require.NoError(t, err)
+			defer outDir.Close()
+
+			_, err = New(config.PcapsConfig{CaptureSingle: true, ProtocolDirs: tc.dirs}, outDir)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestPcapsProtocolDirsDHCP(t *testing.T) {
+	dhcpDir := filepath.Join(t.TempDir(), "audit")
+
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		ProtocolDirs:  map[string]string{"dhcp": dhcpDir},
+	})
+
+	mac := net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}
+	newDHCP := func(op layers.DHCPOp, msgType layers.DHCPMsgType, yiaddr net.IP) []byte {
+		dhcp := &layers.DHCPv4{
+			Operation:    op,
+			HardwareType: layers.LinkTypeEthernet,
+			HardwareLen:  6,
+			Xid:          0x3903f326,
+			ClientIP:     net.IPv4zero,
+			YourClientIP: yiaddr,
+			NextServerIP: net.IPv4zero,
+			RelayAgentIP: net.IPv4zero,
+			ClientHWAddr: mac,
+			Options: layers.DHCPOptions{
+				layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(msgType)}),
+			},
+		}
+		buf := gopacket.NewSerializeBuffer()
+		require.NoError(t, dhcp.SerializeTo(buf, gopacket.SerializeOptions{}))
+		return buf.Bytes()
+	}
+
+	discover := newTestUDPPacket(t, "0.0.0.0", "255.255.255.255", 68, 67,
+		newDHCP(layers.DHCPOpRequest, layers.DHCPMsgTypeDiscover, net.IPv4zero))
+	offer := newTestUDPPacket(t, "192.168.1.1", "255.255.255.255", 67, 68,
+		newDHCP(layers.DHCPOpReply, layers.DHCPMsgTypeOffer, net.IP{192, 168, 1, 100}))
+	udp := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 5000, []byte("other"))
+
+	require.NoError(t, p.Write(newTestEvent(1), discover))
+	require.NoError(t, p.Write(newTestEvent(2), udp))
+	require.NoError(t, p.Write(newTestEvent(3), offer))
+	require.NoError(t, p.Destroy())
+
+	single := filepath.Join(pcapSingleDir, "single.pcap")
+
+	require.Equal(t, [][]byte{discover, offer}, readTestPcap(t, filepath.Join(dhcpDir, single)))
+	require.Equal(t, [][]byte{udp}, readTestPcap(t, filepath.Join(dir, single)))
+}
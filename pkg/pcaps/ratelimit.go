@@ -0,0 +1,86 @@
+package pcaps
+
+//
+// Each capture target (each pcap file) might be rate limited by packets per
+// second, bytes per second or both. When both limits are set, a packet is only
+// written if it fits in both of them (and it only consumes from both buckets
+// when written). Rates are measured using the event timestamps, so bursts of
+// up to 1 second worth of packets (or bytes) are allowed.
+//
+
+// tokenBucket is a token bucket refilled at rate tokens per second.
+type tokenBucket struct {
+	rate   float64 // tokens per second (also the bucket capacity)
+	tokens float64 // available tokens
+}
+
+func newTokenBucket(rate uint64) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(rate),
+		tokens: float64(rate),
+	}
+}
+
+func (b *tokenBucket) refill(elapsed int64) {
+	b.tokens += float64(elapsed) / 1e9 * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+}
+
+// rateLimiter limits the packets and/or bytes per second written to a target.
+type rateLimiter struct {
+	packets *tokenBucket // packets per second (nil: unlimited)
+	bytes   *tokenBucket // bytes per second (nil: unlimited)
+	last    int64        // timestamp of last refill (nanoseconds)
+}
+
+// newRateLimiter returns a rate limiter, or nil if there are no limits.
+func newRateLimiter(packetsPerSec, bytesPerSec uint64) *rateLimiter {
+	if packetsPerSec == 0 && bytesPerSec == 0 {
+		return nil
+	}
+
+	l := &rateLimiter{}
+	if packetsPerSec > 0 {
+		l.packets = newTokenBucket(packetsPerSec)
+	}
+	if bytesPerSec > 0 {
+		l.bytes = newTokenBucket(bytesPerSec)
+	}
+
+	return l
+}
+
+// allow returns true if a packet of given size, at given time, is within the
+// limits (consuming from the buckets), or false if it should be dropped.
+func (l *rateLimiter) allow(now int64, size int) bool {
+	if l.last != 0 && now > l.last {
+		elapsed := now - l.last
+		if l.packets != nil {
+			l.packets.refill(elapsed)
+		}
+		if l.bytes != nil {
+			l.bytes.refill(elapsed)
+		}
+	}
+	if now > l.last {
+		l.last = now
+	}
+
+	if l.packets != nil && l.packets.tokens < 1 {
+		return false
+	}
+	if l.bytes != nil && l.bytes.tokens < float64(size) {
+		return false
+	}
+
+	if l.packets != nil {
+		l.packets.tokens--
+	}
+	if l.bytes != nil {
+		l.bytes.tokens -= float64(size)
+	}
+
+	return true
+}
@@ -0,0 +1,67 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestRateLimiterBytes(t *testing.T) {
+	t.Parallel()
+
+	l := newRateLimiter(0, 1000) // 1000 bytes per second
+	sec := int64(time.Second)
+
+	// few big packets: byte limit kicks in (no packet limit set)
+	require.True(t, l.allow(sec, 600))
+	require.False(t, l.allow(sec, 600))
+	require.True(t, l.allow(sec, 400))
+	require.False(t, l.allow(sec, 1))
+
+	// half a second later: half of the bytes are available again
+	require.True(t, l.allow(sec+sec/2, 500))
+	require.False(t, l.allow(sec+sec/2, 1))
+}
+
+func TestRateLimiterPacketsAndBytes(t *testing.T) {
+	t.Parallel()
+
+	l := newRateLimiter(3, 1000)
+	sec := int64(time.Second)
+
+	// small packets: packet limit kicks in first
+	require.True(t, l.allow(sec, 10))
+	require.True(t, l.allow(sec, 10))
+	require.True(t, l.allow(sec, 10))
+	require.False(t, l.allow(sec, 10))
+
+	// a packet rejected by the byte limit does not consume a packet token
+	l = newRateLimiter(3, 1000)
+	require.False(t, l.allow(sec, 2000))
+	require.True(t, l.allow(sec, 10))
+	require.True(t, l.allow(sec, 10))
+	require.True(t, l.allow(sec, 10))
+
+	require.Nil(t, newRateLimiter(0, 0))
+}
+
+func TestPcapsRateLimitBytes(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:  true,
+		RateLimitBytes: 1000,
+	})
+
+	big := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, make([]byte, 400))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.Write(newTestEvent(int(time.Second)+i), big))
+	}
+	require.NoError(t, p.Destroy())
+
+	// 2 packets (~432 bytes each) fit in 1000 bytes per second
+	require.Len(t, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")), 2)
+	require.Equal(t, uint64(8), p.Stats().RateLimited.Get())
+}
@@ -0,0 +1,278 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Ring pcap files have a fixed maximum size: once full, new blocks overwrite
+// the oldest ones (like a ring buffer, but on disk), so the file always holds
+// the most recent packets of its capture target.
+//
+// Keeping the file parseable at all times (and not only when it is closed) is
+// what makes this tricky, as pcapng files are a sequence of blocks that can
+// only be read from the beginning:
+//
+// 1. The file header (section header and interface description blocks) is
+//    never overwritten: the ring starts right after it.
+//
+// 2. A new block rarely ends exactly where an old block starts. The gap left
+//    in between (the remains of overwritten blocks) is covered by a filler
+//    block (a custom block readers skip). If the gap is too small to hold a
+//    filler, the next old block is dropped as well (and the gap grows).
+//
+// 3. When a new block does not fit before the end of the file, the file is
+//    truncated right after the newest block (dropping the oldest blocks at the
+//    end of the file) and writing restarts at the beginning of the ring.
+//
+// 4. The filler block also marks where the newest block ends: when the file
+//    is reopened (pcap files might be closed, and reopened, by the LRU cache)
+//    the ring position is recovered by looking for it.
+//
+// Blocks are written in ring order, so, after the first wrap, the file holds
+// the newest packets first, followed by the oldest ones (tools like reordercap
+// sort them). A crash in between writing a block and its filler might leave
+// the file with a corrupted block (readers stop at it).
+//
+
+const (
+	ngBlockTypeCustom   uint32 = 0x40000BAD // custom block (not to be copied)
+	ngFillerBlockLength int64  = 16         // type, length, PEN and length
+)
+
+// ringBlock is a block written to a ring pcap file.
+type ringBlock struct {
+	offset int64
+	length int64
+}
+
+// ringFile writes blocks to a fixed size pcap file, overwriting the oldest
+// blocks when full.
+type ringFile struct {
+	file   *os.File
+	start  int64       // ring start (end of the file header)
+	size   int64       // max file size
+	next   int64       // offset the next block is written at
+	end    int64       // current file size
+	blocks []ringBlock // blocks in the file, from the oldest to the newest
+}
+
+// newRingPcap creates (or reopens) a ring pcap file, of the given max size and
+// packet direction (if split by direction), under the given output directory.
+func newRingPcap(output *pcapOutput, e *trace.Event, t PcapType, direction string, size uint64) (*Pcap, error) {
+	pcapFilePath, err := getPcapFileName(output, e, t, direction)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	file, err := utils.OpenAt(outputDirectory, pcapFilePath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	logger.Debugw("ring pcap file (re)opened", "filename", pcapFilePath)
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, errfmt.WrapError(err)
+	}
+
+	ring, err := openRingFile(file, int64(size), ngWriterOptions(e, t))
+	if err != nil {
+		_ = file.Close()
+		return nil, errfmt.WrapError(err)
+	}
+
+	// all blocks are written by the ring: the gopacket writer is a placeholder
+	writer, err := pcapgo.NewNgWriterInterface(io.Discard, fake, pcapgo.DefaultNgWriterOptions)
+	if err != nil {
+		_ = file.Close()
+		return nil, errfmt.WrapError(err)
+	}
+
+	return &Pcap{
+		pcapType:   t,
+		pcapPath:   pcapFilePath,
+		pcapFile:   file,
+		pcapWriter: writer,
+		ring:       ring,
+		created:    stat.Size() == 0,
+	}, nil
+}
+
+// openRingFile writes the file header (with given writer options) to an empty
+// file, or recovers the ring state of an existing file.
+func openRingFile(file *os.File, size int64, options pcapgo.NgWriterOptions) (*ringFile, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	r := &ringFile{file: file, size: size}
+
+	if stat.Size() == 0 {
+		writer, err := pcapgo.NewNgWriterInterface(file, fake, options)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		if err := writer.Flush(); err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		r.start, err = file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		r.next, r.end = r.start, r.start
+	} else if err := r.recover(stat.Size()); err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	if r.size-r.start < ngFillerBlockLength {
+		return nil, errfmt.Errorf("ring pcap file size too small: %d", size)
+	}
+
+	return r, nil
+}
+
+// recover rebuilds the ring state from the blocks of an existing file.
+func (r *ringFile) recover(fileSize int64) error {
+	data := make([]byte, fileSize)
+	if _, err := r.file.ReadAt(data, 0); err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	var blocks []ringBlock
+	filler := int64(-1)
+	header := true
+
+	for offset := int64(0); offset+12 <= fileSize; {
+		blockType := binary.LittleEndian.Uint32(data[offset:])
+		length := int64(binary.LittleEndian.Uint32(data[offset+4:]))
+		if length < 12 || offset+length > fileSize {
+			fileSize = offset // corrupted tail: drop it
+			break
+		}
+		switch {
+		case header && (blockType == ngBlockTypeSectionHeader || blockType == ngBlockTypeInterfaceDescription):
+			r.start = offset + length
+		case blockType == ngBlockTypeCustom:
+			header = false
+			filler = offset
+		default:
+			header = false
+			blocks = append(blocks, ringBlock{offset: offset, length: length})
+		}
+		offset += length
+	}
+
+	r.end = fileSize
+	r.next = fileSize
+	r.blocks = blocks
+
+	if filler >= 0 {
+		// blocks after the filler are the oldest ones
+		r.next = filler
+		r.blocks = nil
+		for _, b := range blocks {
+			if b.offset > filler {
+				r.blocks = append(r.blocks, b)
+			}
+		}
+		for _, b := range blocks {
+			if b.offset < filler {
+				r.blocks = append(r.blocks, b)
+			}
+		}
+	}
+
+	return nil
+}
+
+// write writes a block to the ring and returns the offset it was written at.
+func (r *ringFile) write(block []byte) (int64, error) {
+	length := int64(len(block))
+	if r.start+length+ngFillerBlockLength > r.size {
+		return 0, errfmt.Errorf("block bigger than ring pcap file: %d bytes", length)
+	}
+
+	// no room until the end of the file: drop the oldest blocks and wrap
+	if r.next+length > r.size {
+		for len(r.blocks) > 0 && r.blocks[0].offset >= r.next {
+			r.blocks = r.blocks[1:]
+		}
+		if err := r.truncate(r.next); err != nil {
+			return 0, errfmt.WrapError(err)
+		}
+		r.next = r.start
+	}
+
+	blockEnd := r.next + length
+
+	// drop the blocks (partially) overwritten by the new one
+	for len(r.blocks) > 0 && r.blocks[0].offset >= r.next && r.blocks[0].offset < blockEnd {
+		r.blocks = r.blocks[1:]
+	}
+
+	// cover the gap up to the oldest block with a filler (or drop the rest)
+	for {
+		if len(r.blocks) == 0 || r.blocks[0].offset < r.next {
+			// no older blocks ahead: the new block is the last one
+			if r.end > blockEnd {
+				if err := r.truncate(blockEnd); err != nil {
+					return 0, errfmt.WrapError(err)
+				}
+			}
+			break
+		}
+		gap := r.blocks[0].offset - blockEnd
+		if gap >= ngFillerBlockLength {
+			if _, err := r.file.WriteAt(encodeNgFiller(gap), blockEnd); err != nil {
+				return 0, errfmt.WrapError(err)
+			}
+			break
+		}
+		r.blocks = r.blocks[1:]
+	}
+
+	offset := r.next
+	if _, err := r.file.WriteAt(block, offset); err != nil {
+		return 0, errfmt.WrapError(err)
+	}
+	r.blocks = append(r.blocks, ringBlock{offset: offset, length: length})
+	r.next = blockEnd
+	if r.end < blockEnd {
+		r.end = blockEnd
+	}
+
+	return offset, nil
+}
+
+func (r *ringFile) truncate(size int64) error {
+	if err := r.file.Truncate(size); err != nil {
+		return errfmt.WrapError(err)
+	}
+	r.end = size
+
+	return nil
+}
+
+// encodeNgFiller encodes a custom block, of the given length, to be skipped
+// by readers.
+func encodeNgFiller(length int64) []byte {
+	b := make([]byte, length)
+	binary.LittleEndian.PutUint32(b[0:], ngBlockTypeCustom)
+	binary.LittleEndian.PutUint32(b[4:], uint32(length))
+	binary.LittleEndian.PutUint32(b[8:], 0) // private enterprise number (none)
+	binary.LittleEndian.PutUint32(b[length-4:], uint32(length))
+
+	return b
+}
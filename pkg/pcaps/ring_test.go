@@ -0,0 +1,96 @@
+package pcaps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// writeTestRingPackets writes numbered packets (from first to last) and
+// returns them by number.
+func writeTestRingPackets(t *testing.T, p *Pcaps, first, last int) map[string]int {
+	t.Helper()
+
+	pkts := make(map[string]int)
+	for i := first; i <= last; i++ {
+		// different payload sizes so blocks rarely line up when overwritten
+		payload := []byte(fmt.Sprintf("packet-%03d-%s", i, make([]byte, i%7*4)))
+		pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 5678, payload)
+		require.NoError(t, p.Write(newTestEvent(i), pkt))
+		pkts[string(pkt)] = i
+	}
+
+	return pkts
+}
+
+// readTestRingPackets returns the numbers of the packets in the ring file.
+func readTestRingPackets(t *testing.T, path string, written map[string]int) []int {
+	t.Helper()
+
+	var numbers []int
+	for _, pkt := range readTestPcap(t, path) {
+		i, ok := written[string(pkt)]
+		require.True(t, ok, "unexpected packet in ring file")
+		numbers = append(numbers, i)
+	}
+	sort.Ints(numbers)
+
+	return numbers
+}
+
+// requireTestRecentPackets checks that the given packet numbers are the most
+// recent ones (a contiguous range ending at last).
+func requireTestRecentPackets(t *testing.T, numbers []int, last int) {
+	t.Helper()
+
+	require.NotEmpty(t, numbers)
+	for i, n := range numbers {
+		require.Equal(t, last-len(numbers)+1+i, n)
+	}
+}
+
+func TestPcapsRingFile(t *testing.T) {
+	const ringSize = 2048
+
+	cfg := config.PcapsConfig{CaptureSingle: true, RingFileSize: ringSize}
+	p, dir := newTestPcaps(t, cfg)
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap")
+
+	written := writeTestRingPackets(t, p, 1, 200)
+
+	// file stays parseable (even before being closed) and bounded
+	numbers := readTestRingPackets(t, path, written)
+	requireTestRecentPackets(t, numbers, 200)
+	require.Less(t, len(numbers), 200)
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	require.LessOrEqual(t, stat.Size(), int64(ringSize))
+
+	require.NoError(t, p.Destroy())
+
+	// reopened ring files keep wrapping from where they were
+	outDir, err := os.Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = outDir.Close() })
+	p, err = New(cfg, outDir)
+	require.NoError(t, err)
+
+	for k, v := range writeTestRingPackets(t, p, 201, 230) {
+		written[k] = v
+	}
+	require.NoError(t, p.Destroy())
+
+	numbers = readTestRingPackets(t, path, written)
+	requireTestRecentPackets(t, numbers, 230)
+
+	stat, err = os.Stat(path)
+	require.NoError(t, err)
+	require.LessOrEqual(t, stat.Size(), int64(ringSize))
+}
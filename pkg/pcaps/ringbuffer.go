@@ -0,0 +1,130 @@
+package pcaps
+
+import (
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Capture might be held in an in-memory ring buffer ("record the last N
+// seconds when something bad happens"): packets are not written as they are
+// captured, but held in memory, up to a fixed amount of bytes (once full, the
+// oldest packets are dropped for the new ones). A trigger dumps the packets
+// held to the pcap files (with their original timestamps), and packets keep
+// being written right away for the configured duration after the trigger
+// (measured in packet time), before being held again:
+//
+// - Detections (signature findings) trigger a dump (see Pcaps.Detection).
+// - Dumps might be triggered on demand as well (see Pcaps.Trigger), e.g. by
+//   the "trigger" capture control command.
+//
+// Packets still held when capture ends (or its session does) are dropped, as
+// they were never triggered. Unlike ring pcap files (see ring.go), nothing is
+// written to disk until a trigger.
+//
+
+// ringBuffer holds the last packets captured, until a trigger dumps them.
+type ringBuffer struct {
+	size    uint64        // max bytes of packets held
+	held    uint64        // bytes of packets held
+	packets []*heldPacket // held packets, from the oldest to the newest
+	after   int64         // packets written right away this long after a trigger (nanoseconds)
+	until   int64         // end of the post-trigger period (packet time)
+	last    int64         // newest packet time seen
+	dropped func(count int)
+}
+
+func newRingBuffer(size uint64, after int64, dropped func(count int)) *ringBuffer {
+	return &ringBuffer{
+		size:    size,
+		after:   after,
+		dropped: dropped,
+	}
+}
+
+// triggered returns true if packets at given time are written right away (a
+// trigger happened less than the post-trigger duration ago).
+func (r *ringBuffer) triggered(ts int64) bool {
+	r.last = max(r.last, ts)
+
+	return r.until > 0 && ts <= r.until
+}
+
+// packet holds a packet, dropping the oldest packets held if full.
+func (r *ringBuffer) packet(held *heldPacket) {
+	length := uint64(len(held.payload))
+	if length > r.size {
+		r.dropped(1) // would never fit
+		return
+	}
+
+	drop := 0
+	for r.held+length > r.size {
+		r.held -= uint64(len(r.packets[drop].payload))
+		r.packets[drop] = nil
+		drop++
+	}
+	if drop > 0 {
+		r.packets = r.packets[drop:]
+		r.dropped(drop)
+	}
+
+	r.packets = append(r.packets, held)
+	r.held += length
+}
+
+// trigger starts the post-trigger period at given time (the newest packet
+// time if 0), returning the packets held (to be dumped).
+func (r *ringBuffer) trigger(ts int64) []*heldPacket {
+	if ts == 0 {
+		ts = r.last
+	}
+	r.until = max(r.until, ts+r.after)
+
+	packets := r.packets
+	r.packets = nil
+	r.held = 0
+	logger.Debugw("Capture ring buffer triggered", "packets", len(packets))
+
+	return packets
+}
+
+// reset drops the packets held, and ends the post-trigger period.
+func (r *ringBuffer) reset() {
+	r.dropped(len(r.packets))
+	r.packets = nil
+	r.held = 0
+	r.until = 0
+}
+
+// Trigger dumps the packets held in the ring buffer to the pcap files, and
+// writes packets right away for the post-trigger duration (from the newest
+// packet captured). It does nothing unless capture is held in a ring buffer.
+func (p *Pcaps) Trigger() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.trigger(nil)
+}
+
+// trigger dumps the packets held in the ring buffer, on a trigger made by the
+// given event (nil: on demand). The caller holds the pcaps lock.
+func (p *Pcaps) trigger(event *trace.Event) error {
+	if p.ringBuffer == nil || p.session == nil {
+		return nil
+	}
+
+	var ts int64
+	if event != nil {
+		ts = int64(event.Timestamp)
+	}
+	for _, held := range p.ringBuffer.trigger(ts) {
+		err := p.writePacket(held.event, held.payload, held.info(), held.caches, held.options)
+		if err != nil {
+			return errfmt.WrapError(err)
+		}
+	}
+
+	return nil
+}
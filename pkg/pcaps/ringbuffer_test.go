@@ -0,0 +1,62 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsRingBuffer(t *testing.T) {
+	const s = int(time.Second)
+
+	newPacket := func(dstPort uint16) []byte {
+		return newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, dstPort, []byte("payload"))
+	}
+
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle:   true,
+		RingBuffer:      uint64(3 * len(newPacket(0))), // 3 packets
+		RingBufferAfter: 10 * time.Second,
+	})
+	write := func(ts int, dstPort uint16) {
+		require.NoError(t, p.Write(newTestEvent(ts), newPacket(dstPort)))
+	}
+
+	for port := 1; port <= 5; port++ {
+		write(port*s, uint16(port)) // full: 1 and 2 are dropped
+	}
+	require.NoError(t, p.Trigger()) // on demand: 3, 4 and 5 dumped
+	write(10*s, 6)                  // within 10s of the newest packet: written
+	write(20*s, 7)
+	p.Detection(newTestEvent(21 * s)) // 7 dumped
+	write(30*s, 8)                    // within 10s of the detection: written
+	write(40*s, 9)
+	require.NoError(t, p.Destroy()) // 9 never triggered
+
+	packets := readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	var ports []uint16
+	for _, packet := range packets {
+		ports = append(ports, newPacketInfo(packet).dstPort)
+	}
+	require.Equal(t, []uint16{3, 4, 5, 6, 7, 8}, ports)
+	require.Equal(t, uint64(3), p.Stats().Untriggered.Get())
+}
+
+func TestPcapsRingBufferShortLived(t *testing.T) {
+	dir := t.TempDir()
+	outDir, err := os.Open(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+
+	_, err = New(config.PcapsConfig{
+		CaptureSingle: true,
+		RingBuffer:    1 << 20,
+		ShortLived:    time.Second,
+	}, outDir)
+	require.ErrorContains(t, err, "can't be used with short-lived processes")
+}
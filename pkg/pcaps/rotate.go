@@ -0,0 +1,63 @@
+package pcaps
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+//
+// Pcap files of capture targets (processes, containers, commands, users or the
+// single file) might be rotated, so long running captures don't grow them
+// unbounded: once the current file of a target is bigger than the rotation
+// size, or the rotation interval elapsed since it was opened, it is closed
+// (readable right away) and the next packets of the target go to a new file,
+// named after the first one with the timestamp of the rotation as a suffix:
+//
+// pcap/containers/abc123.pcap
+// pcap/containers/abc123.20261015T120000.000000Z.pcap
+// pcap/containers/abc123.20261015T130000.000000Z.pcap
+//
+// The rotated file keeps its name (so packet offsets recorded in the index, or
+// in sidecars, remain valid), and its sidecar, hash chain and statistics files
+// (if enabled) are closed with it. Times are packet timestamps. The interval is
+// counted from the first packet written since the file was (re)opened: files
+// closed by the LRU cache, and reopened, restart their interval.
+//
+
+const rotationTimeFormat = "20060102T150405.000000Z"
+
+// rotate returns true if the given pcap file, about to get a packet of the
+// given timestamp, has to be rotated.
+func (p *PcapCache) rotate(item *Pcap, ts int64) bool {
+	if item.ring != nil {
+		return false // ring files have a fixed size already
+	}
+
+	switch {
+	case p.config.RotateSize > 0 && uint64(item.offset) >= p.config.RotateSize:
+	case p.config.RotateInterval > 0 && ts-item.opened >= int64(p.config.RotateInterval):
+	default:
+		return false
+	}
+
+	logger.Debugw("Rotating pcap file", "filename", item.pcapPath, "size", item.offset)
+
+	return true
+}
+
+// rotationSuffix returns the suffix of pcap files rotated at given time.
+func rotationSuffix(ts int64) string {
+	return time.Unix(0, ts).UTC().Format(rotationTimeFormat)
+}
+
+// rotationFileName returns the name of the pcap file with the given rotation
+// suffix (the name itself if not rotated).
+func rotationFileName(name string, rotation string) string {
+	if rotation == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(name, ".pcap") + "." + rotation + ".pcap"
+}
@@ -0,0 +1,98 @@
+package pcaps
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// readTestDirPackets returns the number of packets of each pcap file of the
+// given directory.
+func readTestDirPackets(t *testing.T, dir string) map[string]int {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	packets := make(map[string]int)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".pcap" {
+			continue
+		}
+		packets[entry.Name()] = len(readTestPcapComments(t, filepath.Join(dir, entry.Name())))
+	}
+
+	return packets
+}
+
+func TestPcapsRotateInterval(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureContainer: true, RotateInterval: time.Second})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("payload"))
+	write := func(ts time.Duration, container string) {
+		event := newTestEvent(int(time.Unix(1700000000, 0).Add(ts).UnixNano()))
+		event.Container.ID = container
+		require.NoError(t, p.Write(event, pkt))
+	}
+
+	write(0, "abc")
+	write(500*time.Millisecond, "def")
+	write(900*time.Millisecond, "abc")
+	write(1200*time.Millisecond, "abc") // rotated (abc opened at 0)
+	write(1400*time.Millisecond, "def") // not rotated (def opened at 500ms)
+	write(1500*time.Millisecond, "def") // rotated
+	require.NoError(t, p.Destroy())
+
+	require.Equal(t, map[string]int{
+		"abc.pcap":                         2,
+		"abc.20231114T221321.200000Z.pcap": 1,
+		"def.pcap":                         2,
+		"def.20231114T221321.500000Z.pcap": 1,
+	}, readTestDirPackets(t, filepath.Join(dir, pcapContDir)))
+}
+
+func TestPcapsRotateSize(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, RotateSize: 512})
+
+	pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, make([]byte, 100))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.Write(newTestEvent(1700000000000000000+i*1000), pkt))
+	}
+	require.NoError(t, p.Destroy())
+
+	packets := readTestDirPackets(t, filepath.Join(dir, pcapSingleDir))
+	require.Greater(t, len(packets), 1)
+
+	var total int
+	var names []string
+	for name, count := range packets {
+		require.NotZero(t, count, name)
+		total += count
+		names = append(names, name)
+
+		stat, err := os.Stat(filepath.Join(dir, pcapSingleDir, name))
+		require.NoError(t, err)
+		// a file is only rotated once over the size (by less than a packet)
+		require.Less(t, stat.Size(), int64(512+len(pkt)+32), name)
+	}
+	require.Equal(t, 10, total)
+
+	sort.Strings(names)
+	require.Equal(t, "single.pcap", names[len(names)-1])
+}
+
+func TestPcapsRotateRingFiles(t *testing.T) {
+	dir := t.TempDir()
+	outDir, err := os.Open(dir)
+	require.NoError(t, err)
+	defer outDir.Close()
+
+	_, err = New(config.PcapsConfig{CaptureSingle: true, RingFileSize: 4096, RotateSize: 1024}, outDir)
+	require.ErrorContains(t, err, "can't be used with ring files")
+}
@@ -0,0 +1,75 @@
+package pcaps
+
+import (
+	"bytes"
+
+	"github.com/google/gopacket/layers"
+)
+
+//
+// For application inventory, full captures are not needed: with protocol
+// sampling, only the first packet of each distinct L7 protocol (dns, dhcp,
+// http, tls or ssh) of each capture target is written (one sample per target
+// and protocol, e.g. one HTTP and one DNS packet per container). Packets of
+// unrecognized L7 protocols are sampled by their transport protocol (tcp, udp,
+// icmp or sctp) instead. The sampled protocol of each written packet is
+// recorded in its metadata (sampled_protocol=NAME).
+//
+// L7 protocols are recognized from single packets (no flow tracking needed):
+// DNS and DHCP by their (gopacket) decoders, HTTP by its request or status
+// line, TLS by its handshake record header and SSH by its version banner.
+//
+// Sampled protocols are forgotten when the capture session ends.
+//
+
+const protocolSSH = "ssh"
+
+// protocolSampler tracks the protocols sampled for each capture target.
+type protocolSampler struct {
+	sampled map[string]map[string]struct{} // protocols sampled (by target)
+}
+
+func newProtocolSampler() *protocolSampler {
+	return &protocolSampler{
+		sampled: make(map[string]map[string]struct{}),
+	}
+}
+
+// sample returns true if a packet of the given protocol is the first one of
+// the given target (to be written), accounting it as sampled.
+func (s *protocolSampler) sample(target string, protocol string) bool {
+	protocols, ok := s.sampled[target]
+	if !ok {
+		protocols = make(map[string]struct{})
+		s.sampled[target] = protocols
+	}
+	if _, ok := protocols[protocol]; ok {
+		return false
+	}
+	protocols[protocol] = struct{}{}
+
+	return true
+}
+
+// reset forgets all sampled protocols.
+func (s *protocolSampler) reset() {
+	s.sampled = make(map[string]map[string]struct{})
+}
+
+// sampleProtocol returns the protocol a packet is sampled by: its L7 protocol,
+// if recognized, or its transport protocol.
+func sampleProtocol(info *packetInfo) string {
+	if l7 := flowL7(info); l7 != "" {
+		return l7
+	}
+	if tcp, ok := info.packet.TransportLayer().(*layers.TCP); ok {
+		switch payload := tcp.Payload; {
+		case len(payload) >= 5 && payload[0] == tlsRecordHandshake && payload[1] == 3:
+			return protocolTLS
+		case bytes.HasPrefix(payload, []byte("SSH-")):
+			return protocolSSH
+		}
+	}
+
+	return protocolName(info.protocol)
+}
@@ -0,0 +1,73 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsProtocolSample(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureContainer: true,
+		ProtocolSample:   true,
+	})
+
+	web := &testTCPConn{t: t, client: "10.0.0.1", server: "10.0.0.80", clientPort: 40000, serverPort: 80}
+	ssh := &testTCPConn{t: t, client: "10.0.0.1", server: "10.0.0.22", clientPort: 40001, serverPort: 22}
+	dns := newTestDNSQuery(t, "example.com", layers.DNSTypeA)
+
+	ts := 1
+	write := func(container string, pkt []byte) {
+		event := newTestEvent(ts)
+		event.Container.ID = container
+		require.NoError(t, p.Write(event, pkt))
+		ts++
+	}
+
+	for _, container := range []string{"abc", "def"} {
+		for i := 0; i < 3; i++ {
+			write(container, web.packet(true, "PA", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")))
+		}
+		write(container, ssh.packet(false, "PA", []byte("SSH-2.0-OpenSSH_9.6\r\n")))
+		write(container, ssh.packet(false, "PA", []byte("SSH-2.0-OpenSSH_9.6\r\n")))
+		write(container, web.packet(false, "A", nil))
+		write(container, web.packet(false, "A", nil))
+		write(container, dns)
+	}
+	require.Equal(t, uint64(2*4), p.stats.Unsampled.Get())
+
+	require.NoError(t, p.Destroy())
+
+	// one http, ssh, tcp and dns packet per container
+	for _, container := range []string{"abc", "def"} {
+		path := filepath.Join(dir, pcapContDir, container+".pcap")
+		require.Len(t, readTestPcap(t, path), 4)
+
+		var sampled []string
+		for _, comments := range readTestPcapComments(t, path) {
+			sampled = append(sampled, comments[len(comments)-1])
+		}
+		require.Equal(t, []string{
+			"sampled_protocol=http",
+			"sampled_protocol=ssh",
+			"sampled_protocol=tcp",
+			"sampled_protocol=dns",
+		}, sampled)
+	}
+}
+
+func TestSampleProtocol(t *testing.T) {
+	t.Parallel()
+
+	conn := &testTCPConn{t: t, client: "10.0.0.1", server: "10.0.0.2", clientPort: 40000, serverPort: 443}
+
+	hello := newTestClientHello([]uint16{0x1301}, testClientHelloExtensions)
+	require.Equal(t, protocolTLS, sampleProtocol(newPacketInfo(conn.packet(true, "PA", hello))))
+	require.Equal(t, protocolHTTP, sampleProtocol(newPacketInfo(conn.packet(false, "PA", []byte("HTTP/1.1 200 OK\r\n\r\n")))))
+	require.Equal(t, protocolTCP, sampleProtocol(newPacketInfo(conn.packet(true, "PA", []byte("data")))))
+	require.Equal(t, protocolUDP, sampleProtocol(newPacketInfo(newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 5678, []byte("data")))))
+}
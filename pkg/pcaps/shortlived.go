@@ -0,0 +1,155 @@
+package pcaps
+
+import (
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Capture might be limited to short-lived processes (e.g. ephemeral malware
+// that starts, does some network I/O and exits right away): only packets of
+// processes living less than a threshold are written, packets of long-lived
+// processes are discarded.
+//
+// Whether a process is short-lived is only known once it exits, so packets
+// are buffered (in memory) per process until then:
+//
+// - When the process exits (its whole thread group) before the threshold, its
+//   buffered packets are written (with their original timestamps).
+// - As soon as a packet shows the process lived longer than the threshold, its
+//   buffered packets are discarded (and so are its next packets).
+//
+// The lifetime of a process is measured from the earliest start time seen of
+// its threads (the packets and the exit of the process tell them), which is
+// the start of the process whenever its main thread does network I/O or is
+// the last to exit. Packets not tied to a process (e.g. kernel generated) are
+// discarded.
+//
+// Buffering costs memory: up to the threshold worth of packets per process
+// doing network I/O, bounded by shortLivedMaxProcesses processes and
+// shortLivedMaxPackets packets per process (packets beyond them are
+// discarded). Processes that never exit (e.g. lost exit events) are forgotten
+// once they outlived the threshold, and packets still buffered when capture
+// ends are discarded (the processes lifetime is not known).
+//
+
+const (
+	shortLivedMaxProcesses = 4096 // max processes with buffered packets
+	shortLivedMaxPackets   = 1024 // max buffered packets per process
+)
+
+// shortLivedProcess holds the packets of a process not yet known to be short-
+// or long-lived.
+type shortLivedProcess struct {
+	start   int64 // earliest start time seen of its threads
+	packets []*heldPacket
+}
+
+// shortLivedProcesses buffers packets per process until they exit.
+type shortLivedProcesses struct {
+	threshold int64 // max lifetime (nanoseconds) of short-lived processes
+	processes map[uint32]*shortLivedProcess
+	sweptAt   int64           // last time long-lived processes were forgotten
+	discarded func(count int) // called with the number of discarded packets
+}
+
+func newShortLivedProcesses(threshold int64, discarded func(count int)) *shortLivedProcesses {
+	return &shortLivedProcesses{
+		threshold: threshold,
+		processes: make(map[uint32]*shortLivedProcess),
+		discarded: discarded,
+	}
+}
+
+// packet buffers a packet of the process of its event, discarding it if the
+// process is long-lived (or buffers are full).
+func (s *shortLivedProcesses) packet(held *heldPacket) {
+	ts := int64(held.event.Timestamp)
+	if ts-s.sweptAt >= s.threshold {
+		s.sweep(ts)
+	}
+
+	id := held.event.ProcessEntityId
+	if id == 0 || ts-int64(held.event.ThreadStartTime) >= s.threshold {
+		s.forget(id)
+		s.discarded(1)
+		return
+	}
+
+	process, ok := s.processes[id]
+	if !ok {
+		if len(s.processes) >= shortLivedMaxProcesses {
+			s.discarded(1)
+			return
+		}
+		process = &shortLivedProcess{start: int64(held.event.ThreadStartTime)}
+		s.processes[id] = process
+	}
+	process.start = min(process.start, int64(held.event.ThreadStartTime))
+	if ts-process.start >= s.threshold {
+		s.forget(id)
+		s.discarded(1)
+		return
+	}
+	if len(process.packets) >= shortLivedMaxPackets {
+		s.discarded(1)
+		return
+	}
+	process.packets = append(process.packets, held)
+}
+
+// exit forgets the process of given exit event, returning its buffered packets
+// if it was short-lived (nil otherwise).
+func (s *shortLivedProcesses) exit(event *trace.Event) []*heldPacket {
+	process, ok := s.processes[event.ProcessEntityId]
+	if !ok {
+		return nil
+	}
+	delete(s.processes, event.ProcessEntityId)
+
+	start := min(process.start, int64(event.ThreadStartTime))
+	if int64(event.Timestamp)-start >= s.threshold {
+		s.discarded(len(process.packets))
+		return nil
+	}
+	logger.Debugw("Short-lived process packets retained",
+		"pid", event.HostProcessID, "comm", event.ProcessName, "packets", len(process.packets))
+
+	return process.packets
+}
+
+// forget discards the buffered packets of a (long-lived) process.
+func (s *shortLivedProcesses) forget(id uint32) {
+	if process, ok := s.processes[id]; ok {
+		s.discarded(len(process.packets))
+		delete(s.processes, id)
+	}
+}
+
+// sweep forgets processes that outlived the threshold at given time.
+func (s *shortLivedProcesses) sweep(ts int64) {
+	for id, process := range s.processes {
+		if ts-process.start >= s.threshold {
+			s.forget(id)
+		}
+	}
+	s.sweptAt = ts
+}
+
+// ProcessExit writes the buffered packets of the process of given exit event
+// (of its whole thread group) if it was short-lived. It does nothing unless
+// capture is limited to short-lived processes.
+func (p *Pcaps) ProcessExit(event *trace.Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.shortLived == nil {
+		return
+	}
+	for _, held := range p.shortLived.exit(event) {
+		err := p.writePacket(held.event, held.payload, held.info(), held.caches, held.options)
+		if err != nil {
+			logger.Errorw("Writing short-lived process packet", "error", err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+func TestPcapsShortLived(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, ShortLived: time.Second})
+
+	const ms = int(time.Millisecond)
+	process := func(entity uint32, pid int, start int) *trace.Event {
+		event := newTestEvent(start)
+		event.ProcessEntityId = entity
+		event.HostProcessID = pid
+		event.HostThreadID = pid
+		event.ThreadStartTime = start
+		return event
+	}
+	write := func(process *trace.Event, ts int, dstPort uint16) {
+		event := *process
+		event.Timestamp = ts
+		pkt := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, dstPort, []byte("payload"))
+		require.NoError(t, p.Write(&event, pkt))
+	}
+	exit := func(process *trace.Event, ts int) {
+		event := *process
+		event.Timestamp = ts
+		p.ProcessExit(&event)
+	}
+
+	ephemeral := process(1, 100, 1000*ms)
+	daemon := process(2, 200, 0)
+	slow := process(3, 300, 1000*ms)
+	running := process(4, 400, 1500*ms)
+
+	write(ephemeral, 1010*ms, 1)
+	write(daemon, 1020*ms, 2) // long-lived: discarded right away
+	write(slow, 1030*ms, 3)
+	write(ephemeral, 1040*ms, 1)
+	write(running, 1600*ms, 4)
+	exit(ephemeral, 1100*ms) // short-lived: its packets are written
+	write(slow, 2100*ms, 3)  // outlived the threshold: its packets are discarded
+	exit(slow, 2200*ms)
+	require.NoError(t, p.Destroy()) // running: its lifetime is not known
+
+	packets := readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+	require.Len(t, packets, 2)
+	for _, packet := range packets {
+		require.Equal(t, uint16(1), newPacketInfo(packet).dstPort)
+	}
+	require.Equal(t, uint64(3), p.Stats().LongLived.Get())
+}
@@ -0,0 +1,218 @@
+package pcaps
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// The sidecar is a compact binary file, next to each pcap file (same path plus
+// the ".idx" suffix), holding a fixed size record per written packet: its
+// timestamp, the offset of its block within the pcap file and its 5-tuple.
+// Unlike the (JSON) index, it is meant to be scanned fast, so flows can be
+// extracted from big pcap files without parsing them.
+//
+// Sidecar format (little endian):
+//
+//	header: magic (8 bytes)
+//	record: timestamp (8) | offset (8) | src ip (16) | dst ip (16) |
+//	        src port (2) | dst port (2) | protocol (1) | padding (3)
+//
+// IPv4 addresses are stored as IPv4-mapped IPv6 addresses.
+//
+
+const (
+	sidecarSuffix     = ".idx"
+	sidecarMagic      = "TRCPIDX1"
+	sidecarRecordSize = 56
+)
+
+// SidecarRecord describes a packet written to a pcap file.
+type SidecarRecord struct {
+	Timestamp int64
+	Offset    int64 // offset of the packet block in the pcap file
+	SrcIP     net.IP
+	DstIP     net.IP
+	SrcPort   uint16
+	DstPort   uint16
+	Protocol  uint8
+}
+
+// FlowQuery selects packets by their 5-tuple (in both directions). Zero valued
+// fields match all.
+type FlowQuery struct {
+	SrcIP    net.IP
+	DstIP    net.IP
+	SrcPort  uint16
+	DstPort  uint16
+	Protocol uint8
+}
+
+func (q *FlowQuery) match(r *SidecarRecord) bool {
+	if q.Protocol != 0 && r.Protocol != q.Protocol {
+		return false
+	}
+
+	return q.matchDirection(r.SrcIP, r.DstIP, r.SrcPort, r.DstPort) ||
+		q.matchDirection(r.DstIP, r.SrcIP, r.DstPort, r.SrcPort)
+}
+
+func (q *FlowQuery) matchDirection(srcIP, dstIP net.IP, srcPort, dstPort uint16) bool {
+	if q.SrcIP != nil && !q.SrcIP.Equal(srcIP) {
+		return false
+	}
+	if q.DstIP != nil && !q.DstIP.Equal(dstIP) {
+		return false
+	}
+	if q.SrcPort != 0 && q.SrcPort != srcPort {
+		return false
+	}
+	if q.DstPort != 0 && q.DstPort != dstPort {
+		return false
+	}
+
+	return true
+}
+
+// openSidecar opens (creating it if needed) the sidecar of the given pcap file.
+func openSidecar(pcapPath string) (*os.File, error) {
+	file, err := utils.OpenAt(
+		outputDirectory,
+		pcapPath+sidecarSuffix,
+		os.O_APPEND|os.O_WRONLY|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, errfmt.WrapError(err)
+	}
+	if stat.Size() == 0 {
+		if _, err := file.Write([]byte(sidecarMagic)); err != nil {
+			_ = file.Close()
+			return nil, errfmt.WrapError(err)
+		}
+	}
+
+	return file, nil
+}
+
+// encodeSidecarRecord encodes the sidecar record of a packet.
+func encodeSidecarRecord(ts int64, offset int64, info *packetInfo) []byte {
+	b := make([]byte, sidecarRecordSize)
+
+	binary.LittleEndian.PutUint64(b[0:], uint64(ts))
+	binary.LittleEndian.PutUint64(b[8:], uint64(offset))
+	copy(b[16:32], info.srcIP.To16())
+	copy(b[32:48], info.dstIP.To16())
+	binary.LittleEndian.PutUint16(b[48:], info.srcPort)
+	binary.LittleEndian.PutUint16(b[50:], info.dstPort)
+	b[52] = uint8(info.protocol)
+
+	return b
+}
+
+func decodeSidecarRecord(b []byte) SidecarRecord {
+	return SidecarRecord{
+		Timestamp: int64(binary.LittleEndian.Uint64(b[0:])),
+		Offset:    int64(binary.LittleEndian.Uint64(b[8:])),
+		SrcIP:     net.IP(bytes.Clone(b[16:32])),
+		DstIP:     net.IP(bytes.Clone(b[32:48])),
+		SrcPort:   binary.LittleEndian.Uint16(b[48:]),
+		DstPort:   binary.LittleEndian.Uint16(b[50:]),
+		Protocol:  b[52],
+	}
+}
+
+// ReadSidecar returns the records, from the given sidecar file, matching query.
+func ReadSidecar(sidecarPath string, query FlowQuery) ([]SidecarRecord, error) {
+	file, err := os.Open(sidecarPath)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	r := bufio.NewReaderSize(file, 64*sidecarRecordSize)
+
+	magic := make([]byte, len(sidecarMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != sidecarMagic {
+		return nil, errfmt.Errorf("not a pcap sidecar file: %s", sidecarPath)
+	}
+
+	var records []SidecarRecord
+
+	b := make([]byte, sidecarRecordSize)
+	for {
+		_, err := io.ReadFull(r, b)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break // a truncated last record is ignored
+		}
+		if err != nil {
+			return nil, errfmt.WrapError(err)
+		}
+		record := decodeSidecarRecord(b)
+		if query.match(&record) {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// ExtractPackets writes a pcapng file, to w, holding the file header (section
+// header and interface description blocks) of the given pcap file followed by
+// its packet blocks found at the given offsets.
+func ExtractPackets(pcapPath string, offsets []int64, w io.Writer) error {
+	file, err := os.Open(pcapPath)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	header := make([]byte, 8)
+
+	// file header: blocks up to the first non header block
+	var offset int64
+	for {
+		if _, err := file.ReadAt(header, offset); err == io.EOF {
+			break // no packets at all
+		} else if err != nil {
+			return errfmt.WrapError(err)
+		}
+		blockType := binary.LittleEndian.Uint32(header[0:])
+		if blockType != ngBlockTypeSectionHeader && blockType != ngBlockTypeInterfaceDescription {
+			break
+		}
+		offset += int64(binary.LittleEndian.Uint32(header[4:]))
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(file, 0, offset)); err != nil {
+		return errfmt.WrapError(err)
+	}
+
+	for _, offset := range offsets {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			return errfmt.WrapError(err)
+		}
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		if _, err := io.Copy(w, io.NewSectionReader(file, offset, length)); err != nil {
+			return errfmt.WrapError(err)
+		}
+	}
+
+	return nil
+}
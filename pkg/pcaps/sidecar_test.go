@@ -0,0 +1,77 @@
+package pcaps
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestSidecarExtractFlow(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{
+		CaptureSingle: true,
+		Sidecar:       true,
+	})
+
+	request := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 53, []byte("request"))
+	response := newTestUDPPacket(t, "10.0.0.2", "10.0.0.1", 53, 1234, []byte("response"))
+	other := newTestUDPPacket(t, "10.0.0.1", "10.0.0.3", 4321, 53, []byte("other"))
+
+	var flow [][]byte
+	for i, pkt := range [][]byte{request, other, response, other, request, response} {
+		require.NoError(t, p.Write(newTestEvent(i+1), pkt))
+		if !bytes.Equal(pkt, other) {
+			flow = append(flow, pkt)
+		}
+	}
+	require.NoError(t, p.Destroy())
+
+	pcapPath := filepath.Join(dir, pcapSingleDir, "single.pcap")
+
+	// query matches both directions of the flow
+	records, err := ReadSidecar(pcapPath+sidecarSuffix, FlowQuery{
+		SrcIP:    net.ParseIP("10.0.0.1"),
+		DstIP:    net.ParseIP("10.0.0.2"),
+		SrcPort:  1234,
+		DstPort:  53,
+		Protocol: uint8(layers.IPProtocolUDP),
+	})
+	require.NoError(t, err)
+	require.Len(t, records, len(flow))
+	require.Equal(t, int64(1), records[0].Timestamp)
+	require.Equal(t, uint16(53), records[1].SrcPort)
+
+	var offsets []int64
+	for _, r := range records {
+		offsets = append(offsets, r.Offset)
+	}
+
+	var extracted bytes.Buffer
+	require.NoError(t, ExtractPackets(pcapPath, offsets, &extracted))
+
+	reader, err := pcapgo.NewNgReader(&extracted, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+
+	var pkts [][]byte
+	for {
+		data, _, err := reader.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		pkts = append(pkts, data)
+	}
+	require.Equal(t, flow, pkts)
+
+	// wildcard query
+	records, err = ReadSidecar(pcapPath+sidecarSuffix, FlowQuery{DstPort: 53})
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+}
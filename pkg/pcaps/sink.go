@@ -0,0 +1,205 @@
+package pcaps
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Captured packets are written to a capture sink: pcap files by default (see
+// Pcaps), or a stream sent to an external process (e.g. Zeek or a custom
+// analyzer) over a Unix socket or a TCP connection, without touching disk.
+//
+// The stream is a pcapng stream (section header, fake interface and one
+// enhanced packet block per packet), the same as a pcap file would be, so any
+// tool reading pcapng from a socket or stdin (e.g. through socat) is able to
+// read it. When the connection is lost, packets are dropped until it is
+// reestablished (at most once per streamSinkRedial), and the stream starts
+// over (with a new section header).
+//
+
+const (
+	SinkUnix = "unix"
+	SinkTCP  = "tcp"
+)
+
+const (
+	streamSinkDialTimeout  = 5 * time.Second // max time to connect to the collector
+	streamSinkWriteTimeout = 5 * time.Second // max time to send a packet to the collector
+	streamSinkRedial       = time.Second     // min time between connection attempts
+)
+
+// CaptureSink receives captured packets: the network capture event and its
+// packet (prefixed by the fake layer 2 header).
+type CaptureSink interface {
+	Write(event *trace.Event, payload []byte) error
+	Close() error
+}
+
+var (
+	_ CaptureSink = (*Pcaps)(nil)
+	_ CaptureSink = (*StreamSink)(nil)
+	_ CaptureSink = (*DryRunSink)(nil)
+)
+
+// Close destroys all opened pcap files (see Destroy).
+func (p *Pcaps) Close() error {
+	return p.Destroy()
+}
+
+// ParseSink parses a capture sink: "unix:PATH" or "tcp:HOST:PORT". It returns
+// the network and the address of the collector.
+func ParseSink(sink string) (string, string, error) {
+	network, address, _ := strings.Cut(sink, ":")
+	switch network = strings.ToLower(network); network {
+	case SinkUnix, SinkTCP:
+		if address == "" {
+			return "", "", errfmt.Errorf("pcap sink address cannot be empty: %s", sink)
+		}
+		if network == SinkTCP {
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				return "", "", errfmt.Errorf("invalid pcap sink address: %v", err)
+			}
+		}
+		return network, address, nil
+	}
+
+	return "", "", errfmt.Errorf(
+		"invalid pcap sink (%s:PATH or %s:HOST:PORT): %s",
+		SinkUnix, SinkTCP, sink,
+	)
+}
+
+// StreamSink streams captured packets to a collector (see above).
+type StreamSink struct {
+	mutex    sync.Mutex
+	network  string
+	address  string
+	linkType layers.LinkType
+	conn     net.Conn  // connection to the collector (nil if not connected)
+	dialed   time.Time // last connection attempt
+	dropped  uint64    // packets dropped since the connection was lost
+	closed   bool
+}
+
+// NewStreamSink returns a sink streaming packets, with the given pcap link
+// type, to the collector listening at the given network (unix or tcp) address.
+// The collector must be listening already.
+func NewStreamSink(network string, address string, linkType string) (*StreamSink, error) {
+	s := &StreamSink{
+		network:  network,
+		address:  address,
+		linkType: pcapLinkType(linkType),
+	}
+	if err := s.connect(); err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	return s, nil
+}
+
+// connect connects to the collector and starts a new pcapng stream.
+func (s *StreamSink) connect() error {
+	s.dialed = time.Now()
+
+	conn, err := net.DialTimeout(s.network, s.address, streamSinkDialTimeout)
+	if err != nil {
+		return errfmt.Errorf("could not connect to pcap sink %s:%s: %v", s.network, s.address, err)
+	}
+
+	var header bytes.Buffer
+	writer, err := pcapgo.NewNgWriterInterface(&header, pcapgo.NgInterface{
+		Name:        "tracee",
+		Comment:     "trace fake interface",
+		Description: "non-existing interface",
+		LinkType:    s.linkType,
+		SnapLength:  uint32(math.MaxUint32),
+	}, pcapgo.DefaultNgWriterOptions)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = s.send(conn, header.Bytes())
+	}
+	if err != nil {
+		_ = conn.Close()
+		return errfmt.WrapError(err)
+	}
+	s.conn = conn
+
+	return nil
+}
+
+// send sends data to the collector (with a deadline).
+func (s *StreamSink) send(conn net.Conn, data []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(streamSinkWriteTimeout)); err != nil {
+		return errfmt.WrapError(err)
+	}
+	_, err := conn.Write(data)
+
+	return errfmt.WrapError(err)
+}
+
+// Write sends a packet to the collector. Packets are dropped while the
+// connection is lost.
+func (s *StreamSink) Write(event *trace.Event, payload []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	if s.conn == nil {
+		if time.Since(s.dialed) < streamSinkRedial || s.connect() != nil {
+			s.dropped++
+			return nil
+		}
+		logger.Infow("Network capture: pcap sink reconnected", "address", s.address, "dropped", s.dropped)
+		s.dropped = 0
+	}
+
+	if s.linkType == layers.LinkTypeEthernet {
+		payload = ethernetFrame(payload)
+	}
+	info := gopacket.CaptureInfo{
+		Timestamp:     time.Unix(0, int64(event.Timestamp)),
+		CaptureLength: len(payload),
+		Length:        len(payload),
+	}
+	err := s.send(s.conn, encodeNgEnhancedPacket(info, payload, directionOptions(event)))
+	if err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		s.dropped++
+		return errfmt.Errorf("pcap sink connection lost (packets dropped until reconnected): %v", err)
+	}
+
+	return nil
+}
+
+// Close closes the connection to the collector.
+func (s *StreamSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+
+	return errfmt.WrapError(err)
+}
@@ -0,0 +1,106 @@
+package pcaps
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSink(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		sink            string
+		expectedNetwork string
+		expectedAddress string
+		expectedError   string
+	}{
+		{sink: "unix:/run/collector.sock", expectedNetwork: SinkUnix, expectedAddress: "/run/collector.sock"},
+		{sink: "TCP:127.0.0.1:9000", expectedNetwork: SinkTCP, expectedAddress: "127.0.0.1:9000"},
+		{sink: "tcp:[::1]:9000", expectedNetwork: SinkTCP, expectedAddress: "[::1]:9000"},
+		{sink: "unix:", expectedError: "pcap sink address cannot be empty"},
+		{sink: "tcp:127.0.0.1", expectedError: "invalid pcap sink address"},
+		{sink: "udp:127.0.0.1:9000", expectedError: "invalid pcap sink"},
+		{sink: "", expectedError: "invalid pcap sink"},
+	}
+
+	for _, tc := range testCases {
+		network, address, err := ParseSink(tc.sink)
+		if tc.expectedError != "" {
+			require.ErrorContains(t, err, tc.expectedError, tc.sink)
+			continue
+		}
+		require.NoError(t, err, tc.sink)
+		require.Equal(t, tc.expectedNetwork, network)
+		require.Equal(t, tc.expectedAddress, address)
+	}
+}
+
+func TestStreamSink(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen(SinkUnix, filepath.Join(t.TempDir(), "collector.sock"))
+	require.NoError(t, err)
+	defer listener.Close()
+
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	// reads the pcapng stream of a collector connection (until closed)
+	readStream := func(conn net.Conn) [][]byte {
+		defer conn.Close()
+		r, err := pcapgo.NewNgReader(conn, pcapgo.DefaultNgReaderOptions)
+		require.NoError(t, err)
+		require.Equal(t, layers.LinkTypeNull, r.LinkType())
+
+		var packets [][]byte
+		for {
+			data, _, err := r.ReadPacketData()
+			if err != nil {
+				return packets
+			}
+			packets = append(packets, data)
+		}
+	}
+
+	s, err := NewStreamSink(SinkUnix, listener.Addr().String(), "")
+	require.NoError(t, err)
+	conn := <-conns
+
+	query := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 53, []byte("query"))
+	reply := newTestUDPPacket(t, "10.0.0.2", "10.0.0.1", 53, 40000, []byte("reply"))
+	require.NoError(t, s.Write(newTestEvent(1), query))
+	require.NoError(t, s.Write(newTestEvent(2), reply))
+
+	// the collector goes away (after reading what was sent): packets are
+	// dropped until reconnected
+	require.NoError(t, conn.(*net.UnixConn).CloseRead())
+	require.Equal(t, [][]byte{query, reply}, readStream(conn))
+	require.Eventually(t, func() bool {
+		return s.Write(newTestEvent(3), query) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, s.Write(newTestEvent(4), query)) // dropped (too early to reconnect)
+	require.Nil(t, s.conn)
+
+	// a new stream is started once reconnected
+	s.dialed = time.Time{}
+	require.NoError(t, s.Write(newTestEvent(5), reply))
+	require.Zero(t, s.dropped)
+	conn = <-conns
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Write(newTestEvent(6), query)) // closed: ignored
+	require.Equal(t, [][]byte{reply}, readStream(conn))
+}
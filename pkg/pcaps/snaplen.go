@@ -0,0 +1,101 @@
+package pcaps
+
+import (
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+)
+
+//
+// The snaplen (length captured after the last known header) might be set per
+// protocol (e.g. full DNS answers, but small bulk TCP captures). Application
+// protocols are told by their well known ports (from or to), transport ones by
+// the IP protocol: the most specific protocol of a packet with a snaplen wins,
+// otherwise the default snaplen applies.
+//
+// Packets are captured (by eBPF programs) up to the biggest of all snaplens,
+// and cut to their own snaplen afterwards.
+//
+
+// snaplenPorts are the well known ports of application protocols that can
+// have their own snaplen.
+var snaplenPorts = map[string][]uint16{
+	"dns":  {53, 5353},
+	"http": {80, 8080},
+	"tls":  {443},
+}
+
+// ParseSnaplenProtocol parses the name of a protocol that can have its own
+// snaplen.
+func ParseSnaplenProtocol(protocol string) (string, error) {
+	protocol = strings.ToLower(protocol)
+	if _, ok := snaplenPorts[protocol]; ok {
+		return protocol, nil
+	}
+	switch protocol {
+	case protocolTCP, protocolUDP, protocolICMP, protocolSCTP:
+		return protocol, nil
+	}
+
+	return "", errfmt.Errorf("invalid pcap snaplen protocol (dns, http, tls, tcp, udp, icmp or sctp): %s", protocol)
+}
+
+// MaxSnaplen returns the biggest of all snaplens: the length captured after
+// the last known header of every packet.
+func MaxSnaplen(cfg config.PcapsConfig) uint32 {
+	snaplen := cfg.CaptureLength
+	for _, length := range cfg.CaptureLengths {
+		if length > snaplen {
+			snaplen = length
+		}
+	}
+
+	return snaplen
+}
+
+// PacketSnaplen returns the snaplen of the given packet: the one of its most
+// specific protocol with a snaplen, or the default one.
+func PacketSnaplen(cfg config.PcapsConfig, packet gopacket.Packet) uint32 {
+	if len(cfg.CaptureLengths) == 0 {
+		return cfg.CaptureLength
+	}
+
+	var src, dst uint16
+	var transport string
+
+	switch l4 := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		src, dst, transport = uint16(l4.SrcPort), uint16(l4.DstPort), protocolTCP
+	case *layers.UDP:
+		src, dst, transport = uint16(l4.SrcPort), uint16(l4.DstPort), protocolUDP
+	case *layers.SCTP:
+		transport = protocolSCTP
+	default:
+		if packet.Layer(layers.LayerTypeICMPv4) != nil || packet.Layer(layers.LayerTypeICMPv6) != nil {
+			transport = protocolICMP
+		}
+	}
+
+	if src != 0 || dst != 0 {
+		for _, protocol := range sortedKeys(snaplenPorts) {
+			length, ok := cfg.CaptureLengths[protocol]
+			if !ok {
+				continue
+			}
+			for _, port := range snaplenPorts[protocol] {
+				if src == port || dst == port {
+					return length
+				}
+			}
+		}
+	}
+	if length, ok := cfg.CaptureLengths[transport]; ok {
+		return length
+	}
+
+	return cfg.CaptureLength
+}
@@ -0,0 +1,40 @@
+package pcaps
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPacketSnaplen(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.PcapsConfig{
+		CaptureLength:  96,
+		CaptureLengths: map[string]uint32{"dns": 512, "tcp": 32},
+	}
+	require.Equal(t, uint32(512), MaxSnaplen(cfg))
+
+	snaplen := func(pkt []byte) uint32 {
+		return PacketSnaplen(cfg, gopacket.NewPacket(pkt, layers.LayerTypeLoopback, gopacket.Default))
+	}
+
+	require.Equal(t, uint32(512), snaplen(newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 53, []byte("query"))))
+	require.Equal(t, uint32(96), snaplen(newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 5678, []byte("data"))))
+
+	tcp := &testTCPConn{t: t, client: "10.0.0.1", server: "10.0.0.2", clientPort: 40000, serverPort: 80}
+	require.Equal(t, uint32(32), snaplen(tcp.packet(true, "PA", []byte("GET / HTTP/1.1\r\n"))))
+
+	// without protocol snaplens, the default applies
+	require.Equal(t, uint32(96), PacketSnaplen(config.PcapsConfig{CaptureLength: 96}, nil))
+
+	protocol, err := ParseSnaplenProtocol("TLS")
+	require.NoError(t, err)
+	require.Equal(t, "tls", protocol)
+	_, err = ParseSnaplenProtocol("smtp")
+	require.ErrorContains(t, err, "invalid pcap snaplen protocol")
+}
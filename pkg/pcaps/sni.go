@@ -0,0 +1,90 @@
+package pcaps
+
+import (
+	"encoding/binary"
+)
+
+//
+// TLS hellos tell which server a connection is for, even when DNS is
+// encrypted or cached: the server name requested by the client (SNI), in the
+// (plaintext) ClientHello. The ClientHello only tells the versions offered by
+// the client, the version negotiated is given by the ServerHello (see tls.go).
+//
+// NOTE: The ClientHello must be captured in full (within a single TCP
+//       segment) for its server name to be parsed: truncated ones (with a
+//       small snaplen) are skipped.
+//
+
+const tlsExtServerName = 0
+
+// TLSHello holds what a TLS hello tells about its connection.
+type TLSHello struct {
+	Client     bool   // ClientHello (or ServerHello)
+	ServerName string // server name requested, ClientHello only (empty if none)
+	Version    string // highest version offered (ClientHello), or negotiated (ServerHello), e.g. "TLS1.3"
+}
+
+// ParseTLSHello parses the TLS ClientHello or ServerHello at the start of a
+// TCP segment payload. It returns false if the payload does not start with
+// one, or if a ClientHello was not captured in full.
+func ParseTLSHello(payload []byte) (TLSHello, bool) {
+	if serverHello, ok := parseTLSServerHello(payload); ok {
+		return TLSHello{Version: serverHello.versionName()}, true
+	}
+
+	body, ok := tlsHandshakeMessage(payload, tlsHandshakeClientHello)
+
+	// body: version (2) | random (32) | session id (1 + n) |
+	// cipher suites (2 + n) | compression methods (1 + n) | extensions (2 + n)
+	if !ok || len(body) < 35 || len(body) < 35+int(body[34]) {
+		return TLSHello{}, false
+	}
+	version := binary.BigEndian.Uint16(body)
+	_, rest, ok := parseUint16List(body[35+int(body[34]):], 2)
+	if !ok || len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return TLSHello{}, false
+	}
+
+	hello := TLSHello{Client: true}
+	ok = tlsExtensions(rest[1+int(rest[0]):], func(extType uint16, extData []byte) {
+		switch extType {
+		case tlsExtServerName:
+			hello.ServerName = tlsServerName(extData)
+		case tlsExtSupportedVersions:
+			// supersedes the legacy version field (frozen at TLS 1.2)
+			versions, _, _ := parseUint16List(extData, 1)
+			for _, v := range versions {
+				if !isTLSGrease(v) && v > version {
+					version = v
+				}
+			}
+		}
+	})
+	if !ok {
+		return TLSHello{}, false
+	}
+	hello.Version = tlsVersionName(version)
+
+	return hello, true
+}
+
+// tlsServerName returns the host name of a server_name extension (empty if
+// none, or malformed).
+func tlsServerName(data []byte) string {
+	// server name list (2 + n), of entries: type (1) | name (2 + n)
+	if len(data) < 2 || len(data[2:]) < int(binary.BigEndian.Uint16(data)) {
+		return ""
+	}
+	for names := data[2 : 2+int(binary.BigEndian.Uint16(data))]; len(names) >= 3; {
+		length := int(binary.BigEndian.Uint16(names[1:]))
+		if len(names[3:]) < length {
+			return ""
+		}
+		if names[0] == 0 { // host_name
+			return string(names[3 : 3+length])
+		}
+		names = names[3+length:]
+	}
+
+	return ""
+}
@@ -0,0 +1,63 @@
+package pcaps
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTLSHello(t *testing.T) {
+	t.Parallel()
+
+	clientHello := newTestClientHello([]uint16{0x1301}, testClientHelloExtensions)
+	serverHello := captureTestServerHello(t, tls.VersionTLS12)
+
+	testCases := []struct {
+		name          string
+		payload       []byte
+		expectedHello TLSHello
+		expectedOK    bool
+	}{
+		{
+			name:          "client hello",
+			payload:       clientHello,
+			expectedHello: TLSHello{Client: true, ServerName: "example.com", Version: "TLS1.3"},
+			expectedOK:    true,
+		},
+		{
+			name:          "client hello without extensions",
+			payload:       newTestClientHello([]uint16{0xc02b}, nil),
+			expectedHello: TLSHello{Client: true, Version: "TLS1.2"},
+			expectedOK:    true,
+		},
+		{
+			name:          "client hello without server name",
+			payload:       newTestClientHello([]uint16{0x1301}, testClientHelloExtensions[2:]),
+			expectedHello: TLSHello{Client: true, Version: "TLS1.3"},
+			expectedOK:    true,
+		},
+		{
+			name:          "server hello",
+			payload:       serverHello,
+			expectedHello: TLSHello{Version: "TLS1.2"},
+			expectedOK:    true,
+		},
+		{
+			name:       "truncated client hello",
+			payload:    clientHello[:60],
+			expectedOK: false,
+		},
+		{
+			name:       "not a hello",
+			payload:    []byte("GET / HTTP/1.1\r\n\r\n"),
+			expectedOK: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		hello, ok := ParseTLSHello(tc.payload)
+		require.Equal(t, tc.expectedOK, ok, tc.name)
+		require.Equal(t, tc.expectedHello, hello, tc.name)
+	}
+}
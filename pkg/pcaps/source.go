@@ -0,0 +1,135 @@
+package pcaps
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Capture sources are the eBPF hooks capture events originate from. Packets
+// are currently captured by the cgroup skb programs, attached to the cgroup
+// ingress and egress hooks, told apart by the packet direction flags of the
+// capture event return value. Events carrying none of the flags come from an
+// unknown source. Any new hook feeding the capture pipeline should get its own
+// source.
+//
+
+const (
+	SourceCgroupSkbIngress = "cgroup_skb_ingress"
+	SourceCgroupSkbEgress  = "cgroup_skb_egress"
+	SourceUnknown          = "unknown"
+)
+
+// ParseSource parses the name of a capture source.
+func ParseSource(source string) (string, error) {
+	switch source = strings.ToLower(source); source {
+	case SourceCgroupSkbIngress, SourceCgroupSkbEgress, SourceUnknown:
+		return source, nil
+	}
+
+	return "", errfmt.Errorf(
+		"invalid pcap source (%s, %s or %s): %s",
+		SourceCgroupSkbIngress, SourceCgroupSkbEgress, SourceUnknown, source,
+	)
+}
+
+//
+// Single-stack deployments might capture packets of a single address family:
+// packets of the other one are skipped, before being parsed, as told apart by
+// the family flags of the capture event return value.
+//
+
+const (
+	FamilyBoth = "both"
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// ParseFamily parses the name of the address family of captured packets.
+func ParseFamily(family string) (string, error) {
+	switch family = strings.ToLower(family); family {
+	case FamilyBoth, FamilyIPv4, FamilyIPv6:
+		return family, nil
+	}
+
+	return "", errfmt.Errorf(
+		"invalid pcap family (%s, %s or %s): %s",
+		FamilyBoth, FamilyIPv4, FamilyIPv6, family,
+	)
+}
+
+// PacketSource returns the source (eBPF hook) of given capture event.
+func PacketSource(event *trace.Event) string {
+	switch {
+	case event.ReturnValue&packetIngress == packetIngress:
+		return SourceCgroupSkbIngress
+	case event.ReturnValue&packetEgress == packetEgress:
+		return SourceCgroupSkbEgress
+	}
+
+	return SourceUnknown
+}
+
+//
+// Pcap files need a layer 2 header: a fake one (BSD loopback encapsulation,
+// 4 bytes) is written before each captured (L3) packet. Sources either give
+// the bare packet, so the fake header is prepended to it, or the packet after
+// a 4-byte (little endian) packet size prefix, so the fake header overwrites
+// the prefix. Overwriting a packet that carries no prefix would clobber its
+// first bytes, so the mode follows the source and, for sources of unknown
+// layout, is detected from the payload itself.
+//
+
+const (
+	L2ModeAuto      = "auto"
+	L2ModePrepend   = "prepend"
+	L2ModeOverwrite = "overwrite"
+)
+
+// l2PrefixSize is the size of the packet size prefix (and of the fake layer 2
+// header overwriting it).
+const l2PrefixSize = 4
+
+// ParseL2Mode parses the name of a fake layer 2 header mode.
+func ParseL2Mode(mode string) (string, error) {
+	switch mode = strings.ToLower(mode); mode {
+	case L2ModeAuto, L2ModePrepend, L2ModeOverwrite:
+		return mode, nil
+	}
+
+	return "", errfmt.Errorf(
+		"invalid pcap l2 mode (%s, %s or %s): %s",
+		L2ModeAuto, L2ModePrepend, L2ModeOverwrite, mode,
+	)
+}
+
+// SourceL2Mode returns the fake layer 2 header mode given source needs, or
+// L2ModeAuto if the source payload layout is unknown.
+func SourceL2Mode(source string) string {
+	switch source {
+	case SourceCgroupSkbIngress, SourceCgroupSkbEgress:
+		return L2ModePrepend // bare packets
+	}
+
+	return L2ModeAuto
+}
+
+// DetectL2Mode returns the fake layer 2 header mode given payload needs:
+// L2ModeOverwrite if it starts with a prefix holding the size of the IP packet
+// following it, L2ModePrepend otherwise.
+func DetectL2Mode(payload []byte) string {
+	if len(payload) <= l2PrefixSize {
+		return L2ModePrepend
+	}
+
+	size := binary.LittleEndian.Uint32(payload[:l2PrefixSize])
+	version := payload[l2PrefixSize] >> 4
+	if int(size) == len(payload)-l2PrefixSize && (version == 4 || version == 6) {
+		return L2ModeOverwrite
+	}
+
+	return L2ModePrepend
+}
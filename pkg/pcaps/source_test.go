@@ -0,0 +1,56 @@
+package pcaps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+func TestPacketSource(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, SourceCgroupSkbIngress, PacketSource(&trace.Event{ReturnValue: 1 | packetIngress}))
+	require.Equal(t, SourceCgroupSkbEgress, PacketSource(&trace.Event{ReturnValue: 1 | packetEgress}))
+	require.Equal(t, SourceUnknown, PacketSource(&trace.Event{ReturnValue: 1}))
+
+	source, err := ParseSource("CGROUP_SKB_INGRESS")
+	require.NoError(t, err)
+	require.Equal(t, SourceCgroupSkbIngress, source)
+
+	_, err = ParseSource("kprobe")
+	require.ErrorContains(t, err, "invalid pcap source")
+}
+
+func TestParseFamily(t *testing.T) {
+	t.Parallel()
+
+	family, err := ParseFamily("IPv6")
+	require.NoError(t, err)
+	require.Equal(t, FamilyIPv6, family)
+
+	_, err = ParseFamily("inet")
+	require.ErrorContains(t, err, "invalid pcap family (both, ipv4 or ipv6): inet")
+}
+
+func TestL2Mode(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, L2ModePrepend, SourceL2Mode(SourceCgroupSkbIngress))
+	require.Equal(t, L2ModePrepend, SourceL2Mode(SourceCgroupSkbEgress))
+	require.Equal(t, L2ModeAuto, SourceL2Mode(SourceUnknown))
+
+	packet := []byte{0x45, 0x00, 0x00, 0x1c, 0x00, 0x00, 0x00, 0x00}
+	require.Equal(t, L2ModePrepend, DetectL2Mode(packet))
+	require.Equal(t, L2ModeOverwrite, DetectL2Mode(append([]byte{8, 0, 0, 0}, packet...)))
+	require.Equal(t, L2ModePrepend, DetectL2Mode(append([]byte{9, 0, 0, 0}, packet...))) // size mismatch
+	require.Equal(t, L2ModePrepend, DetectL2Mode([]byte{8, 0, 0, 0}))
+
+	mode, err := ParseL2Mode("OVERWRITE")
+	require.NoError(t, err)
+	require.Equal(t, L2ModeOverwrite, mode)
+
+	_, err = ParseL2Mode("swap")
+	require.ErrorContains(t, err, "invalid pcap l2 mode")
+}
@@ -0,0 +1,141 @@
+package pcaps
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// For monitoring without a metrics backend, each capture target might get a
+// statistics file next to its pcap file (FILE.pcap.stats.json): a JSON object
+// with the packets and bytes written, the protocol mix and the number of
+// distinct flows seen. Files are rewritten on an interval (of packet time,
+// only if the target got packets since) and when their pcap file is closed.
+// Counts go on from the previous file contents when a pcap file is reopened
+// (e.g. once evicted from its cache), but distinct flows are only told apart
+// while the pcap file is open.
+//
+
+const (
+	statsSuffix      = ".stats.json"
+	statsMaxFlowKeys = 65536 // max distinct flows told apart per target (more are not counted)
+)
+
+// targetStats holds the statistics of a capture target (of its pcap file).
+type targetStats struct {
+	Target      string            `json:"target"`
+	Packets     uint64            `json:"packets"`
+	Bytes       uint64            `json:"bytes"`
+	Protocols   map[string]uint64 `json:"protocols"`
+	Flows       uint64            `json:"flows"`
+	FirstPacket string            `json:"first_packet,omitempty"`
+	LastPacket  string            `json:"last_packet,omitempty"`
+
+	path  string               // stats file path (relative to output dir, unless absolute)
+	flows map[flowKey]struct{} // distinct flows seen since the pcap file was opened
+	dirty bool                 // changed since last written
+}
+
+// openTargetStats returns the statistics of the given pcap file, going on from
+// the ones in its stats file (if any).
+func openTargetStats(pcapPath string, target string) *targetStats {
+	s := &targetStats{
+		Target:    target,
+		Protocols: make(map[string]uint64),
+		path:      pcapPath + statsSuffix,
+		flows:     make(map[flowKey]struct{}),
+	}
+
+	file, err := utils.OpenAt(outputDirectory, s.path, os.O_RDONLY, 0)
+	if err != nil {
+		return s // no previous stats
+	}
+	defer func() { _ = file.Close() }()
+
+	data, err := io.ReadAll(file)
+	if err == nil {
+		var previous targetStats
+		if json.Unmarshal(data, &previous) == nil && previous.Protocols != nil {
+			previous.path, previous.flows = s.path, s.flows
+			return &previous
+		}
+	}
+
+	return s
+}
+
+// packet accounts a packet written to the target pcap file.
+func (s *targetStats) packet(ts int64, info *packetInfo, length int) {
+	s.Packets++
+	s.Bytes += uint64(length)
+	if s.FirstPacket == "" {
+		s.FirstPacket = flowLogTime(ts)
+	}
+	s.LastPacket = flowLogTime(ts)
+	s.dirty = true
+
+	if info == nil || info.srcIP == nil {
+		s.Protocols["other"]++
+		return
+	}
+	s.Protocols[protocolName(info.protocol)]++
+
+	key, _ := newFlowKey(info)
+	if _, ok := s.flows[key]; !ok && len(s.flows) < statsMaxFlowKeys {
+		s.flows[key] = struct{}{}
+		s.Flows++
+	}
+}
+
+// write rewrites the stats file (if the stats changed since last written).
+func (s *targetStats) write() (bool, error) {
+	if !s.dirty {
+		return false, nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return false, errfmt.WrapError(err)
+	}
+	file, err := utils.OpenAt(outputDirectory, s.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return false, errfmt.WrapError(err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, errfmt.WrapError(err)
+	}
+	s.dirty = false
+
+	return true, nil
+}
+
+// flushTargetStats rewrites the stats files of all open pcap files.
+func (p *Pcaps) flushTargetStats() error {
+	for _, caches := range p.allCaches() {
+		for k := range caches {
+			for _, index := range caches[k].itemCache.Keys() {
+				item, ok := caches[k].itemCache.Peek(index) // recency kept
+				if !ok || item.stats == nil {
+					continue
+				}
+				written, err := item.stats.write()
+				if err != nil {
+					return errfmt.WrapError(err)
+				}
+				if written && p.session != nil {
+					p.session.file(item.stats.path)
+				}
+			}
+		}
+	}
+
+	return nil
+}
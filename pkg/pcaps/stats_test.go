@@ -0,0 +1,67 @@
+package pcaps
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func readTestStats(t *testing.T, path string) targetStats {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var stats targetStats
+	require.NoError(t, json.Unmarshal(data, &stats))
+
+	return stats
+}
+
+func TestPcapsTargetStats(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, StatsInterval: 10 * time.Second})
+	path := filepath.Join(dir, pcapSingleDir, "single.pcap"+statsSuffix)
+
+	udp := newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 40000, 5000, []byte("udp"))
+	reply := newTestUDPPacket(t, "10.0.0.2", "10.0.0.1", 5000, 40000, []byte("reply"))
+	dns := newTestDNSQuery(t, "example.com", layers.DNSTypeA)
+	conn := &testTCPConn{t: t, client: "10.0.0.1", server: "10.0.0.80", clientPort: 40000, serverPort: 80}
+	syn := conn.packet(true, "S", nil)
+
+	require.NoError(t, p.Write(newTestEvent(1e9), udp))
+	require.NoError(t, p.Write(newTestEvent(2e9), reply))
+	require.NoError(t, p.Write(newTestEvent(3e9), dns))
+
+	// interval not over yet: no stats file
+	_, err := os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, p.Write(newTestEvent(12e9), syn))
+
+	// interval over: stats file reflects the captured packets
+	stats := readTestStats(t, path)
+	require.Equal(t, "single", stats.Target)
+	require.Equal(t, uint64(4), stats.Packets)
+	require.Equal(t, uint64(len(udp)+len(reply)+len(dns)+len(syn)), stats.Bytes)
+	require.Equal(t, map[string]uint64{"udp": 3, "tcp": 1}, stats.Protocols)
+	require.Equal(t, uint64(3), stats.Flows) // udp and its reply are the same flow
+	require.Equal(t, flowLogTime(1e9), stats.FirstPacket)
+	require.Equal(t, flowLogTime(12e9), stats.LastPacket)
+
+	// counts go on once the pcap file is reopened (new session)
+	require.NoError(t, p.EndSession())
+	require.NoError(t, p.StartSession())
+	require.NoError(t, p.Write(newTestEvent(13e9), udp))
+	require.NoError(t, p.Destroy())
+
+	stats = readTestStats(t, path)
+	require.Equal(t, uint64(5), stats.Packets)
+	require.Equal(t, uint64(4), stats.Protocols["udp"])
+}
@@ -0,0 +1,66 @@
+package pcaps
+
+import (
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Capture might be limited to bulk transfers: packets of a flow are withheld
+// until the flow exceeds a byte threshold (the bytes of all its packets, both
+// directions), and written from the packet crossing it on. The last packets
+// withheld (up to the configured backfill) are kept in the flow table and
+// written right before the crossing packet, so the start of the transfer is
+// captured as well. Flows that are over before crossing the threshold leave
+// nothing in the pcap files (their summaries only go to the flow log).
+//
+// Packets not tracked in a flow (not IP, or the flow table is full) are
+// written as usual. Backfilled packets cost memory: up to the backfill size
+// per tracked flow.
+//
+
+const flowMaxBackfill = 64 // max withheld packets kept per flow
+
+// heldPacket is a packet withheld (below the byte threshold).
+type heldPacket struct {
+	event   *trace.Event
+	payload []byte
+	caches  map[PcapType]*PcapCache
+	options []ngOption
+}
+
+// newHeldPacket copies a packet to be withheld.
+func newHeldPacket(event *trace.Event, payload []byte, caches map[PcapType]*PcapCache, options []ngOption) *heldPacket {
+	copied := *event
+
+	return &heldPacket{
+		event:   &copied,
+		payload: append([]byte(nil), payload...),
+		caches:  caches,
+		options: options,
+	}
+}
+
+// info returns the packet info of a withheld packet.
+func (h *heldPacket) info() *packetInfo {
+	return newPacketInfo(h.payload)
+}
+
+// hold withholds a packet of the flow, keeping up to the given amount of the
+// last withheld packets to backfill.
+func (f *flow) hold(held *heldPacket, backfill int) {
+	if len(f.held) == backfill {
+		f.held[0] = nil
+		f.held = f.held[1:]
+	}
+	f.held = append(f.held, held)
+}
+
+// release stops withholding packets of the flow (the byte threshold was
+// crossed), returning the withheld packets to backfill.
+func (f *flow) release() []*heldPacket {
+	held := f.held
+	f.withheld = false
+	f.held = nil
+
+	return held
+}
@@ -0,0 +1,66 @@
+package pcaps
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+func TestPcapsFlowByteThreshold(t *testing.T) {
+	testCases := []struct {
+		name     string
+		backfill uint32
+		written  []int // packets of the bulk flow written
+	}{
+		{
+			name:    "no backfill",
+			written: []int{3, 4, 5},
+		},
+		{
+			name:     "backfill",
+			backfill: 2,
+			written:  []int{1, 2, 3, 4, 5},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			p, dir := newTestPcaps(t, config.PcapsConfig{
+				CaptureSingle:     true,
+				Flows:             true,
+				FlowByteThreshold: 500,
+				FlowBackfill:      tc.backfill,
+			})
+
+			// 132 bytes packets: the 4th one crosses the threshold
+			var bulk [][]byte
+			for i := 0; i < 6; i++ {
+				payload := make([]byte, 100)
+				payload[0] = byte(i)
+				bulk = append(bulk, newTestUDPPacket(t, "10.0.0.1", "10.0.0.2", 1234, 9000, payload))
+			}
+			small := newTestUDPPacket(t, "10.0.0.1", "10.0.0.3", 1234, 53, make([]byte, 100))
+
+			for i, pkt := range bulk {
+				require.NoError(t, p.Write(newTestEvent(i), pkt))
+				if i < 3 {
+					require.NoError(t, p.Write(newTestEvent(i), small)) // never crosses
+				}
+			}
+			require.NoError(t, p.Destroy())
+
+			var expected [][]byte
+			for _, i := range tc.written {
+				expected = append(expected, bulk[i])
+			}
+			require.Equal(t, expected, readTestPcap(t, filepath.Join(dir, pcapSingleDir, "single.pcap")))
+
+			require.Equal(t, uint64(3+3), p.Stats().BelowThreshold.Get())
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package pcaps
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// At the end of each capture session, the flows that were over during the
+// session (fed by the flow table) might be drawn as a timeline, for quick
+// visual triage: an SVG image (pcap/timeline-TIMESTAMP.svg, next to the
+// session manifest) with one horizontal bar per flow, from its first to its
+// last packet, colored by protocol (the application protocol if recognized,
+// the transport one otherwise). The bar thickness grows with the flow bytes
+// (logarithmically), and hovering a bar tells the flow details.
+//
+// Bars are sorted by start time, and each carries its exact timing and volume
+// as data attributes (data-start and data-end, nanoseconds since epoch, and
+// data-bytes), for tools post-processing the image. Up to timelineMaxFlows
+// flows are drawn per session: the image tells how many more were left out.
+//
+
+const (
+	timelineMaxFlows   = 10000 // max flows drawn per session
+	timelineLabelWidth = 330   // width of the flow labels column
+	timelineBarsWidth  = 900   // width of the time axis
+	timelineRowHeight  = 14    // height of each flow row
+	timelineHeader     = 56    // height of the title, legend and time axis
+	timelineTicks      = 5     // time axis ticks (after the start)
+)
+
+// timelineColors are the colors of the flow bars, per protocol.
+var timelineColors = map[string]string{
+	protocolTCP:  "#4e79a7",
+	protocolUDP:  "#f28e2b",
+	protocolICMP: "#e15759",
+	protocolSCTP: "#76b7b2",
+	protocolDNS:  "#59a14f",
+	protocolHTTP: "#edc948",
+	protocolTLS:  "#b07aa1",
+	protocolDHCP: "#ff9da7",
+}
+
+const timelineOtherColor = "#9c755f"
+
+// timelineFlow is a flow drawn in the timeline.
+type timelineFlow struct {
+	first    int64
+	last     int64
+	protocol string
+	src      string
+	dst      string
+	packets  uint64
+	bytes    uint64
+	reason   string
+}
+
+// flowTimeline holds the flows of a session timeline.
+type flowTimeline struct {
+	flows   []timelineFlow
+	dropped uint64 // flows not drawn (too many)
+}
+
+func newFlowTimeline() *flowTimeline {
+	return &flowTimeline{}
+}
+
+// add adds a flow that is over to the timeline.
+func (t *flowTimeline) add(s *flowSummary) {
+	if len(t.flows) >= timelineMaxFlows {
+		t.dropped++
+		return
+	}
+
+	f := s.flow
+	protocol := f.l7
+	if protocol == "" {
+		protocol = protocolName(f.protocol)
+	}
+	t.flows = append(t.flows, timelineFlow{
+		first:    f.first,
+		last:     f.last,
+		protocol: protocol,
+		src:      f.src,
+		dst:      f.dst,
+		packets:  f.packets,
+		bytes:    f.bytes,
+		reason:   s.reason,
+	})
+}
+
+// timelinePath returns the path of the session timeline (relative to output
+// dir).
+func (s *captureSession) timelinePath() string {
+	return pcapDir + "timeline-" + s.start.Format(manifestTimeFormat) + ".svg"
+}
+
+// writeTimeline writes the session timeline.
+func (s *captureSession) writeTimeline(output *os.File) error {
+	err := utils.MkdirAtExist(output, pcapDir, os.ModePerm)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	file, err := utils.OpenAt(output, s.timelinePath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errfmt.WrapError(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	w := bufio.NewWriter(file)
+	s.timeline.render(w, s.start.Format(manifestTimeFormat))
+	if err := w.Flush(); err != nil {
+		return errfmt.WrapError(err)
+	}
+	s.file(s.timelinePath())
+
+	return nil
+}
+
+// render draws the timeline as an SVG image.
+func (t *flowTimeline) render(w io.Writer, title string) {
+	flows := t.flows
+	sort.SliceStable(flows, func(i, j int) bool {
+		return flows[i].first < flows[j].first
+	})
+
+	var start, end int64
+	for i, f := range flows {
+		if i == 0 || f.first < start {
+			start = f.first
+		}
+		if f.last > end {
+			end = f.last
+		}
+	}
+	span := end - start
+	if span <= 0 {
+		span = 1 // avoid dividing by zero (single instant)
+	}
+	var maxBytes uint64
+	for _, f := range flows {
+		maxBytes = max(maxBytes, f.bytes)
+	}
+
+	width := timelineLabelWidth + timelineBarsWidth + 20
+	height := timelineHeader + len(flows)*timelineRowHeight + 30
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="11">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", width, height)
+	fmt.Fprintf(w, `<text x="4" y="14" font-size="13" font-weight="bold">Network capture session %s: %d flows</text>`+"\n",
+		html.EscapeString(title), len(flows)+int(t.dropped))
+
+	// legend (protocols present only)
+	present := make(map[string]struct{})
+	for _, f := range flows {
+		present[f.protocol] = struct{}{}
+	}
+	x := 4
+	for _, protocol := range sortedKeys(present) {
+		fmt.Fprintf(w, `<rect x="%d" y="21" width="10" height="10" fill="%s"/><text x="%d" y="30">%s</text>`+"\n",
+			x, timelineColor(protocol), x+14, html.EscapeString(protocol))
+		x += 14 + 7*len(protocol) + 12
+	}
+
+	// time axis (offsets from the first flow start)
+	axis := timelineHeader - 6
+	for i := 0; i <= timelineTicks; i++ {
+		tickX := timelineLabelWidth + i*timelineBarsWidth/timelineTicks
+		offset := float64(span) * float64(i) / timelineTicks / 1e9
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#dddddd"/>`+"\n",
+			tickX, axis, tickX, height-30)
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">+%.3fs</text>`+"\n", tickX, axis-3, offset)
+	}
+
+	for i, f := range flows {
+		y := timelineHeader + i*timelineRowHeight
+		x := timelineLabelWidth + int(float64(f.first-start)*timelineBarsWidth/float64(span))
+		barWidth := max(int(float64(f.last-f.first)*timelineBarsWidth/float64(span)), 1)
+
+		// bar thickness grows logarithmically with the flow bytes
+		thickness := timelineRowHeight - 2
+		if maxBytes > 1 {
+			scale := math.Log(float64(f.bytes)+1) / math.Log(float64(maxBytes)+1)
+			thickness = max(int(scale*float64(timelineRowHeight-2)), 2)
+		}
+
+		fmt.Fprintf(w, `<text x="4" y="%d">%s %s → %s</text>`+"\n",
+			y+timelineRowHeight-3, html.EscapeString(f.protocol), html.EscapeString(f.src), html.EscapeString(f.dst))
+		fmt.Fprintf(w,
+			`<rect class="flow" x="%d" y="%d" width="%d" height="%d" fill="%s" data-start="%d" data-end="%d" data-bytes="%d">`+
+				`<title>%s %s → %s: %d packets, %d bytes, %.6fs (closed: %s)</title></rect>`+"\n",
+			x, y+(timelineRowHeight-thickness)/2, barWidth, thickness, timelineColor(f.protocol),
+			f.first, f.last, f.bytes,
+			html.EscapeString(f.protocol), html.EscapeString(f.src), html.EscapeString(f.dst),
+			f.packets, f.bytes, float64(f.last-f.first)/1e9, html.EscapeString(f.reason),
+		)
+	}
+
+	if len(flows) == 0 {
+		fmt.Fprintf(w, `<text x="4" y="%d">no flows</text>`+"\n", timelineHeader+timelineRowHeight)
+	}
+	if t.dropped > 0 {
+		fmt.Fprintf(w, `<text x="4" y="%d">%d more flows not drawn</text>`+"\n", height-10, t.dropped)
+	}
+	fmt.Fprintf(w, "</svg>\n")
+}
+
+// timelineColor returns the color of the flow bars of given protocol.
+func timelineColor(protocol string) string {
+	if color, ok := timelineColors[protocol]; ok {
+		return color
+	}
+
+	return timelineOtherColor
+}
@@ -0,0 +1,117 @@
+package pcaps
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// testTimelineBar is a flow bar of a timeline.
+type testTimelineBar struct {
+	Class string `xml:"class,attr"`
+	X     int    `xml:"x,attr"`
+	Width int    `xml:"width,attr"`
+	Fill  string `xml:"fill,attr"`
+	Start int64  `xml:"data-start,attr"`
+	End   int64  `xml:"data-end,attr"`
+	Bytes uint64 `xml:"data-bytes,attr"`
+	Title string `xml:"title"`
+}
+
+// readTestTimelineBars returns the flow bars of the only session timeline.
+func readTestTimelineBars(t *testing.T, dir string) []testTimelineBar {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, pcapDir, "timeline-*.svg"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	data, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+
+	var svg struct {
+		XMLName xml.Name          `xml:"svg"`
+		Rects   []testTimelineBar `xml:"rect"`
+	}
+	require.NoError(t, xml.Unmarshal(data, &svg))
+
+	var bars []testTimelineBar
+	for _, rect := range svg.Rects {
+		if rect.Class == "flow" {
+			bars = append(bars, rect)
+		}
+	}
+
+	return bars
+}
+
+func TestPcapsFlowTimeline(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true, FlowTimeline: true})
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+	const s = 1000000000
+	const start = 1700000000 * s
+
+	query := newTestUDPPacket(t, "10.0.0.1", "10.0.0.53", 40001, 53, make([]byte, 30))
+	reply := newTestUDPPacket(t, "10.0.0.53", "10.0.0.1", 53, 40001, make([]byte, 60))
+	require.NoError(t, p.Write(newTestEvent(start), query))
+	require.NoError(t, p.Write(newTestEvent(start+s/10), reply))
+
+	var tcpBytes int
+	for i, pkt := range [][]byte{
+		conn.packet(true, "S", nil),
+		conn.packet(false, "SA", nil),
+		conn.packet(true, "A", nil),
+		conn.packet(true, "PA", []byte("data")),
+		conn.packet(true, "FA", nil),
+		conn.packet(false, "FA", nil),
+	} {
+		require.NoError(t, p.Write(newTestEvent(start+s/2+i*s/2), pkt))
+		tcpBytes += len(pkt)
+	}
+	require.NoError(t, p.Destroy())
+
+	bars := readTestTimelineBars(t, dir)
+	require.Len(t, bars, 2)
+
+	// sorted by start time, on a time axis from 0 (first start) to 3s (last end)
+	udp, tcp := bars[0], bars[1]
+	require.Equal(t, int64(start), udp.Start)
+	require.Equal(t, int64(start+s/10), udp.End)
+	require.Equal(t, uint64(len(query)+len(reply)), udp.Bytes)
+	require.Equal(t, timelineLabelWidth, udp.X)
+	require.Equal(t, timelineBarsWidth/30, udp.Width)
+	require.Equal(t, timelineColors[protocolDNS], udp.Fill)
+	require.True(t, strings.HasPrefix(udp.Title, "dns 10.0.0.1:40001 → 10.0.0.53:53: 2 packets"), udp.Title)
+
+	require.Equal(t, int64(start+s/2), tcp.Start)
+	require.Equal(t, int64(start+3*s), tcp.End)
+	require.Equal(t, uint64(tcpBytes), tcp.Bytes)
+	require.Equal(t, timelineLabelWidth+timelineBarsWidth/6, tcp.X)
+	require.Equal(t, timelineBarsWidth*5/6, tcp.Width)
+	require.Equal(t, timelineColors[protocolTCP], tcp.Fill)
+	require.True(t, strings.HasSuffix(tcp.Title, "(closed: end)"), tcp.Title)
+
+	// the timeline is a session file
+	manifests, err := filepath.Glob(filepath.Join(dir, pcapDir, "manifest-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	manifest, err := os.ReadFile(manifests[0])
+	require.NoError(t, err)
+	ts := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(manifests[0]), "manifest-"), ".txt")
+	require.Contains(t, string(manifest), " "+pcapDir+"timeline-"+ts+".svg\n")
+}
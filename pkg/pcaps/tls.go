@@ -0,0 +1,136 @@
+package pcaps
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"strings"
+)
+
+//
+// TLS flows are tagged with the version and cipher suite negotiated by the
+// server, taken from the (plaintext) ServerHello. Since TLS 1.3, the record
+// and ServerHello versions are frozen at TLS 1.2 and the negotiated version
+// is given by the supported_versions extension instead.
+//
+// NOTE: The ServerHello must be captured up to its extensions (a snaplen of
+//       256 bytes is usually enough). If the extensions are truncated, a TLS
+//       1.2 ServerHello can't be told apart from a TLS 1.3 one and the version
+//       is recorded as unknown.
+//
+
+const (
+	tlsRecordHandshake      = 22
+	tlsHandshakeServerHello = 2
+	tlsExtSupportedVersions = 43
+)
+
+// tlsHelloRetryRandom is the ServerHello random of TLS 1.3 HelloRetryRequests.
+var tlsHelloRetryRandom = sha256.Sum256([]byte("HelloRetryRequest"))
+
+// tlsServerHello holds the parameters negotiated in a TLS ServerHello.
+type tlsServerHello struct {
+	version uint16 // 0 if unknown (extensions not captured)
+	cipher  uint16
+}
+
+// versionName returns the name of the negotiated version (e.g. "TLS1.3").
+func (h *tlsServerHello) versionName() string {
+	return tlsVersionName(h.version)
+}
+
+// tlsVersionName returns the name of a TLS version (e.g. "TLS1.3"), or
+// "unknown" if 0.
+func tlsVersionName(version uint16) string {
+	if version == 0 {
+		return "unknown"
+	}
+
+	return strings.ReplaceAll(tls.VersionName(version), " ", "")
+}
+
+// cipherName returns the name of the negotiated cipher suite.
+func (h *tlsServerHello) cipherName() string {
+	return tls.CipherSuiteName(h.cipher)
+}
+
+// parseTLSServerHello parses the TLS ServerHello at the start of a TCP segment
+// payload. It returns false if the payload does not start with a ServerHello
+// (or it is a TLS 1.3 HelloRetryRequest, followed by the actual ServerHello).
+func parseTLSServerHello(payload []byte) (tlsServerHello, bool) {
+	var hello tlsServerHello
+
+	// record header: type (1) | version (2) | length (2)
+	if len(payload) < 5 || payload[0] != tlsRecordHandshake || payload[1] != 3 {
+		return hello, false
+	}
+	record := payload[5:]
+	if length := int(binary.BigEndian.Uint16(payload[3:])); len(record) > length {
+		record = record[:length]
+	}
+
+	// handshake header: type (1) | length (3)
+	if len(record) < 4 || record[0] != tlsHandshakeServerHello {
+		return hello, false
+	}
+	body := record[4:]
+	length := int(record[1])<<16 | int(record[2])<<8 | int(record[3])
+	complete := len(body) >= length // whole message captured
+	if complete {
+		body = body[:length]
+	}
+
+	// body: version (2) | random (32) | session id (1 + n) | cipher (2) |
+	// compression (1) | extensions (2 + n)
+	if len(body) < 35 || bytes.Equal(body[2:34], tlsHelloRetryRandom[:]) {
+		return hello, false
+	}
+	pos := 35 + int(body[34])
+	if len(body) < pos+3 {
+		return hello, false
+	}
+	legacyVersion := binary.BigEndian.Uint16(body)
+	hello.cipher = binary.BigEndian.Uint16(body[pos:])
+	pos += 3
+
+	// versions up to TLS 1.2 are given by the legacy version field
+	if legacyVersion != tls.VersionTLS12 {
+		hello.version = legacyVersion
+		return hello, true
+	}
+
+	if len(body) == pos && complete {
+		hello.version = legacyVersion // no extensions
+		return hello, true
+	}
+	if len(body) < pos+2 {
+		return hello, true // extensions not captured: version unknown
+	}
+	extensions := body[pos+2:]
+	if length := int(binary.BigEndian.Uint16(body[pos:])); len(extensions) >= length {
+		extensions = extensions[:length]
+		complete = true
+	} else {
+		complete = false
+	}
+
+	// extension: type (2) | length (2) | data
+	for len(extensions) >= 4 {
+		extType := binary.BigEndian.Uint16(extensions)
+		extLength := int(binary.BigEndian.Uint16(extensions[2:]))
+		if len(extensions) < 4+extLength {
+			break // truncated
+		}
+		if extType == tlsExtSupportedVersions && extLength == 2 {
+			hello.version = binary.BigEndian.Uint16(extensions[4:])
+			return hello, true
+		}
+		extensions = extensions[4+extLength:]
+	}
+	if complete && len(extensions) == 0 {
+		hello.version = legacyVersion // all extensions seen, none overriding
+	}
+
+	return hello, true
+}
@@ -0,0 +1,154 @@
+package pcaps
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// recordingConn records the first write to the connection.
+type recordingConn struct {
+	net.Conn
+	first chan []byte
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	select {
+	case c.first <- append([]byte(nil), b...):
+	default:
+	}
+	return c.Conn.Write(b)
+}
+
+// captureTestServerHello runs a TLS handshake, limited to the given version,
+// and returns the first flight sent by the server (starting with the
+// ServerHello).
+func captureTestServerHello(t *testing.T, maxVersion uint16) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	clientConn, serverConn := net.Pipe()
+	recorder := &recordingConn{Conn: serverConn, first: make(chan []byte, 1)}
+
+	server := tls.Server(recorder, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MaxVersion:   maxVersion,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	})
+	client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Handshake()
+	}()
+	require.NoError(t, client.Handshake())
+	require.NoError(t, <-done)
+	_ = clientConn.Close()
+	_ = serverConn.Close()
+
+	return <-recorder.first
+}
+
+func TestPcapsFlowTLS(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxVersion uint16
+		expected   string
+	}{
+		{
+			name:       "tls 1.3 (supported_versions)",
+			maxVersion: tls.VersionTLS13,
+			expected:   "tls_version=TLS1.3 tls_cipher=TLS_AES_128_GCM_SHA256",
+		},
+		{
+			name:       "tls 1.2",
+			maxVersion: tls.VersionTLS12,
+			expected:   "tls_version=TLS1.2 tls_cipher=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true})
+
+			conn := &testTCPConn{
+				t:          t,
+				client:     "10.0.0.1",
+				server:     "10.0.0.80",
+				clientPort: 40000,
+				serverPort: 443,
+				clientSeq:  1000,
+				serverSeq:  5000,
+			}
+			serverHello := captureTestServerHello(t, tc.maxVersion)
+
+			packets := [][]byte{
+				conn.packet(true, "S", nil),
+				conn.packet(false, "SA", nil),
+				conn.packet(true, "A", nil),
+				conn.packet(true, "PA", []byte{0x16, 0x03, 0x01, 0x00, 0x00}), // (fake) ClientHello
+				conn.packet(false, "PA", serverHello),
+				conn.packet(true, "R", nil),
+			}
+			for i, pkt := range packets {
+				require.NoError(t, p.Write(newTestEvent(i+1), pkt))
+			}
+			require.NoError(t, p.Destroy())
+
+			summaries := readTestStatsComments(t, filepath.Join(dir, pcapSingleDir, "single.pcap"))
+			require.Len(t, summaries, 1)
+			require.True(t, strings.HasSuffix(summaries[0], " "+tc.expected), summaries[0])
+		})
+	}
+}
+
+func TestParseTLSServerHello(t *testing.T) {
+	t.Parallel()
+
+	serverHello := captureTestServerHello(t, tls.VersionTLS13)
+
+	hello, ok := parseTLSServerHello(serverHello)
+	require.True(t, ok)
+	require.Equal(t, tlsServerHello{version: tls.VersionTLS13, cipher: tls.TLS_AES_128_GCM_SHA256}, hello)
+
+	// extensions truncated (snaplen): version can't be told
+	hello, ok = parseTLSServerHello(serverHello[:5+4+2+32+1+32+3])
+	require.True(t, ok)
+	require.Equal(t, "unknown", hello.versionName())
+	require.Equal(t, "TLS_AES_128_GCM_SHA256", hello.cipherName())
+
+	// HelloRetryRequest: the actual ServerHello comes later
+	retry := append([]byte(nil), serverHello...)
+	copy(retry[5+4+2:], tlsHelloRetryRandom[:])
+	_, ok = parseTLSServerHello(retry)
+	require.False(t, ok)
+
+	// not a ServerHello
+	_, ok = parseTLSServerHello([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+	require.False(t, ok)
+	_, ok = parseTLSServerHello(serverHello[:20])
+	require.False(t, ok)
+}
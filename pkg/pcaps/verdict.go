@@ -0,0 +1,37 @@
+package pcaps
+
+import (
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+//
+// Programs making verdicts on packets (e.g. cgroup skb programs dropping them)
+// tell what the kernel decided through the verdict flags of the capture event
+// return value. Packets without any of the flags set had no verdict made (they
+// were only observed). The verdict is recorded as packet metadata.
+//
+
+const (
+	VerdictAllow = "allow"
+	VerdictDrop  = "drop"
+)
+
+// packet verdict flags of the capture event return value (see the retval
+// flags in pkg/ebpf/c/common/network.h)
+const (
+	packetVerdictAllow = 1 << 11
+	packetVerdictDrop  = 1 << 12
+)
+
+// PacketVerdict returns the kernel verdict on the packet of given capture
+// event, or an empty string if no verdict was made.
+func PacketVerdict(event *trace.Event) string {
+	switch {
+	case event.ReturnValue&packetVerdictDrop == packetVerdictDrop:
+		return VerdictDrop // a drop wins over an allow (e.g. by other program)
+	case event.ReturnValue&packetVerdictAllow == packetVerdictAllow:
+		return VerdictAllow
+	}
+
+	return ""
+}
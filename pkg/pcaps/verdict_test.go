@@ -0,0 +1,18 @@
+package pcaps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+func TestPacketVerdict(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, VerdictAllow, PacketVerdict(&trace.Event{ReturnValue: 1 | packetIngress | packetVerdictAllow}))
+	require.Equal(t, VerdictDrop, PacketVerdict(&trace.Event{ReturnValue: 1 | packetEgress | packetVerdictDrop}))
+	require.Equal(t, VerdictDrop, PacketVerdict(&trace.Event{ReturnValue: 1 | packetVerdictAllow | packetVerdictDrop}))
+	require.Equal(t, "", PacketVerdict(&trace.Event{ReturnValue: 1 | packetIngress}))
+}
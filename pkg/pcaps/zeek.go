@@ -0,0 +1,278 @@
+package pcaps
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/aquasecurity/tracee/pkg/errfmt"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+//
+// The Zeek conn.log is a single append-only file, shared by all capture
+// sessions using the same output directory, holding one record per flow that
+// is over (fed by the flow table) in the Zeek ASCII (TSV) log format, for drop
+// in compatibility with Zeek-centric pipelines. The header is only written
+// once (the file is never closed with a #close line, as it is appended to).
+//
+// Flow states are mapped to Zeek conn_state codes:
+//
+// - TCP: S0 (SYN, no reply), REJ (SYN answered by a RST), RSTOS0 (SYN and
+//   RST from the originator, no reply), SH (SYN and FIN from the originator,
+//   no reply), S1 (established), SF (established and closed by both), S2/S3
+//   (established and closed by the originator/responder only), RSTO/RSTR
+//   (established and reset by the originator/responder) and OTH (no SYN, the
+//   flow started before the capture).
+// - Other protocols: SF (packets seen in both directions) or S0.
+//
+// Byte counts are as captured: with a capture length (snaplen), orig_bytes and
+// resp_bytes only count the captured payload. missed_bytes are the TCP bytes
+// never captured (sequence gaps, see flows.go). local_orig and local_resp are
+// unset.
+//
+
+const pcapZeekConnLogFile string = pcapDir + "conn.log"
+
+// zeekConnFields are the fields (and their types) of the Zeek conn.log.
+var zeekConnFields = [][2]string{
+	{"ts", "time"},
+	{"uid", "string"},
+	{"id.orig_h", "addr"},
+	{"id.orig_p", "port"},
+	{"id.resp_h", "addr"},
+	{"id.resp_p", "port"},
+	{"proto", "enum"},
+	{"service", "string"},
+	{"duration", "interval"},
+	{"orig_bytes", "count"},
+	{"resp_bytes", "count"},
+	{"conn_state", "string"},
+	{"local_orig", "bool"},
+	{"local_resp", "bool"},
+	{"missed_bytes", "count"},
+	{"history", "string"},
+	{"orig_pkts", "count"},
+	{"orig_ip_bytes", "count"},
+	{"resp_pkts", "count"},
+	{"resp_ip_bytes", "count"},
+	{"tunnel_parents", "set[string]"},
+}
+
+const zeekUnset = "-"
+
+// zeekConnLog appends flow records to the Zeek conn.log file.
+type zeekConnLog struct {
+	file *os.File
+}
+
+func newZeekConnLog(output *os.File) (*zeekConnLog, error) {
+	err := utils.MkdirAtExist(output, pcapDir, os.ModePerm)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+	file, err := utils.OpenAt(
+		output,
+		pcapZeekConnLogFile,
+		os.O_APPEND|os.O_WRONLY|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return nil, errfmt.WrapError(err)
+	}
+
+	// the header is only written once (the file is shared by all sessions)
+	stat, err := file.Stat()
+	if err == nil && stat.Size() == 0 {
+		_, err = file.WriteString(zeekConnHeader(time.Now()))
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, errfmt.WrapError(err)
+	}
+
+	return &zeekConnLog{file: file}, nil
+}
+
+// zeekConnHeader returns the header of a Zeek conn.log opened at given time.
+func zeekConnHeader(open time.Time) string {
+	names := make([]string, 0, len(zeekConnFields))
+	types := make([]string, 0, len(zeekConnFields))
+	for _, field := range zeekConnFields {
+		names = append(names, field[0])
+		types = append(types, field[1])
+	}
+
+	return "#separator \\x09\n" +
+		"#set_separator\t,\n" +
+		"#empty_field\t(empty)\n" +
+		"#unset_field\t" + zeekUnset + "\n" +
+		"#path\tconn\n" +
+		"#open\t" + open.UTC().Format("2006-01-02-15-04-05") + "\n" +
+		"#fields\t" + strings.Join(names, "\t") + "\n" +
+		"#types\t" + strings.Join(types, "\t") + "\n"
+}
+
+// add appends the record of a flow that is over.
+func (l *zeekConnLog) add(s *flowSummary) error {
+	_, err := l.file.WriteString(strings.Join(zeekConnRecord(s.flow), "\t") + "\n")
+	return errfmt.WrapError(err)
+}
+
+func (l *zeekConnLog) close() error {
+	return l.file.Close()
+}
+
+// zeekConnRecord returns the conn.log fields of a flow.
+func zeekConnRecord(f *flow) []string {
+	srcIP, srcPort, _ := net.SplitHostPort(f.src)
+	dstIP, dstPort, _ := net.SplitHostPort(f.dst)
+
+	service := zeekUnset
+	switch f.l7 {
+	case "":
+	case protocolTLS:
+		service = "ssl"
+	default:
+		service = f.l7
+	}
+
+	history := zeekUnset
+	if len(f.history) > 0 {
+		history = string(f.history)
+	}
+
+	orig, resp := &f.dirs[0], &f.dirs[1]
+
+	return []string{
+		zeekTime(f.first),
+		zeekUID(f),
+		srcIP,
+		srcPort,
+		dstIP,
+		dstPort,
+		protocolName(f.protocol),
+		service,
+		zeekTime(f.last - f.first),
+		strconv.FormatUint(orig.payload, 10),
+		strconv.FormatUint(resp.payload, 10),
+		zeekConnState(f),
+		zeekUnset,
+		zeekUnset,
+		strconv.FormatUint(f.missing(), 10),
+		history,
+		strconv.FormatUint(orig.packets, 10),
+		strconv.FormatUint(orig.ipBytes, 10),
+		strconv.FormatUint(resp.packets, 10),
+		strconv.FormatUint(resp.ipBytes, 10),
+		zeekUnset,
+	}
+}
+
+// zeekTime formats a timestamp or interval (nanoseconds) as Zeek does
+// (seconds, with microseconds).
+func zeekTime(ns int64) string {
+	return fmt.Sprintf("%d.%06d", ns/1e9, ns%1e9/1e3)
+}
+
+// zeekUIDChars are the characters of Zeek connection UIDs (base62).
+const zeekUIDChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// zeekUID returns a Zeek like connection UID of a flow ("C" followed by 17
+// base62 characters), derived from its 5-tuple and start time.
+func zeekUID(f *flow) string {
+	sum := flowHash(f)
+
+	uid := []byte{'C'}
+	for i := 0; i < 17; i++ {
+		uid = append(uid, zeekUIDChars[int(sum[i%len(sum)])%len(zeekUIDChars)])
+	}
+
+	return string(uid)
+}
+
+// zeekConnState returns the Zeek conn_state code of a flow.
+func zeekConnState(f *flow) string {
+	orig, resp := &f.dirs[0], &f.dirs[1]
+
+	if f.protocol != layers.IPProtocolTCP {
+		if resp.packets > 0 {
+			return "SF"
+		}
+		return "S0"
+	}
+
+	switch f.handshake.state {
+	case handshakeNone:
+		return "OTH"
+	case handshakeRefused:
+		return "REJ"
+	case handshakeSyn:
+		switch {
+		case orig.rst:
+			return "RSTOS0"
+		case orig.fin:
+			return "SH"
+		}
+		return "S0"
+	}
+
+	// established (SYN-ACK seen)
+	switch {
+	case orig.rst:
+		return "RSTO"
+	case resp.rst:
+		return "RSTR"
+	case orig.fin && resp.fin:
+		return "SF"
+	case orig.fin:
+		return "S2"
+	case resp.fin:
+		return "S3"
+	}
+
+	return "S1"
+}
+
+// zeekHistory adds the Zeek history letters of a TCP segment, sent in given
+// direction, to the history of its flow: S (SYN), H (SYN-ACK), A (pure ACK),
+// D (data), F (FIN) and R (RST), upper case from the originator and lower case
+// from the responder, each recorded once (the first time it is seen).
+func zeekHistory(history []byte, tcp *layers.TCP, dir int) []byte {
+	var letters []byte
+
+	switch {
+	case tcp.SYN && tcp.ACK:
+		letters = append(letters, 'H')
+	case tcp.SYN:
+		letters = append(letters, 'S')
+	case tcp.ACK && len(tcp.Payload) == 0 && !tcp.FIN && !tcp.RST:
+		letters = append(letters, 'A')
+	}
+	if len(tcp.Payload) > 0 {
+		letters = append(letters, 'D')
+	}
+	if tcp.FIN {
+		letters = append(letters, 'F')
+	}
+	if tcp.RST {
+		letters = append(letters, 'R')
+	}
+
+	for _, letter := range letters {
+		if dir == 1 {
+			letter += 'a' - 'A'
+		}
+		if bytes.IndexByte(history, letter) < 0 {
+			history = append(history, letter)
+		}
+	}
+
+	return history
+}
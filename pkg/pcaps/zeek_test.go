@@ -0,0 +1,159 @@
+package pcaps
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aquasecurity/tracee/pkg/config"
+)
+
+// readTestZeekConnLog returns the header lines and records (fields) of the
+// Zeek conn.log.
+func readTestZeekConnLog(t *testing.T, dir string) ([]string, [][]string) {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(dir, pcapZeekConnLogFile))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var header []string
+	var records [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "#") {
+			header = append(header, scanner.Text())
+			continue
+		}
+		records = append(records, strings.Split(scanner.Text(), "\t"))
+	}
+	require.NoError(t, scanner.Err())
+
+	return header, records
+}
+
+func TestPcapsZeekConnLog(t *testing.T) {
+	p, dir := newTestPcaps(t, config.PcapsConfig{CaptureSingle: true, Flows: true, FlowZeekLog: true})
+
+	conn := &testTCPConn{
+		t:          t,
+		client:     "10.0.0.1",
+		server:     "10.0.0.80",
+		clientPort: 40000,
+		serverPort: 80,
+		clientSeq:  1000,
+		serverSeq:  5000,
+	}
+	request := []byte("GET / HTTP/1.1\r\n\r\n")
+	response := []byte("HTTP/1.1 204 No Content\r\n\r\n")
+	packets := []struct {
+		fromClient bool
+		pkt        []byte
+	}{
+		{true, conn.packet(true, "S", nil)},
+		{false, conn.packet(false, "SA", nil)},
+		{true, conn.packet(true, "A", nil)},
+		{true, conn.packet(true, "PA", request)},
+		{false, conn.packet(false, "PA", response)},
+		{true, conn.packet(true, "FA", nil)},
+		{false, conn.packet(false, "FA", nil)},
+		{true, conn.packet(true, "A", nil)},
+	}
+
+	var origPackets, origIPBytes, respPackets, respIPBytes int
+	for i, packet := range packets {
+		require.NoError(t, p.Write(newTestEvent(1500000000+i*1000), packet.pkt))
+		if packet.fromClient {
+			origPackets++
+			origIPBytes += len(packet.pkt) - 4 // fake L2 header
+		} else {
+			respPackets++
+			respIPBytes += len(packet.pkt) - 4
+		}
+	}
+	require.NoError(t, p.Destroy())
+
+	header, records := readTestZeekConnLog(t, dir)
+	require.Len(t, header, 8)
+	require.Equal(t, "#separator \\x09", header[0])
+	require.Equal(t, "#path\tconn", header[4])
+	require.Equal(t,
+		"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\tservice\tduration\t"+
+			"orig_bytes\tresp_bytes\tconn_state\tlocal_orig\tlocal_resp\tmissed_bytes\thistory\t"+
+			"orig_pkts\torig_ip_bytes\tresp_pkts\tresp_ip_bytes\ttunnel_parents",
+		header[6],
+	)
+
+	require.Len(t, records, 1)
+	record := records[0]
+	require.Len(t, record, len(zeekConnFields))
+	require.Regexp(t, "^C[0-9A-Za-z]{17}$", record[1])
+	record[1] = "uid"
+	require.Equal(t, []string{
+		"1.500000", "uid", "10.0.0.1", "40000", "10.0.0.80", "80", "tcp", "http", "0.000007",
+		strconv.Itoa(len(request)), strconv.Itoa(len(response)), "SF", "-", "-", "0", "ShADdFf",
+		strconv.Itoa(origPackets), strconv.Itoa(origIPBytes),
+		strconv.Itoa(respPackets), strconv.Itoa(respIPBytes), "-",
+	}, record)
+}
+
+func TestZeekConnState(t *testing.T) {
+	t.Parallel()
+
+	syn := &layers.TCP{SYN: true}
+	synAck := &layers.TCP{SYN: true, ACK: true}
+	ack := &layers.TCP{ACK: true}
+	fin := &layers.TCP{FIN: true, ACK: true}
+	rst := &layers.TCP{RST: true}
+
+	type segment struct {
+		tcp *layers.TCP
+		dir int
+	}
+
+	testCases := []struct {
+		name     string
+		segments []segment
+		state    string
+		history  string
+	}{
+		{"no reply", []segment{{syn, 0}, {syn, 0}}, "S0", "S"},
+		{"rejected", []segment{{syn, 0}, {rst, 1}}, "REJ", "Sr"},
+		{"originator reset before reply", []segment{{syn, 0}, {rst, 0}}, "RSTOS0", "SR"},
+		{"established", []segment{{syn, 0}, {synAck, 1}, {ack, 0}}, "S1", "ShA"},
+		{"closed by originator", []segment{{syn, 0}, {synAck, 1}, {ack, 0}, {fin, 0}}, "S2", "ShAF"},
+		{"closed by responder", []segment{{syn, 0}, {synAck, 1}, {ack, 0}, {fin, 1}}, "S3", "ShAf"},
+		{"reset by responder", []segment{{syn, 0}, {synAck, 1}, {ack, 0}, {rst, 1}}, "RSTR", "ShAr"},
+		{"started before the capture", []segment{{ack, 0}, {fin, 1}}, "OTH", "Af"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &flow{protocol: layers.IPProtocolTCP}
+			for _, s := range tc.segments {
+				f.dirs[s.dir].packets++
+				f.tcp(0, s.tcp, s.dir, 0)
+				f.handshake.segment(0, s.tcp, s.dir)
+				f.history = zeekHistory(f.history, s.tcp, s.dir)
+			}
+			require.Equal(t, tc.state, zeekConnState(f))
+			require.Equal(t, tc.history, string(f.history))
+		})
+	}
+
+	udp := &flow{protocol: layers.IPProtocolUDP}
+	udp.dirs[0].packets = 1
+	require.Equal(t, "S0", zeekConnState(udp))
+	udp.dirs[1].packets = 1
+	require.Equal(t, "SF", zeekConnState(udp))
+}